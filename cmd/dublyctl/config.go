@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// configFile holds dublyctl's profiles. It contains API keys, so it's
+// written readable by its owner only.
+type configFile struct {
+	Default  string              `json:"default,omitempty"`
+	Profiles map[string]*profile `json:"profiles"`
+}
+
+// profile is one Dubly server to talk to.
+type profile struct {
+	URL    string `json:"url"`
+	APIKey string `json:"api_key"`
+}
+
+// defaultConfigPath is $DUBLYCTL_CONFIG, or dublyctl/config.json in the
+// user's config directory.
+func defaultConfigPath() string {
+	if p := os.Getenv("DUBLYCTL_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "dublyctl.json"
+	}
+	return filepath.Join(dir, "dublyctl", "config.json")
+}
+
+// loadConfig reads the config file at path. A missing file is an empty
+// config.
+func loadConfig(path string) (*configFile, error) {
+	cfg := &configFile{Profiles: map[string]*profile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*profile{}
+	}
+	return cfg, nil
+}
+
+func (cfg *configFile) save(path string) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// resolve picks the profile called name, or when name is empty the
+// default profile, or the only one there is.
+func (cfg *configFile) resolve(name string) (*profile, error) {
+	if name == "" {
+		name = cfg.Default
+	}
+	if name == "" {
+		switch len(cfg.Profiles) {
+		case 0:
+			return nil, errors.New("no profiles: add one with dublyctl profile add, or set DUBLYCTL_URL and DUBLYCTL_API_KEY")
+		case 1:
+			for _, p := range cfg.Profiles {
+				return p, nil
+			}
+		default:
+			return nil, errors.New("several profiles and no default: pick one with -profile or dublyctl profile use")
+		}
+	}
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("no profile %q", name)
+	}
+	return p, nil
+}
+
+func (cfg *configFile) names() []string {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
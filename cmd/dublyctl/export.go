@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+func (c *cli) exportCmd(ctx context.Context, args []string) error {
+	fs := c.flags("export", "")
+	format := fs.String("format", "csv", "csv or ndjson")
+	clicks := fs.Bool("clicks", false, "include every click")
+	file := fs.String("file", "-", "file to write, or - for standard output")
+	if _, err := c.parse(fs, args); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	rc, err := api.Export(ctx, *format, *clicks)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if *file == "-" {
+		_, err := io.Copy(c.stdout, rc)
+		return err
+	}
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/cmdline"
+	"github.com/scmmishra/dubly/pkg/client"
+)
+
+func (c *cli) linksCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(c.stderr, "usage: dublyctl links create|list|get|update|delete\n")
+		return cmdline.ErrUsage
+	}
+	switch sub, args := args[0], args[1:]; sub {
+	case "create":
+		return c.linksCreate(ctx, args)
+	case "list":
+		return c.linksList(ctx, args)
+	case "get":
+		return c.linksGet(ctx, args)
+	case "update":
+		return c.linksUpdate(ctx, args)
+	case "delete":
+		return c.linksDelete(ctx, args)
+	default:
+		return fmt.Errorf("unknown links command %q: use create, list, get, update or delete", sub)
+	}
+}
+
+// linkFields are the flags create and update share.
+type linkFields struct {
+	slug, domain, dest, title, tags, notes string
+	utmPreset                              string
+	utm                                    [5]string // source, medium, campaign, term, content
+}
+
+var utmFlags = [5]string{"utm-source", "utm-medium", "utm-campaign", "utm-term", "utm-content"}
+
+func (f *linkFields) define(fs *flag.FlagSet) {
+	fs.StringVar(&f.slug, "slug", "", "slug (generated when empty)")
+	fs.StringVar(&f.domain, "domain", "", "domain")
+	fs.StringVar(&f.dest, "dest", "", "destination URL")
+	fs.StringVar(&f.title, "title", "", "title")
+	fs.StringVar(&f.tags, "tags", "", "comma-separated tags")
+	fs.StringVar(&f.notes, "notes", "", "notes")
+	fs.StringVar(&f.utmPreset, "utm-preset", "", "UTM preset to apply")
+	for i, name := range utmFlags {
+		fs.StringVar(&f.utm[i], name, "", strings.ReplaceAll(name, "-", "_")+" parameter (empty removes it)")
+	}
+}
+
+// utmValue returns the i-th UTM flag's value when it was given.
+func (f *linkFields) utmValue(set map[string]bool, i int) *string {
+	if !set[utmFlags[i]] {
+		return nil
+	}
+	return client.String(f.utm[i])
+}
+
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func (c *cli) linksCreate(ctx context.Context, args []string) error {
+	fs := c.flags("links create", "")
+	var f linkFields
+	f.define(fs)
+	dedupe := fs.Bool("dedupe", false, "return the existing link with the same destination instead of creating one")
+	if _, err := c.parse(fs, args); err != nil {
+		return err
+	}
+	if f.domain == "" || f.dest == "" {
+		return fmt.Errorf("-domain and -dest are required")
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	set := visited(fs)
+	in := &client.LinkInput{
+		Slug:        f.slug,
+		Domain:      f.domain,
+		Destination: f.dest,
+		Title:       f.title,
+		Tags:        splitTags(f.tags),
+		Notes:       f.notes,
+		UTMPreset:   f.utmPreset,
+		UTMSource:   f.utmValue(set, 0),
+		UTMMedium:   f.utmValue(set, 1),
+		UTMCampaign: f.utmValue(set, 2),
+		UTMTerm:     f.utmValue(set, 3),
+		UTMContent:  f.utmValue(set, 4),
+	}
+	var link *client.Link
+	if *dedupe {
+		link, _, err = api.GetOrCreateLink(ctx, in)
+	} else {
+		link, err = api.CreateLink(ctx, in)
+	}
+	if err != nil {
+		return err
+	}
+	return c.print(link, linkTable([]client.Link{*link}))
+}
+
+func (c *cli) linksList(ctx context.Context, args []string) error {
+	fs := c.flags("links list", "")
+	var opts client.ListOptions
+	var tags string
+	fs.StringVar(&opts.Search, "search", "", "text to search for")
+	fs.StringVar(&opts.Query, "q", "", "query in the search language, e.g. 'tag:launch clicks:>10'")
+	fs.StringVar(&tags, "tags", "", "comma-separated tags links must all have")
+	fs.StringVar(&opts.Domain, "domain", "", "only links on this domain")
+	fs.StringVar(&opts.Status, "status", "", "active, inactive or all")
+	fs.StringVar(&opts.Sort, "sort", "", "created, updated, title, clicks, last_clicked or relevance")
+	fs.StringVar(&opts.Order, "order", "", "asc or desc")
+	fs.IntVar(&opts.Limit, "limit", 0, "links per page, up to 100 (default 25)")
+	fs.StringVar(&opts.Cursor, "cursor", "", "cursor of the page to show")
+	all := fs.Bool("all", false, "list every matching link, not one page")
+	if _, err := c.parse(fs, args); err != nil {
+		return err
+	}
+	opts.Tags = splitTags(tags)
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	links := []client.Link{}
+	if *all {
+		err = api.EachLink(ctx, &opts, func(l *client.Link) error {
+			links = append(links, *l)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return c.print(links, linkTable(links))
+	}
+
+	page, err := api.ListLinks(ctx, &opts)
+	if err != nil {
+		return err
+	}
+	if err := c.print(page, linkTable(page.Links)); err != nil {
+		return err
+	}
+	if page.NextCursor != "" && c.output != "json" {
+		fmt.Fprintf(c.stderr, "%d of %d links; next page: -cursor %s\n", len(page.Links), page.Total, page.NextCursor)
+	}
+	return nil
+}
+
+func (c *cli) linksGet(ctx context.Context, args []string) error {
+	fs := c.flags("links get", "ID")
+	ids, err := c.parseIDs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	link, err := api.GetLink(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	return c.print(link, linkTable([]client.Link{*link}))
+}
+
+func (c *cli) linksUpdate(ctx context.Context, args []string) error {
+	fs := c.flags("links update", "ID")
+	var f linkFields
+	f.define(fs)
+	ifMatch := fs.String("if-match", "", "only update if the link still has this ETag")
+	ids, err := c.parseIDs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	set := visited(fs)
+	upd := &client.LinkUpdate{
+		Slug:        f.slug,
+		Domain:      f.domain,
+		Destination: f.dest,
+		UTMPreset:   f.utmPreset,
+		UTMSource:   f.utmValue(set, 0),
+		UTMMedium:   f.utmValue(set, 1),
+		UTMCampaign: f.utmValue(set, 2),
+		UTMTerm:     f.utmValue(set, 3),
+		UTMContent:  f.utmValue(set, 4),
+		IfMatch:     *ifMatch,
+	}
+	if set["title"] {
+		upd.Title = &f.title
+	}
+	if set["notes"] {
+		upd.Notes = &f.notes
+	}
+	if set["tags"] {
+		tags := splitTags(f.tags)
+		upd.Tags = &tags
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	link, err := api.UpdateLink(ctx, ids[0], upd)
+	if err != nil {
+		return err
+	}
+	return c.print(link, linkTable([]client.Link{*link}))
+}
+
+func (c *cli) linksDelete(ctx context.Context, args []string) error {
+	fs := c.flags("links delete", "ID...")
+	ids, err := c.parseIDs(fs, args, -1)
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := api.DeleteLink(ctx, id); err != nil {
+			return fmt.Errorf("link %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// parseIDs parses a command's flags and its link ID arguments, of which
+// there must be n, or at least one when n is negative.
+func (c *cli) parseIDs(fs *flag.FlagSet, args []string, n int) ([]int64, error) {
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(positional) == 0 || (n >= 0 && len(positional) != n) {
+		fs.Usage()
+		return nil, cmdline.ErrUsage
+	}
+	ids := make([]int64, len(positional))
+	for i, s := range positional {
+		if ids[i], err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid link ID %q", s)
+		}
+	}
+	return ids, nil
+}
+
+func linkTable(links []client.Link) table {
+	t := table{header: []string{"ID", "SHORT URL", "DESTINATION", "TITLE", "TAGS", "CLICKS", "ACTIVE", "CREATED"}}
+	for _, l := range links {
+		t.rows = append(t.rows, []string{
+			strconv.FormatInt(l.ID, 10),
+			l.ShortURL,
+			l.Destination,
+			l.Title,
+			l.Tags,
+			strconv.Itoa(l.Clicks),
+			yesNo(l.IsActive),
+			l.CreatedAt.Format(time.DateTime),
+		})
+	}
+	return t
+}
@@ -0,0 +1,142 @@
+// Command dublyctl manages links on a Dubly server through its API.
+//
+//	dublyctl profile add prod -url https://dubly.example.com -key "$KEY"
+//	dublyctl links create -domain short.io -dest https://example.com/launch
+//	dublyctl links list -q 'tag:launch clicks:>10' -o csv
+//	dublyctl stats 42
+//	dublyctl qr 42 -file launch.png
+//	dublyctl export -format ndjson -clicks > backup.ndjson
+//
+// Profiles are read from dublyctl/config.json in the user's config
+// directory, or the file DUBLYCTL_CONFIG names. Setting DUBLYCTL_URL and
+// DUBLYCTL_API_KEY skips the file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/scmmishra/dubly/internal/cmdline"
+	"github.com/scmmishra/dubly/pkg/client"
+)
+
+const usage = `usage: dublyctl <command> [flags] [args]
+
+Commands:
+  profile add NAME -url URL -key KEY   save a server to talk to
+  profile use NAME                     make a profile the default
+  profile list                         list profiles
+  profile remove NAME                  forget a profile
+  links create -domain D -dest URL     create a link
+  links list                           list links
+  links get ID                         show a link
+  links update ID                      change a link
+  links delete ID...                   deactivate links
+  stats ID                             click stats for a link
+  qr ID                                save a link's QR code as a PNG
+  export                               export links, and clicks, as CSV or NDJSON
+
+Every command takes -profile NAME, -config FILE and -o table|json|csv.
+Run dublyctl <command> -h for its flags.
+`
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, cmdline.ErrUsage):
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "dublyctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// cli is one run of dublyctl.
+type cli struct {
+	stdout, stderr io.Writer
+
+	// Set by the flags every command takes
+	configPath string
+	profile    string
+	output     string
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	c := &cli{stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return cmdline.ErrUsage
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "profile":
+		return c.profileCmd(args)
+	case "links":
+		return c.linksCmd(ctx, args)
+	case "stats":
+		return c.statsCmd(ctx, args)
+	case "qr":
+		return c.qrCmd(ctx, args)
+	case "export":
+		return c.exportCmd(ctx, args)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	fmt.Fprintf(stderr, "dublyctl: unknown command %q\n\n%s", cmd, usage)
+	return cmdline.ErrUsage
+}
+
+// flags returns a flag set for a command, with the flags every command
+// takes already defined.
+func (c *cli) flags(name, argsUsage string) *flag.FlagSet {
+	fs := cmdline.FlagSet("dublyctl "+name, argsUsage, c.stderr)
+	fs.StringVar(&c.configPath, "config", defaultConfigPath(), "config file")
+	fs.StringVar(&c.profile, "profile", os.Getenv("DUBLYCTL_PROFILE"), "profile to use (default the config's default)")
+	fs.StringVar(&c.output, "o", "table", "output format: table, json or csv")
+	return fs
+}
+
+// parse parses a command's flags with cmdline.Parse and checks the
+// output format.
+func (c *cli) parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional, err := cmdline.Parse(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(outputFormats, c.output) {
+		return nil, fmt.Errorf("unknown output %q: use %s", c.output, strings.Join(outputFormats, ", "))
+	}
+	return positional, nil
+}
+
+// visited returns the names of the flags set on the command line.
+func visited(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
+
+// client returns an API client for the chosen profile.
+func (c *cli) client() (*client.Client, error) {
+	if url, key := os.Getenv("DUBLYCTL_URL"), os.Getenv("DUBLYCTL_API_KEY"); url != "" && key != "" && c.profile == "" {
+		return client.New(url, key), nil
+	}
+	cfg, err := loadConfig(c.configPath)
+	if err != nil {
+		return nil, err
+	}
+	p, err := cfg.resolve(c.profile)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(p.URL, p.APIKey), nil
+}
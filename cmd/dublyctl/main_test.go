@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/scmmishra/dubly/internal/cache"
+	"github.com/scmmishra/dubly/internal/cmdline"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/db"
+	"github.com/scmmishra/dubly/internal/server"
+	"github.com/scmmishra/dubly/pkg/client"
+)
+
+const testKey = "secret"
+
+// setup starts an in-process server on an empty database and writes a
+// config file whose only profile points at it. It returns the config
+// file's path.
+func setup(t *testing.T) string {
+	t.Helper()
+	for _, env := range []string{"DUBLYCTL_CONFIG", "DUBLYCTL_PROFILE", "DUBLYCTL_URL", "DUBLYCTL_API_KEY"} {
+		t.Setenv(env, "")
+	}
+
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkCache, err := cache.New(100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Password: testKey, Domains: []string{"short.io"}, IdempotencyWindow: time.Hour}
+	r := chi.NewRouter()
+	r.Mount("/api", server.APIRouter(database, cfg, linkCache))
+	srv := httptest.NewServer(r)
+	t.Cleanup(func() {
+		srv.Close()
+		database.Close()
+	})
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if _, err := dublyctl(t, "profile", "add", "test", "-config", path, "-url", srv.URL, "-key", testKey); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func dublyctl(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), args, &stdout, &stderr)
+	return stdout.String(), err
+}
+
+// createLink creates a link through dublyctl and returns it.
+func createLink(t *testing.T, config string, args ...string) client.Link {
+	t.Helper()
+	out, err := dublyctl(t, append([]string{"links", "create", "-config", config, "-o", "json", "-domain", "short.io"}, args...)...)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var link client.Link
+	if err := json.Unmarshal([]byte(out), &link); err != nil {
+		t.Fatalf("create output %q: %v", out, err)
+	}
+	return link
+}
+
+func TestProfiles(t *testing.T) {
+	path := setup(t)
+
+	if _, err := dublyctl(t, "profile", "add", "prod", "-config", path, "-url", "https://dubly.example.com/", "-key", "k"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := dublyctl(t, "profile", "list", "-config", path, "-o", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "NAME,URL,DEFAULT\nprod,https://dubly.example.com,no\n"
+	if !strings.HasPrefix(out, want) || !strings.Contains(out, "test,") || !strings.HasSuffix(out, ",yes\n") {
+		t.Errorf("list = %q", out)
+	}
+	if strings.Contains(out, testKey) {
+		t.Error("list shows an API key")
+	}
+
+	if _, err := dublyctl(t, "profile", "use", "prod", "-config", path); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := loadConfig(path)
+	if cfg.Default != "prod" {
+		t.Errorf("default = %q, want prod", cfg.Default)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("config mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	if _, err := dublyctl(t, "profile", "use", "nope", "-config", path); err == nil {
+		t.Error("using an unknown profile succeeded")
+	}
+	if _, err := dublyctl(t, "links", "list", "-config", path, "-profile", "nope"); err == nil || !strings.Contains(err.Error(), `no profile "nope"`) {
+		t.Errorf("unknown -profile: err = %v", err)
+	}
+}
+
+func TestLinks_CRUD(t *testing.T) {
+	path := setup(t)
+
+	link := createLink(t, path, "-slug", "launch", "-dest", "https://example.com/launch", "-tags", "a, b", "-utm-source", "cli")
+	if link.ShortURL != "https://short.io/launch" || link.Destination != "https://example.com/launch?utm_source=cli" || link.Tags != "a,b" {
+		t.Errorf("created = %+v", link)
+	}
+
+	out, err := dublyctl(t, "links", "get", "-config", path, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID ") || !strings.Contains(lines[1], "https://short.io/launch") {
+		t.Errorf("get table = %q", out)
+	}
+
+	// Flags may follow the ID; only the flags given are changed
+	out, err = dublyctl(t, "links", "update", "-config", path, "1", "-title", "Launch", "-tags", "", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updated client.Link
+	json.Unmarshal([]byte(out), &updated)
+	if updated.Title != "Launch" || updated.Tags != "" || updated.Destination != link.Destination {
+		t.Errorf("updated = %+v", updated)
+	}
+
+	if _, err := dublyctl(t, "links", "delete", "-config", path, "1"); err != nil {
+		t.Fatal(err)
+	}
+	out, _ = dublyctl(t, "links", "get", "-config", path, "1", "-o", "json")
+	var deleted client.Link
+	json.Unmarshal([]byte(out), &deleted)
+	if deleted.IsActive {
+		t.Error("link still active after delete")
+	}
+
+	_, err = dublyctl(t, "links", "get", "-config", path, "99")
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("unknown link: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLinks_CreateDedupe(t *testing.T) {
+	path := setup(t)
+	first := createLink(t, path, "-dest", "https://example.com/page")
+	again := createLink(t, path, "-dest", "https://EXAMPLE.com/page", "-dedupe")
+	if again.ID != first.ID {
+		t.Errorf("dedupe created link %d, want existing %d", again.ID, first.ID)
+	}
+}
+
+func TestLinks_ListOutputs(t *testing.T) {
+	path := setup(t)
+	for _, dest := range []string{"https://example.com/1", "https://example.com/2", "https://example.com/3"} {
+		createLink(t, path, "-dest", dest, "-title", "Title, with comma")
+	}
+
+	out, err := dublyctl(t, "links", "list", "-config", path, "-o", "csv", "-sort", "created", "-order", "asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[0][0] != "ID" || records[1][2] != "https://example.com/1" || records[1][3] != "Title, with comma" {
+		t.Errorf("csv = %q", records)
+	}
+
+	out, err = dublyctl(t, "links", "list", "-config", path, "-o", "json", "-limit", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page client.LinkList
+	json.Unmarshal([]byte(out), &page)
+	if len(page.Links) != 2 || page.Total != 3 || page.NextCursor == "" {
+		t.Errorf("page = %+v", page)
+	}
+
+	out, err = dublyctl(t, "links", "list", "-config", path, "-o", "json", "-limit", "2", "-all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []client.Link
+	json.Unmarshal([]byte(out), &all)
+	if len(all) != 3 {
+		t.Errorf("-all listed %d links, want 3", len(all))
+	}
+
+	if _, err := dublyctl(t, "links", "list", "-config", path, "-o", "yaml"); err == nil {
+		t.Error("unknown output format accepted")
+	}
+}
+
+func TestStats(t *testing.T) {
+	path := setup(t)
+	link := createLink(t, path, "-slug", "s", "-dest", "https://example.com")
+
+	out, err := dublyctl(t, "stats", "-config", path, "-o", "csv", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "STAT,VALUE,CLICKS\ntotal,all time,0\n") {
+		t.Errorf("stats = %q", out)
+	}
+
+	out, err = dublyctl(t, "stats", "-config", path, "-o", "json", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats client.LinkStats
+	if err := json.Unmarshal([]byte(out), &stats); err != nil || stats.LinkID != link.ID {
+		t.Errorf("stats = %+v, %v", stats, err)
+	}
+}
+
+func TestQR(t *testing.T) {
+	path := setup(t)
+	createLink(t, path, "-slug", "launch", "-dest", "https://example.com")
+
+	file := filepath.Join(t.TempDir(), "code.png")
+	if _, err := dublyctl(t, "qr", "-config", path, "-file", file, "-circle", "1"); err != nil {
+		t.Fatal(err)
+	}
+	png, err := os.ReadFile(file)
+	if err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Errorf("qr file: %v", err)
+	}
+
+	out, err := dublyctl(t, "qr", "-config", path, "-file", "-", "1")
+	if err != nil || !strings.HasPrefix(out, "\x89PNG") {
+		t.Errorf("qr to stdout: %v", err)
+	}
+	if _, err := dublyctl(t, "qr", "-config", path, "-color", "red", "1"); err == nil {
+		t.Error("invalid color accepted")
+	}
+}
+
+func TestExport(t *testing.T) {
+	path := setup(t)
+	createLink(t, path, "-slug", "a", "-dest", "https://example.com/a")
+
+	out, err := dublyctl(t, "export", "-config", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "type,domain,slug,destination") || !strings.Contains(out, "https://example.com/a") {
+		t.Errorf("csv export = %q", out)
+	}
+
+	file := filepath.Join(t.TempDir(), "links.ndjson")
+	if _, err := dublyctl(t, "export", "-config", path, "-format", "ndjson", "-file", file); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(file); !strings.Contains(string(data), `"slug":"a"`) {
+		t.Errorf("ndjson export = %s", data)
+	}
+}
+
+func TestEnvironmentProfile(t *testing.T) {
+	path := setup(t)
+	cfg, _ := loadConfig(path)
+	t.Setenv("DUBLYCTL_URL", cfg.Profiles["test"].URL)
+	t.Setenv("DUBLYCTL_API_KEY", testKey)
+
+	// No config file needed
+	missing := filepath.Join(t.TempDir(), "none.json")
+	if _, err := dublyctl(t, "links", "list", "-config", missing); err != nil {
+		t.Errorf("list with environment profile: %v", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"frobnicate"}, {"links"}, {"links", "get"}} {
+		if _, err := dublyctl(t, args...); !errors.Is(err, cmdline.ErrUsage) {
+			t.Errorf("%q: err = %v, want a usage error", args, err)
+		}
+	}
+}
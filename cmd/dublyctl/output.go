@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+var outputFormats = []string{"table", "json", "csv"}
+
+// table is the tabular form of a command's result, for table and CSV
+// output.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// print writes a command's result in the chosen output format: v as JSON,
+// or t as an aligned table or CSV.
+func (c *cli) print(v any, t table) error {
+	switch c.output {
+	case "json":
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "csv":
+		w := csv.NewWriter(c.stdout)
+		w.Write(t.header)
+		w.WriteAll(t.rows)
+		return w.Error()
+	default:
+		tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
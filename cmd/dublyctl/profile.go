@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/scmmishra/dubly/internal/cmdline"
+)
+
+func (c *cli) profileCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(c.stderr, "usage: dublyctl profile add|use|list|remove\n")
+		return cmdline.ErrUsage
+	}
+	sub, args := args[0], args[1:]
+
+	fs := c.flags("profile "+sub, "NAME")
+	var url, key string
+	var makeDefault bool
+	if sub == "add" {
+		fs.StringVar(&url, "url", "", "server URL, e.g. https://dubly.example.com")
+		fs.StringVar(&key, "key", "", "API key (DUBLY_PASSWORD on the server)")
+		fs.BoolVar(&makeDefault, "default", false, "make this the default profile")
+	}
+	names, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(c.configPath)
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "list":
+		type entry struct {
+			Name    string `json:"name"`
+			URL     string `json:"url"`
+			Default bool   `json:"default"`
+		}
+		entries := []entry{}
+		t := table{header: []string{"NAME", "URL", "DEFAULT"}}
+		for _, name := range cfg.names() {
+			e := entry{name, cfg.Profiles[name].URL, name == cfg.Default}
+			entries = append(entries, e)
+			t.rows = append(t.rows, []string{e.Name, e.URL, yesNo(e.Default)})
+		}
+		return c.print(entries, t)
+	case "add", "use", "remove":
+	default:
+		return fmt.Errorf("unknown profile command %q: use add, use, list or remove", sub)
+	}
+
+	if len(names) != 1 {
+		fs.Usage()
+		return cmdline.ErrUsage
+	}
+	name := names[0]
+	switch sub {
+	case "add":
+		if url == "" || key == "" {
+			return errors.New("-url and -key are required")
+		}
+		cfg.Profiles[name] = &profile{URL: strings.TrimRight(url, "/"), APIKey: key}
+		if makeDefault || len(cfg.Profiles) == 1 {
+			cfg.Default = name
+		}
+	case "use":
+		if cfg.Profiles[name] == nil {
+			return fmt.Errorf("no profile %q", name)
+		}
+		cfg.Default = name
+	case "remove":
+		if cfg.Profiles[name] == nil {
+			return fmt.Errorf("no profile %q", name)
+		}
+		delete(cfg.Profiles, name)
+		if cfg.Default == name {
+			cfg.Default = ""
+		}
+	}
+	return cfg.save(c.configPath)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
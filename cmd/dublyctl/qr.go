@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/scmmishra/dubly/internal/qr"
+)
+
+func (c *cli) qrCmd(ctx context.Context, args []string) error {
+	fs := c.flags("qr", "ID")
+	file := fs.String("file", "", "PNG to write, or - for standard output (default SLUG-qr.png)")
+	circle := fs.Bool("circle", false, "draw round modules")
+	color := fs.String("color", "", "foreground color as #rrggbb")
+	ids, err := c.parseIDs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *color != "" && !qr.ValidColor(*color) {
+		return fmt.Errorf("invalid color %q: use #rrggbb", *color)
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	link, err := api.GetLink(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+
+	png, err := qr.PNG(link.ShortURL, qr.Options{Circle: *circle, Color: *color})
+	if err != nil {
+		return fmt.Errorf("qr code: %w", err)
+	}
+	if *file == "-" {
+		_, err := c.stdout.Write(png)
+		return err
+	}
+	if *file == "" {
+		*file = link.Slug + "-qr.png"
+	}
+	if err := os.WriteFile(*file, png, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "%s: %s\n", *file, link.ShortURL)
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/scmmishra/dubly/pkg/client"
+)
+
+func (c *cli) statsCmd(ctx context.Context, args []string) error {
+	fs := c.flags("stats", "ID")
+	limit := fs.Int("limit", 0, "rows per breakdown, up to 100 (default 10)")
+	ids, err := c.parseIDs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	stats, err := api.LinkStats(ctx, ids[0], *limit)
+	if err != nil {
+		return err
+	}
+	return c.print(stats, statsTable(stats))
+}
+
+// statsTable lays the totals and every breakdown out as rows of one
+// table, each labelled with what it counts.
+func statsTable(s *client.LinkStats) table {
+	t := table{header: []string{"STAT", "VALUE", "CLICKS"}}
+	add := func(stat, value string, clicks int) {
+		t.rows = append(t.rows, []string{stat, value, strconv.Itoa(clicks)})
+	}
+	add("total", "all time", s.Clicks)
+	add("total", "today", s.ClicksToday)
+	add("total", "last 7 days", s.ClicksThisWeek)
+	add("total", "previous 7 days", s.ClicksPrevWeek)
+	if h := s.HistoricalClicks; h != nil {
+		add("total", "historical ("+h.Source+")", h.Clicks)
+	}
+	for _, b := range []struct {
+		stat   string
+		counts []client.StatCount
+	}{
+		{"referrer", s.Referrers},
+		{"country", s.Countries},
+		{"browser", s.Browsers},
+		{"device", s.Devices},
+	} {
+		for _, c := range b.counts {
+			add(b.stat, c.Value, c.Clicks)
+		}
+	}
+	for _, sl := range s.Slugs {
+		add("slug", sl.Domain+"/"+sl.Slug, sl.Clicks)
+	}
+	return t
+}
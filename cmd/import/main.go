@@ -0,0 +1,155 @@
+// Command import loads links into the Dubly database from Dubly's own
+// exports or from Bitly, YOURLS, Shlink and browser bookmark exports. It
+// reads the same DUBLY_* environment as the server.
+//
+//	go run ./cmd/import -format bitly -domain short.io bitly.csv
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/scmmishra/dubly/internal/cmdline"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/db"
+	"github.com/scmmishra/dubly/internal/transfer"
+)
+
+// errFailed reports that some files or lines didn't import; the details
+// have been printed.
+var errFailed = errors.New("import failed")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, cmdline.ErrUsage):
+		os.Exit(2)
+	case errors.Is(err, errFailed):
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := cmdline.FlagSet("import", "file...", stderr)
+	formatName := fs.String("format", "", "csv, ndjson, bitly, yourls, shlink or bookmarks (default csv)")
+	conflictName := fs.String("conflict", "skip", "what to do with taken slugs: skip, overwrite or rename")
+	domain := fs.String("domain", "", "domain for links whose own isn't configured here")
+	historical := fs.Bool("historical-clicks", false, "keep click totals from other shorteners")
+	dryRun := fs.Bool("dry-run", false, "report what would happen without writing anything")
+	asJSON := fs.Bool("json", false, "print the summary as JSON")
+	usage := fs.Usage
+	fs.Usage = func() {
+		usage()
+		fmt.Fprintln(stderr, "\nReads standard input when no file or - is given.")
+	}
+	files, err := cmdline.Parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	format, err := transfer.ParseImportFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	conflict, err := transfer.ParseConflict(*conflictName)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	defer database.Close()
+
+	im := &transfer.Importer{DB: database, Cfg: cfg}
+	opts := transfer.Options{
+		Format:           format,
+		Conflict:         conflict,
+		Domain:           *domain,
+		HistoricalClicks: *historical,
+		DryRun:           *dryRun,
+	}
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	failed := false
+	for _, name := range files {
+		summary, err := importFile(im, name, opts)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			failed = true
+			continue
+		}
+		if *asJSON {
+			json.NewEncoder(stdout).Encode(summary)
+		} else {
+			printSummary(stdout, name, summary)
+		}
+		failed = failed || summary.Links.Failed > 0
+	}
+	if conflict == transfer.Overwrite && !*dryRun {
+		fmt.Fprintf(stderr, "note: a running server picks up overwritten destinations within %s (DUBLY_CACHE_TTL)\n", cfg.CacheTTL)
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+func importFile(im *transfer.Importer, name string, opts transfer.Options) (*transfer.Summary, error) {
+	if name == "-" {
+		return im.Import(os.Stdin, opts)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return im.Import(f, opts)
+}
+
+func printSummary(w io.Writer, name string, s *transfer.Summary) {
+	if name == "-" {
+		name = "stdin"
+	}
+	if s.DryRun {
+		fmt.Fprintf(w, "%s (dry run, nothing saved)\n", name)
+	} else {
+		fmt.Fprintf(w, "%s\n", name)
+	}
+	fmt.Fprintf(w, "  links:  %d created, %d already imported, %d renamed, %d overwritten, %d skipped, %d failed\n",
+		s.Links.Created, s.Links.Unchanged, s.Links.Renamed, s.Links.Overwritten, s.Links.Skipped, s.Links.Failed)
+	fmt.Fprintf(w, "  clicks: %d imported, %d skipped, %d historical totals\n",
+		s.Clicks.Imported, s.Clicks.Skipped, s.Clicks.Historical)
+	for _, c := range s.Conflicts {
+		if c.NewSlug != "" {
+			fmt.Fprintf(w, "  line %d: %s/%s is taken, imported as %s\n", c.Line, c.Domain, c.Slug, c.NewSlug)
+		} else {
+			fmt.Fprintf(w, "  line %d: %s/%s is taken, %s\n", c.Line, c.Domain, c.Slug, pastTense(c.Action))
+		}
+	}
+	for _, e := range s.Errors {
+		fmt.Fprintf(w, "  line %d: %s\n", e.Line, e.Error)
+	}
+}
+
+func pastTense(c transfer.Conflict) string {
+	if c == transfer.Overwrite {
+		return "overwritten"
+	}
+	return "skipped"
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/scmmishra/dubly/internal/cmdline"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/db"
+)
+
+const usage = `usage: dubly [command] [flags] [args]
+
+Commands:
+  serve                           run the server (the default)
+  links list                      list links
+  links disable ID...             deactivate links
+  links enable ID...              reactivate links
+  links set-destination ID URL    point a link somewhere else
+  clicks purge                    delete recorded clicks
+  config check                    check the configuration and database
+  stats                           link and click totals
+  vacuum                          compact the database file
+
+Commands work on the database directly and read the same DUBLY_*
+environment as the server. A running server keeps serving cached
+redirects for links changed here for up to DUBLY_CACHE_TTL.
+Run dubly <command> -h for its flags.
+`
+
+// admin is one run of an administration command.
+type admin struct {
+	stdout, stderr io.Writer
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	a := &admin{stdout: stdout, stderr: stderr}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "links":
+		return a.linksCmd(args)
+	case "clicks":
+		return a.clicksCmd(args)
+	case "config":
+		return a.configCmd(args)
+	case "stats":
+		return a.statsCmd(args)
+	case "vacuum":
+		return a.vacuumCmd(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	fmt.Fprintf(stderr, "dubly: unknown command %q\n\n%s", cmd, usage)
+	return cmdline.ErrUsage
+}
+
+// flags returns a flag set for a command that reports errors to stderr.
+func (a *admin) flags(name, argsUsage string) *flag.FlagSet {
+	return cmdline.FlagSet("dubly "+name, argsUsage, a.stderr)
+}
+
+// open loads the server's configuration and opens its database.
+func open() (*config.Config, *sql.DB, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("config: %w", err)
+	}
+	database, err := db.Open(cfg.DBPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("database: %w", err)
+	}
+	return cfg, database, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/cmdline"
+	"github.com/scmmishra/dubly/internal/db"
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+// adminDB points the server's environment at a temporary database and
+// returns a connection to it for seeding and checking.
+func adminDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dubly.db")
+	t.Setenv("DUBLY_PASSWORD", "secret")
+	t.Setenv("DUBLY_DOMAINS", "short.io")
+	t.Setenv("DUBLY_DB_PATH", path)
+	database, err := db.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database, path
+}
+
+func seedLink(t *testing.T, database *sql.DB, slug string) *models.Link {
+	t.Helper()
+	l := &models.Link{Slug: slug, Domain: "short.io", Destination: "https://example.com/" + slug}
+	if err := models.CreateLink(database, l); err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func runAdmin(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(args, &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestLinksList(t *testing.T) {
+	database, _ := adminDB(t)
+	a := seedLink(t, database, "launch")
+	b := seedLink(t, database, "docs")
+	if err := models.SetLinkActive(database, b.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runAdmin(t, "links", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "https://example.com/launch") || !strings.Contains(out, "https://example.com/docs") {
+		t.Errorf("list is missing links:\n%s", out)
+	}
+
+	out, err = runAdmin(t, "links", "list", "-status", "active", "-json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var links []models.Link
+	if err := json.Unmarshal([]byte(out), &links); err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0].ID != a.ID {
+		t.Errorf("active links = %+v, want only %d", links, a.ID)
+	}
+
+	if _, err := runAdmin(t, "links", "list", "-status", "gone"); err == nil {
+		t.Error("unknown status accepted")
+	}
+}
+
+func TestLinksDisableEnable(t *testing.T) {
+	database, _ := adminDB(t)
+	l := seedLink(t, database, "launch")
+
+	if _, err := runAdmin(t, "links", "disable", "999"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("disable missing link: err = %v, want not found", err)
+	}
+	if _, err := runAdmin(t, "links", "disable", itoa(l.ID)); err != nil {
+		t.Fatal(err)
+	}
+	models.GetLinkByID(database, l)
+	if l.IsActive {
+		t.Error("link still active after disable")
+	}
+	if _, err := runAdmin(t, "links", "enable", itoa(l.ID)); err != nil {
+		t.Fatal(err)
+	}
+	models.GetLinkByID(database, l)
+	if !l.IsActive {
+		t.Error("link inactive after enable")
+	}
+}
+
+func TestLinksSetDestination(t *testing.T) {
+	database, _ := adminDB(t)
+	l := seedLink(t, database, "launch")
+
+	if _, err := runAdmin(t, "links", "set-destination", itoa(l.ID), "not a url"); err == nil {
+		t.Error("relative destination accepted")
+	}
+	if _, err := runAdmin(t, "links", "set-destination", itoa(l.ID), "https://example.com/new"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := models.GetLinkBySlugAndDomain(database, "launch", "short.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Destination != "https://example.com/new" {
+		t.Errorf("destination = %q, want the new one", got.Destination)
+	}
+}
+
+func TestClicksPurge(t *testing.T) {
+	database, _ := adminDB(t)
+	l := seedLink(t, database, "launch")
+	old := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	err := models.BatchInsertClicks(database, []models.Click{
+		{LinkID: l.ID, ClickedAt: old},
+		{LinkID: l.ID, ClickedAt: old},
+		{LinkID: l.ID, ClickedAt: time.Now().UTC()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runAdmin(t, "clicks", "purge"); err == nil {
+		t.Error("purge without a filter or -all accepted")
+	}
+	out, err := runAdmin(t, "clicks", "purge", "-before", "2024-02-01", "-dry-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "would purge 2 clicks\n" {
+		t.Errorf("dry run = %q", out)
+	}
+	if n, _ := models.ClicksAllTime(database); n != 3 {
+		t.Fatalf("dry run deleted clicks: %d left", n)
+	}
+
+	if _, err := runAdmin(t, "clicks", "purge", "-before", "2024-02-01", "-link", itoa(l.ID)); err != nil {
+		t.Fatal(err)
+	}
+	models.GetLinkByID(database, l)
+	if l.Clicks != 1 {
+		t.Errorf("clicks = %d, want 1 left", l.Clicks)
+	}
+
+	if _, err := runAdmin(t, "clicks", "purge", "-all"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := models.ClicksAllTime(database); n != 0 {
+		t.Errorf("%d clicks left after -all", n)
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	adminDB(t)
+	out, err := runAdmin(t, "config", "check")
+	if err != nil {
+		t.Fatalf("check failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "database") {
+		t.Errorf("output doesn't report the database:\n%s", out)
+	}
+
+	t.Setenv("DUBLY_GEOIP_PATH", filepath.Join(t.TempDir(), "missing.mmdb"))
+	out, err = runAdmin(t, "config", "check")
+	if err == nil || !strings.Contains(out, "geoip") || !strings.Contains(out, "FAIL") {
+		t.Errorf("missing GeoIP file: err = %v\n%s", err, out)
+	}
+
+	t.Setenv("DUBLY_DOMAINS", "")
+	if _, err := runAdmin(t, "config", "check"); err == nil {
+		t.Error("check passed without DUBLY_DOMAINS")
+	}
+}
+
+func TestStats(t *testing.T) {
+	database, _ := adminDB(t)
+	l := seedLink(t, database, "launch")
+	seedLink(t, database, "docs")
+	if err := models.BatchInsertClicks(database, []models.Click{{LinkID: l.ID, ClickedAt: time.Now().UTC()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runAdmin(t, "stats", "-json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s dbStats
+	if err := json.Unmarshal([]byte(out), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ActiveLinks != 2 || s.Clicks != 1 || s.DBBytes == 0 {
+		t.Errorf("stats = %+v", s)
+	}
+	if len(s.TopLinks) == 0 || s.TopLinks[0].Link.ID != l.ID {
+		t.Errorf("top links = %+v, want %d first", s.TopLinks, l.ID)
+	}
+}
+
+func TestVacuum(t *testing.T) {
+	database, _ := adminDB(t)
+	seedLink(t, database, "launch")
+
+	out, err := runAdmin(t, "vacuum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "vacuumed ") {
+		t.Errorf("output = %q", out)
+	}
+	if _, err := models.GetLinkBySlugAndDomain(database, "launch", "short.io"); err != nil {
+		t.Errorf("link lost in vacuum: %v", err)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	if _, err := runAdmin(t, "frobnicate"); err != cmdline.ErrUsage {
+		t.Errorf("err = %v, want cmdline.ErrUsage", err)
+	}
+}
+
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
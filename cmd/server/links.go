@@ -0,0 +1,206 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/cmdline"
+	"github.com/scmmishra/dubly/internal/linkquery"
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+func (a *admin) linksCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(a.stderr, "usage: dubly links list|disable|enable|set-destination\n")
+		return cmdline.ErrUsage
+	}
+	switch sub, args := args[0], args[1:]; sub {
+	case "list":
+		return a.linksList(args)
+	case "disable":
+		return a.linksSetActive(args, false)
+	case "enable":
+		return a.linksSetActive(args, true)
+	case "set-destination":
+		return a.linksSetDestination(args)
+	default:
+		return fmt.Errorf("unknown links command %q: use list, disable, enable or set-destination", sub)
+	}
+}
+
+func (a *admin) linksList(args []string) error {
+	fs := a.flags("links list", "")
+	query := fs.String("q", "", "query in the search language, e.g. 'tag:launch clicks:>10'")
+	domain := fs.String("domain", "", "only links on this domain")
+	status := fs.String("status", "all", "active, inactive or all")
+	limit := fs.Int("limit", 50, "links to show; 0 shows them all")
+	asJSON := fs.Bool("json", false, "print the links as JSON")
+	if _, err := cmdline.Parse(fs, args); err != nil {
+		return err
+	}
+
+	if *limit < 0 {
+		return fmt.Errorf("-limit can't be negative")
+	}
+
+	f := models.LinkFilter{Domain: *domain}
+	if err := linkquery.Apply(&f, *query); err != nil {
+		return fmt.Errorf("-q: %w", err)
+	}
+	switch *status {
+	case "all":
+	case "active", "inactive":
+		active := *status == "active"
+		f.Active = &active
+	default:
+		return fmt.Errorf("unknown status %q: use active, inactive or all", *status)
+	}
+
+	cfg, database, err := open()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	links, total, err := models.ListLinks(database, *limit, 0, f)
+	if err != nil {
+		return err
+	}
+	if *limit == 0 && total > 0 {
+		links, _, err = models.ListLinks(database, total, 0, f)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range links {
+		links[i].FillShortURL(cfg.ShortURLBase)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(links)
+	}
+
+	tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSHORT URL\tDESTINATION\tCLICKS\tACTIVE\tCREATED")
+	for _, l := range links {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\n",
+			l.ID, l.ShortURL, l.Destination, l.Clicks, yesNo(l.IsActive), l.CreatedAt.Format(time.DateTime))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if total > len(links) {
+		fmt.Fprintf(a.stderr, "showing %d of %d links; use -limit 0 for all\n", len(links), total)
+	}
+	return nil
+}
+
+func (a *admin) linksSetActive(args []string, active bool) error {
+	name := "links disable"
+	if active {
+		name = "links enable"
+	}
+	fs := a.flags(name, "ID...")
+	ids, err := parseIDs(fs, args, -1)
+	if err != nil {
+		return err
+	}
+
+	cfg, database, err := open()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	for _, id := range ids {
+		if err := models.SetLinkActive(database, id, active); err != nil {
+			return linkError(id, err)
+		}
+		fmt.Fprintf(a.stdout, "%sd link %d\n", strings.TrimPrefix(name, "links "), id)
+	}
+	if !active {
+		fmt.Fprintf(a.stderr, "note: a running server stops redirecting them within %s (DUBLY_CACHE_TTL)\n", cfg.CacheTTL)
+	}
+	return nil
+}
+
+func (a *admin) linksSetDestination(args []string) error {
+	fs := a.flags("links set-destination", "ID URL")
+	positional, err := cmdline.Parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return cmdline.ErrUsage
+	}
+	id, err := strconv.ParseInt(positional[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid link ID %q", positional[0])
+	}
+	destination := positional[1]
+	if u, err := url.Parse(destination); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid destination %q: use an absolute http or https URL", destination)
+	}
+
+	cfg, database, err := open()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	link := &models.Link{ID: id}
+	if err := models.GetLinkByID(database, link); err != nil {
+		return linkError(id, err)
+	}
+	link.SetDestination(destination)
+	if err := models.UpdateLink(database, link); err != nil {
+		return linkError(id, err)
+	}
+	fmt.Fprintf(a.stdout, "link %d now redirects to %s\n", id, link.Destination)
+	fmt.Fprintf(a.stderr, "note: a running server picks up the new destination within %s (DUBLY_CACHE_TTL)\n", cfg.CacheTTL)
+	return nil
+}
+
+// parseIDs parses a command's flags and its link ID arguments, of which
+// there must be n, or at least one when n is negative.
+func parseIDs(fs *flag.FlagSet, args []string, n int) ([]int64, error) {
+	positional, err := cmdline.Parse(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(positional) == 0 || (n >= 0 && len(positional) != n) {
+		fs.Usage()
+		return nil, cmdline.ErrUsage
+	}
+	ids := make([]int64, len(positional))
+	for i, s := range positional {
+		if ids[i], err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid link ID %q", s)
+		}
+	}
+	return ids, nil
+}
+
+func linkError(id int64, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("link %d not found", id)
+	}
+	return fmt.Errorf("link %d: %w", id, err)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -1,28 +1,63 @@
+// Command server runs Dubly. Given a command instead, it administers the
+// database directly, for when the admin UI and API are unavailable:
+//
+//	dubly                                  serve, same as dubly serve
+//	dubly links list -q launch
+//	dubly links disable 42
+//	dubly clicks purge -before 2024-01-01
+//	dubly config check
+//
+// Every command reads the same DUBLY_* environment as the server.
 package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // analytics time zones on hosts without a zone database
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 
 	"github.com/scmmishra/dubly/internal/analytics"
+	"github.com/scmmishra/dubly/internal/autotls"
 	"github.com/scmmishra/dubly/internal/cache"
+	"github.com/scmmishra/dubly/internal/cmdline"
 	"github.com/scmmishra/dubly/internal/config"
 	"github.com/scmmishra/dubly/internal/datacenter"
 	"github.com/scmmishra/dubly/internal/db"
+	"github.com/scmmishra/dubly/internal/domaincheck"
 	"github.com/scmmishra/dubly/internal/geo"
 	"github.com/scmmishra/dubly/internal/handlers"
+	"github.com/scmmishra/dubly/internal/server"
 	"github.com/scmmishra/dubly/internal/web"
 )
 
 func main() {
+	args := os.Args[1:]
+	if len(args) == 0 || args[0] == "serve" {
+		serve()
+		return
+	}
+	err := run(args, os.Stdout, os.Stderr)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, cmdline.ErrUsage):
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "dubly: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func serve() {
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("config: %v", err)
@@ -41,44 +76,39 @@ func main() {
 	}
 	defer geoReader.Close()
 
-	linkCache, err := cache.New(cfg.CacheSize)
+	linkCache, err := cache.New(cfg.CacheSize, cfg.CacheTTL)
 	if err != nil {
 		log.Fatalf("cache: %v", err)
 	}
 
 	collector := analytics.NewCollector(database, geoReader, cfg.BufferSize, cfg.FlushInterval)
 	dcChecker := datacenter.NewChecker()
-
-	linkHandler := &handlers.LinkHandler{
-		DB:    database,
-		Cfg:   cfg,
-		Cache: linkCache,
-	}
+	domainChecker := domaincheck.NewChecker(database, cfg.Domains, cfg.DomainCheckInterval)
 
 	redirectHandler := &handlers.RedirectHandler{
 		DB:        database,
+		Cfg:       cfg,
 		Cache:     linkCache,
 		Collector: collector,
 		DC:        dcChecker,
 	}
 
+	tlsAskHandler := &handlers.TLSAskHandler{Cfg: cfg}
+
 	r := chi.NewRouter()
 	r.Use(chimiddleware.RealIP)
 	r.Use(chimiddleware.Logger)
 	r.Use(chimiddleware.Recoverer)
 
-	// API routes (authenticated)
-	r.Route("/api", func(r chi.Router) {
-		r.Use(handlers.AuthMiddleware(cfg.Password))
-		r.Post("/links", linkHandler.Create)
-		r.Get("/links", linkHandler.List)
-		r.Get("/links/{id}", linkHandler.Get)
-		r.Patch("/links/{id}", linkHandler.Update)
-		r.Delete("/links/{id}", linkHandler.Delete)
-	})
+	r.Mount("/api", server.APIRouter(database, cfg, linkCache))
+
+	// On-demand TLS ask endpoint shares the main listener only when a token guards it
+	if cfg.TLSAskAddr == "" && cfg.TLSAskToken != "" {
+		r.Get("/internal/tls-allowed", tlsAskHandler.ServeHTTP)
+	}
 
 	// Admin UI
-	adminHandler, err := web.NewAdminHandler(database, cfg, linkCache)
+	adminHandler, err := web.NewAdminHandler(database, cfg, linkCache, domainChecker)
 	if err != nil {
 		log.Fatalf("admin: %v", err)
 	}
@@ -92,17 +122,68 @@ func main() {
 		Handler: r,
 	}
 
+	// Built-in HTTPS: serve TLS directly and answer ACME challenges plus
+	// HTTP→HTTPS redirects on the plain HTTP listener.
+	var httpSrv *http.Server
+	if cfg.AutoTLS {
+		certManager, err := autotls.NewManager(cfg)
+		if err != nil {
+			log.Fatalf("autotls: %v", err)
+		}
+		srv.Addr = cfg.HTTPSAddr
+		srv.TLSConfig = certManager.TLSConfig()
+		httpSrv = &http.Server{
+			Addr:    cfg.HTTPAddr,
+			Handler: certManager.HTTPHandler(nil),
+		}
+	}
+
+	var askSrv *http.Server
+	if cfg.TLSAskAddr != "" {
+		askRouter := chi.NewRouter()
+		askRouter.Get("/internal/tls-allowed", tlsAskHandler.ServeHTTP)
+		askSrv = &http.Server{
+			Addr:    cfg.TLSAskAddr,
+			Handler: askRouter,
+		}
+	}
+
 	// Graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		log.Printf("dubly listening on :%s", cfg.Port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.AutoTLS {
+			log.Printf("dubly listening on %s (https)", cfg.HTTPSAddr)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("dubly listening on :%s", cfg.Port)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("server: %v", err)
 		}
 	}()
 
+	if httpSrv != nil {
+		go func() {
+			log.Printf("http redirect listening on %s", cfg.HTTPAddr)
+			if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("http server: %v", err)
+			}
+		}()
+	}
+
+	if askSrv != nil {
+		go func() {
+			log.Printf("tls ask endpoint listening on %s", cfg.TLSAskAddr)
+			if err := askSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("tls ask server: %v", err)
+			}
+		}()
+	}
+
 	<-stop
 	log.Println("shutting down...")
 
@@ -112,8 +193,19 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("server shutdown: %v", err)
 	}
+	if httpSrv != nil {
+		if err := httpSrv.Shutdown(ctx); err != nil {
+			log.Printf("http server shutdown: %v", err)
+		}
+	}
+	if askSrv != nil {
+		if err := askSrv.Shutdown(ctx); err != nil {
+			log.Printf("tls ask server shutdown: %v", err)
+		}
+	}
 
 	collector.Shutdown()
 	dcChecker.Shutdown()
+	domainChecker.Shutdown()
 	log.Println("goodbye")
 }
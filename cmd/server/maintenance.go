@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/scmmishra/dubly/internal/autotls"
+	"github.com/scmmishra/dubly/internal/cmdline"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/db"
+	"github.com/scmmishra/dubly/internal/geo"
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+func (a *admin) clicksCmd(args []string) error {
+	if len(args) == 0 || args[0] != "purge" {
+		fmt.Fprint(a.stderr, "usage: dubly clicks purge [flags]\n")
+		return cmdline.ErrUsage
+	}
+	fs := a.flags("clicks purge", "")
+	linkID := fs.Int64("link", 0, "only clicks on this link")
+	before := fs.String("before", "", "only clicks before this date (YYYY-MM-DD or RFC 3339)")
+	all := fs.Bool("all", false, "purge every click when neither -link nor -before is given")
+	dryRun := fs.Bool("dry-run", false, "count the clicks without deleting them")
+	if _, err := cmdline.Parse(fs, args[1:]); err != nil {
+		return err
+	}
+
+	f := models.ClickFilter{LinkID: *linkID}
+	var err error
+	if f.Before, err = models.ParseFilterTime(*before, false); err != nil {
+		return fmt.Errorf("-before: %w", err)
+	}
+	if f.LinkID == 0 && f.Before == nil && !*all {
+		return fmt.Errorf("give -link, -before or both, or -all to purge every click")
+	}
+
+	_, database, err := open()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if f.LinkID != 0 {
+		if err := models.GetLinkByID(database, &models.Link{ID: f.LinkID}); err != nil {
+			return linkError(f.LinkID, err)
+		}
+	}
+	if *dryRun {
+		n, err := models.CountClicks(database, f)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stdout, "would purge %d clicks\n", n)
+		return nil
+	}
+	n, err := models.PurgeClicks(database, f)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "purged %d clicks\n", n)
+	return nil
+}
+
+// configCmd checks what the server needs at startup without starting it:
+// the environment, the database and, when configured, the GeoIP database
+// and certificate directory.
+func (a *admin) configCmd(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprint(a.stderr, "usage: dubly config check\n")
+		return cmdline.ErrUsage
+	}
+	fs := a.flags("config check", "")
+	if _, err := cmdline.Parse(fs, args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	failed := false
+	report := func(what string, err error, ok string) {
+		if err != nil {
+			failed = true
+			fmt.Fprintf(tw, "%s\tFAIL\t%v\n", what, err)
+		} else {
+			fmt.Fprintf(tw, "%s\tok\t%s\n", what, ok)
+		}
+	}
+
+	report("environment", nil, fmt.Sprintf("%d domains", len(cfg.Domains)))
+	report("database", checkDatabase(cfg.DBPath), cfg.DBPath)
+	if cfg.GeoIPPath == "" {
+		report("geoip", nil, "disabled")
+	} else {
+		r, err := geo.Open(cfg.GeoIPPath)
+		r.Close()
+		report("geoip", err, cfg.GeoIPPath)
+	}
+	if cfg.AutoTLS {
+		_, err := autotls.NewManager(cfg)
+		report("certificates", err, cfg.CertDir)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if failed {
+		return fmt.Errorf("config check failed")
+	}
+	return nil
+}
+
+// checkDatabase opens the database, which migrates it, and runs SQLite's
+// quick integrity check.
+func checkDatabase(path string) error {
+	database, err := db.Open(path)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+	var result string
+	if err := database.QueryRow(`PRAGMA quick_check`).Scan(&result); err != nil {
+		return fmt.Errorf("quick check: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("quick check: %s", result)
+	}
+	return nil
+}
+
+// dbStats is what the stats command reports.
+type dbStats struct {
+	ActiveLinks int                     `json:"active_links"`
+	Clicks      int                     `json:"clicks"`
+	ClicksToday int                     `json:"clicks_today"`
+	DBBytes     int64                   `json:"db_bytes"`
+	TopLinks    []models.LinkWithClicks `json:"top_links"`
+}
+
+func (a *admin) statsCmd(args []string) error {
+	fs := a.flags("stats", "")
+	top := fs.Int("top", 10, "how many of the most clicked links to show")
+	asJSON := fs.Bool("json", false, "print the stats as JSON")
+	if _, err := cmdline.Parse(fs, args); err != nil {
+		return err
+	}
+
+	cfg, database, err := open()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	var s dbStats
+	if s.ActiveLinks, err = models.TotalLinkCount(database); err != nil {
+		return err
+	}
+	if s.Clicks, err = models.ClicksAllTime(database); err != nil {
+		return err
+	}
+	if s.ClicksToday, err = models.ClicksToday(database); err != nil {
+		return err
+	}
+	if s.TopLinks, err = models.TopLinksByClicks(database, *top); err != nil {
+		return err
+	}
+	for i := range s.TopLinks {
+		s.TopLinks[i].Link.FillShortURL(cfg.ShortURLBase)
+	}
+	s.DBBytes = fileSize(cfg.DBPath)
+
+	if *asJSON {
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+	fmt.Fprintf(a.stdout, "active links  %d\n", s.ActiveLinks)
+	fmt.Fprintf(a.stdout, "clicks        %d\n", s.Clicks)
+	fmt.Fprintf(a.stdout, "clicks today  %d\n", s.ClicksToday)
+	fmt.Fprintf(a.stdout, "database      %s\n", formatBytes(s.DBBytes))
+	if len(s.TopLinks) == 0 {
+		return nil
+	}
+	fmt.Fprintln(a.stdout)
+	tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSHORT URL\tCLICKS")
+	for _, l := range s.TopLinks {
+		fmt.Fprintf(tw, "%d\t%s\t%d\n", l.Link.ID, l.Link.ShortURL, l.ClickCount)
+	}
+	return tw.Flush()
+}
+
+// vacuumCmd folds the write-ahead log into the database file and rebuilds
+// it, returning the space left by deleted links and purged clicks.
+func (a *admin) vacuumCmd(args []string) error {
+	fs := a.flags("vacuum", "")
+	if _, err := cmdline.Parse(fs, args); err != nil {
+		return err
+	}
+
+	cfg, database, err := open()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	before := fileSize(cfg.DBPath) + fileSize(cfg.DBPath+"-wal")
+	if _, err := database.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if _, err := database.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	if _, err := database.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	after := fileSize(cfg.DBPath) + fileSize(cfg.DBPath+"-wal")
+	fmt.Fprintf(a.stdout, "vacuumed %s: %s → %s\n", cfg.DBPath, formatBytes(before), formatBytes(after))
+	return nil
+}
+
+// fileSize returns the size of the file at path, or 0 when there is none.
+func fileSize(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
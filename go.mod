@@ -9,6 +9,9 @@ require (
 	github.com/oschwald/maxminddb-golang v1.13.1
 	github.com/yeqown/go-qrcode/v2 v2.2.5
 	github.com/yeqown/go-qrcode/writer/standard v1.3.0
+	golang.org/x/crypto v0.43.0
+	golang.org/x/net v0.45.0
+	golang.org/x/text v0.30.0
 	modernc.org/sqlite v1.46.1
 )
 
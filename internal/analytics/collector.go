@@ -18,6 +18,7 @@ type RawClick struct {
 	IP        string
 	UserAgent string
 	Referer   string
+	AliasID   int64 // set when the link was reached through an alias
 }
 
 type Collector struct {
@@ -134,5 +135,6 @@ func (c *Collector) enrich(raw RawClick) models.Click {
 		BrowserVersion: browserVersion,
 		OS:             ua.OS(),
 		DeviceType:     deviceType,
+		AliasID:        raw.AliasID,
 	}
 }
@@ -0,0 +1,69 @@
+package autotls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/scmmishra/dubly/internal/config"
+)
+
+// NewManager returns an autocert manager that issues certificates for the
+// configured domains only and stores them under cfg.CertDir.
+func NewManager(cfg *config.Config) (*autocert.Manager, error) {
+	if err := os.MkdirAll(cfg.CertDir, 0o700); err != nil {
+		return nil, fmt.Errorf("create cert dir: %w", err)
+	}
+
+	client := &acme.Client{DirectoryURL: cfg.ACMEDirectoryURL}
+	if cfg.ACMECACert != "" {
+		httpClient, err := clientTrusting(cfg.ACMECACert)
+		if err != nil {
+			return nil, err
+		}
+		client.HTTPClient = httpClient
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cfg.CertDir),
+		HostPolicy: HostPolicy(cfg),
+		Email:      cfg.ACMEEmail,
+		Client:     client,
+	}, nil
+}
+
+// HostPolicy only allows certificate requests for configured domains and
+// their aliases.
+func HostPolicy(cfg *config.Config) autocert.HostPolicy {
+	return func(_ context.Context, host string) error {
+		if !cfg.ServesHost(host) {
+			return fmt.Errorf("autotls: host %q is not configured", host)
+		}
+		return nil
+	}
+}
+
+// clientTrusting returns an HTTP client that trusts the PEM certificates in
+// path, e.g. the root of a local ACME test server.
+func clientTrusting(path string) (*http.Client, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read acme ca cert: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("acme ca cert %s: no certificates found", path)
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}, nil
+}
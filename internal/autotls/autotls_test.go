@@ -0,0 +1,119 @@
+package autotls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/config"
+)
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	return &config.Config{
+		Domains:          []string{"short.io", "go.example.com"},
+		CertDir:          filepath.Join(t.TempDir(), "certs"),
+		ACMEDirectoryURL: "https://localhost:14000/dir",
+		ACMEEmail:        "ops@example.com",
+	}
+}
+
+// writeTestCA writes a self-signed CA certificate in PEM form and returns its path.
+func writeTestCA(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test acme ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHostPolicy(t *testing.T) {
+	policy := HostPolicy(testConfig(t))
+
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"short.io", true},
+		{"GO.EXAMPLE.COM", true},
+		{"evil.com", false},
+		{"sub.short.io", false},
+	}
+	for _, tt := range tests {
+		err := policy(context.Background(), tt.host)
+		if (err == nil) != tt.allowed {
+			t.Errorf("HostPolicy(%q) err = %v, want allowed = %v", tt.host, err, tt.allowed)
+		}
+	}
+}
+
+func TestNewManager_UsesConfig(t *testing.T) {
+	cfg := testConfig(t)
+	m, err := NewManager(cfg)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if m.Client.DirectoryURL != cfg.ACMEDirectoryURL {
+		t.Errorf("directory = %q, want %q", m.Client.DirectoryURL, cfg.ACMEDirectoryURL)
+	}
+	if m.Email != cfg.ACMEEmail {
+		t.Errorf("email = %q, want %q", m.Email, cfg.ACMEEmail)
+	}
+	if info, err := os.Stat(cfg.CertDir); err != nil || !info.IsDir() {
+		t.Errorf("cert dir %q not created: %v", cfg.CertDir, err)
+	}
+}
+
+func TestNewManager_TrustsCustomCA(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ACMECACert = writeTestCA(t)
+
+	m, err := NewManager(cfg)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if m.Client.HTTPClient == nil {
+		t.Error("expected a custom HTTP client trusting the test CA")
+	}
+}
+
+func TestNewManager_InvalidCA(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.ACMECACert = filepath.Join(t.TempDir(), "missing.pem")
+	if _, err := NewManager(cfg); err == nil {
+		t.Error("expected error for missing CA file")
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.pem")
+	os.WriteFile(bad, []byte("not a cert"), 0o600)
+	cfg.ACMECACert = bad
+	if _, err := NewManager(cfg); err == nil {
+		t.Error("expected error for CA file without certificates")
+	}
+}
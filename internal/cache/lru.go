@@ -1,20 +1,35 @@
 package cache
 
 import (
+	"database/sql"
+	"strings"
+	"time"
+
 	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/scmmishra/dubly/internal/models"
 )
 
+// LinkCache holds recently redirected links keyed by domain and slug.
+// Entries expire after a TTL so changes made to the database by other
+// processes, such as the dubly admin commands, reach a running server.
 type LinkCache struct {
-	c *lru.Cache[string, *models.Link]
+	c   *lru.Cache[string, entry]
+	ttl time.Duration
+}
+
+type entry struct {
+	link    *models.Link
+	expires time.Time
 }
 
-func New(size int) (*LinkCache, error) {
-	c, err := lru.New[string, *models.Link](size)
+// New returns a cache of up to size links, each kept for at most ttl. A ttl
+// of 0 keeps links until they're evicted or invalidated.
+func New(size int, ttl time.Duration) (*LinkCache, error) {
+	c, err := lru.New[string, entry](size)
 	if err != nil {
 		return nil, err
 	}
-	return &LinkCache{c: c}, nil
+	return &LinkCache{c: c, ttl: ttl}, nil
 }
 
 func key(domain, slug string) string {
@@ -22,13 +37,56 @@ func key(domain, slug string) string {
 }
 
 func (lc *LinkCache) Get(domain, slug string) (*models.Link, bool) {
-	return lc.c.Get(key(domain, slug))
+	k := key(domain, slug)
+	e, ok := lc.c.Get(k)
+	if !ok {
+		return nil, false
+	}
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		lc.c.Remove(k)
+		return nil, false
+	}
+	return e.link, true
 }
 
 func (lc *LinkCache) Set(domain, slug string, link *models.Link) {
-	lc.c.Add(key(domain, slug), link)
+	e := entry{link: link}
+	if lc.ttl > 0 {
+		e.expires = time.Now().Add(lc.ttl)
+	}
+	lc.c.Add(key(domain, slug), e)
 }
 
+// Invalidate drops the entry for domain/slug. Case-insensitive domains cache
+// links under the lowercased slug, so that key is dropped too.
 func (lc *LinkCache) Invalidate(domain, slug string) {
 	lc.c.Remove(key(domain, slug))
+	if lower := strings.ToLower(slug); lower != slug {
+		lc.c.Remove(key(domain, lower))
+	}
+}
+
+// InvalidateAliases drops the entries cached under each of a link's aliases,
+// which hold their own copy of the link.
+func (lc *LinkCache) InvalidateAliases(aliases []models.LinkAlias) {
+	for _, a := range aliases {
+		lc.Invalidate(a.Domain, a.Slug)
+	}
+}
+
+// InvalidateLink drops the entries for link id, cached under domain/slug and
+// under each of its aliases, after the link changed. domain and slug are
+// the key it was cached under, which an update may have just changed.
+func (lc *LinkCache) InvalidateLink(db *sql.DB, id int64, domain, slug string) {
+	lc.Invalidate(domain, slug)
+	if aliases, err := models.ListLinkAliases(db, id); err == nil {
+		lc.InvalidateAliases(aliases)
+	}
+}
+
+// InvalidateLinks is InvalidateLink for each of links.
+func (lc *LinkCache) InvalidateLinks(db *sql.DB, links []models.Link) {
+	for _, l := range links {
+		lc.InvalidateLink(db, l.ID, l.Domain, l.Slug)
+	}
 }
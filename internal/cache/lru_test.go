@@ -2,12 +2,14 @@ package cache
 
 import (
 	"testing"
+	"time"
 
+	"github.com/scmmishra/dubly/internal/db"
 	"github.com/scmmishra/dubly/internal/models"
 )
 
 func TestCache_SetAndGet(t *testing.T) {
-	c, err := New(10)
+	c, err := New(10, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -25,7 +27,7 @@ func TestCache_SetAndGet(t *testing.T) {
 }
 
 func TestCache_GetMiss(t *testing.T) {
-	c, err := New(10)
+	c, err := New(10, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -37,7 +39,7 @@ func TestCache_GetMiss(t *testing.T) {
 }
 
 func TestCache_Invalidate(t *testing.T) {
-	c, err := New(10)
+	c, err := New(10, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -52,8 +54,52 @@ func TestCache_Invalidate(t *testing.T) {
 	}
 }
 
+func TestCache_InvalidateDropsLowercasedKey(t *testing.T) {
+	c, err := New(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Case-insensitive domains cache under the lowercased slug
+	c.Set("go", "wiki", &models.Link{ID: 1, Slug: "Wiki", Domain: "go"})
+	c.Invalidate("go", "Wiki")
+
+	if _, found := c.Get("go", "wiki"); found {
+		t.Error("expected lowercased key to be invalidated")
+	}
+}
+
+func TestCache_InvalidateLinkDropsAliases(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	link := &models.Link{Slug: "abc", Domain: "d.co", Destination: "https://example.com"}
+	if err := models.CreateLink(database, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.CreateLinkAlias(database, &models.LinkAlias{LinkID: link.ID, Slug: "alt", Domain: "d.co"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("d.co", "abc", link)
+	c.Set("d.co", "alt", link)
+	c.InvalidateLink(database, link.ID, "d.co", "abc")
+
+	for _, slug := range []string{"abc", "alt"} {
+		if _, found := c.Get("d.co", slug); found {
+			t.Errorf("expected %s to be invalidated", slug)
+		}
+	}
+}
+
 func TestCache_EvictsLRU(t *testing.T) {
-	c, err := New(2)
+	c, err := New(2, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -75,3 +121,16 @@ func TestCache_EvictsLRU(t *testing.T) {
 		t.Error("expected 'c' to be cached")
 	}
 }
+
+func TestCache_EntriesExpire(t *testing.T) {
+	c, err := New(10, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("d.co", "abc", &models.Link{ID: 1})
+	time.Sleep(5 * time.Millisecond)
+
+	if _, found := c.Get("d.co", "abc"); found {
+		t.Error("expected expired entry to miss")
+	}
+}
@@ -0,0 +1,44 @@
+// Package cmdline holds the flag handling shared by the dubly and dublyctl
+// commands.
+package cmdline
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// ErrUsage reports a malformed command line; the message has been printed.
+var ErrUsage = errors.New("usage")
+
+// FlagSet returns a flag set for command, such as "dubly links list", that
+// reports errors and usage to w.
+func FlagSet(command, argsUsage string, w io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.Usage = func() {
+		fmt.Fprintf(w, "usage: %s [flags] %s\n\n", command, argsUsage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Parse parses a command's flags, which may come before, between or after
+// its arguments, and returns the arguments.
+func Parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, ErrUsage
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
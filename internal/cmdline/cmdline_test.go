@@ -0,0 +1,36 @@
+package cmdline
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParse_InterleavedFlags(t *testing.T) {
+	fs := FlagSet("dubly links list", "", io.Discard)
+	n := fs.Int("n", 0, "")
+	v := fs.Bool("v", false, "")
+
+	args, err := Parse(fs, []string{"a", "-n", "3", "b", "-v", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+	if *n != 3 || !*v {
+		t.Errorf("n = %d, v = %v", *n, *v)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	fs := FlagSet("dubly links list", "", io.Discard)
+	if _, err := Parse(fs, []string{"-unknown"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("unknown flag: err = %v, want ErrUsage", err)
+	}
+	if _, err := Parse(fs, []string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("-h: err = %v, want flag.ErrHelp", err)
+	}
+}
@@ -3,11 +3,18 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/net/idna"
+
+	"github.com/scmmishra/dubly/internal/slug"
 )
 
+const letsEncryptURL = "https://acme-v02.api.letsencrypt.org/directory"
+
 type Config struct {
 	Port          string
 	DBPath        string
@@ -17,7 +24,50 @@ type Config struct {
 	FlushInterval time.Duration
 	BufferSize    int
 	CacheSize     int
+	CacheTTL      time.Duration
 	AppName       string
+
+	// Per-domain overrides keyed by lowercase domain; see SettingsFor.
+	DomainSettings map[string]DomainSettings
+
+	// Slug generation defaults. SlugMode applies to domains without their
+	// own slug_mode; SlugBlocklist extends the built-in profanity filter.
+	SlugMode      string
+	SlugBlocklist []string
+
+	// Custom slug validation: maximum length and reserved slugs on top of
+	// the built-in list; see SlugValidator.
+	SlugMaxLength int
+	ReservedSlugs []string
+
+	// DomainAliases maps an alias host to the configured domain whose links
+	// it serves, e.g. an old brand domain kept alive after a rebrand.
+	DomainAliases map[string]string
+
+	// Domain health checks (DNS + TLS certificate) run every
+	// DomainCheckInterval; certificates expiring within CertWarnWindow are flagged.
+	DomainCheckInterval time.Duration
+	CertWarnWindow      time.Duration
+
+	// Responses to API requests sent with an Idempotency-Key header are
+	// replayed to retries for IdempotencyWindow.
+	IdempotencyWindow time.Duration
+
+	// On-demand TLS "ask" endpoint for Caddy. When TLSAskAddr is set the
+	// endpoint is served on that separate listener; otherwise it is mounted
+	// on the main router only if TLSAskToken is set.
+	TLSAskAddr  string
+	TLSAskToken string
+
+	// Built-in HTTPS. When AutoTLS is set the server terminates TLS itself
+	// with ACME-issued certificates instead of listening on Port.
+	AutoTLS          bool
+	HTTPAddr         string
+	HTTPSAddr        string
+	CertDir          string
+	ACMEEmail        string
+	ACMEDirectoryURL string
+	ACMECACert       string
 }
 
 func Load() (*Config, error) {
@@ -33,9 +83,23 @@ func Load() (*Config, error) {
 	var domains []string
 	for _, d := range strings.Split(domainsRaw, ",") {
 		d = strings.TrimSpace(d)
-		if d != "" {
-			domains = append(domains, d)
+		if d == "" {
+			continue
 		}
+		if strings.Contains(d, "*") && (!IsWildcard(d) || len(d) < 3 || strings.Contains(d[1:], "*")) {
+			return nil, fmt.Errorf("DUBLY_DOMAINS: invalid wildcard %q, use the form *.example.com", d)
+		}
+		domains = append(domains, d)
+	}
+
+	domainSettings, err := parseDomainSettings(os.Getenv("DUBLY_DOMAIN_SETTINGS"), domains)
+	if err != nil {
+		return nil, fmt.Errorf("DUBLY_DOMAIN_SETTINGS: %w", err)
+	}
+
+	domainAliases, err := parseDomainAliases(os.Getenv("DUBLY_DOMAIN_ALIASES"), domains)
+	if err != nil {
+		return nil, fmt.Errorf("DUBLY_DOMAIN_ALIASES: %w", err)
 	}
 
 	cfg := &Config{
@@ -47,8 +111,34 @@ func Load() (*Config, error) {
 		FlushInterval: parseDuration("DUBLY_FLUSH_INTERVAL", 30*time.Second),
 		BufferSize:    parseInt("DUBLY_BUFFER_SIZE", 50000),
 		CacheSize:     parseInt("DUBLY_CACHE_SIZE", 10000),
+		CacheTTL:      parseDuration("DUBLY_CACHE_TTL", time.Minute),
 		AppName:       envOrDefault("DUBLY_APP_NAME", "Dubly"),
+
+		DomainSettings: domainSettings,
+		DomainAliases:  domainAliases,
+
+		SlugMode:      envOrDefault("DUBLY_SLUG_MODE", string(slug.ModeRandom)),
+		SlugBlocklist: parseList(os.Getenv("DUBLY_SLUG_BLOCKLIST")),
+		SlugMaxLength: parseInt("DUBLY_SLUG_MAX_LENGTH", slug.DefaultMaxLength),
+		ReservedSlugs: parseList(os.Getenv("DUBLY_RESERVED_SLUGS")),
+
+		DomainCheckInterval: parseDuration("DUBLY_DOMAIN_CHECK_INTERVAL", 12*time.Hour),
+		CertWarnWindow:      parseDuration("DUBLY_CERT_WARN_WINDOW", 14*24*time.Hour),
+
+		IdempotencyWindow: parseDuration("DUBLY_IDEMPOTENCY_WINDOW", 24*time.Hour),
+
+		TLSAskAddr:  os.Getenv("DUBLY_TLS_ASK_ADDR"),
+		TLSAskToken: os.Getenv("DUBLY_TLS_ASK_TOKEN"),
+
+		AutoTLS:          parseBool("DUBLY_AUTO_TLS", false),
+		HTTPAddr:         envOrDefault("DUBLY_HTTP_ADDR", ":80"),
+		HTTPSAddr:        envOrDefault("DUBLY_HTTPS_ADDR", ":443"),
+		ACMEEmail:        os.Getenv("DUBLY_ACME_EMAIL"),
+		ACMEDirectoryURL: envOrDefault("DUBLY_ACME_DIRECTORY_URL", letsEncryptURL),
+		ACMECACert:       os.Getenv("DUBLY_ACME_CA_CERT"),
 	}
+	// Certificates live next to the database unless told otherwise
+	cfg.CertDir = envOrDefault("DUBLY_CERT_DIR", filepath.Join(filepath.Dir(cfg.DBPath), "certs"))
 
 	if cfg.FlushInterval <= 0 {
 		return nil, fmt.Errorf("DUBLY_FLUSH_INTERVAL must be positive")
@@ -59,17 +149,122 @@ func Load() (*Config, error) {
 	if cfg.CacheSize <= 0 {
 		return nil, fmt.Errorf("DUBLY_CACHE_SIZE must be positive")
 	}
+	if cfg.CacheTTL <= 0 {
+		return nil, fmt.Errorf("DUBLY_CACHE_TTL must be positive")
+	}
+	if !slug.ValidMode(slug.Mode(cfg.SlugMode)) {
+		return nil, fmt.Errorf("DUBLY_SLUG_MODE must be one of random, unambiguous, words or title")
+	}
+	if cfg.SlugMaxLength <= 0 {
+		return nil, fmt.Errorf("DUBLY_SLUG_MAX_LENGTH must be positive")
+	}
+	if cfg.DomainCheckInterval <= 0 {
+		return nil, fmt.Errorf("DUBLY_DOMAIN_CHECK_INTERVAL must be positive")
+	}
+	if cfg.CertWarnWindow <= 0 {
+		return nil, fmt.Errorf("DUBLY_CERT_WARN_WINDOW must be positive")
+	}
+	if cfg.IdempotencyWindow <= 0 {
+		return nil, fmt.Errorf("DUBLY_IDEMPOTENCY_WINDOW must be positive")
+	}
 
 	return cfg, nil
 }
 
+// IsDomainAllowed reports whether links may be created on and served from
+// domain, either because it is listed exactly or because it is a single-label
+// subdomain of a wildcard entry such as "*.links.example.com".
 func (c *Config) IsDomainAllowed(domain string) bool {
+	_, ok := c.MatchDomain(domain)
+	return ok
+}
+
+// MatchDomain returns the configured entry that domain falls under: the
+// domain itself, or the wildcard pattern it matches. IDNs match in either
+// their Unicode or punycode form.
+func (c *Config) MatchDomain(domain string) (string, bool) {
+	if domain == "" || strings.Contains(domain, "*") {
+		return "", false
+	}
+	ascii := CanonicalDomain(domain)
+	for _, d := range c.Domains {
+		if !IsWildcard(d) && CanonicalDomain(d) == ascii {
+			return d, true
+		}
+	}
 	for _, d := range c.Domains {
-		if strings.EqualFold(d, domain) {
-			return true
+		if IsWildcard(d) && matchesWildcard(d, domain) {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+// IsWildcard reports whether a configured domain is a wildcard entry.
+func IsWildcard(domain string) bool {
+	return strings.HasPrefix(domain, "*.")
+}
+
+// matchesWildcard reports whether host is exactly one valid DNS label
+// followed by the pattern's suffix, mirroring TLS wildcard semantics.
+func matchesWildcard(pattern, host string) bool {
+	suffix := WildcardSuffix(pattern) // ".links.example.com"
+	host = CanonicalDomain(host)
+	if !strings.HasSuffix(host, suffix) {
+		return false
+	}
+	return IsValidLabel(strings.TrimSuffix(host, suffix))
+}
+
+// WildcardSuffix returns the canonical suffix shared by every host under a
+// wildcard entry, e.g. ".links.example.com" for "*.links.example.com".
+func WildcardSuffix(pattern string) string {
+	return "." + CanonicalDomain(strings.TrimPrefix(pattern, "*."))
+}
+
+// CanonicalDomain returns the form links are stored and looked up under:
+// lowercase, without a trailing dot, and punycode for IDNs, which is what
+// browsers send in the Host header. Values that aren't valid hostnames are
+// only lowercased.
+func CanonicalDomain(domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return domain
+	}
+	return ascii
+}
+
+// DisplayDomain returns the Unicode form of a punycode domain for display.
+func DisplayDomain(domain string) string {
+	if u, err := idna.Display.ToUnicode(domain); err == nil {
+		return u
+	}
+	return domain
+}
+
+// IsValidLabel reports whether s is a valid single DNS label.
+func IsValidLabel(s string) bool {
+	if s == "" || len(s) > 63 || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
 		}
 	}
-	return false
+	return true
+}
+
+// parseList splits a comma-separated value, dropping empty entries.
+func parseList(v string) []string {
+	var list []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
 }
 
 func envOrDefault(key, fallback string) string {
@@ -102,3 +297,15 @@ func parseDuration(key string, fallback time.Duration) time.Duration {
 	}
 	return d
 }
+
+func parseBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fallback
+	}
+	return b
+}
@@ -9,7 +9,12 @@ func clearEnv(t *testing.T) {
 	t.Helper()
 	for _, key := range []string{
 		"DUBLY_PASSWORD", "DUBLY_DOMAINS", "DUBLY_PORT", "DUBLY_DB_PATH",
-		"DUBLY_GEOIP_PATH", "DUBLY_FLUSH_INTERVAL", "DUBLY_BUFFER_SIZE", "DUBLY_CACHE_SIZE",
+		"DUBLY_GEOIP_PATH", "DUBLY_FLUSH_INTERVAL", "DUBLY_BUFFER_SIZE", "DUBLY_CACHE_SIZE", "DUBLY_CACHE_TTL",
+		"DUBLY_DOMAIN_SETTINGS", "DUBLY_DOMAIN_ALIASES", "DUBLY_SLUG_MODE", "DUBLY_SLUG_BLOCKLIST", "DUBLY_SLUG_MAX_LENGTH", "DUBLY_RESERVED_SLUGS", "DUBLY_DOMAIN_CHECK_INTERVAL", "DUBLY_CERT_WARN_WINDOW",
+		"DUBLY_IDEMPOTENCY_WINDOW",
+		"DUBLY_TLS_ASK_ADDR", "DUBLY_TLS_ASK_TOKEN",
+		"DUBLY_AUTO_TLS", "DUBLY_HTTP_ADDR", "DUBLY_HTTPS_ADDR", "DUBLY_CERT_DIR",
+		"DUBLY_ACME_EMAIL", "DUBLY_ACME_DIRECTORY_URL", "DUBLY_ACME_CA_CERT",
 	} {
 		t.Setenv(key, "")
 	}
@@ -39,6 +44,18 @@ func TestLoad_MinimalValid(t *testing.T) {
 	if cfg.CacheSize != 10000 {
 		t.Errorf("cache size = %d, want %d", cfg.CacheSize, 10000)
 	}
+	if cfg.CacheTTL != time.Minute {
+		t.Errorf("cache ttl = %v, want %v", cfg.CacheTTL, time.Minute)
+	}
+	if cfg.DomainCheckInterval != 12*time.Hour {
+		t.Errorf("domain check interval = %v, want %v", cfg.DomainCheckInterval, 12*time.Hour)
+	}
+	if cfg.CertWarnWindow != 14*24*time.Hour {
+		t.Errorf("cert warn window = %v, want %v", cfg.CertWarnWindow, 14*24*time.Hour)
+	}
+	if cfg.IdempotencyWindow != 24*time.Hour {
+		t.Errorf("idempotency window = %v, want %v", cfg.IdempotencyWindow, 24*time.Hour)
+	}
 }
 
 func TestLoad_AllFieldsOverridden(t *testing.T) {
@@ -51,6 +68,11 @@ func TestLoad_AllFieldsOverridden(t *testing.T) {
 	t.Setenv("DUBLY_FLUSH_INTERVAL", "10s")
 	t.Setenv("DUBLY_BUFFER_SIZE", "500")
 	t.Setenv("DUBLY_CACHE_SIZE", "200")
+	t.Setenv("DUBLY_DOMAIN_CHECK_INTERVAL", "1h")
+	t.Setenv("DUBLY_CERT_WARN_WINDOW", "720h")
+	t.Setenv("DUBLY_IDEMPOTENCY_WINDOW", "1h")
+	t.Setenv("DUBLY_TLS_ASK_ADDR", "127.0.0.1:8081")
+	t.Setenv("DUBLY_TLS_ASK_TOKEN", "ask-token")
 
 	cfg, err := Load()
 	if err != nil {
@@ -80,6 +102,68 @@ func TestLoad_AllFieldsOverridden(t *testing.T) {
 	if cfg.CacheSize != 200 {
 		t.Errorf("cache = %d, want %d", cfg.CacheSize, 200)
 	}
+	if cfg.DomainCheckInterval != time.Hour {
+		t.Errorf("domain check interval = %v, want %v", cfg.DomainCheckInterval, time.Hour)
+	}
+	if cfg.CertWarnWindow != 720*time.Hour {
+		t.Errorf("cert warn window = %v, want %v", cfg.CertWarnWindow, 720*time.Hour)
+	}
+	if cfg.IdempotencyWindow != time.Hour {
+		t.Errorf("idempotency window = %v, want %v", cfg.IdempotencyWindow, time.Hour)
+	}
+	if cfg.TLSAskAddr != "127.0.0.1:8081" {
+		t.Errorf("tls ask addr = %q, want %q", cfg.TLSAskAddr, "127.0.0.1:8081")
+	}
+	if cfg.TLSAskToken != "ask-token" {
+		t.Errorf("tls ask token = %q, want %q", cfg.TLSAskToken, "ask-token")
+	}
+}
+
+func TestLoad_AutoTLSDefaults(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DUBLY_PASSWORD", "secret")
+	t.Setenv("DUBLY_DOMAINS", "example.com")
+	t.Setenv("DUBLY_DB_PATH", "/var/lib/dubly/dubly.db")
+	t.Setenv("DUBLY_AUTO_TLS", "true")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.AutoTLS {
+		t.Error("expected AutoTLS to be enabled")
+	}
+	if cfg.HTTPAddr != ":80" || cfg.HTTPSAddr != ":443" {
+		t.Errorf("addrs = %q/%q, want :80/:443", cfg.HTTPAddr, cfg.HTTPSAddr)
+	}
+	if cfg.CertDir != "/var/lib/dubly/certs" {
+		t.Errorf("cert dir = %q, want %q", cfg.CertDir, "/var/lib/dubly/certs")
+	}
+	if cfg.ACMEDirectoryURL != "https://acme-v02.api.letsencrypt.org/directory" {
+		t.Errorf("acme directory = %q, want Let's Encrypt production", cfg.ACMEDirectoryURL)
+	}
+}
+
+func TestLoad_AutoTLSOverrides(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DUBLY_PASSWORD", "secret")
+	t.Setenv("DUBLY_DOMAINS", "example.com")
+	t.Setenv("DUBLY_CERT_DIR", "/tmp/certs")
+	t.Setenv("DUBLY_ACME_DIRECTORY_URL", "https://localhost:14000/dir")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.AutoTLS {
+		t.Error("expected AutoTLS to be disabled by default")
+	}
+	if cfg.CertDir != "/tmp/certs" {
+		t.Errorf("cert dir = %q, want %q", cfg.CertDir, "/tmp/certs")
+	}
+	if cfg.ACMEDirectoryURL != "https://localhost:14000/dir" {
+		t.Errorf("acme directory = %q, want %q", cfg.ACMEDirectoryURL, "https://localhost:14000/dir")
+	}
 }
 
 func TestLoad_MissingPassword(t *testing.T) {
@@ -153,6 +237,21 @@ func TestLoad_ZeroCacheSize(t *testing.T) {
 	}
 }
 
+func TestLoad_NegativeCertWarnWindow(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DUBLY_PASSWORD", "secret")
+	t.Setenv("DUBLY_DOMAINS", "example.com")
+	t.Setenv("DUBLY_CERT_WARN_WINDOW", "-24h")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected error for negative cert warn window")
+	}
+	if err.Error() != "DUBLY_CERT_WARN_WINDOW must be positive" {
+		t.Errorf("error = %q, want %q", err.Error(), "DUBLY_CERT_WARN_WINDOW must be positive")
+	}
+}
+
 func TestLoad_DomainsTrimsWhitespace(t *testing.T) {
 	clearEnv(t)
 	t.Setenv("DUBLY_PASSWORD", "secret")
@@ -201,3 +300,77 @@ func TestIsDomainAllowed_NotInList(t *testing.T) {
 		t.Error("expected notallowed.com to not be allowed")
 	}
 }
+
+func TestIsDomainAllowed_Wildcard(t *testing.T) {
+	cfg := &Config{Domains: []string{"short.io", "*.links.example.com"}}
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"alice.links.example.com", true},
+		{"BOB.Links.Example.com", true},
+		{"links.example.com", false},
+		{"a.b.links.example.com", false},
+		{"-bad.links.example.com", false},
+		{"alice.links.example.com.evil.io", false},
+		{"*.links.example.com", false},
+		{"sub.short.io", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.IsDomainAllowed(tt.domain); got != tt.want {
+			t.Errorf("IsDomainAllowed(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestMatchDomain_ReturnsPattern(t *testing.T) {
+	cfg := &Config{Domains: []string{"short.io", "*.links.example.com"}}
+	if p, ok := cfg.MatchDomain("alice.links.example.com"); !ok || p != "*.links.example.com" {
+		t.Errorf("MatchDomain = %q, %v; want wildcard pattern", p, ok)
+	}
+	if p, ok := cfg.MatchDomain("short.io"); !ok || p != "short.io" {
+		t.Errorf("MatchDomain = %q, %v; want short.io", p, ok)
+	}
+}
+
+func TestLoad_InvalidWildcard(t *testing.T) {
+	for _, d := range []string{"*", "*.", "foo.*.com", "*links.com", "*.*.links.com"} {
+		clearEnv(t)
+		t.Setenv("DUBLY_PASSWORD", "secret")
+		t.Setenv("DUBLY_DOMAINS", d)
+		if _, err := Load(); err == nil {
+			t.Errorf("expected error for domain %q", d)
+		}
+	}
+}
+
+func TestCanonicalDomain(t *testing.T) {
+	tests := map[string]string{
+		"Short.IO":            "short.io",
+		"short.io.":           "short.io",
+		"bücher.de":           "xn--bcher-kva.de",
+		"XN--BCHER-KVA.de":    "xn--bcher-kva.de",
+		"*.links.example.com": "*.links.example.com",
+		" go ":                "go",
+	}
+	for in, want := range tests {
+		if got := CanonicalDomain(in); got != want {
+			t.Errorf("CanonicalDomain(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := DisplayDomain("xn--bcher-kva.de"); got != "bücher.de" {
+		t.Errorf("DisplayDomain = %q, want bücher.de", got)
+	}
+}
+
+func TestIsDomainAllowed_IDN(t *testing.T) {
+	cfg := &Config{Domains: []string{"bücher.de", "xn--mnchen-3ya.de", "*.bücher.example"}}
+	for _, d := range []string{"bücher.de", "xn--bcher-kva.de", "münchen.de", "xn--mnchen-3ya.de", "shop.xn--bcher-kva.example", "shop.bücher.example"} {
+		if !cfg.IsDomainAllowed(d) {
+			t.Errorf("IsDomainAllowed(%q) = false, want true", d)
+		}
+	}
+	if cfg.IsDomainAllowed("bucher.de") {
+		t.Error("IsDomainAllowed(bucher.de) = true, want false")
+	}
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/scmmishra/dubly/internal/slug"
+)
+
+// DomainSettings customizes how links on one domain are generated, matched
+// and displayed. Zero values fall back to the defaults applied by SettingsFor.
+type DomainSettings struct {
+	Scheme          string `json:"scheme"`           // "https" (default) or "http"
+	Port            int    `json:"port"`             // included in short URLs when set
+	CaseInsensitive bool   `json:"case_insensitive"` // match slugs regardless of case
+	SlugLength      int    `json:"slug_length"`      // length of generated slugs, 0 for the default
+	SlugAlphabet    string `json:"slug_alphabet"`    // characters used in generated slugs
+	SlugMode        string `json:"slug_mode"`        // random, unambiguous, words or title
+	DefaultTags     string `json:"default_tags"`     // applied when a new link has no tags
+}
+
+// SettingsFor returns the settings for domain with defaults filled in.
+// Subdomains under a wildcard entry inherit the wildcard's settings.
+func (c *Config) SettingsFor(domain string) DomainSettings {
+	s, ok := c.DomainSettings[strings.ToLower(domain)]
+	if !ok {
+		if pattern, matched := c.MatchDomain(domain); matched {
+			s = c.DomainSettings[strings.ToLower(pattern)]
+		}
+	}
+	if s.Scheme == "" {
+		s.Scheme = "https"
+	}
+	if s.SlugLength == 0 {
+		s.SlugLength = slug.DefaultLength
+	}
+	if s.SlugAlphabet == "" {
+		s.SlugAlphabet = slug.Base62
+		if s.CaseInsensitive {
+			s.SlugAlphabet = slug.Base36
+		}
+	}
+	if s.SlugMode == "" {
+		s.SlugMode = c.SlugMode
+	}
+	if s.SlugMode == "" {
+		s.SlugMode = string(slug.ModeRandom)
+	}
+	return s
+}
+
+// SlugGenerator returns the generator for new slugs on domain, honouring
+// its slug settings and the configured blocklist.
+func (c *Config) SlugGenerator(domain string) (slug.Generator, error) {
+	s := c.SettingsFor(domain)
+	return slug.New(slug.Options{
+		Mode:            slug.Mode(s.SlugMode),
+		Length:          s.SlugLength,
+		Alphabet:        s.SlugAlphabet,
+		CaseInsensitive: s.CaseInsensitive,
+		Filter:          slug.NewFilter(c.SlugBlocklist),
+	})
+}
+
+// ShortURLBase returns the scheme, host and optional port that prefix short
+// URLs on domain, e.g. "http://go:8080".
+func (c *Config) ShortURLBase(domain string) string {
+	s := c.SettingsFor(domain)
+	host := CanonicalDomain(domain)
+	if s.Port != 0 {
+		host += ":" + strconv.Itoa(s.Port)
+	}
+	return s.Scheme + "://" + host
+}
+
+// NormalizeSlug converts slug to Unicode NFC and lowercases it on
+// case-insensitive domains so it is stored and looked up in one canonical
+// form.
+func (c *Config) NormalizeSlug(domain, s string) string {
+	if c.SettingsFor(domain).CaseInsensitive {
+		s = strings.ToLower(s)
+	}
+	return slug.Normalize(s)
+}
+
+// SlugValidator returns the validator applied to custom and generated slugs.
+func (c *Config) SlugValidator() *slug.Validator {
+	return slug.NewValidator(c.SlugMaxLength, c.ReservedSlugs)
+}
+
+// ResolveAlias returns the domain whose links host serves: the alias target
+// when host is a configured alias, otherwise host itself.
+func (c *Config) ResolveAlias(host string) string {
+	if target, ok := c.DomainAliases[CanonicalDomain(host)]; ok {
+		return target
+	}
+	return host
+}
+
+// ServesHost reports whether requests for host should be answered, either
+// because it is an allowed domain or an alias of one. Certificates are
+// issued for exactly these hosts.
+func (c *Config) ServesHost(host string) bool {
+	if _, ok := c.DomainAliases[CanonicalDomain(host)]; ok {
+		return true
+	}
+	return c.IsDomainAllowed(host)
+}
+
+// parseDomainAliases decodes a comma-separated list of alias=target pairs,
+// e.g. "old.io=new.io,legacy.io=new.io". Targets must be configured domains
+// and aliases must not be, so every host resolves unambiguously.
+func parseDomainAliases(raw string, domains []string) (map[string]string, error) {
+	aliases := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		alias, target, ok := strings.Cut(pair, "=")
+		alias = CanonicalDomain(alias)
+		target = CanonicalDomain(target)
+		if !ok || alias == "" || target == "" {
+			return nil, fmt.Errorf("invalid entry %q, use the form alias=domain", pair)
+		}
+		if strings.Contains(alias, "*") || IsWildcard(target) {
+			return nil, fmt.Errorf("%q: wildcards can't be aliased", pair)
+		}
+		if !containsDomain(domains, target) {
+			return nil, fmt.Errorf("%q: target %q is not in DUBLY_DOMAINS", pair, target)
+		}
+		if containsDomain(domains, alias) {
+			return nil, fmt.Errorf("%q: alias %q is also in DUBLY_DOMAINS", pair, alias)
+		}
+		if _, dup := aliases[alias]; dup {
+			return nil, fmt.Errorf("alias %q listed twice", alias)
+		}
+		aliases[alias] = target
+	}
+	return aliases, nil
+}
+
+// parseDomainSettings decodes a JSON object keyed by domain, e.g.
+// {"go": {"scheme": "http", "case_insensitive": true}}.
+func parseDomainSettings(raw string, domains []string) (map[string]DomainSettings, error) {
+	settings := map[string]DomainSettings{}
+	if raw == "" {
+		return settings, nil
+	}
+
+	var decoded map[string]DomainSettings
+	if err := json.Unmarshal([]byte(raw), &decoded); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	for domain, s := range decoded {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if !containsFold(domains, domain) {
+			return nil, fmt.Errorf("%s is not in DUBLY_DOMAINS", domain)
+		}
+		if s.Scheme != "" && s.Scheme != "http" && s.Scheme != "https" {
+			return nil, fmt.Errorf("%s: scheme must be http or https", domain)
+		}
+		if s.Port < 0 || s.Port > 65535 {
+			return nil, fmt.Errorf("%s: port out of range", domain)
+		}
+		if s.SlugLength < 0 || s.SlugLength > 64 {
+			return nil, fmt.Errorf("%s: slug_length must be between 1 and 64, or 0 for the default", domain)
+		}
+		if s.SlugAlphabet != "" && len([]rune(s.SlugAlphabet)) < 2 {
+			return nil, fmt.Errorf("%s: slug_alphabet needs at least 2 characters", domain)
+		}
+		if s.SlugMode != "" && !slug.ValidMode(slug.Mode(s.SlugMode)) {
+			return nil, fmt.Errorf("%s: unknown slug_mode %q", domain, s.SlugMode)
+		}
+		settings[domain] = s
+	}
+	return settings, nil
+}
+
+// containsDomain reports whether list holds domain, comparing canonical forms.
+func containsDomain(list []string, domain string) bool {
+	domain = CanonicalDomain(domain)
+	for _, v := range list {
+		if CanonicalDomain(v) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
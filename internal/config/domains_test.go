@@ -0,0 +1,194 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad_DomainSettings(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DUBLY_PASSWORD", "secret")
+	t.Setenv("DUBLY_DOMAINS", "short.io,go")
+	t.Setenv("DUBLY_DOMAIN_SETTINGS", `{"Go": {"scheme": "http", "port": 8080, "case_insensitive": true, "slug_length": 4, "default_tags": "intranet"}}`)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := cfg.SettingsFor("GO")
+	if s.Scheme != "http" || s.Port != 8080 || !s.CaseInsensitive || s.SlugLength != 4 || s.DefaultTags != "intranet" {
+		t.Errorf("settings = %+v", s)
+	}
+}
+
+func TestLoad_DomainSettingsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"invalid json", `{`, "invalid JSON"},
+		{"unknown domain", `{"other.io": {}}`, "other.io is not in DUBLY_DOMAINS"},
+		{"bad scheme", `{"go": {"scheme": "ftp"}}`, "scheme must be http or https"},
+		{"bad port", `{"go": {"port": 70000}}`, "port out of range"},
+		{"bad length", `{"go": {"slug_length": 100}}`, "slug_length"},
+		{"negative length", `{"go": {"slug_length": -1}}`, "or 0 for the default"},
+		{"short alphabet", `{"go": {"slug_alphabet": "a"}}`, "slug_alphabet"},
+		{"bad slug mode", `{"go": {"slug_mode": "emoji"}}`, "unknown slug_mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("DUBLY_PASSWORD", "secret")
+			t.Setenv("DUBLY_DOMAINS", "go")
+			t.Setenv("DUBLY_DOMAIN_SETTINGS", tt.raw)
+
+			_, err := Load()
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsFor_Defaults(t *testing.T) {
+	cfg := &Config{Domains: []string{"short.io"}}
+	s := cfg.SettingsFor("short.io")
+	if s.Scheme != "https" {
+		t.Errorf("scheme = %q, want https", s.Scheme)
+	}
+	if s.SlugLength != 6 {
+		t.Errorf("slug length = %d, want 6", s.SlugLength)
+	}
+	if len(s.SlugAlphabet) != 62 {
+		t.Errorf("alphabet = %q, want Base62", s.SlugAlphabet)
+	}
+	if s.CaseInsensitive {
+		t.Error("expected case-sensitive matching by default")
+	}
+}
+
+func TestSettingsFor_CaseInsensitiveUsesLowercaseAlphabet(t *testing.T) {
+	cfg := &Config{DomainSettings: map[string]DomainSettings{"go": {CaseInsensitive: true}}}
+	s := cfg.SettingsFor("go")
+	if s.SlugAlphabet != strings.ToLower(s.SlugAlphabet) {
+		t.Errorf("alphabet = %q, want lowercase only", s.SlugAlphabet)
+	}
+}
+
+func TestShortURLBase(t *testing.T) {
+	cfg := &Config{DomainSettings: map[string]DomainSettings{
+		"go":        {Scheme: "http"},
+		"dev.local": {Scheme: "http", Port: 8080},
+	}}
+	tests := map[string]string{
+		"short.io":  "https://short.io",
+		"go":        "http://go",
+		"dev.local": "http://dev.local:8080",
+	}
+	for domain, want := range tests {
+		if got := cfg.ShortURLBase(domain); got != want {
+			t.Errorf("ShortURLBase(%q) = %q, want %q", domain, got, want)
+		}
+	}
+}
+
+func TestNormalizeSlug(t *testing.T) {
+	cfg := &Config{DomainSettings: map[string]DomainSettings{"go": {CaseInsensitive: true}}}
+	if got := cfg.NormalizeSlug("go", "Wiki"); got != "wiki" {
+		t.Errorf("NormalizeSlug(go) = %q, want %q", got, "wiki")
+	}
+	if got := cfg.NormalizeSlug("short.io", "Wiki"); got != "Wiki" {
+		t.Errorf("NormalizeSlug(short.io) = %q, want %q", got, "Wiki")
+	}
+}
+
+func TestSettingsFor_WildcardInherited(t *testing.T) {
+	cfg := &Config{
+		Domains:        []string{"*.links.example.com"},
+		DomainSettings: map[string]DomainSettings{"*.links.example.com": {SlugLength: 3}},
+	}
+	if s := cfg.SettingsFor("alice.links.example.com"); s.SlugLength != 3 {
+		t.Errorf("slug length = %d, want 3 from wildcard entry", s.SlugLength)
+	}
+}
+
+func TestLoad_DomainAliases(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DUBLY_PASSWORD", "secret")
+	t.Setenv("DUBLY_DOMAINS", "new.io,short.io")
+	t.Setenv("DUBLY_DOMAIN_ALIASES", "Old.io=new.io, legacy.io = new.io")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.ResolveAlias("OLD.IO"); got != "new.io" {
+		t.Errorf("ResolveAlias(OLD.IO) = %q, want new.io", got)
+	}
+	if got := cfg.ResolveAlias("short.io"); got != "short.io" {
+		t.Errorf("ResolveAlias(short.io) = %q, want short.io", got)
+	}
+	if !cfg.ServesHost("legacy.io") || !cfg.ServesHost("short.io") || cfg.ServesHost("other.io") {
+		t.Error("ServesHost should accept domains and aliases only")
+	}
+	if cfg.IsDomainAllowed("old.io") {
+		t.Error("links should not be created on an alias")
+	}
+}
+
+func TestLoad_DomainAliasErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing target":   "old.io",
+		"unknown target":   "old.io=other.io",
+		"alias is domain":  "short.io=new.io",
+		"wildcard alias":   "*.old.io=new.io",
+		"duplicate alias":  "old.io=new.io,old.io=short.io",
+		"empty alias name": "=new.io",
+	}
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("DUBLY_PASSWORD", "secret")
+			t.Setenv("DUBLY_DOMAINS", "new.io,short.io")
+			t.Setenv("DUBLY_DOMAIN_ALIASES", raw)
+			if _, err := Load(); err == nil || !strings.HasPrefix(err.Error(), "DUBLY_DOMAIN_ALIASES: ") {
+				t.Errorf("err = %v, want DUBLY_DOMAIN_ALIASES error", err)
+			}
+		})
+	}
+}
+
+func TestLoad_SlugMode(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DUBLY_PASSWORD", "secret")
+	t.Setenv("DUBLY_DOMAINS", "short.io,go")
+	t.Setenv("DUBLY_SLUG_MODE", "unambiguous")
+	t.Setenv("DUBLY_SLUG_BLOCKLIST", "acme, ,rival")
+	t.Setenv("DUBLY_DOMAIN_SETTINGS", `{"go": {"slug_mode": "words"}}`)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.SettingsFor("short.io").SlugMode; got != "unambiguous" {
+		t.Errorf("short.io slug mode = %q, want global unambiguous", got)
+	}
+	if got := cfg.SettingsFor("go").SlugMode; got != "words" {
+		t.Errorf("go slug mode = %q, want words", got)
+	}
+	if len(cfg.SlugBlocklist) != 2 {
+		t.Errorf("SlugBlocklist = %v, want 2 entries", cfg.SlugBlocklist)
+	}
+	if _, err := cfg.SlugGenerator("go"); err != nil {
+		t.Errorf("SlugGenerator: %v", err)
+	}
+
+	t.Setenv("DUBLY_SLUG_MODE", "emoji")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for unknown DUBLY_SLUG_MODE")
+	}
+}
@@ -1,10 +1,248 @@
 package db
 
-import "database/sql"
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/scmmishra/dubly/internal/tags"
+	"github.com/scmmishra/dubly/internal/urlnorm"
+	"github.com/scmmishra/dubly/internal/utm"
+)
 
 func Migrate(db *sql.DB) error {
-	_, err := db.Exec(schema)
-	return err
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+	for _, c := range addedColumns {
+		added, err := addColumnIfMissing(db, c.table, c.column, c.definition)
+		if err != nil {
+			return err
+		}
+		if added && c.backfill != "" {
+			if _, err := db.Exec(c.backfill); err != nil {
+				return fmt.Errorf("backfill %s.%s: %w", c.table, c.column, err)
+			}
+		}
+		if added && c.backfillFunc != nil {
+			if err := c.backfillFunc(db); err != nil {
+				return fmt.Errorf("backfill %s.%s: %w", c.table, c.column, err)
+			}
+		}
+	}
+	if _, err := db.Exec(columnSchema); err != nil {
+		return err
+	}
+	if err := createSearchIndex(db); err != nil {
+		return err
+	}
+	if err := backfillNormalizedDestinations(db); err != nil {
+		return err
+	}
+	return backfillLinkTags(db)
+}
+
+// createSearchIndex sets up full-text search over links. The index is built
+// from existing links only when it's first created; after that the triggers
+// in searchSchema keep it in step.
+func createSearchIndex(db *sql.DB) error {
+	var exists int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'links_fts'`).Scan(&exists); err != nil {
+		return fmt.Errorf("inspect search index: %w", err)
+	}
+	if _, err := db.Exec(searchSchema); err != nil {
+		return fmt.Errorf("create search index: %w", err)
+	}
+	if exists == 0 {
+		if _, err := db.Exec(`INSERT INTO links_fts(links_fts) VALUES ('rebuild')`); err != nil {
+			return fmt.Errorf("build search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// addedColumns are columns introduced after their table first shipped.
+// CREATE TABLE IF NOT EXISTS leaves existing tables untouched, so databases
+// created by older versions get them here, along with the backfill that
+// fills them in for existing rows: SQL, or a function for work that needs Go.
+var addedColumns = []struct {
+	table, column, definition string
+	backfill                  string
+	backfillFunc              func(*sql.DB) error
+}{
+	{"clicks", "alias_id", "INTEGER", "", nil},
+	{"links", "last_clicked_at", "DATETIME", "", nil},
+	{"links", "normalized_destination", "TEXT NOT NULL DEFAULT ''", "", nil},
+	{"links", "click_count", "INTEGER NOT NULL DEFAULT 0", `UPDATE links SET
+		click_count = (SELECT COUNT(*) FROM clicks WHERE link_id = links.id),
+		last_clicked_at = (SELECT MAX(clicked_at) FROM clicks WHERE link_id = links.id)`, nil},
+	{"links", "utm_source", "TEXT NOT NULL DEFAULT ''", "", nil},
+	{"links", "utm_medium", "TEXT NOT NULL DEFAULT ''", "", nil},
+	{"links", "utm_campaign", "TEXT NOT NULL DEFAULT ''", "", nil},
+	{"links", "utm_term", "TEXT NOT NULL DEFAULT ''", "", nil},
+	// The last UTM column fills in all five
+	{"links", "utm_content", "TEXT NOT NULL DEFAULT ''", "", backfillLinkUTM},
+}
+
+// addColumnIfMissing reports whether it had to add the column.
+func addColumnIfMissing(db *sql.DB, table, column, definition string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return false, fmt.Errorf("inspect %s: %w", table, err)
+		}
+		if name == column {
+			return false, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("inspect %s: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return false, fmt.Errorf("add %s.%s: %w", table, column, err)
+	}
+	return true, nil
+}
+
+// backfillLinkTags splits the tags column of links that predate the tags
+// tables into link_tags rows, rewriting the column in its normalized form.
+// Links that already have rows are skipped, so it only does work once.
+func backfillLinkTags(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, tags FROM links WHERE tags != '' AND id NOT IN (SELECT link_id FROM link_tags)`)
+	if err != nil {
+		return fmt.Errorf("find untagged links: %w", err)
+	}
+	pending := map[int64][]string{}
+	for rows.Next() {
+		var id int64
+		var raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan link tags: %w", err)
+		}
+		pending[id] = tags.Parse(raw)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("find untagged links: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tag backfill: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, names := range pending {
+		for _, name := range names {
+			if _, err := tx.Exec(`INSERT INTO tags (name) VALUES (?) ON CONFLICT(name) DO NOTHING`, name); err != nil {
+				return fmt.Errorf("insert tag: %w", err)
+			}
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO link_tags (link_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`, id, name); err != nil {
+				return fmt.Errorf("tag link %d: %w", id, err)
+			}
+		}
+		if _, err := tx.Exec(`UPDATE links SET tags = ? WHERE id = ?`, tags.Join(names), id); err != nil {
+			return fmt.Errorf("normalize link %d tags: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// backfillLinkUTM copies the utm_* parameters of existing destinations into
+// the UTM columns.
+func backfillLinkUTM(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, destination FROM links WHERE destination LIKE '%utm\_%' ESCAPE '\'`)
+	if err != nil {
+		return fmt.Errorf("find links with utm parameters: %w", err)
+	}
+	pending := map[int64]map[string]string{}
+	for rows.Next() {
+		var id int64
+		var dest string
+		if err := rows.Scan(&id, &dest); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan link destination: %w", err)
+		}
+		pending[id] = utm.Extract(dest)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("find links with utm parameters: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin utm backfill: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, v := range pending {
+		if _, err := tx.Exec(
+			`UPDATE links SET utm_source = ?, utm_medium = ?, utm_campaign = ?, utm_term = ?, utm_content = ? WHERE id = ?`,
+			v["utm_source"], v["utm_medium"], v["utm_campaign"], v["utm_term"], v["utm_content"], id,
+		); err != nil {
+			return fmt.Errorf("copy link %d utm parameters: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// backfillNormalizedDestinations fills in normalized_destination for links
+// saved before it existed. Normalizing happens in Go, so it can't be an
+// addedColumns backfill.
+func backfillNormalizedDestinations(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, destination FROM links WHERE normalized_destination = ''`)
+	if err != nil {
+		return fmt.Errorf("find unnormalized links: %w", err)
+	}
+	pending := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var dest string
+		if err := rows.Scan(&id, &dest); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan link destination: %w", err)
+		}
+		pending[id] = urlnorm.Normalize(dest)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("find unnormalized links: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin destination backfill: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, norm := range pending {
+		if _, err := tx.Exec(`UPDATE links SET normalized_destination = ? WHERE id = ?`, norm, id); err != nil {
+			return fmt.Errorf("normalize link %d destination: %w", id, err)
+		}
+	}
+	return tx.Commit()
 }
 
 const schema = `
@@ -19,6 +257,14 @@ CREATE TABLE IF NOT EXISTS links (
     is_active     INTEGER NOT NULL DEFAULT 1,
     created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    click_count   INTEGER NOT NULL DEFAULT 0,
+    last_clicked_at DATETIME,
+    normalized_destination TEXT NOT NULL DEFAULT '',
+    utm_source    TEXT    NOT NULL DEFAULT '',
+    utm_medium    TEXT    NOT NULL DEFAULT '',
+    utm_campaign  TEXT    NOT NULL DEFAULT '',
+    utm_term      TEXT    NOT NULL DEFAULT '',
+    utm_content   TEXT    NOT NULL DEFAULT '',
     UNIQUE(slug, domain)
 );
 
@@ -41,9 +287,170 @@ CREATE TABLE IF NOT EXISTS clicks (
     browser_version TEXT,
     os              TEXT,
     device_type     TEXT,
+    alias_id        INTEGER,
     FOREIGN KEY (link_id) REFERENCES links(id)
 );
 
 CREATE INDEX IF NOT EXISTS idx_clicks_link_id ON clicks(link_id);
 CREATE INDEX IF NOT EXISTS idx_clicks_clicked_at ON clicks(clicked_at);
+
+CREATE TABLE IF NOT EXISTS link_aliases (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    link_id    INTEGER NOT NULL,
+    slug       TEXT    NOT NULL,
+    domain     TEXT    NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(slug, domain),
+    FOREIGN KEY (link_id) REFERENCES links(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_aliases_link_id ON link_aliases(link_id);
+
+CREATE TABLE IF NOT EXISTS historical_clicks (
+    link_id     INTEGER PRIMARY KEY,
+    source      TEXT    NOT NULL DEFAULT '',
+    clicks      INTEGER NOT NULL,
+    imported_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (link_id) REFERENCES links(id)
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT    NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS link_tags (
+    link_id INTEGER NOT NULL,
+    tag_id  INTEGER NOT NULL,
+    PRIMARY KEY (link_id, tag_id),
+    FOREIGN KEY (link_id) REFERENCES links(id),
+    FOREIGN KEY (tag_id) REFERENCES tags(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_tags_tag_id ON link_tags(tag_id);
+
+CREATE TABLE IF NOT EXISTS campaigns (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    name         TEXT    NOT NULL UNIQUE,
+    starts_on    TEXT    NOT NULL DEFAULT '',
+    ends_on      TEXT    NOT NULL DEFAULT '',
+    utm_source   TEXT    NOT NULL DEFAULT '',
+    utm_medium   TEXT    NOT NULL DEFAULT '',
+    utm_campaign TEXT    NOT NULL DEFAULT '',
+    utm_term     TEXT    NOT NULL DEFAULT '',
+    utm_content  TEXT    NOT NULL DEFAULT '',
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS campaign_links (
+    link_id     INTEGER PRIMARY KEY,
+    campaign_id INTEGER NOT NULL,
+    FOREIGN KEY (link_id) REFERENCES links(id),
+    FOREIGN KEY (campaign_id) REFERENCES campaigns(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaign_links_campaign_id ON campaign_links(campaign_id);
+
+CREATE TABLE IF NOT EXISTS utm_presets (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    name         TEXT    NOT NULL UNIQUE COLLATE NOCASE,
+    utm_source   TEXT    NOT NULL DEFAULT '',
+    utm_medium   TEXT    NOT NULL DEFAULT '',
+    utm_campaign TEXT    NOT NULL DEFAULT '',
+    utm_term     TEXT    NOT NULL DEFAULT '',
+    utm_content  TEXT    NOT NULL DEFAULT '',
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS domain_checks (
+    domain          TEXT PRIMARY KEY,
+    ips             TEXT NOT NULL DEFAULT '',
+    dns_error       TEXT NOT NULL DEFAULT '',
+    tls_issuer      TEXT NOT NULL DEFAULT '',
+    tls_sans        TEXT NOT NULL DEFAULT '',
+    tls_not_after   DATETIME,
+    tls_valid       INTEGER NOT NULL DEFAULT 0,
+    tls_error       TEXT NOT NULL DEFAULT '',
+    checked_at      DATETIME NOT NULL
+);
+
+-- Links merged into another, so clicks still buffered for them are recorded
+-- against that link, through the alias that took over their slug
+CREATE TABLE IF NOT EXISTS merged_links (
+    link_id  INTEGER PRIMARY KEY,
+    into_id  INTEGER NOT NULL,
+    alias_id INTEGER NOT NULL
+);
+
+-- Responses to API requests made with an Idempotency-Key, replayed when
+-- the request is retried. status is 0 while the request is in progress.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key          TEXT PRIMARY KEY,
+    request_hash TEXT    NOT NULL,
+    status       INTEGER NOT NULL DEFAULT 0,
+    body         BLOB,
+    created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+`
+
+// columnSchema holds what depends on addedColumns, so it runs once they
+// exist.
+const columnSchema = `
+-- Keep each link's click counter and last click time current, so listings
+-- can sort on them without reading clicks
+CREATE TRIGGER IF NOT EXISTS clicks_count_insert AFTER INSERT ON clicks BEGIN
+    UPDATE links SET
+        click_count = click_count + 1,
+        last_clicked_at = CASE WHEN last_clicked_at IS NULL OR NEW.clicked_at > last_clicked_at THEN NEW.clicked_at ELSE last_clicked_at END
+    WHERE id = NEW.link_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS clicks_count_delete AFTER DELETE ON clicks BEGIN
+    UPDATE links SET
+        click_count = click_count - 1,
+        last_clicked_at = CASE WHEN OLD.clicked_at < last_clicked_at THEN last_clicked_at
+            ELSE (SELECT MAX(clicked_at) FROM clicks WHERE link_id = OLD.link_id) END
+    WHERE id = OLD.link_id;
+END;
+
+-- One per link listing sort, with id breaking ties for cursors
+CREATE INDEX IF NOT EXISTS idx_links_created_at ON links(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_links_updated_at ON links(updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_links_title ON links(title COLLATE NOCASE, id);
+CREATE INDEX IF NOT EXISTS idx_links_click_count ON links(click_count, id);
+CREATE INDEX IF NOT EXISTS idx_links_last_clicked_at ON links(IFNULL(last_clicked_at, ''), id);
+
+-- Finds an active link's duplicates: same domain, same normalized destination
+CREATE INDEX IF NOT EXISTS idx_links_normalized_destination ON links(domain, normalized_destination) WHERE is_active = 1;
+`
+
+// searchSchema indexes the text of links for search. links_fts holds no
+// copy of the text, only the index, so the triggers hand it old values to
+// remove and new ones to add.
+const searchSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS links_fts USING fts5(
+    slug, title, destination, tags, notes,
+    content = 'links', content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS links_fts_insert AFTER INSERT ON links BEGIN
+    INSERT INTO links_fts(rowid, slug, title, destination, tags, notes)
+    VALUES (NEW.id, NEW.slug, NEW.title, NEW.destination, NEW.tags, NEW.notes);
+END;
+
+CREATE TRIGGER IF NOT EXISTS links_fts_delete AFTER DELETE ON links BEGIN
+    INSERT INTO links_fts(links_fts, rowid, slug, title, destination, tags, notes)
+    VALUES ('delete', OLD.id, OLD.slug, OLD.title, OLD.destination, OLD.tags, OLD.notes);
+END;
+
+CREATE TRIGGER IF NOT EXISTS links_fts_update AFTER UPDATE OF slug, title, destination, tags, notes ON links BEGIN
+    INSERT INTO links_fts(links_fts, rowid, slug, title, destination, tags, notes)
+    VALUES ('delete', OLD.id, OLD.slug, OLD.title, OLD.destination, OLD.tags, OLD.notes);
+    INSERT INTO links_fts(rowid, slug, title, destination, tags, notes)
+    VALUES (NEW.id, NEW.slug, NEW.title, NEW.destination, NEW.tags, NEW.notes);
+END;
 `
@@ -0,0 +1,265 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestMigrate_AddsColumnsToOldTables(t *testing.T) {
+	d, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.SetMaxOpenConns(1)
+
+	// clicks as created before alias_id existed
+	if _, err := d.Exec(`CREATE TABLE clicks (id INTEGER PRIMARY KEY AUTOINCREMENT, link_id INTEGER NOT NULL, clicked_at DATETIME NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`INSERT INTO clicks (link_id, clicked_at) VALUES (1, CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Running twice must be a no-op the second time
+	for i := 0; i < 2; i++ {
+		if err := Migrate(d); err != nil {
+			t.Fatalf("migrate #%d: %v", i+1, err)
+		}
+	}
+
+	var aliasID sql.NullInt64
+	if err := d.QueryRow(`SELECT alias_id FROM clicks WHERE id = 1`).Scan(&aliasID); err != nil {
+		t.Fatal(err)
+	}
+	if aliasID.Valid {
+		t.Errorf("alias_id = %d on an existing row, want NULL", aliasID.Int64)
+	}
+}
+
+func TestMigrate_BackfillsLinkTags(t *testing.T) {
+	d, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.SetMaxOpenConns(1)
+
+	// links as created before the tags tables existed
+	if _, err := d.Exec(`CREATE TABLE links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, slug TEXT NOT NULL, domain TEXT NOT NULL, destination TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '', tags TEXT NOT NULL DEFAULT '', notes TEXT NOT NULL DEFAULT '',
+		is_active INTEGER NOT NULL DEFAULT 1, created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP, UNIQUE(slug, domain))`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`INSERT INTO links (slug, domain, destination, tags) VALUES
+		('a', 'd.co', 'https://example.com', 'Docs, features'),
+		('b', 'd.co', 'https://example.com', 'docs'),
+		('c', 'd.co', 'https://example.com', '')`); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := Migrate(d); err != nil {
+			t.Fatalf("migrate #%d: %v", i+1, err)
+		}
+	}
+
+	var stored string
+	if err := d.QueryRow(`SELECT tags FROM links WHERE slug = 'a'`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != "docs,features" {
+		t.Errorf("tags = %q, want normalized %q", stored, "docs,features")
+	}
+
+	var tagCount, linkTagCount int
+	d.QueryRow(`SELECT COUNT(*) FROM tags`).Scan(&tagCount)
+	d.QueryRow(`SELECT COUNT(*) FROM link_tags`).Scan(&linkTagCount)
+	if tagCount != 2 || linkTagCount != 3 {
+		t.Errorf("tags = %d, link_tags = %d, want 2 and 3", tagCount, linkTagCount)
+	}
+}
+
+func TestMigrate_BackfillsClickCounters(t *testing.T) {
+	d, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.SetMaxOpenConns(1)
+
+	// links and clicks as created before links kept click counters
+	if _, err := d.Exec(`CREATE TABLE links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, slug TEXT NOT NULL, domain TEXT NOT NULL, destination TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '', tags TEXT NOT NULL DEFAULT '', notes TEXT NOT NULL DEFAULT '',
+		is_active INTEGER NOT NULL DEFAULT 1, created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP, UNIQUE(slug, domain))`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`CREATE TABLE clicks (id INTEGER PRIMARY KEY AUTOINCREMENT, link_id INTEGER NOT NULL, clicked_at DATETIME NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`INSERT INTO links (slug, domain, destination) VALUES ('a', 'd.co', 'https://example.com'), ('b', 'd.co', 'https://example.com')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`INSERT INTO clicks (link_id, clicked_at) VALUES (1, '2024-01-01 00:00:00'), (1, '2024-02-01 00:00:00')`); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := Migrate(d); err != nil {
+			t.Fatalf("migrate #%d: %v", i+1, err)
+		}
+	}
+
+	// New clicks keep the backfilled counters current
+	if _, err := d.Exec(`INSERT INTO clicks (link_id, clicked_at) VALUES (1, '2024-03-01 00:00:00')`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		slug        string
+		clicks      int
+		lastClicked string
+	}{
+		{"a", 3, "2024-03-01 00:00:00"},
+		{"b", 0, ""},
+	}
+	for _, tt := range tests {
+		var clicks int
+		var lastClicked sql.NullString
+		if err := d.QueryRow(`SELECT click_count, CAST(last_clicked_at AS TEXT) FROM links WHERE slug = ?`, tt.slug).Scan(&clicks, &lastClicked); err != nil {
+			t.Fatal(err)
+		}
+		if clicks != tt.clicks || lastClicked.String != tt.lastClicked {
+			t.Errorf("%s: click_count = %d, last_clicked_at = %q; want %d, %q", tt.slug, clicks, lastClicked.String, tt.clicks, tt.lastClicked)
+		}
+	}
+}
+
+func TestMigrate_IndexesExistingLinksForSearch(t *testing.T) {
+	d, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.SetMaxOpenConns(1)
+
+	// links as created before search existed
+	if _, err := d.Exec(`CREATE TABLE links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, slug TEXT NOT NULL, domain TEXT NOT NULL, destination TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '', tags TEXT NOT NULL DEFAULT '', notes TEXT NOT NULL DEFAULT '',
+		is_active INTEGER NOT NULL DEFAULT 1, created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP, UNIQUE(slug, domain))`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`INSERT INTO links (slug, domain, destination, title) VALUES ('a', 'd.co', 'https://example.com', 'Pricing page')`); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := Migrate(d); err != nil {
+			t.Fatalf("migrate #%d: %v", i+1, err)
+		}
+	}
+	if _, err := d.Exec(`UPDATE links SET title = 'Plans' WHERE slug = 'a'`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"pricing", 0},
+		{"plans", 1},
+		{"example", 1},
+	}
+	for _, tt := range tests {
+		var n int
+		if err := d.QueryRow(`SELECT COUNT(*) FROM links_fts WHERE links_fts MATCH ?`, tt.query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != tt.want {
+			t.Errorf("%s: %d matches, want %d", tt.query, n, tt.want)
+		}
+	}
+}
+
+func TestMigrate_BackfillsNormalizedDestinations(t *testing.T) {
+	d, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.SetMaxOpenConns(1)
+
+	// links as created before destinations were normalized
+	if _, err := d.Exec(`CREATE TABLE links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, slug TEXT NOT NULL, domain TEXT NOT NULL, destination TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '', tags TEXT NOT NULL DEFAULT '', notes TEXT NOT NULL DEFAULT '',
+		is_active INTEGER NOT NULL DEFAULT 1, created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP, UNIQUE(slug, domain))`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`INSERT INTO links (slug, domain, destination) VALUES ('a', 'd.co', 'HTTPS://Example.com?b=2&a=1')`); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := Migrate(d); err != nil {
+			t.Fatalf("migrate #%d: %v", i+1, err)
+		}
+	}
+
+	var norm string
+	if err := d.QueryRow(`SELECT normalized_destination FROM links WHERE slug = 'a'`).Scan(&norm); err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://example.com/?a=1&b=2"; norm != want {
+		t.Errorf("normalized_destination = %q, want %q", norm, want)
+	}
+}
+
+func TestMigrate_BackfillsLinkUTM(t *testing.T) {
+	d, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.SetMaxOpenConns(1)
+
+	// links as created before UTM parameters had their own columns
+	if _, err := d.Exec(`CREATE TABLE links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, slug TEXT NOT NULL, domain TEXT NOT NULL, destination TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '', tags TEXT NOT NULL DEFAULT '', notes TEXT NOT NULL DEFAULT '',
+		is_active INTEGER NOT NULL DEFAULT 1, created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP, UNIQUE(slug, domain))`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`INSERT INTO links (slug, domain, destination) VALUES
+		('a', 'd.co', 'https://example.com/p?utm_source=news&utm_campaign=spring&x=1'),
+		('b', 'd.co', 'https://example.com/utmost')`); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := Migrate(d); err != nil {
+			t.Fatalf("migrate #%d: %v", i+1, err)
+		}
+	}
+
+	var source, medium, campaign string
+	if err := d.QueryRow(`SELECT utm_source, utm_medium, utm_campaign FROM links WHERE slug = 'a'`).Scan(&source, &medium, &campaign); err != nil {
+		t.Fatal(err)
+	}
+	if source != "news" || medium != "" || campaign != "spring" {
+		t.Errorf("utm columns = %q, %q, %q, want news, empty, spring", source, medium, campaign)
+	}
+	if err := d.QueryRow(`SELECT utm_source FROM links WHERE slug = 'b'`).Scan(&source); err != nil || source != "" {
+		t.Errorf("utm_source of a link without parameters = %q, %v", source, err)
+	}
+}
@@ -0,0 +1,185 @@
+package domaincheck
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+const checkTimeout = 10 * time.Second
+
+// Checker periodically resolves each domain and inspects the certificate it
+// serves on port 443, storing the results in the domain_checks table.
+type Checker struct {
+	db       *sql.DB
+	domains  []string
+	interval time.Duration
+
+	// lookupHost, addrFor and roots default to the system resolver, port 443
+	// and the system pool; tests override them to use local listeners.
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	addrFor    func(domain string) string
+	roots      *x509.CertPool
+
+	mu   sync.Mutex // serializes refreshes
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewChecker starts a background goroutine that checks all domains
+// immediately and again every interval.
+func NewChecker(db *sql.DB, domains []string, interval time.Duration) *Checker {
+	c := newChecker(db, domains, interval)
+	go c.run()
+	return c
+}
+
+func newChecker(db *sql.DB, domains []string, interval time.Duration) *Checker {
+	// Wildcard entries have no single host to resolve or connect to
+	var concrete []string
+	for _, d := range domains {
+		if !config.IsWildcard(d) {
+			concrete = append(concrete, d)
+		}
+	}
+
+	return &Checker{
+		db:       db,
+		domains:  concrete,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+
+		lookupHost: net.DefaultResolver.LookupHost,
+		addrFor:    func(domain string) string { return net.JoinHostPort(domain, "443") },
+	}
+}
+
+// Shutdown stops the background refresh and waits for it to finish.
+func (c *Checker) Shutdown() {
+	close(c.stop)
+	<-c.done
+}
+
+func (c *Checker) run() {
+	defer close(c.done)
+	c.Refresh()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Refresh()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Refresh checks every domain concurrently and stores the results.
+func (c *Checker) Refresh() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, d := range c.domains {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+			result := c.check(domain)
+			if err := models.UpsertDomainCheck(c.db, result); err != nil {
+				log.Printf("domaincheck: %s: %v", domain, err)
+			}
+		}(d)
+	}
+	wg.Wait()
+}
+
+func (c *Checker) check(domain string) *models.DomainCheck {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	result := &models.DomainCheck{
+		Domain:    domain,
+		IPs:       []string{},
+		TLSSANs:   []string{},
+		CheckedAt: time.Now().UTC(),
+	}
+
+	ips, err := c.lookupHost(ctx, domain)
+	if err != nil {
+		result.DNSError = err.Error()
+	} else {
+		result.IPs = ips
+	}
+
+	c.checkTLS(ctx, domain, result)
+	return result
+}
+
+// checkTLS records the certificate presented for domain. Verification is done
+// manually after the handshake so details are captured even for certificates
+// that would fail a normal handshake.
+func (c *Checker) checkTLS(ctx context.Context, domain string, result *models.DomainCheck) {
+	dialer := &tls.Dialer{Config: &tls.Config{
+		ServerName:         domain,
+		InsecureSkipVerify: true,
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addrFor(domain))
+	if err != nil {
+		result.TLSError = err.Error()
+		return
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		result.TLSError = "no certificate presented"
+		return
+	}
+
+	leaf := certs[0]
+	notAfter := leaf.NotAfter.UTC()
+	result.TLSIssuer = issuerName(leaf)
+	result.TLSSANs = leaf.DNSNames
+	if result.TLSSANs == nil {
+		result.TLSSANs = []string{}
+	}
+	result.TLSNotAfter = &notAfter
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName:       domain,
+		Roots:         c.roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		result.TLSError = err.Error()
+		return
+	}
+	result.TLSValid = true
+}
+
+func issuerName(cert *x509.Certificate) string {
+	if cert.Issuer.CommonName != "" {
+		if len(cert.Issuer.Organization) > 0 {
+			return fmt.Sprintf("%s (%s)", cert.Issuer.CommonName, cert.Issuer.Organization[0])
+		}
+		return cert.Issuer.CommonName
+	}
+	return cert.Issuer.String()
+}
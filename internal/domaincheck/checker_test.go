@@ -0,0 +1,255 @@
+package domaincheck
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/db"
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+// ── Helpers ─────────────────────────────────────────────────────────
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root", Organization: []string{"Dubly Test"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// serveTLS starts a local TLS listener presenting a leaf certificate for
+// names that expires at notAfter, and returns its address.
+func serveTLS(t *testing.T, ca *testCA, names []string, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// testChecker creates a Checker that dials addr for every domain,
+// with no background goroutine.
+func testChecker(t *testing.T, ca *testCA, addr string, domains ...string) *Checker {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	c := newChecker(database, domains, time.Hour)
+	c.lookupHost = func(_ context.Context, host string) ([]string, error) {
+		if host == "unresolved.test" {
+			return nil, errors.New("no such host")
+		}
+		return []string{"127.0.0.1"}, nil
+	}
+	c.addrFor = func(string) string { return addr }
+	c.roots = ca.pool
+	return c
+}
+
+// ── check ───────────────────────────────────────────────────────────
+
+func TestCheck_ValidCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	notAfter := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+	addr := serveTLS(t, ca, []string{"short.test", "www.short.test"}, notAfter)
+	c := testChecker(t, ca, addr, "short.test")
+
+	result := c.check("short.test")
+	if !result.TLSValid {
+		t.Fatalf("TLSValid = false, error = %q", result.TLSError)
+	}
+	if result.TLSIssuer != "Test Root (Dubly Test)" {
+		t.Errorf("issuer = %q, want %q", result.TLSIssuer, "Test Root (Dubly Test)")
+	}
+	if strings.Join(result.TLSSANs, ",") != "short.test,www.short.test" {
+		t.Errorf("SANs = %v", result.TLSSANs)
+	}
+	if result.TLSNotAfter == nil || !result.TLSNotAfter.Equal(notAfter) {
+		t.Errorf("not after = %v, want %v", result.TLSNotAfter, notAfter)
+	}
+	if len(result.IPs) != 1 || result.IPs[0] != "127.0.0.1" {
+		t.Errorf("IPs = %v, want [127.0.0.1]", result.IPs)
+	}
+	if result.ExpiresWithin(14 * 24 * time.Hour) {
+		t.Error("certificate valid for 90 days should not be expiring within 14 days")
+	}
+}
+
+func TestCheck_HostnameMismatch(t *testing.T) {
+	ca := newTestCA(t)
+	addr := serveTLS(t, ca, []string{"other.test"}, time.Now().Add(90*24*time.Hour))
+	c := testChecker(t, ca, addr, "short.test")
+
+	result := c.check("short.test")
+	if result.TLSValid {
+		t.Error("TLSValid = true for certificate issued to another host")
+	}
+	if result.TLSError == "" {
+		t.Error("expected TLS error for hostname mismatch")
+	}
+	if result.TLSIssuer == "" || result.TLSNotAfter == nil {
+		t.Error("certificate details should be recorded even when invalid")
+	}
+}
+
+func TestCheck_UntrustedIssuer(t *testing.T) {
+	ca := newTestCA(t)
+	addr := serveTLS(t, ca, []string{"short.test"}, time.Now().Add(90*24*time.Hour))
+	c := testChecker(t, newTestCA(t), addr, "short.test")
+
+	if result := c.check("short.test"); result.TLSValid {
+		t.Error("TLSValid = true for certificate from an untrusted CA")
+	}
+}
+
+func TestCheck_ExpiringSoon(t *testing.T) {
+	ca := newTestCA(t)
+	addr := serveTLS(t, ca, []string{"short.test"}, time.Now().Add(3*24*time.Hour))
+	c := testChecker(t, ca, addr, "short.test")
+
+	result := c.check("short.test")
+	if !result.TLSValid {
+		t.Fatalf("TLSValid = false, error = %q", result.TLSError)
+	}
+	if !result.ExpiresWithin(14 * 24 * time.Hour) {
+		t.Error("certificate expiring in 3 days should be flagged within 14 days")
+	}
+	if result.ExpiresWithin(24 * time.Hour) {
+		t.Error("certificate expiring in 3 days should not be flagged within 1 day")
+	}
+}
+
+func TestCheck_ConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := testChecker(t, newTestCA(t), addr, "unresolved.test")
+	result := c.check("unresolved.test")
+	if result.DNSError == "" {
+		t.Error("expected DNS error")
+	}
+	if result.TLSError == "" {
+		t.Error("expected TLS error for closed port")
+	}
+	if result.TLSNotAfter != nil {
+		t.Error("TLSNotAfter should be nil when no certificate was seen")
+	}
+}
+
+// ── Refresh ─────────────────────────────────────────────────────────
+
+func TestRefresh_StoresResults(t *testing.T) {
+	ca := newTestCA(t)
+	addr := serveTLS(t, ca, []string{"a.test", "b.test"}, time.Now().Add(30*24*time.Hour))
+	c := testChecker(t, ca, addr, "a.test", "b.test")
+
+	c.Refresh()
+
+	checks, err := models.ListDomainChecks(c.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("stored %d checks, want 2", len(checks))
+	}
+	for _, d := range []string{"a.test", "b.test"} {
+		check := checks[d]
+		if check == nil {
+			t.Fatalf("no stored check for %s", d)
+		}
+		if !check.TLSValid || check.TLSNotAfter == nil || len(check.TLSSANs) != 2 {
+			t.Errorf("%s: stored check = %+v", d, check)
+		}
+	}
+
+	// A second refresh overwrites rather than duplicates
+	c.Refresh()
+	checks, _ = models.ListDomainChecks(c.db)
+	if len(checks) != 2 {
+		t.Errorf("stored %d checks after second refresh, want 2", len(checks))
+	}
+}
+
+func TestNewChecker_SkipsWildcards(t *testing.T) {
+	c := newChecker(nil, []string{"short.test", "*.links.test"}, time.Hour)
+	if len(c.domains) != 1 || c.domains[0] != "short.test" {
+		t.Errorf("domains = %v, want [short.test]", c.domains)
+	}
+}
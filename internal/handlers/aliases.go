@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+type createAliasRequest struct {
+	Slug   string `json:"slug"`
+	Domain string `json:"domain"`
+}
+
+type aliasListResponse struct {
+	Aliases []models.LinkAlias `json:"aliases"`
+	// PrimaryClicks counts clicks through the link's own slug
+	PrimaryClicks int `json:"primary_clicks"`
+}
+
+// linkFromURL loads the link named by the {id} URL param, writing the error
+// response itself when it can't.
+func (h *LinkHandler) linkFromURL(w http.ResponseWriter, r *http.Request) (*models.Link, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		jsonError(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+	link := &models.Link{ID: id}
+	if err := models.GetLinkByID(h.DB, link); err != nil {
+		if err == sql.ErrNoRows {
+			jsonError(w, "not found", http.StatusNotFound)
+			return nil, false
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return link, true
+}
+
+// ListAliases returns a link's aliases with per-alias click counts.
+func (h *LinkHandler) ListAliases(w http.ResponseWriter, r *http.Request) {
+	link, ok := h.linkFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	aliases, err := models.ListLinkAliases(h.DB, link.ID)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	counts, err := models.ClicksByAliasForLink(h.DB, link)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := aliasListResponse{Aliases: aliases}
+	if resp.Aliases == nil {
+		resp.Aliases = []models.LinkAlias{}
+	}
+	for i := range resp.Aliases {
+		resp.Aliases[i].FillShortURL(h.Cfg.ShortURLBase)
+	}
+	for _, c := range counts {
+		if c.AliasID == 0 {
+			resp.PrimaryClicks = c.Count
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CreateAlias adds another slug for a link. The domain defaults to the
+// link's own; the slug shares its namespace with links on that domain.
+func (h *LinkHandler) CreateAlias(w http.ResponseWriter, r *http.Request) {
+	link, ok := h.linkFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var req createAliasRequest
+	if err := decodeJSON(r, &req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	domain := link.Domain
+	if req.Domain != "" {
+		domain = config.CanonicalDomain(req.Domain)
+		if !h.Cfg.IsDomainAllowed(domain) {
+			jsonError(w, "domain not allowed", http.StatusBadRequest)
+			return
+		}
+	}
+	s := h.Cfg.NormalizeSlug(domain, req.Slug)
+	if s == "" {
+		jsonError(w, "slug is required", http.StatusBadRequest)
+		return
+	}
+	if err := h.Cfg.SlugValidator().Validate(s); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slugExists := models.SlugExists
+	if h.Cfg.SettingsFor(domain).CaseInsensitive {
+		slugExists = models.SlugExistsFold
+	}
+	exists, err := slugExists(h.DB, s, domain)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if exists {
+		jsonError(w, "slug already exists for this domain", http.StatusConflict)
+		return
+	}
+
+	alias := &models.LinkAlias{LinkID: link.ID, Slug: s, Domain: domain}
+	if err := models.CreateLinkAlias(h.DB, alias); err != nil {
+		if isConstraintError(err) {
+			jsonError(w, "slug already exists for this domain", http.StatusConflict)
+			return
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	alias.FillShortURL(h.Cfg.ShortURLBase)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(alias)
+}
+
+// DeleteAlias removes one of a link's aliases. Clicks recorded through it
+// are kept.
+func (h *LinkHandler) DeleteAlias(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		jsonError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	aliasID, err := strconv.ParseInt(chi.URLParam(r, "aliasID"), 10, 64)
+	if err != nil {
+		jsonError(w, "invalid alias id", http.StatusBadRequest)
+		return
+	}
+
+	alias, err := models.DeleteLinkAlias(h.DB, id, aliasID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			jsonError(w, "not found", http.StatusNotFound)
+			return
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.Cache.Invalidate(alias.Domain, alias.Slug)
+
+	w.WriteHeader(http.StatusNoContent)
+}
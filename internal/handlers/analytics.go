@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+const (
+	defaultAnalyticsRange = 30 * 24 * time.Hour
+	defaultAnalyticsLimit = 100
+	maxAnalyticsLimit     = 1000
+	maxAnalyticsGroupBy   = 3
+)
+
+// AnalyticsHandler answers click analytics queries.
+type AnalyticsHandler struct {
+	DB *sql.DB
+}
+
+type analyticsRow struct {
+	Values map[models.Dimension]string `json:"values"`
+	Clicks int                         `json:"clicks"`
+	Series []models.ClickBucket        `json:"series,omitempty"`
+}
+
+type analyticsResponse struct {
+	From     time.Time          `json:"from"`
+	To       time.Time          `json:"to"`
+	Timezone string             `json:"timezone"`
+	Interval models.Interval    `json:"interval,omitempty"`
+	GroupBy  []models.Dimension `json:"group_by"`
+	Clicks   int                `json:"clicks"`
+	Rows     []analyticsRow     `json:"rows"`
+}
+
+// Query counts clicks in a time range, grouped by up to three dimensions
+// and optionally per interval. Every dimension is also a filter parameter
+// taking comma-separated values, e.g. ?group_by=country&tag=launch&device=mobile.
+func (h *AnalyticsHandler) Query(w http.ResponseWriter, r *http.Request) {
+	q, err := h.parseAnalyticsQuery(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	total, err := models.QueryClicks(h.DB, models.ClickQuery{From: q.From, To: q.To, Filters: q.Filters, Campaign: q.Campaign})
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	groups, err := models.QueryClicks(h.DB, q)
+	if errors.Is(err, models.ErrTooManyBuckets) {
+		jsonError(w, err.Error()+": use a longer interval or a shorter range", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := analyticsResponse{
+		From:     q.From.In(q.Location),
+		To:       q.To.In(q.Location),
+		Timezone: q.Location.String(),
+		Interval: q.Interval,
+		GroupBy:  q.GroupBy,
+		Clicks:   total[0].Clicks,
+		Rows:     make([]analyticsRow, 0, len(groups)),
+	}
+	if resp.GroupBy == nil {
+		resp.GroupBy = []models.Dimension{}
+	}
+	for _, g := range groups {
+		row := analyticsRow{Values: map[models.Dimension]string{}, Clicks: g.Clicks, Series: g.Series}
+		for i, d := range q.GroupBy {
+			row.Values[d] = g.Values[i]
+		}
+		resp.Rows = append(resp.Rows, row)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseAnalyticsQuery reads a query from request parameters. The range
+// defaults to the 30 days up to now, and dates are read in the timezone.
+func (h *AnalyticsHandler) parseAnalyticsQuery(params url.Values) (models.ClickQuery, error) {
+	q := models.ClickQuery{Location: time.UTC, Limit: defaultAnalyticsLimit, Filters: models.ClickFilters{}}
+
+	if tz := params.Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return q, badRequest(fmt.Sprintf("unknown timezone %q", tz))
+		}
+		q.Location = loc
+	}
+	var err error
+	if q.From, err = parseAnalyticsTime(params.Get("from"), q.Location, false); err != nil {
+		return q, badRequest("from: " + err.Error())
+	}
+	if q.To, err = parseAnalyticsTime(params.Get("to"), q.Location, true); err != nil {
+		return q, badRequest("to: " + err.Error())
+	}
+	if q.To == nil {
+		now := time.Now()
+		q.To = &now
+	}
+	if q.From == nil {
+		from := q.To.Add(-defaultAnalyticsRange)
+		q.From = &from
+	}
+	if !q.From.Before(*q.To) {
+		return q, badRequest("from must be before to")
+	}
+
+	if q.Interval, err = models.ParseInterval(params.Get("interval")); err != nil {
+		return q, badRequest(err.Error())
+	}
+	for _, name := range splitParam(params["group_by"]) {
+		d, err := models.ParseDimension(name)
+		if err != nil {
+			return q, badRequest("group_by: " + err.Error())
+		}
+		if slices.Contains(q.GroupBy, d) {
+			return q, badRequest(fmt.Sprintf("group_by: %s given twice", d))
+		}
+		q.GroupBy = append(q.GroupBy, d)
+	}
+	if len(q.GroupBy) > maxAnalyticsGroupBy {
+		return q, badRequest(fmt.Sprintf("group_by: at most %d dimensions", maxAnalyticsGroupBy))
+	}
+
+	for _, d := range models.Dimensions {
+		values := splitParam(params[string(d)])
+		if len(values) == 0 {
+			continue
+		}
+		for i, v := range values {
+			switch d {
+			case models.DimensionLink:
+				if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+					return q, badRequest(fmt.Sprintf("link: invalid id %q", v))
+				}
+			case models.DimensionDomain:
+				values[i] = config.CanonicalDomain(v)
+			}
+		}
+		q.Filters[d] = values
+	}
+	if v := params.Get("campaign"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return q, badRequest(fmt.Sprintf("campaign: invalid id %q", v))
+		}
+		q.Campaign = &models.Campaign{ID: id}
+		if err := models.GetCampaign(h.DB, q.Campaign); err == sql.ErrNoRows {
+			return q, badRequest(fmt.Sprintf("campaign %d not found", id))
+		} else if err != nil {
+			return q, err
+		}
+	}
+
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return q, badRequest("limit must be a positive integer")
+		}
+		q.Limit = min(n, maxAnalyticsLimit)
+	}
+	return q, nil
+}
+
+// parseAnalyticsTime reads a range bound given as RFC 3339 or as a date in
+// loc. A date upper bound covers the whole day.
+func parseAnalyticsTime(s string, loc *time.Location, upper bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q: use YYYY-MM-DD or RFC 3339", s)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// splitParam returns the comma-separated values of a repeatable parameter.
+func splitParam(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
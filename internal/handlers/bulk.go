@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/slug"
+)
+
+const (
+	maxBulkLinks    = 500
+	maxBulkBodySize = 8 << 20 // 8 MB
+)
+
+type bulkCreateRequest struct {
+	Atomic *bool               `json:"atomic"`
+	Links  []createLinkRequest `json:"links"`
+}
+
+type bulkUpdateItem struct {
+	ID int64 `json:"id"`
+	updateLinkRequest
+}
+
+type bulkUpdateRequest struct {
+	Atomic *bool            `json:"atomic"`
+	Links  []bulkUpdateItem `json:"links"`
+}
+
+type bulkResult struct {
+	Index  int          `json:"index"`
+	Status int          `json:"status"`
+	Link   *models.Link `json:"link,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+type bulkResponse struct {
+	Atomic    bool         `json:"atomic"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []bulkResult `json:"results"`
+}
+
+// bulkItem is one item's work within the batch transaction. It returns the
+// link as written, plus for updates the link as it was before.
+type bulkItem func(tx *sql.Tx) (link, old *models.Link, err error)
+
+// BulkCreate creates up to maxBulkLinks links in one transaction. By default
+// the batch is atomic: one failing item rolls all of them back. With
+// "atomic": false every valid item is created and failures are reported
+// alongside them.
+func (h *LinkHandler) BulkCreate(w http.ResponseWriter, r *http.Request) {
+	var req bulkCreateRequest
+	if err := decodeBulk(r, &req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if msg := checkBulkSize(len(req.Links)); msg != "" {
+		jsonError(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	batch, err := h.planSlugs(req.Links)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]bulkItem, len(req.Links))
+	for i := range req.Links {
+		item := &req.Links[i]
+		items[i] = func(tx *sql.Tx) (*models.Link, *models.Link, error) {
+			link, err := h.prepareCreate(tx, item, batch)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := models.CreateLinkTx(tx, link); err != nil {
+				return nil, nil, err
+			}
+			batch.use(link.Domain, link.Slug)
+			return link, nil, nil
+		}
+	}
+	h.runBulk(w, req.Atomic == nil || *req.Atomic, http.StatusCreated, items)
+}
+
+// bulkSlugs tracks which slugs a bulk create knows to be taken or free, so
+// its items reach the database only on a collision. A nil *bulkSlugs knows
+// nothing.
+type bulkSlugs struct {
+	cfg   *config.Config
+	known map[models.SlugRef]bool
+	// first holds a checked generated candidate for items without a slug
+	first map[*createLinkRequest]string
+}
+
+// planSlugs checks the slugs reqs ask for, and a generated candidate for
+// each request without one, in one query.
+func (h *LinkHandler) planSlugs(reqs []createLinkRequest) (*bulkSlugs, error) {
+	b := &bulkSlugs{
+		cfg:   h.Cfg,
+		known: make(map[models.SlugRef]bool),
+		first: make(map[*createLinkRequest]string),
+	}
+	validator := h.Cfg.SlugValidator()
+	var refs []models.SlugRef
+	planned := make(map[models.SlugRef]bool)
+	for i := range reqs {
+		req := &reqs[i]
+		// Leave invalid requests for prepareCreate to turn away
+		domain := config.CanonicalDomain(req.Domain)
+		if req.Destination == "" || !h.Cfg.IsDomainAllowed(domain) {
+			continue
+		}
+		s := h.Cfg.NormalizeSlug(domain, req.Slug)
+		if s == "" {
+			gen, err := h.Cfg.SlugGenerator(domain)
+			if err != nil {
+				continue
+			}
+			if s, err = gen.Generate(slug.Input{Title: req.Title}, 0); err != nil || validator.Validate(s) != nil {
+				continue
+			}
+			if planned[b.ref(domain, s)] {
+				continue
+			}
+			b.first[req] = s
+		}
+		ref := b.ref(domain, s)
+		if !planned[ref] {
+			planned[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	taken, err := models.TakenSlugs(h.DB, refs)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		b.known[ref] = taken[ref]
+	}
+	return b, nil
+}
+
+func (b *bulkSlugs) ref(domain, s string) models.SlugRef {
+	fold := b.cfg.SettingsFor(domain).CaseInsensitive
+	if fold {
+		s = strings.ToLower(s)
+	}
+	return models.SlugRef{Domain: domain, Slug: s, Fold: fold}
+}
+
+// lookup reports whether s is taken on domain, with ok false when b doesn't
+// know.
+func (b *bulkSlugs) lookup(domain, s string) (taken, ok bool) {
+	if b == nil {
+		return false, false
+	}
+	taken, ok = b.known[b.ref(domain, s)]
+	return taken, ok
+}
+
+// use records that the batch has taken s on domain.
+func (b *bulkSlugs) use(domain, s string) {
+	if b != nil {
+		b.known[b.ref(domain, s)] = true
+	}
+}
+
+// generator returns gen, starting from req's planned candidate if it has one.
+func (b *bulkSlugs) generator(req *createLinkRequest, gen slug.Generator) slug.Generator {
+	if b == nil || b.first[req] == "" {
+		return gen
+	}
+	return plannedGenerator{Generator: gen, first: b.first[req]}
+}
+
+// plannedGenerator offers a candidate chosen ahead of time before falling
+// back to its Generator.
+type plannedGenerator struct {
+	slug.Generator
+	first string
+}
+
+func (g plannedGenerator) Generate(in slug.Input, attempt int) (string, error) {
+	if attempt == 0 {
+		return g.first, nil
+	}
+	return g.Generator.Generate(in, attempt)
+}
+
+// BulkUpdate applies partial updates, each naming its link by id, with the
+// same transaction semantics as BulkCreate.
+func (h *LinkHandler) BulkUpdate(w http.ResponseWriter, r *http.Request) {
+	var req bulkUpdateRequest
+	if err := decodeBulk(r, &req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if msg := checkBulkSize(len(req.Links)); msg != "" {
+		jsonError(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	items := make([]bulkItem, len(req.Links))
+	for i := range req.Links {
+		item := &req.Links[i]
+		items[i] = func(tx *sql.Tx) (*models.Link, *models.Link, error) {
+			if item.ID == 0 {
+				return nil, nil, badRequest("id is required")
+			}
+			link := &models.Link{ID: item.ID}
+			if err := models.GetLinkByID(tx, link); err != nil {
+				if err == sql.ErrNoRows {
+					return nil, nil, &apiError{http.StatusNotFound, "not found"}
+				}
+				return nil, nil, err
+			}
+			old := *link
+			if err := h.prepareUpdate(tx, link, &item.updateLinkRequest); err != nil {
+				return nil, nil, err
+			}
+			if err := models.UpdateLinkTx(tx, link); err != nil {
+				return nil, nil, err
+			}
+			return link, &old, nil
+		}
+	}
+	h.runBulk(w, req.Atomic == nil || *req.Atomic, http.StatusOK, items)
+}
+
+// runBulk runs items in one transaction, each in its own savepoint so a
+// failure undoes only that item's writes. Atomic batches are rolled back as
+// a whole when any item fails. Cached copies of every written link are
+// dropped once the transaction commits.
+func (h *LinkHandler) runBulk(w http.ResponseWriter, atomic bool, okStatus int, items []bulkItem) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	resp := bulkResponse{Atomic: atomic, Results: make([]bulkResult, len(items))}
+	var changed []models.Link
+	for i, item := range items {
+		var link, old *models.Link
+		err := models.Savepoint(tx, func() error {
+			var err error
+			link, old, err = item(tx)
+			return err
+		})
+		if err != nil {
+			resp.Results[i] = bulkItemError(i, err)
+			resp.Failed++
+			continue
+		}
+		link.FillShortURL(h.Cfg.ShortURLBase)
+		resp.Results[i] = bulkResult{Index: i, Status: okStatus, Link: link}
+		resp.Succeeded++
+		changed = append(changed, *link)
+		if old != nil {
+			changed = append(changed, *old)
+		}
+	}
+
+	status := okStatus
+	switch {
+	case resp.Failed > 0 && atomic:
+		// Nothing is written; say so on the items that would have been
+		for i := range resp.Results {
+			if resp.Results[i].Error == "" {
+				resp.Results[i] = bulkResult{Index: i, Status: http.StatusFailedDependency, Error: "not applied: another item failed"}
+			}
+		}
+		resp.Succeeded = 0
+		status = http.StatusUnprocessableEntity
+	case resp.Failed > 0:
+		status = http.StatusMultiStatus
+	}
+
+	if resp.Succeeded > 0 {
+		if err := tx.Commit(); err != nil {
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		h.Cache.InvalidateLinks(h.DB, changed)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func bulkItemError(i int, err error) bulkResult {
+	ae, ok := err.(*apiError)
+	if !ok {
+		if isConstraintError(err) {
+			ae = errSlugTaken
+		} else {
+			ae = &apiError{http.StatusInternalServerError, "internal error"}
+		}
+	}
+	return bulkResult{Index: i, Status: ae.status, Error: ae.msg}
+}
+
+func checkBulkSize(n int) string {
+	if n == 0 {
+		return "links is required"
+	}
+	if n > maxBulkLinks {
+		return fmt.Sprintf("at most %d links per request", maxBulkLinks)
+	}
+	return ""
+}
+
+// decodeBulk is decodeJSON with room for a full batch.
+func decodeBulk(r *http.Request, dst any) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxBulkBodySize))
+	dec.DisallowUnknownFields()
+	return dec.Decode(dst)
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/scmmishra/dubly/internal/cache"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+const campaignBreakdownLimit = 10
+
+type CampaignHandler struct {
+	DB    *sql.DB
+	Cfg   *config.Config
+	Cache *cache.LinkCache
+}
+
+type campaignRequest struct {
+	Name        *string `json:"name"`
+	StartsOn    *string `json:"starts_on"`
+	EndsOn      *string `json:"ends_on"`
+	UTMSource   *string `json:"utm_source"`
+	UTMMedium   *string `json:"utm_medium"`
+	UTMCampaign *string `json:"utm_campaign"`
+	UTMTerm     *string `json:"utm_term"`
+	UTMContent  *string `json:"utm_content"`
+}
+
+// apply copies the fields present in the request onto c.
+func (req *campaignRequest) apply(c *models.Campaign) {
+	for _, f := range []struct {
+		src *string
+		dst *string
+	}{
+		{req.Name, &c.Name},
+		{req.StartsOn, &c.StartsOn},
+		{req.EndsOn, &c.EndsOn},
+		{req.UTMSource, &c.UTMSource},
+		{req.UTMMedium, &c.UTMMedium},
+		{req.UTMCampaign, &c.UTMCampaign},
+		{req.UTMTerm, &c.UTMTerm},
+		{req.UTMContent, &c.UTMContent},
+	} {
+		if f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+}
+
+type campaignListResponse struct {
+	Campaigns []models.CampaignSummary `json:"campaigns"`
+}
+
+type campaignStats struct {
+	Clicks    int                 `json:"clicks"`
+	Referrers []models.ValueCount `json:"referrers"`
+	Countries []models.ValueCount `json:"countries"`
+	Devices   []models.ValueCount `json:"devices"`
+}
+
+type campaignLink struct {
+	models.Link
+	Clicks int `json:"clicks"`
+}
+
+type campaignResponse struct {
+	models.Campaign
+	Status string         `json:"status"`
+	Links  []campaignLink `json:"links"`
+	Stats  campaignStats  `json:"stats"`
+}
+
+type attachLinksRequest struct {
+	LinkIDs []int64 `json:"link_ids"`
+}
+
+func (h *CampaignHandler) List(w http.ResponseWriter, r *http.Request) {
+	list, err := models.ListCampaigns(h.DB)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []models.CampaignSummary{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(campaignListResponse{Campaigns: list})
+}
+
+func (h *CampaignHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req campaignRequest
+	if err := decodeJSON(r, &req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	c := &models.Campaign{}
+	req.apply(c)
+	if err := c.Validate(); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := models.CreateCampaign(h.DB, c); err != nil {
+		if isConstraintError(err) {
+			jsonError(w, "campaign name already exists", http.StatusConflict)
+			return
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// Get returns a campaign with its member links and click breakdowns across
+// them, counting only clicks within the campaign's date range.
+func (h *CampaignHandler) Get(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.campaignFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	links, err := models.CampaignLinks(h.DB, c)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	resp := campaignResponse{Campaign: *c, Status: c.Status(time.Now().UTC()), Links: []campaignLink{}}
+	for _, lc := range links {
+		lc.Link.FillShortURL(h.Cfg.ShortURLBase)
+		resp.Links = append(resp.Links, campaignLink{Link: lc.Link, Clicks: lc.ClickCount})
+	}
+
+	stats := &resp.Stats
+	scope := models.CampaignClicks(c)
+	if stats.Clicks, err = models.ClicksForCampaign(h.DB, c); err == nil {
+		if stats.Referrers, err = models.TopValues(h.DB, scope, models.DimensionReferrer, campaignBreakdownLimit); err == nil {
+			if stats.Countries, err = models.TopValues(h.DB, scope, models.DimensionCountry, campaignBreakdownLimit); err == nil {
+				stats.Devices, err = models.TopValues(h.DB, scope, models.DimensionDevice, campaignBreakdownLimit)
+			}
+		}
+	}
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *CampaignHandler) Update(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.campaignFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var req campaignRequest
+	if err := decodeJSON(r, &req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.apply(c)
+	if err := c.Validate(); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := models.UpdateCampaign(h.DB, c); err != nil {
+		if isConstraintError(err) {
+			jsonError(w, "campaign name already exists", http.StatusConflict)
+			return
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+func (h *CampaignHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		jsonError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if err := models.DeleteCampaign(h.DB, id); err != nil {
+		if err == sql.ErrNoRows {
+			jsonError(w, "not found", http.StatusNotFound)
+			return
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AttachLinks adds links to the campaign and fills their missing UTM
+// parameters from the campaign's defaults.
+func (h *CampaignHandler) AttachLinks(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.campaignFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var req attachLinksRequest
+	if err := decodeJSON(r, &req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.LinkIDs) == 0 {
+		jsonError(w, "link_ids is required", http.StatusBadRequest)
+		return
+	}
+
+	attached, err := models.AttachLinks(h.DB, c, req.LinkIDs)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			jsonError(w, "link not found", http.StatusNotFound)
+			return
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.Cache.InvalidateLinks(h.DB, attached)
+
+	h.Get(w, r)
+}
+
+func (h *CampaignHandler) DetachLink(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		jsonError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	linkID, err := strconv.ParseInt(chi.URLParam(r, "linkID"), 10, 64)
+	if err != nil {
+		jsonError(w, "invalid link id", http.StatusBadRequest)
+		return
+	}
+	if err := models.DetachLink(h.DB, id, linkID); err != nil {
+		if err == sql.ErrNoRows {
+			jsonError(w, "not found", http.StatusNotFound)
+			return
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// campaignFromURL loads the campaign named by the {id} URL param, writing
+// the error response itself when it can't.
+func (h *CampaignHandler) campaignFromURL(w http.ResponseWriter, r *http.Request) (*models.Campaign, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		jsonError(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+	c := &models.Campaign{ID: id}
+	if err := models.GetCampaign(h.DB, c); err != nil {
+		if err == sql.ErrNoRows {
+			jsonError(w, "not found", http.StatusNotFound)
+			return nil, false
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return c, true
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/scmmishra/dubly/internal/cache"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+type DomainHandler struct {
+	DB    *sql.DB
+	Cfg   *config.Config
+	Cache *cache.LinkCache
+}
+
+type domainStatus struct {
+	Domain       string              `json:"domain"`
+	Check        *models.DomainCheck `json:"check"`
+	ExpiringSoon bool                `json:"expiring_soon"`
+}
+
+type domainsResponse struct {
+	Domains        []domainStatus    `json:"domains"`
+	Aliases        map[string]string `json:"aliases"`
+	CertWarnWindow string            `json:"cert_warn_window"`
+}
+
+type moveDomainRequest struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	DryRun bool   `json:"dry_run"`
+}
+
+type moveDomainResponse struct {
+	From       string                 `json:"from"`
+	To         string                 `json:"to"`
+	DryRun     bool                   `json:"dry_run"`
+	Links      int                    `json:"links"`
+	Moved      int                    `json:"moved"`
+	Collisions []models.MoveCollision `json:"collisions"`
+}
+
+// List returns the stored DNS/TLS check results for every configured
+// domain. Domains that haven't been checked yet have a null check.
+func (h *DomainHandler) List(w http.ResponseWriter, r *http.Request) {
+	checks, err := models.ListDomainChecks(h.DB)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	statuses := make([]domainStatus, 0, len(h.Cfg.Domains))
+	for _, d := range h.Cfg.Domains {
+		status := domainStatus{Domain: d, Check: checks[d]}
+		if status.Check != nil {
+			status.ExpiringSoon = status.Check.ExpiresWithin(h.Cfg.CertWarnWindow)
+		}
+		statuses = append(statuses, status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(domainsResponse{
+		Domains:        statuses,
+		Aliases:        h.Cfg.DomainAliases,
+		CertWarnWindow: h.Cfg.CertWarnWindow.String(),
+	})
+}
+
+// Move re-keys every link on one domain to another, keeping link IDs and
+// click history. Collisions are checked first; if there are any, nothing
+// moves and they are returned with a 409. dry_run only reports the plan.
+func (h *DomainHandler) Move(w http.ResponseWriter, r *http.Request) {
+	var req moveDomainRequest
+	if err := decodeJSON(r, &req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	from := config.CanonicalDomain(req.From)
+	to := config.CanonicalDomain(req.To)
+	if from == "" || to == "" {
+		jsonError(w, "from and to are required", http.StatusBadRequest)
+		return
+	}
+	if from == to {
+		jsonError(w, "from and to must differ", http.StatusBadRequest)
+		return
+	}
+	if !h.Cfg.IsDomainAllowed(to) {
+		jsonError(w, "domain not allowed", http.StatusBadRequest)
+		return
+	}
+
+	plan, err := models.PlanDomainMove(h.DB, from, to, h.Cfg.SettingsFor(to).CaseInsensitive)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := moveDomainResponse{
+		From:       from,
+		To:         to,
+		DryRun:     req.DryRun,
+		Links:      len(plan.Links),
+		Collisions: plan.Collisions,
+	}
+	if resp.Collisions == nil {
+		resp.Collisions = []models.MoveCollision{}
+	}
+
+	code := http.StatusOK
+	switch {
+	case len(plan.Collisions) > 0:
+		code = http.StatusConflict
+	case !req.DryRun:
+		if err := models.ApplyDomainMove(h.DB, plan); err != nil {
+			if errors.Is(err, models.ErrMoveCollisions) || isConstraintError(err) {
+				jsonError(w, "slug collision on target domain", http.StatusConflict)
+				return
+			}
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		for _, l := range plan.Links {
+			h.Cache.Invalidate(from, l.Slug)
+			h.Cache.Invalidate(to, plan.NewSlug(l))
+		}
+		h.Cache.InvalidateAliases(plan.Aliases)
+		resp.Moved = len(plan.Links)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -17,21 +19,29 @@ import (
 	"github.com/scmmishra/dubly/internal/db"
 	"github.com/scmmishra/dubly/internal/geo"
 	"github.com/scmmishra/dubly/internal/handlers"
+	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/server"
+	"github.com/scmmishra/dubly/internal/slug"
 )
 
 const testPassword = "test-secret"
 
 func setupRouter(t *testing.T) *chi.Mux {
+	t.Helper()
+	return setupRouterWithConfig(t, &config.Config{
+		Password:          testPassword,
+		Domains:           []string{"short.io"},
+		IdempotencyWindow: time.Hour,
+	})
+}
+
+func setupRouterWithConfig(t *testing.T, cfg *config.Config) *chi.Mux {
 	t.Helper()
 	database, err := db.Open(":memory:")
 	if err != nil {
 		t.Fatal(err)
 	}
-	cfg := &config.Config{
-		Password: testPassword,
-		Domains:  []string{"short.io"},
-	}
-	linkCache, err := cache.New(100)
+	linkCache, err := cache.New(100, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -42,18 +52,10 @@ func setupRouter(t *testing.T) *chi.Mux {
 		database.Close()
 	})
 
-	linkHandler := &handlers.LinkHandler{DB: database, Cfg: cfg, Cache: linkCache}
-	redirectHandler := &handlers.RedirectHandler{DB: database, Cache: linkCache, Collector: collector}
+	redirectHandler := &handlers.RedirectHandler{DB: database, Cfg: cfg, Cache: linkCache, Collector: collector}
 
 	r := chi.NewRouter()
-	r.Route("/api", func(r chi.Router) {
-		r.Use(handlers.AuthMiddleware(cfg.Password))
-		r.Post("/links", linkHandler.Create)
-		r.Get("/links", linkHandler.List)
-		r.Get("/links/{id}", linkHandler.Get)
-		r.Patch("/links/{id}", linkHandler.Update)
-		r.Delete("/links/{id}", linkHandler.Delete)
-	})
+	r.Mount("/api", server.APIRouter(database, cfg, linkCache))
 	r.NotFound(redirectHandler.ServeHTTP)
 	return r
 }
@@ -228,242 +230,2233 @@ func TestCreateLink_UnknownJSONField_Returns400(t *testing.T) {
 	}
 }
 
-// --- List tests ---
+// --- Dedupe tests ---
 
-func TestListLinks_DefaultPagination(t *testing.T) {
+func TestCreateLink_Dedupe(t *testing.T) {
 	r := setupRouter(t)
-	// Create 3 links
-	for i := range 3 {
-		body := fmt.Sprintf(`{"slug":"list%d","domain":"short.io","destination":"https://example.com"}`, i)
-		doRequest(r, authReq("POST", "/api/links", body))
+	id := createLink(t, r, "first", "short.io", "https://Example.com/page?b=2&a=1")
+
+	rr := doRequest(r, authReq("POST", "/api/links?dedupe=true", `{"domain":"short.io","destination":"https://example.com:443/page?a=1&b=2","slug":"second"}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200, body = %s", rr.Code, rr.Body.String())
+	}
+	var link models.Link
+	json.NewDecoder(rr.Body).Decode(&link)
+	if link.ID != id || link.Slug != "first" {
+		t.Errorf("got link %d %q, want the existing link %d", link.ID, link.Slug, id)
 	}
 
-	rr := doRequest(r, authReq("GET", "/api/links", ""))
+	// Without dedupe the same destination gets a new link
+	if rr := doRequest(r, authReq("POST", "/api/links", `{"domain":"short.io","destination":"https://example.com/page?a=1&b=2"}`)); rr.Code != http.StatusCreated {
+		t.Errorf("without dedupe: status = %d, want 201", rr.Code)
+	}
+}
+
+func TestCreateLink_DedupeCreatesWhenMissing(t *testing.T) {
+	r := setupRouterWithConfig(t, &config.Config{Password: testPassword, Domains: []string{"short.io", "other.io"}})
+	id := createLink(t, r, "first", "short.io", "https://example.com")
+	gone := createLink(t, r, "gone", "other.io", "https://example.com/deleted")
+	doRequest(r, authReq("DELETE", fmt.Sprintf("/api/links/%d", gone), ""))
+
+	tests := []struct {
+		name, body string
+	}{
+		{"other domain", `{"domain":"other.io","destination":"https://example.com"}`},
+		{"other destination", `{"domain":"short.io","destination":"https://example.com/other"}`},
+		{"only an inactive match", `{"domain":"other.io","destination":"https://example.com/deleted"}`},
+		{"different utm", `{"domain":"short.io","destination":"https://example.com","utm_source":"news"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := doRequest(r, authReq("POST", "/api/links?dedupe=1", tt.body))
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want 201, body = %s", rr.Code, rr.Body.String())
+			}
+			var link models.Link
+			json.NewDecoder(rr.Body).Decode(&link)
+			if link.ID == id {
+				t.Error("should have created a new link")
+			}
+		})
+	}
+
+	// The UTM parameters are part of the destination that's matched
+	rr := doRequest(r, authReq("POST", "/api/links?dedupe=true", `{"domain":"short.io","destination":"https://example.com/?utm_source=news"}`))
 	if rr.Code != http.StatusOK {
-		t.Fatalf("status = %d, want 200", rr.Code)
+		t.Errorf("utm match: status = %d, want 200", rr.Code)
 	}
+}
 
-	var resp map[string]any
-	json.NewDecoder(rr.Body).Decode(&resp)
-	if int(resp["limit"].(float64)) != 25 {
-		t.Errorf("limit = %v, want 25", resp["limit"])
+func TestCreateLink_DedupeInvalid(t *testing.T) {
+	r := setupRouter(t)
+	if rr := doRequest(r, authReq("POST", "/api/links?dedupe=maybe", `{"domain":"short.io","destination":"https://a.com"}`)); rr.Code != http.StatusBadRequest {
+		t.Errorf("dedupe=maybe: status = %d, want 400", rr.Code)
 	}
-	if int(resp["total"].(float64)) != 3 {
-		t.Errorf("total = %v, want 3", resp["total"])
+	if rr := doRequest(r, authReq("POST", "/api/links?dedupe=true", `{"domain":"nope.io","destination":"https://a.com"}`)); rr.Code != http.StatusBadRequest {
+		t.Errorf("disallowed domain: status = %d, want 400", rr.Code)
 	}
 }
 
-func TestListLinks_LimitCappedAt100(t *testing.T) {
+func TestPutLinkByDestination(t *testing.T) {
 	r := setupRouter(t)
-	rr := doRequest(r, authReq("GET", "/api/links?limit=999", ""))
-	if rr.Code != http.StatusOK {
-		t.Fatalf("status = %d, want 200", rr.Code)
+	body := `{"domain":"short.io","destination":"https://example.com/docs","title":"Docs"}`
+
+	first := doRequest(r, authReq("PUT", "/api/links/by-destination", body))
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first: status = %d, want 201, body = %s", first.Code, first.Body.String())
+	}
+	second := doRequest(r, authReq("PUT", "/api/links/by-destination", body))
+	if second.Code != http.StatusOK {
+		t.Fatalf("second: status = %d, want 200", second.Code)
+	}
+	var a, b models.Link
+	json.NewDecoder(first.Body).Decode(&a)
+	json.NewDecoder(second.Body).Decode(&b)
+	if a.ID != b.ID {
+		t.Errorf("second PUT returned link %d, want %d", b.ID, a.ID)
+	}
+	if second.Header().Get("ETag") == "" {
+		t.Error("response should carry an ETag")
 	}
+}
 
-	var resp map[string]any
-	json.NewDecoder(rr.Body).Decode(&resp)
-	if int(resp["limit"].(float64)) != 100 {
-		t.Errorf("limit = %v, want 100 (capped)", resp["limit"])
+// --- Idempotency tests ---
+
+func idempotentReq(key, body string) *http.Request {
+	req := authReq("POST", "/api/links", body)
+	req.Header.Set("Idempotency-Key", key)
+	return req
+}
+
+func TestCreateLink_IdempotencyKeyReplays(t *testing.T) {
+	r := setupRouter(t)
+	body := `{"domain":"short.io","destination":"https://example.com"}`
+
+	first := doRequest(r, idempotentReq("create-1", body))
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first: status = %d, body = %s", first.Code, first.Body.String())
+	}
+	retry := doRequest(r, idempotentReq("create-1", body))
+	if retry.Code != http.StatusCreated {
+		t.Fatalf("retry: status = %d, body = %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("retry should be marked Idempotent-Replayed")
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("retry body = %s, want %s", retry.Body.String(), first.Body.String())
+	}
+
+	var list struct {
+		Total int `json:"total"`
+	}
+	json.NewDecoder(doRequest(r, authReq("GET", "/api/links", "")).Body).Decode(&list)
+	if list.Total != 1 {
+		t.Errorf("total = %d, want 1 link after a retry", list.Total)
+	}
+
+	// A new key is a new request
+	if rr := doRequest(r, idempotentReq("create-2", body)); rr.Code != http.StatusCreated || rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("new key: status = %d, replayed = %q", rr.Code, rr.Header().Get("Idempotent-Replayed"))
 	}
 }
 
-// --- Get tests ---
+func TestCreateLink_IdempotencyKeyReusedForDifferentRequest(t *testing.T) {
+	r := setupRouter(t)
+	doRequest(r, idempotentReq("k", `{"domain":"short.io","destination":"https://a.com"}`))
+	rr := doRequest(r, idempotentReq("k", `{"domain":"short.io","destination":"https://b.com"}`))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want 422, body = %s", rr.Code, rr.Body.String())
+	}
+}
 
-func TestGetLink_NotFound(t *testing.T) {
+func TestCreateLink_IdempotencyKeyReplaysErrors(t *testing.T) {
 	r := setupRouter(t)
-	rr := doRequest(r, authReq("GET", "/api/links/99999", ""))
-	if rr.Code != http.StatusNotFound {
-		t.Errorf("status = %d, want 404", rr.Code)
+	createLink(t, r, "taken", "short.io", "https://a.com")
+	body := `{"slug":"taken","domain":"short.io","destination":"https://b.com"}`
+
+	first := doRequest(r, idempotentReq("k", body))
+	if first.Code != http.StatusConflict {
+		t.Fatalf("first: status = %d, want 409", first.Code)
+	}
+	retry := doRequest(r, idempotentReq("k", body))
+	if retry.Code != http.StatusConflict || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry: status = %d, replayed = %q, want a replayed 409", retry.Code, retry.Header().Get("Idempotent-Replayed"))
 	}
 }
 
-func TestGetLink_InvalidID(t *testing.T) {
+func TestCreateLink_IdempotencyKeyTooLong(t *testing.T) {
 	r := setupRouter(t)
-	rr := doRequest(r, authReq("GET", "/api/links/abc", ""))
+	rr := doRequest(r, idempotentReq(strings.Repeat("k", 256), `{"domain":"short.io","destination":"https://a.com"}`))
 	if rr.Code != http.StatusBadRequest {
 		t.Errorf("status = %d, want 400", rr.Code)
 	}
 }
 
-// --- Update tests ---
-
-func TestUpdateLink_PartialUpdate(t *testing.T) {
-	r := setupRouter(t)
-	id := createLink(t, r, "partial", "short.io", "https://old.com")
+// --- Bulk tests ---
 
-	// Create with title and tags via direct creation, then update only destination
-	// First, set title via update
-	body := `{"title":"My Title","tags":"tag1"}`
-	path := fmt.Sprintf("/api/links/%d", id)
-	doRequest(r, authReq("PATCH", path, body))
+type bulkResp struct {
+	Atomic    bool `json:"atomic"`
+	Succeeded int  `json:"succeeded"`
+	Failed    int  `json:"failed"`
+	Results   []struct {
+		Index  int    `json:"index"`
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+		Link   *struct {
+			ID          int64  `json:"id"`
+			Slug        string `json:"slug"`
+			Destination string `json:"destination"`
+		} `json:"link"`
+	} `json:"results"`
+}
 
-	// Now update only destination
-	body2 := `{"destination":"https://new.com"}`
-	rr := doRequest(r, authReq("PATCH", path, body2))
-	if rr.Code != http.StatusOK {
-		t.Fatalf("status = %d, want 200, body = %s", rr.Code, rr.Body.String())
+func doBulk(t *testing.T, r *chi.Mux, method, body string) (int, bulkResp) {
+	t.Helper()
+	rr := doRequest(r, authReq(method, "/api/links/bulk", body))
+	var resp bulkResp
+	if rr.Code != http.StatusBadRequest {
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
 	}
+	return rr.Code, resp
+}
 
-	var link map[string]any
-	json.NewDecoder(rr.Body).Decode(&link)
-	if link["destination"] != "https://new.com" {
-		t.Errorf("destination = %v, want %q", link["destination"], "https://new.com")
+func TestBulkCreate_Success(t *testing.T) {
+	r := setupRouter(t)
+	code, resp := doBulk(t, r, "POST", `{"links":[
+		{"domain":"short.io","slug":"one","destination":"https://example.com/1"},
+		{"domain":"short.io","destination":"https://example.com/2","utm_source":"cms"},
+		{"domain":"short.io","destination":"https://example.com/3"}
+	]}`)
+	if code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", code)
 	}
-	if link["title"] != "My Title" {
-		t.Errorf("title = %v, want %q (preserved)", link["title"], "My Title")
+	if !resp.Atomic || resp.Succeeded != 3 || resp.Failed != 0 {
+		t.Fatalf("resp = %+v", resp)
 	}
-	if link["tags"] != "tag1" {
-		t.Errorf("tags = %v, want %q (preserved)", link["tags"], "tag1")
+	if resp.Results[1].Link.Destination != "https://example.com/2?utm_source=cms" {
+		t.Errorf("destination = %q", resp.Results[1].Link.Destination)
+	}
+	if resp.Results[1].Link.Slug == resp.Results[2].Link.Slug {
+		t.Error("generated slugs collide")
+	}
+	if rr := redirectOn(r, "short.io", "/one"); rr.Code != http.StatusFound {
+		t.Errorf("redirect status = %d", rr.Code)
 	}
 }
 
-func TestUpdateLink_ClearsFieldWithEmptyString(t *testing.T) {
+func TestBulkCreate_AtomicRollsBack(t *testing.T) {
 	r := setupRouter(t)
-	// Create link then set title
-	body := `{"slug":"clear","domain":"short.io","destination":"https://example.com","title":"Has Title"}`
-	rr := doRequest(r, authReq("POST", "/api/links", body))
-	var created map[string]any
-	json.NewDecoder(rr.Body).Decode(&created)
-	id := int64(created["id"].(float64))
+	createLink(t, r, "taken", "short.io", "https://example.com")
 
-	// Clear title with empty string
-	clearBody := `{"title":""}`
-	path := fmt.Sprintf("/api/links/%d", id)
-	rr2 := doRequest(r, authReq("PATCH", path, clearBody))
-	if rr2.Code != http.StatusOK {
-		t.Fatalf("status = %d, want 200", rr2.Code)
+	code, resp := doBulk(t, r, "POST", `{"links":[
+		{"domain":"short.io","slug":"fresh","destination":"https://example.com"},
+		{"domain":"short.io","slug":"taken","destination":"https://example.com"},
+		{"domain":"short.io","slug":"dup","destination":"https://example.com"},
+		{"domain":"short.io","slug":"dup","destination":"https://example.com"}
+	]}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", code)
 	}
-
-	var updated map[string]any
-	json.NewDecoder(rr2.Body).Decode(&updated)
-	if updated["title"] != "" {
-		t.Errorf("title = %v, want empty string", updated["title"])
+	want := []int{http.StatusFailedDependency, http.StatusConflict, http.StatusFailedDependency, http.StatusConflict}
+	for i, res := range resp.Results {
+		if res.Status != want[i] || res.Error == "" || res.Link != nil {
+			t.Errorf("result %d = %+v, want status %d", i, res, want[i])
+		}
+	}
+	if rr := redirectOn(r, "short.io", "/fresh"); rr.Code != http.StatusNotFound {
+		t.Errorf("rolled back link redirect status = %d, want 404", rr.Code)
 	}
 }
 
-func TestUpdateLink_CacheInvalidatesOldKey(t *testing.T) {
+func TestBulkCreate_NonAtomicKeepsValidItems(t *testing.T) {
 	r := setupRouter(t)
-	id := createLink(t, r, "oldslug", "short.io", "https://example.com")
-
-	// Trigger redirect to cache the link
-	req := httptest.NewRequest("GET", "/oldslug", nil)
-	req.Host = "short.io"
-	rr := doRequest(r, req)
-	if rr.Code != http.StatusFound {
-		t.Fatalf("initial redirect: status = %d, want 302", rr.Code)
+	code, resp := doBulk(t, r, "POST", `{"atomic":false,"links":[
+		{"domain":"short.io","slug":"ok","destination":"https://example.com"},
+		{"domain":"nope.io","destination":"https://example.com"},
+		{"domain":"short.io","destination":""}
+	]}`)
+	if code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want 207", code)
 	}
-
-	// Update slug from "oldslug" to "newslug"
-	body := fmt.Sprintf(`{"slug":"newslug"}`)
-	path := fmt.Sprintf("/api/links/%d", id)
-	rr2 := doRequest(r, authReq("PATCH", path, body))
-	if rr2.Code != http.StatusOK {
-		t.Fatalf("update: status = %d, want 200, body = %s", rr2.Code, rr2.Body.String())
+	if resp.Succeeded != 1 || resp.Failed != 2 {
+		t.Errorf("resp = %+v", resp)
 	}
-
-	// Old slug should now 404 (cache invalidated)
-	req2 := httptest.NewRequest("GET", "/oldslug", nil)
-	req2.Host = "short.io"
-	rr3 := doRequest(r, req2)
-	if rr3.Code != http.StatusNotFound {
-		t.Errorf("old slug after update: status = %d, want 404", rr3.Code)
+	if resp.Results[1].Status != http.StatusBadRequest || resp.Results[1].Error != "domain not allowed" {
+		t.Errorf("result 1 = %+v", resp.Results[1])
 	}
-
-	// New slug should redirect
-	req3 := httptest.NewRequest("GET", "/newslug", nil)
-	req3.Host = "short.io"
-	rr4 := doRequest(r, req3)
-	if rr4.Code != http.StatusFound {
-		t.Errorf("new slug: status = %d, want 302", rr4.Code)
+	if rr := redirectOn(r, "short.io", "/ok"); rr.Code != http.StatusFound {
+		t.Errorf("redirect status = %d, want 302", rr.Code)
 	}
 }
 
-// --- Delete tests ---
+func TestBulkCreate_Limits(t *testing.T) {
+	r := setupRouter(t)
+	if code, _ := doBulk(t, r, "POST", `{"links":[]}`); code != http.StatusBadRequest {
+		t.Errorf("empty batch: status = %d, want 400", code)
+	}
+	items := strings.Repeat(`{"domain":"short.io","destination":"https://example.com"},`, 501)
+	if code, _ := doBulk(t, r, "POST", `{"links":[`+strings.TrimSuffix(items, ",")+`]}`); code != http.StatusBadRequest {
+		t.Errorf("oversized batch: status = %d, want 400", code)
+	}
+}
 
-func TestDeleteLink_Returns204(t *testing.T) {
+func TestBulkUpdate_InvalidatesCache(t *testing.T) {
 	r := setupRouter(t)
-	id := createLink(t, r, "todelete", "short.io", "https://example.com")
+	a := createLink(t, r, "a", "short.io", "https://example.com/a")
+	b := createLink(t, r, "b", "short.io", "https://example.com/b")
+	redirectOn(r, "short.io", "/a")
+	redirectOn(r, "short.io", "/b")
 
-	path := fmt.Sprintf("/api/links/%d", id)
-	rr := doRequest(r, authReq("DELETE", path, ""))
-	if rr.Code != http.StatusNoContent {
-		t.Errorf("status = %d, want 204", rr.Code)
+	code, resp := doBulk(t, r, "PATCH", fmt.Sprintf(`{"links":[
+		{"id":%d,"destination":"https://example.com/a2"},
+		{"id":%d,"slug":"b2"}
+	]}`, a, b))
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; resp = %+v", code, resp)
 	}
-	if rr.Body.Len() != 0 {
-		t.Errorf("body = %q, want empty", rr.Body.String())
+	if loc := redirectOn(r, "short.io", "/a").Header().Get("Location"); loc != "https://example.com/a2" {
+		t.Errorf("Location = %q, want the new destination", loc)
+	}
+	if rr := redirectOn(r, "short.io", "/b"); rr.Code != http.StatusNotFound {
+		t.Errorf("old slug status = %d, want 404", rr.Code)
+	}
+	if rr := redirectOn(r, "short.io", "/b2"); rr.Code != http.StatusFound {
+		t.Errorf("new slug status = %d, want 302", rr.Code)
 	}
 }
 
-func TestDeleteLink_NotFound(t *testing.T) {
+func TestBulkUpdate_ReportsEachFailure(t *testing.T) {
 	r := setupRouter(t)
-	rr := doRequest(r, authReq("DELETE", "/api/links/99999", ""))
-	if rr.Code != http.StatusNotFound {
-		t.Errorf("status = %d, want 404", rr.Code)
+	a := createLink(t, r, "a", "short.io", "https://example.com/a")
+	createLink(t, r, "b", "short.io", "https://example.com/b")
+
+	code, resp := doBulk(t, r, "PATCH", fmt.Sprintf(`{"links":[
+		{"id":%d,"slug":"b"},
+		{"id":999,"title":"missing"}
+	]}`, a))
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", code)
+	}
+	if resp.Results[0].Status != http.StatusConflict || resp.Results[1].Status != http.StatusNotFound {
+		t.Errorf("results = %+v", resp.Results)
 	}
 }
 
-// --- Redirect tests ---
+// --- Export/import tests ---
 
-func TestRedirect_Success(t *testing.T) {
-	r := setupRouter(t)
-	createLink(t, r, "go", "short.io", "https://example.com")
+type importSummary struct {
+	DryRun bool `json:"dry_run"`
+	Links  struct {
+		Created   int `json:"created"`
+		Unchanged int `json:"unchanged"`
+		Skipped   int `json:"skipped"`
+		Renamed   int `json:"renamed"`
+		Failed    int `json:"failed"`
+	} `json:"links"`
+	Clicks struct {
+		Imported int `json:"imported"`
+	} `json:"clicks"`
+	Errors []struct {
+		Line  int    `json:"line"`
+		Error string `json:"error"`
+	} `json:"errors"`
+}
 
-	req := httptest.NewRequest("GET", "/go", nil)
-	req.Host = "short.io"
+func doImport(t *testing.T, r *chi.Mux, query, contentType, body string) (int, importSummary) {
+	t.Helper()
+	req := authReq("POST", "/api/import"+query, body)
+	req.Header.Set("Content-Type", contentType)
 	rr := doRequest(r, req)
-	if rr.Code != http.StatusFound {
-		t.Errorf("status = %d, want 302", rr.Code)
+	var s importSummary
+	if rr.Code == http.StatusOK {
+		if err := json.NewDecoder(rr.Body).Decode(&s); err != nil {
+			t.Fatal(err)
+		}
 	}
-	if loc := rr.Header().Get("Location"); loc != "https://example.com" {
-		t.Errorf("Location = %q, want %q", loc, "https://example.com")
+	return rr.Code, s
+}
+
+func TestExport_CSV(t *testing.T) {
+	r := setupRouter(t)
+	createLink(t, r, "a", "short.io", "https://example.com/a")
+	createLink(t, r, "b", "short.io", "https://example.com/b")
+
+	rr := doRequest(r, authReq("GET", "/api/export?format=csv", ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); !strings.Contains(cd, ".csv") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "type,domain,slug,destination") {
+		t.Errorf("export = %q", rr.Body.String())
 	}
 }
 
-func TestRedirect_EmptySlug_Returns404(t *testing.T) {
+func TestExport_BadFormat(t *testing.T) {
 	r := setupRouter(t)
-	req := httptest.NewRequest("GET", "/", nil)
-	req.Host = "short.io"
-	rr := doRequest(r, req)
-	if rr.Code != http.StatusNotFound {
-		t.Errorf("status = %d, want 404", rr.Code)
+	rr := doRequest(r, authReq("GET", "/api/export?format=xml", ""))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rr.Code)
 	}
 }
 
-func TestRedirect_UnknownSlug_Returns404(t *testing.T) {
+func TestImport_RoundTripBetweenInstances(t *testing.T) {
+	src := setupRouter(t)
+	createLink(t, src, "a", "short.io", "https://example.com/a")
+	createLink(t, src, "b", "short.io", "https://example.com/b")
+	rr := doRequest(src, authReq("GET", "/api/export?format=ndjson&include=clicks", ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export status = %d", rr.Code)
+	}
+	export := rr.Body.String()
+
+	dst := setupRouter(t)
+	code, s := doImport(t, dst, "?dry_run=true", "application/x-ndjson", export)
+	if code != http.StatusOK || !s.DryRun || s.Links.Created != 2 {
+		t.Fatalf("dry run: status = %d, summary = %+v", code, s)
+	}
+	if rr := redirectOn(dst, "short.io", "/a"); rr.Code != http.StatusNotFound {
+		t.Fatalf("dry run wrote links: redirect status = %d", rr.Code)
+	}
+
+	code, s = doImport(t, dst, "", "application/x-ndjson", export)
+	if code != http.StatusOK || s.Links.Created != 2 {
+		t.Fatalf("import: status = %d, summary = %+v", code, s)
+	}
+	if rr := redirectOn(dst, "short.io", "/a"); rr.Code != http.StatusFound {
+		t.Errorf("redirect status = %d, want 302", rr.Code)
+	}
+
+	// Importing the same file again changes nothing
+	code, s = doImport(t, dst, "", "application/x-ndjson", export)
+	if code != http.StatusOK || s.Links.Created != 0 || s.Links.Unchanged != 2 {
+		t.Errorf("re-import: status = %d, summary = %+v", code, s)
+	}
+}
+
+func TestImport_RenameAndLineErrors(t *testing.T) {
 	r := setupRouter(t)
-	req := httptest.NewRequest("GET", "/nonexistent", nil)
-	req.Host = "short.io"
-	rr := doRequest(r, req)
-	if rr.Code != http.StatusNotFound {
-		t.Errorf("status = %d, want 404", rr.Code)
+	createLink(t, r, "taken", "short.io", "https://example.com/old")
+
+	body := "type,domain,slug,destination\n" +
+		"link,short.io,taken,https://example.com/new\n" +
+		"link,nope.io,x,https://example.com/x\n"
+	code, s := doImport(t, r, "?conflict=rename", "text/csv", body)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if s.Links.Renamed != 1 || s.Links.Failed != 1 {
+		t.Errorf("summary = %+v", s)
+	}
+	if len(s.Errors) != 1 || s.Errors[0].Line != 3 {
+		t.Errorf("errors = %+v", s.Errors)
+	}
+	if rr := redirectOn(r, "short.io", "/taken"); rr.Header().Get("Location") != "https://example.com/old" {
+		t.Errorf("existing link changed: Location = %q", rr.Header().Get("Location"))
 	}
 }
 
-func TestRedirect_InactiveLink_Returns410(t *testing.T) {
+func TestImport_FromOtherShorteners(t *testing.T) {
 	r := setupRouter(t)
-	id := createLink(t, r, "inactive", "short.io", "https://example.com")
+	body := "Bitlink,Long URL,Title,Clicks\nhttps://bit.ly/launch,https://example.com/launch,Launch,12\n"
 
-	// Soft delete
-	path := fmt.Sprintf("/api/links/%d", id)
-	doRequest(r, authReq("DELETE", path, ""))
+	code, s := doImport(t, r, "?format=bitly", "text/csv", body)
+	if code != http.StatusOK || s.Links.Failed != 1 {
+		t.Fatalf("without a domain: status = %d, summary = %+v", code, s)
+	}
 
-	// Redirect should return 410
-	req := httptest.NewRequest("GET", "/inactive", nil)
-	req.Host = "short.io"
-	rr := doRequest(r, req)
-	if rr.Code != http.StatusGone {
-		t.Errorf("status = %d, want 410", rr.Code)
+	code, s = doImport(t, r, "?format=bitly&domain=short.io&historical_clicks=true", "text/csv", body)
+	if code != http.StatusOK || s.Links.Created != 1 {
+		t.Fatalf("status = %d, summary = %+v", code, s)
+	}
+	if rr := redirectOn(r, "short.io", "/launch"); rr.Header().Get("Location") != "https://example.com/launch" {
+		t.Errorf("Location = %q", rr.Header().Get("Location"))
+	}
+
+	code, s = doImport(t, r, "?format=bitly&domain=short.io", "text/csv", body)
+	if code != http.StatusOK || s.Links.Unchanged != 1 {
+		t.Errorf("re-import: status = %d, summary = %+v", code, s)
+	}
+
+	if code, _ := doImport(t, r, "?format=yourls", "text/plain", "INSERT INTO yourls_url VALUES ('a','https://example.com','',NULL,'',0);"); code != http.StatusBadRequest {
+		t.Errorf("yourls without a domain: status = %d, want 400", code)
 	}
 }
 
-func TestRedirect_HostNormalizedToLowercase(t *testing.T) {
+func TestImport_BadInput(t *testing.T) {
 	r := setupRouter(t)
-	createLink(t, r, "hosttest", "short.io", "https://example.com")
+	tests := []struct {
+		name, query, contentType, body string
+	}{
+		{"unknown conflict policy", "?conflict=merge", "text/csv", "type,domain,slug,destination\n"},
+		{"unknown format", "?format=xml", "text/csv", "type,domain,slug,destination\n"},
+		{"missing destination column", "", "text/csv", "type,domain,slug\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := doImport(t, r, tt.query, tt.contentType, tt.body); code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", code)
+			}
+		})
+	}
+}
 
-	req := httptest.NewRequest("GET", "/hosttest", nil)
-	req.Host = "SHORT.IO"
-	rr := doRequest(r, req)
-	if rr.Code != http.StatusFound {
-		t.Errorf("status = %d, want 302 (host normalization)", rr.Code)
+// --- List tests ---
+
+func TestListLinks_DefaultPagination(t *testing.T) {
+	r := setupRouter(t)
+	// Create 3 links
+	for i := range 3 {
+		body := fmt.Sprintf(`{"slug":"list%d","domain":"short.io","destination":"https://example.com"}`, i)
+		doRequest(r, authReq("POST", "/api/links", body))
+	}
+
+	rr := doRequest(r, authReq("GET", "/api/links", ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rr.Code)
+	}
+
+	var resp map[string]any
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if int(resp["limit"].(float64)) != 25 {
+		t.Errorf("limit = %v, want 25", resp["limit"])
+	}
+	if int(resp["total"].(float64)) != 3 {
+		t.Errorf("total = %v, want 3", resp["total"])
+	}
+}
+
+func TestListLinks_LimitCappedAt100(t *testing.T) {
+	r := setupRouter(t)
+	rr := doRequest(r, authReq("GET", "/api/links?limit=999", ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rr.Code)
+	}
+
+	var resp map[string]any
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if int(resp["limit"].(float64)) != 100 {
+		t.Errorf("limit = %v, want 100 (capped)", resp["limit"])
+	}
+}
+
+func TestListLinks_CursorPagesInSortOrder(t *testing.T) {
+	r := setupRouter(t)
+	for _, title := range []string{"Charlie", "alpha", "bravo"} {
+		body := fmt.Sprintf(`{"slug":"%s","domain":"short.io","destination":"https://example.com","title":"%s"}`, strings.ToLower(title), title)
+		doRequest(r, authReq("POST", "/api/links", body))
+	}
+
+	var titles []string
+	path := "/api/links?sort=title&limit=2"
+	for range 3 {
+		rr := doRequest(r, authReq("GET", path, ""))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			Links      []models.Link `json:"links"`
+			Total      int           `json:"total"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		for _, l := range resp.Links {
+			titles = append(titles, l.Title)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		path = "/api/links?sort=title&limit=2&cursor=" + resp.NextCursor
+	}
+	if got := strings.Join(titles, ","); got != "alpha,bravo,Charlie" {
+		t.Errorf("titles = %s, want alpha,bravo,Charlie", got)
+	}
+}
+
+func TestListLinks_StatusAndDateFilters(t *testing.T) {
+	r := setupRouter(t)
+	createLink(t, r, "keep", "short.io", "https://example.com")
+	id := createLink(t, r, "gone", "short.io", "https://example.com")
+	doRequest(r, authReq("DELETE", fmt.Sprintf("/api/links/%d", id), ""))
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"status=active", 1},
+		{"status=deleted", 1},
+		{"status=all", 2},
+		{"created_after=2000-01-01", 2},
+		{"created_before=2000-01-01", 0},
+		{"last_clicked_after=2000-01-01T00:00:00Z", 0},
+		{"domain=SHORT.IO", 2},
+	}
+	for _, tt := range tests {
+		rr := doRequest(r, authReq("GET", "/api/links?"+tt.query, ""))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", tt.query, rr.Code, rr.Body.String())
+		}
+		var resp map[string]any
+		json.NewDecoder(rr.Body).Decode(&resp)
+		if int(resp["total"].(float64)) != tt.want {
+			t.Errorf("%s: total = %v, want %d", tt.query, resp["total"], tt.want)
+		}
+	}
+}
+
+func TestListLinks_SearchRanksMatches(t *testing.T) {
+	r := setupRouter(t)
+	doRequest(r, authReq("POST", "/api/links", `{"slug":"docs-home","domain":"short.io","destination":"https://example.com/start"}`))
+	doRequest(r, authReq("POST", "/api/links", `{"slug":"pricing","domain":"short.io","destination":"https://example.com/docs/pricing"}`))
+	doRequest(r, authReq("POST", "/api/links", `{"slug":"blog","domain":"short.io","destination":"https://example.com/blog"}`))
+
+	rr := doRequest(r, authReq("GET", "/api/links?search=doc", ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Links []models.Link `json:"links"`
+		Total int           `json:"total"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Total != 2 || len(resp.Links) != 2 || resp.Links[0].Slug != "docs-home" {
+		t.Errorf("search = %+v, want docs-home ranked above pricing", resp)
+	}
+}
+
+func TestListLinks_QueryLanguage(t *testing.T) {
+	r := setupRouter(t)
+	doRequest(r, authReq("POST", "/api/links", `{"slug":"a","domain":"short.io","destination":"https://example.com/launch","tags":["launch"]}`))
+	doRequest(r, authReq("POST", "/api/links", `{"slug":"b","domain":"short.io","destination":"https://example.com/launch","tags":["launch","old"]}`))
+	id := createLink(t, r, "c", "short.io", "https://example.com/launch")
+	doRequest(r, authReq("DELETE", fmt.Sprintf("/api/links/%d", id), ""))
+
+	q := url.QueryEscape("launch tag:launch -tag:old -is:deleted clicks:<10 created:>2000-01-01")
+	rr := doRequest(r, authReq("GET", "/api/links?q="+q, ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Links []models.Link `json:"links"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if len(resp.Links) != 1 || resp.Links[0].Slug != "a" {
+		t.Errorf("links = %+v, want only a", resp.Links)
+	}
+
+	rr = doRequest(r, authReq("GET", "/api/links?q="+url.QueryEscape("clicks:>many"), ""))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `isn't a number of clicks`) {
+		t.Errorf("status = %d, body = %s, want 400 explaining the bad term", rr.Code, rr.Body.String())
+	}
+}
+
+func TestListLinks_QueryLanguageStatusAndDates(t *testing.T) {
+	r := setupRouter(t)
+	createLink(t, r, "live", "short.io", "https://example.com/live")
+	id := createLink(t, r, "gone", "short.io", "https://example.com/gone")
+	doRequest(r, authReq("DELETE", fmt.Sprintf("/api/links/%d", id), ""))
+
+	for query, want := range map[string]int{
+		"-is:deleted":         1,
+		"is:deleted":          1,
+		"created:<2000-01-01": 0,
+		"clicked:>2000-01-01": 0,
+		"created:>2000-01-01": 2,
+	} {
+		rr := doRequest(r, authReq("GET", "/api/links?q="+url.QueryEscape(query), ""))
+		var resp struct {
+			Total int `json:"total"`
+		}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		if rr.Code != http.StatusOK || resp.Total != want {
+			t.Errorf("q=%s: status = %d, total = %d, want %d", query, rr.Code, resp.Total, want)
+		}
+	}
+}
+
+func TestListLinks_RejectsBadParameters(t *testing.T) {
+	r := setupRouter(t)
+	for _, query := range []string{"sort=slug", "order=up", "status=gone", "created_after=yesterday", "cursor=nope"} {
+		rr := doRequest(r, authReq("GET", "/api/links?"+query, ""))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rr.Code)
+		}
+	}
+}
+
+// --- Get tests ---
+
+func TestGetLink_NotFound(t *testing.T) {
+	r := setupRouter(t)
+	rr := doRequest(r, authReq("GET", "/api/links/99999", ""))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rr.Code)
+	}
+}
+
+func TestGetLink_ETag(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "tagged", "short.io", "https://example.com")
+	path := fmt.Sprintf("/api/links/%d", id)
+
+	rr := doRequest(r, authReq("GET", path, ""))
+	etag := rr.Header().Get("ETag")
+	if !regexp.MustCompile(`^"\d+"$`).MatchString(etag) {
+		t.Fatalf("ETag = %q, want a quoted version", etag)
+	}
+
+	req := authReq("GET", path, "")
+	req.Header.Set("If-None-Match", "W/"+etag)
+	if rr := doRequest(r, req); rr.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: status = %d, want 304", rr.Code)
+	}
+}
+
+func TestGetLink_InvalidID(t *testing.T) {
+	r := setupRouter(t)
+	rr := doRequest(r, authReq("GET", "/api/links/abc", ""))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rr.Code)
+	}
+}
+
+// --- Stats tests ---
+
+func TestLinkStats(t *testing.T) {
+	r := setupRouter(t)
+	now := time.Now().UTC().Format(time.RFC3339)
+	body := `{"type":"link","domain":"short.io","slug":"launch","destination":"https://example.com"}
+{"type":"click","domain":"short.io","slug":"launch","clicked_at":"` + now + `","referer_domain":"google.com","country":"US","browser":"Chrome","device_type":"desktop"}
+{"type":"click","domain":"short.io","slug":"launch","clicked_at":"` + now + `","referer_domain":"google.com","country":"DE","browser":"Chrome","device_type":"mobile"}
+{"type":"click","domain":"short.io","slug":"launch","clicked_at":"2020-01-01T00:00:00Z","referer_domain":"t.co","country":"US","browser":"Firefox","device_type":"desktop"}
+`
+	if code, s := doImport(t, r, "", "application/x-ndjson", body); code != http.StatusOK || s.Clicks.Imported != 3 {
+		t.Fatalf("import: status = %d, summary = %+v", code, s)
+	}
+	rr := doRequest(r, authReq("GET", "/api/links?search=launch", ""))
+	var list struct {
+		Links []models.Link `json:"links"`
+	}
+	json.NewDecoder(rr.Body).Decode(&list)
+	if len(list.Links) != 1 {
+		t.Fatalf("links = %+v", list.Links)
+	}
+	path := fmt.Sprintf("/api/links/%d/stats", list.Links[0].ID)
+
+	rr = doRequest(r, authReq("GET", path+"?limit=1", ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	type count struct {
+		Value  string `json:"value"`
+		Clicks int    `json:"clicks"`
+	}
+	var stats struct {
+		Clicks         int     `json:"clicks"`
+		ClicksThisWeek int     `json:"clicks_this_week"`
+		Referrers      []count `json:"referrers"`
+		Browsers       []count `json:"browsers"`
+		Slugs          []struct {
+			Slug   string `json:"slug"`
+			Clicks int    `json:"clicks"`
+		} `json:"slugs"`
+	}
+	json.NewDecoder(rr.Body).Decode(&stats)
+	if stats.Clicks != 3 || stats.ClicksThisWeek != 2 {
+		t.Errorf("clicks = %d, this week = %d; want 3 and 2", stats.Clicks, stats.ClicksThisWeek)
+	}
+	if len(stats.Referrers) != 1 || stats.Referrers[0] != (count{"google.com", 2}) {
+		t.Errorf("referrers = %+v, want just google.com with 2", stats.Referrers)
+	}
+	if len(stats.Browsers) != 1 || stats.Browsers[0] != (count{"Chrome", 2}) {
+		t.Errorf("browsers = %+v, want just Chrome with 2", stats.Browsers)
+	}
+	if len(stats.Slugs) != 1 || stats.Slugs[0].Slug != "launch" || stats.Slugs[0].Clicks != 3 {
+		t.Errorf("slugs = %+v", stats.Slugs)
+	}
+}
+
+func TestLinkStats_Errors(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "a", "short.io", "https://example.com")
+
+	if rr := doRequest(r, authReq("GET", "/api/links/99999/stats", "")); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown link: status = %d, want 404", rr.Code)
+	}
+	path := fmt.Sprintf("/api/links/%d/stats?limit=0", id)
+	if rr := doRequest(r, authReq("GET", path, "")); rr.Code != http.StatusBadRequest {
+		t.Errorf("limit=0: status = %d, want 400", rr.Code)
+	}
+}
+
+type analyticsResult struct {
+	Timezone string `json:"timezone"`
+	Clicks   int    `json:"clicks"`
+	Rows     []struct {
+		Values map[string]string `json:"values"`
+		Clicks int               `json:"clicks"`
+		Series []struct {
+			Start  time.Time `json:"start"`
+			Clicks int       `json:"clicks"`
+		} `json:"series"`
+	} `json:"rows"`
+}
+
+func TestAnalytics(t *testing.T) {
+	r := setupRouter(t)
+	body := `{"type":"link","domain":"short.io","slug":"launch","destination":"https://example.com/a","tags":["spring"]}
+{"type":"link","domain":"short.io","slug":"docs","destination":"https://example.com/b"}
+{"type":"click","domain":"short.io","slug":"launch","clicked_at":"2024-03-01T20:00:00Z","country":"US","device_type":"mobile"}
+{"type":"click","domain":"short.io","slug":"launch","clicked_at":"2024-03-02T09:00:00Z","country":"US","device_type":"desktop"}
+{"type":"click","domain":"short.io","slug":"docs","clicked_at":"2024-03-02T10:00:00Z","country":"DE","device_type":"mobile"}
+{"type":"click","domain":"short.io","slug":"docs","clicked_at":"2023-01-01T00:00:00Z","country":"DE","device_type":"mobile"}
+`
+	if code, s := doImport(t, r, "", "application/x-ndjson", body); code != http.StatusOK || s.Clicks.Imported != 4 {
+		t.Fatalf("import: status = %d, summary = %+v", code, s)
+	}
+
+	get := func(query string) analyticsResult {
+		t.Helper()
+		rr := doRequest(r, authReq("GET", "/api/analytics?"+query, ""))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", query, rr.Code, rr.Body.String())
+		}
+		var res analyticsResult
+		json.NewDecoder(rr.Body).Decode(&res)
+		return res
+	}
+
+	res := get("from=2024-03-01&to=2024-03-02&group_by=country,device")
+	if res.Clicks != 3 || len(res.Rows) != 3 {
+		t.Fatalf("result = %+v, want 3 clicks in 3 rows", res)
+	}
+	if res.Rows[0].Values["country"] == "" || res.Rows[0].Values["device"] == "" {
+		t.Errorf("row values = %v", res.Rows[0].Values)
+	}
+
+	res = get("from=2024-03-01&to=2024-03-02&group_by=country&tag=spring&device=mobile,desktop")
+	if len(res.Rows) != 1 || res.Rows[0].Values["country"] != "US" || res.Rows[0].Clicks != 2 {
+		t.Errorf("spring clicks by country = %+v", res.Rows)
+	}
+
+	// Dates and buckets are New York days; 09:00 UTC on March 2 is 04:00 there
+	res = get("from=2024-03-01&to=2024-03-02&tz=America/New_York&interval=day")
+	if res.Timezone != "America/New_York" || len(res.Rows) != 1 || len(res.Rows[0].Series) != 2 {
+		t.Fatalf("daily series = %+v", res)
+	}
+	if s := res.Rows[0].Series; s[0].Clicks != 1 || s[1].Clicks != 2 || s[0].Start.Format(time.RFC3339) != "2024-03-01T00:00:00-05:00" {
+		t.Errorf("daily series = %+v", s)
+	}
+}
+
+func TestAnalytics_Errors(t *testing.T) {
+	r := setupRouter(t)
+	for _, query := range []string{
+		"group_by=planet",
+		"group_by=country,country",
+		"group_by=link,tag,domain,os",
+		"interval=fortnight",
+		"tz=Mars/Olympus",
+		"from=2024-03-02&to=2024-03-01",
+		"link=abc",
+		"campaign=999",
+		"limit=0",
+		"from=2000-01-01&interval=hour",
+	} {
+		if rr := doRequest(r, authReq("GET", "/api/analytics?"+query, "")); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rr.Code)
+		}
+	}
+}
+
+// --- OpenAPI tests ---
+
+func TestOpenAPI_ServedWithoutKey(t *testing.T) {
+	r := setupRouter(t)
+	rr := doRequest(r, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rr.Code)
+	}
+	var doc struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") || doc.Paths["/api/links"] == nil {
+		t.Errorf("openapi = %q with %d paths", doc.OpenAPI, len(doc.Paths))
+	}
+}
+
+// --- Update tests ---
+
+func TestUpdateLink_PartialUpdate(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "partial", "short.io", "https://old.com")
+
+	// Create with title and tags via direct creation, then update only destination
+	// First, set title via update
+	body := `{"title":"My Title","tags":"tag1"}`
+	path := fmt.Sprintf("/api/links/%d", id)
+	doRequest(r, authReq("PATCH", path, body))
+
+	// Now update only destination
+	body2 := `{"destination":"https://new.com"}`
+	rr := doRequest(r, authReq("PATCH", path, body2))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200, body = %s", rr.Code, rr.Body.String())
+	}
+
+	var link map[string]any
+	json.NewDecoder(rr.Body).Decode(&link)
+	if link["destination"] != "https://new.com" {
+		t.Errorf("destination = %v, want %q", link["destination"], "https://new.com")
+	}
+	if link["title"] != "My Title" {
+		t.Errorf("title = %v, want %q (preserved)", link["title"], "My Title")
+	}
+	if link["tags"] != "tag1" {
+		t.Errorf("tags = %v, want %q (preserved)", link["tags"], "tag1")
+	}
+}
+
+func TestUpdateLink_ClearsFieldWithEmptyString(t *testing.T) {
+	r := setupRouter(t)
+	// Create link then set title
+	body := `{"slug":"clear","domain":"short.io","destination":"https://example.com","title":"Has Title"}`
+	rr := doRequest(r, authReq("POST", "/api/links", body))
+	var created map[string]any
+	json.NewDecoder(rr.Body).Decode(&created)
+	id := int64(created["id"].(float64))
+
+	// Clear title with empty string
+	clearBody := `{"title":""}`
+	path := fmt.Sprintf("/api/links/%d", id)
+	rr2 := doRequest(r, authReq("PATCH", path, clearBody))
+	if rr2.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rr2.Code)
+	}
+
+	var updated map[string]any
+	json.NewDecoder(rr2.Body).Decode(&updated)
+	if updated["title"] != "" {
+		t.Errorf("title = %v, want empty string", updated["title"])
+	}
+}
+
+func TestUpdateLink_CacheInvalidatesOldKey(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "oldslug", "short.io", "https://example.com")
+
+	// Trigger redirect to cache the link
+	req := httptest.NewRequest("GET", "/oldslug", nil)
+	req.Host = "short.io"
+	rr := doRequest(r, req)
+	if rr.Code != http.StatusFound {
+		t.Fatalf("initial redirect: status = %d, want 302", rr.Code)
+	}
+
+	// Update slug from "oldslug" to "newslug"
+	body := fmt.Sprintf(`{"slug":"newslug"}`)
+	path := fmt.Sprintf("/api/links/%d", id)
+	rr2 := doRequest(r, authReq("PATCH", path, body))
+	if rr2.Code != http.StatusOK {
+		t.Fatalf("update: status = %d, want 200, body = %s", rr2.Code, rr2.Body.String())
+	}
+
+	// Old slug should now 404 (cache invalidated)
+	req2 := httptest.NewRequest("GET", "/oldslug", nil)
+	req2.Host = "short.io"
+	rr3 := doRequest(r, req2)
+	if rr3.Code != http.StatusNotFound {
+		t.Errorf("old slug after update: status = %d, want 404", rr3.Code)
+	}
+
+	// New slug should redirect
+	req3 := httptest.NewRequest("GET", "/newslug", nil)
+	req3.Host = "short.io"
+	rr4 := doRequest(r, req3)
+	if rr4.Code != http.StatusFound {
+		t.Errorf("new slug: status = %d, want 302", rr4.Code)
+	}
+}
+
+func patchIfMatch(r *chi.Mux, id int64, etag, body string) *httptest.ResponseRecorder {
+	req := authReq("PATCH", fmt.Sprintf("/api/links/%d", id), body)
+	req.Header.Set("If-Match", etag)
+	return doRequest(r, req)
+}
+
+func TestUpdateLink_IfMatch(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "guarded", "short.io", "https://v1.com")
+	etag := doRequest(r, authReq("GET", fmt.Sprintf("/api/links/%d", id), "")).Header().Get("ETag")
+
+	rr := patchIfMatch(r, id, etag, `{"destination":"https://v2.com"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200, body = %s", rr.Code, rr.Body.String())
+	}
+	next := rr.Header().Get("ETag")
+	if next == "" || next == etag {
+		t.Fatalf("ETag after update = %q, want a new one (was %q)", next, etag)
+	}
+
+	// A second editor still holding the first ETag is turned away
+	rr = patchIfMatch(r, id, etag, `{"destination":"https://v3.com"}`)
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: status = %d, want 412", rr.Code)
+	}
+	if rr.Header().Get("ETag") != next {
+		t.Errorf("412 ETag = %q, want the current %q", rr.Header().Get("ETag"), next)
+	}
+	var link models.Link
+	json.NewDecoder(doRequest(r, authReq("GET", fmt.Sprintf("/api/links/%d", id), "")).Body).Decode(&link)
+	if link.Destination != "https://v2.com" {
+		t.Errorf("destination = %q, want the first update kept", link.Destination)
+	}
+
+	if rr := patchIfMatch(r, id, `"1", `+next, `{"title":"ok"}`); rr.Code != http.StatusOK {
+		t.Errorf("If-Match list: status = %d, want 200", rr.Code)
+	}
+	if rr := patchIfMatch(r, id, "*", `{"title":"any"}`); rr.Code != http.StatusOK {
+		t.Errorf("If-Match *: status = %d, want 200", rr.Code)
+	}
+}
+
+// --- Delete tests ---
+
+func TestDeleteLink_Returns204(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "todelete", "short.io", "https://example.com")
+
+	path := fmt.Sprintf("/api/links/%d", id)
+	rr := doRequest(r, authReq("DELETE", path, ""))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", rr.Code)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("body = %q, want empty", rr.Body.String())
+	}
+}
+
+func TestDeleteLink_IfMatch(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "todelete", "short.io", "https://example.com")
+	path := fmt.Sprintf("/api/links/%d", id)
+	etag := doRequest(r, authReq("GET", path, "")).Header().Get("ETag")
+	doRequest(r, authReq("PATCH", path, `{"title":"changed"}`))
+
+	req := authReq("DELETE", path, "")
+	req.Header.Set("If-Match", etag)
+	if rr := doRequest(r, req); rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: status = %d, want 412", rr.Code)
+	}
+
+	req = authReq("DELETE", path, "")
+	req.Header.Set("If-Match", doRequest(r, authReq("GET", path, "")).Header().Get("ETag"))
+	if rr := doRequest(r, req); rr.Code != http.StatusNoContent {
+		t.Errorf("current If-Match: status = %d, want 204", rr.Code)
+	}
+}
+
+func TestDeleteLink_NotFound(t *testing.T) {
+	r := setupRouter(t)
+	rr := doRequest(r, authReq("DELETE", "/api/links/99999", ""))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rr.Code)
+	}
+}
+
+// --- Redirect tests ---
+
+func TestRedirect_Success(t *testing.T) {
+	r := setupRouter(t)
+	createLink(t, r, "go", "short.io", "https://example.com")
+
+	req := httptest.NewRequest("GET", "/go", nil)
+	req.Host = "short.io"
+	rr := doRequest(r, req)
+	if rr.Code != http.StatusFound {
+		t.Errorf("status = %d, want 302", rr.Code)
+	}
+	if loc := rr.Header().Get("Location"); loc != "https://example.com" {
+		t.Errorf("Location = %q, want %q", loc, "https://example.com")
+	}
+}
+
+func TestRedirect_EmptySlug_Returns404(t *testing.T) {
+	r := setupRouter(t)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "short.io"
+	rr := doRequest(r, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rr.Code)
+	}
+}
+
+func TestRedirect_UnknownSlug_Returns404(t *testing.T) {
+	r := setupRouter(t)
+	req := httptest.NewRequest("GET", "/nonexistent", nil)
+	req.Host = "short.io"
+	rr := doRequest(r, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rr.Code)
+	}
+}
+
+func TestRedirect_InactiveLink_Returns410(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "inactive", "short.io", "https://example.com")
+
+	// Soft delete
+	path := fmt.Sprintf("/api/links/%d", id)
+	doRequest(r, authReq("DELETE", path, ""))
+
+	// Redirect should return 410
+	req := httptest.NewRequest("GET", "/inactive", nil)
+	req.Host = "short.io"
+	rr := doRequest(r, req)
+	if rr.Code != http.StatusGone {
+		t.Errorf("status = %d, want 410", rr.Code)
+	}
+}
+
+func TestRedirect_HostNormalizedToLowercase(t *testing.T) {
+	r := setupRouter(t)
+	createLink(t, r, "hosttest", "short.io", "https://example.com")
+
+	req := httptest.NewRequest("GET", "/hosttest", nil)
+	req.Host = "SHORT.IO"
+	rr := doRequest(r, req)
+	if rr.Code != http.StatusFound {
+		t.Errorf("status = %d, want 302 (host normalization)", rr.Code)
+	}
+}
+
+// --- Domain settings tests ---
+
+func setupDomainSettingsRouter(t *testing.T) *chi.Mux {
+	t.Helper()
+	return setupRouterWithConfig(t, &config.Config{
+		Password: testPassword,
+		Domains:  []string{"short.io", "go"},
+		DomainSettings: map[string]config.DomainSettings{
+			"go": {
+				Scheme:          "http",
+				Port:            8080,
+				CaseInsensitive: true,
+				SlugLength:      4,
+				SlugAlphabet:    "xyz",
+				DefaultTags:     "intranet",
+			},
+		},
+	})
+}
+
+func TestCreateLink_DomainSettings(t *testing.T) {
+	r := setupDomainSettingsRouter(t)
+	rr := doRequest(r, authReq("POST", "/api/links", `{"domain":"go","destination":"https://example.com"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+
+	var link map[string]any
+	json.NewDecoder(rr.Body).Decode(&link)
+	s, _ := link["slug"].(string)
+	if len(s) != 4 || strings.Trim(s, "xyz") != "" {
+		t.Errorf("slug = %q, want 4 characters from \"xyz\"", s)
+	}
+	if link["short_url"] != "http://go:8080/"+s {
+		t.Errorf("short_url = %v, want %q", link["short_url"], "http://go:8080/"+s)
+	}
+	if link["tags"] != "intranet" {
+		t.Errorf("tags = %v, want default %q", link["tags"], "intranet")
+	}
+}
+
+func TestCreateLink_DefaultDomainSettings(t *testing.T) {
+	r := setupDomainSettingsRouter(t)
+	rr := doRequest(r, authReq("POST", "/api/links", `{"domain":"short.io","destination":"https://example.com","slug":"MiXeD"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+
+	var link map[string]any
+	json.NewDecoder(rr.Body).Decode(&link)
+	if link["slug"] != "MiXeD" {
+		t.Errorf("slug = %v, want case preserved", link["slug"])
+	}
+	if link["short_url"] != "https://short.io/MiXeD" {
+		t.Errorf("short_url = %v, want https default", link["short_url"])
+	}
+	if link["tags"] != "" {
+		t.Errorf("tags = %v, want empty", link["tags"])
+	}
+}
+
+func TestCreateLink_CaseInsensitiveDomainNormalizesSlug(t *testing.T) {
+	r := setupDomainSettingsRouter(t)
+	rr := doRequest(r, authReq("POST", "/api/links", `{"domain":"go","destination":"https://example.com","slug":"Wiki"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var link map[string]any
+	json.NewDecoder(rr.Body).Decode(&link)
+	if link["slug"] != "wiki" {
+		t.Errorf("slug = %v, want %q", link["slug"], "wiki")
+	}
+
+	rr = doRequest(r, authReq("POST", "/api/links", `{"domain":"go","destination":"https://example.com","slug":"WIKI"}`))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("duplicate differing by case: status = %d, want 409", rr.Code)
+	}
+}
+
+func TestRedirect_CaseInsensitiveDomain(t *testing.T) {
+	r := setupDomainSettingsRouter(t)
+	createLink(t, r, "wiki", "go", "https://wiki.example.com")
+
+	for _, path := range []string{"/wiki", "/WIKI", "/Wiki"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Host = "go:8080"
+		rr := doRequest(r, req)
+		if rr.Code != http.StatusFound {
+			t.Errorf("%s: status = %d, want 302", path, rr.Code)
+		}
+	}
+}
+
+func TestRedirect_CaseSensitiveByDefault(t *testing.T) {
+	r := setupDomainSettingsRouter(t)
+	createLink(t, r, "Docs", "short.io", "https://example.com")
+
+	req := httptest.NewRequest("GET", "/docs", nil)
+	req.Host = "short.io"
+	rr := doRequest(r, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 for different case", rr.Code)
+	}
+}
+
+func TestCreateLink_SlugModes(t *testing.T) {
+	r := setupRouterWithConfig(t, &config.Config{
+		Password: testPassword,
+		Domains:  []string{"words.io", "title.io"},
+		DomainSettings: map[string]config.DomainSettings{
+			"words.io": {SlugMode: "words"},
+			"title.io": {SlugMode: "title"},
+		},
+	})
+
+	create := func(domain, title string) string {
+		body := fmt.Sprintf(`{"domain":%q,"destination":"https://example.com","title":%q}`, domain, title)
+		rr := doRequest(r, authReq("POST", "/api/links", body))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+		}
+		var link models.Link
+		json.NewDecoder(rr.Body).Decode(&link)
+		return link.Slug
+	}
+
+	if s := create("words.io", ""); !regexp.MustCompile(`^[a-z]+-[a-z]+-[0-9]{2,}$`).MatchString(s) {
+		t.Errorf("words slug = %q", s)
+	}
+	if s := create("title.io", "Launch Day"); s != "launch-day" {
+		t.Errorf("title slug = %q, want launch-day", s)
+	}
+	if s := create("title.io", "Launch Day"); !strings.HasPrefix(s, "launch-day-") {
+		t.Errorf("colliding title slug = %q, want a suffix", s)
+	}
+}
+
+// --- Slug validation tests ---
+
+func TestCreateLink_InvalidSlugs(t *testing.T) {
+	r := setupRouter(t)
+	for _, s := range []string{"admin", "API", "favicon.ico", "has space", "a/b", ".env", strings.Repeat("x", 65)} {
+		body := fmt.Sprintf(`{"slug":%q,"domain":"short.io","destination":"https://example.com"}`, s)
+		rr := doRequest(r, authReq("POST", "/api/links", body))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("slug %q: status = %d, want 400", s, rr.Code)
+		}
+	}
+}
+
+func TestCreateLink_ConfiguredReservedSlug(t *testing.T) {
+	r := setupRouterWithConfig(t, &config.Config{
+		Password:      testPassword,
+		Domains:       []string{"short.io"},
+		ReservedSlugs: []string{"pricing"},
+	})
+	rr := doRequest(r, authReq("POST", "/api/links", `{"slug":"pricing","domain":"short.io","destination":"https://example.com"}`))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "reserved") {
+		t.Errorf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestUpdateLink_InvalidSlug(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "ok", "short.io", "https://example.com")
+	rr := doRequest(r, authReq("PATCH", fmt.Sprintf("/api/links/%d", id), `{"slug":"admin"}`))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rr.Code)
+	}
+}
+
+func TestCreateLink_TitleModeSkipsReserved(t *testing.T) {
+	r := setupRouterWithConfig(t, &config.Config{
+		Password:       testPassword,
+		Domains:        []string{"short.io"},
+		DomainSettings: map[string]config.DomainSettings{"short.io": {SlugMode: "title"}},
+	})
+	rr := doRequest(r, authReq("POST", "/api/links", `{"domain":"short.io","destination":"https://example.com","title":"Admin"}`))
+	var link models.Link
+	json.NewDecoder(rr.Body).Decode(&link)
+	if rr.Code != http.StatusCreated || link.Slug == "admin" {
+		t.Errorf("status = %d, slug = %q; want a non-reserved slug", rr.Code, link.Slug)
+	}
+}
+
+func TestBulkCreate_TitleModeCollisions(t *testing.T) {
+	r := setupRouterWithConfig(t, &config.Config{
+		Password:       testPassword,
+		Domains:        []string{"short.io"},
+		DomainSettings: map[string]config.DomainSettings{"short.io": {SlugMode: "title"}},
+	})
+	createLink(t, r, "launch", "short.io", "https://example.com")
+
+	item := `{"domain":"short.io","destination":"https://example.com","title":"Launch"}`
+	code, resp := doBulk(t, r, "POST", `{"links":[`+item+`,`+item+`,`+item+`]}`)
+	if code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; resp = %+v", code, resp)
+	}
+	seen := map[string]bool{"launch": true}
+	for _, res := range resp.Results {
+		if seen[res.Link.Slug] {
+			t.Errorf("slug %q reused", res.Link.Slug)
+		}
+		seen[res.Link.Slug] = true
+	}
+}
+
+func TestAPIRoutes_AreReservedSlugs(t *testing.T) {
+	r := setupRouter(t)
+	r.Get("/internal/tls-allowed", (&handlers.TLSAskHandler{Cfg: &config.Config{}}).ServeHTTP)
+	v := slug.NewValidator(0, nil)
+	chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		first := strings.SplitN(strings.TrimPrefix(route, "/"), "/", 2)[0]
+		if first != "" && !v.IsReserved(first) {
+			t.Errorf("route %s %s is not covered by the reserved slug list", method, route)
+		}
+		return nil
+	})
+}
+
+type slugCheck struct {
+	Available   bool     `json:"available"`
+	Reason      string   `json:"reason"`
+	Suggestions []string `json:"suggestions"`
+}
+
+func checkSlug(t *testing.T, r *chi.Mux, query string) slugCheck {
+	t.Helper()
+	rr := doRequest(r, authReq("GET", "/api/slugs/check?"+query, ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("%s: status = %d, body = %s", query, rr.Code, rr.Body.String())
+	}
+	var resp slugCheck
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestCheckSlug(t *testing.T) {
+	r := setupRouterWithConfig(t, &config.Config{
+		Password: testPassword,
+		Domains:  []string{"short.io", "other.io"},
+	})
+	createLink(t, r, "launch", "short.io", "https://example.com")
+
+	if resp := checkSlug(t, r, "domain=short.io&slug=fresh"); !resp.Available || resp.Reason != "" || len(resp.Suggestions) != 0 {
+		t.Errorf("free slug: %+v", resp)
+	}
+	if resp := checkSlug(t, r, "domain=short.io&slug=launch"); resp.Available || resp.Reason != "taken" || len(resp.Suggestions) != 3 {
+		t.Errorf("taken slug: %+v", resp)
+	}
+	if resp := checkSlug(t, r, "domain=short.io&slug=admin"); resp.Available || resp.Reason != "reserved" || len(resp.Suggestions) == 0 {
+		t.Errorf("reserved slug: %+v", resp)
+	}
+	if resp := checkSlug(t, r, "domain=short.io&slug=a%20b"); resp.Available || resp.Reason != "invalid" {
+		t.Errorf("invalid slug: %+v", resp)
+	}
+	// Another domain has its own namespace
+	if resp := checkSlug(t, r, "domain=other.io&slug=launch"); !resp.Available {
+		t.Errorf("slug on other domain: %+v", resp)
+	}
+}
+
+func TestCheckSlug_BadRequest(t *testing.T) {
+	r := setupRouter(t)
+	for _, q := range []string{"slug=x", "domain=short.io", "domain=evil.com&slug=x"} {
+		if rr := doRequest(r, authReq("GET", "/api/slugs/check?"+q, "")); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, rr.Code)
+		}
+	}
+}
+
+// --- Unicode slug tests ---
+
+func TestUnicodeSlug_NormalizedAndRedirected(t *testing.T) {
+	r := setupRouter(t)
+
+	// Created with a decomposed "é" (e + combining acute accent)
+	body := `{"slug":"cafe\u0301","domain":"short.io","destination":"https://example.com/cafe"}`
+	rr := doRequest(r, authReq("POST", "/api/links", body))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var link models.Link
+	json.NewDecoder(rr.Body).Decode(&link)
+	if link.Slug != "caf\u00e9" {
+		t.Errorf("slug = %q, want NFC form", link.Slug)
+	}
+	if link.ShortURL != "https://short.io/caf%C3%A9" {
+		t.Errorf("short_url = %q, want percent-encoded", link.ShortURL)
+	}
+
+	// Percent-encoded NFC, percent-encoded NFD and raw paths all resolve
+	for _, path := range []string{"/caf%C3%A9", "/cafe%CC%81", "/caf\u00e9"} {
+		if rr := redirectOn(r, "short.io", path); rr.Code != http.StatusFound {
+			t.Errorf("%q: status = %d, want 302", path, rr.Code)
+		}
+	}
+}
+
+func TestUnicodeSlug_Emoji(t *testing.T) {
+	r := setupRouter(t)
+	createLink(t, r, "🚀", "short.io", "https://example.com/launch")
+
+	rr := redirectOn(r, "short.io", "/%F0%9F%9A%80")
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "https://example.com/launch" {
+		t.Errorf("status = %d, Location = %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestUnicodeSlug_MalformedEscape(t *testing.T) {
+	r := setupRouter(t)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.URL.RawPath = "/%zz"
+	req.URL.Path = "/%zz"
+	req.Host = "short.io"
+	if rr := doRequest(r, req); rr.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rr.Code)
+	}
+}
+
+func TestIDNDomain(t *testing.T) {
+	r := setupRouterWithConfig(t, &config.Config{
+		Password: testPassword,
+		Domains:  []string{"bücher.de"},
+	})
+
+	// Created with the Unicode name, stored and served under punycode
+	rr := doRequest(r, authReq("POST", "/api/links", `{"slug":"neu","domain":"Bücher.de","destination":"https://example.com"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var link models.Link
+	json.NewDecoder(rr.Body).Decode(&link)
+	if link.Domain != "xn--bcher-kva.de" || link.ShortURL != "https://xn--bcher-kva.de/neu" {
+		t.Errorf("domain = %q, short_url = %q", link.Domain, link.ShortURL)
+	}
+
+	if rr := redirectOn(r, "xn--bcher-kva.de", "/neu"); rr.Code != http.StatusFound {
+		t.Errorf("punycode host: status = %d, want 302", rr.Code)
+	}
+}
+
+// --- Wildcard domain tests ---
+
+func TestWildcardDomain_SameSlugPerSubdomain(t *testing.T) {
+	r := setupRouterWithConfig(t, &config.Config{
+		Password: testPassword,
+		Domains:  []string{"*.links.example.com"},
+	})
+	createLink(t, r, "cv", "alice.links.example.com", "https://alice.dev/cv.pdf")
+	createLink(t, r, "cv", "bob.links.example.com", "https://bob.dev/cv.pdf")
+
+	for host, want := range map[string]string{
+		"alice.links.example.com": "https://alice.dev/cv.pdf",
+		"bob.links.example.com":   "https://bob.dev/cv.pdf",
+	} {
+		req := httptest.NewRequest("GET", "/cv", nil)
+		req.Host = host
+		rr := doRequest(r, req)
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != want {
+			t.Errorf("%s: status = %d, Location = %q; want 302 to %q", host, rr.Code, rr.Header().Get("Location"), want)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/cv", nil)
+	req.Host = "carol.links.example.com"
+	if rr := doRequest(r, req); rr.Code != http.StatusNotFound {
+		t.Errorf("unregistered subdomain: status = %d, want 404", rr.Code)
+	}
+}
+
+func TestWildcardDomain_RejectsPatternAndNestedHosts(t *testing.T) {
+	r := setupRouterWithConfig(t, &config.Config{
+		Password: testPassword,
+		Domains:  []string{"*.links.example.com"},
+	})
+	for _, domain := range []string{"*.links.example.com", "links.example.com", "a.b.links.example.com"} {
+		body := fmt.Sprintf(`{"domain":%q,"destination":"https://example.com"}`, domain)
+		rr := doRequest(r, authReq("POST", "/api/links", body))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("domain %q: status = %d, want 400", domain, rr.Code)
+		}
+	}
+}
+
+// --- Domain alias and move tests ---
+
+func redirectOn(r *chi.Mux, host, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.Host = host
+	return doRequest(r, req)
+}
+
+func TestRedirect_DomainAlias(t *testing.T) {
+	r := setupRouterWithConfig(t, &config.Config{
+		Password:      testPassword,
+		Domains:       []string{"new.io"},
+		DomainAliases: map[string]string{"old.io": "new.io"},
+	})
+	createLink(t, r, "launch", "new.io", "https://example.com/launch")
+
+	for _, host := range []string{"new.io", "old.io", "OLD.io:443"} {
+		rr := redirectOn(r, host, "/launch")
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != "https://example.com/launch" {
+			t.Errorf("%s: status = %d, Location = %q", host, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+
+	// Links can't be created on the alias itself
+	rr := doRequest(r, authReq("POST", "/api/links", `{"domain":"old.io","destination":"https://example.com"}`))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("create on alias: status = %d, want 400", rr.Code)
+	}
+}
+
+func setupMoveRouter(t *testing.T) *chi.Mux {
+	t.Helper()
+	return setupRouterWithConfig(t, &config.Config{
+		Password: testPassword,
+		Domains:  []string{"old.io", "new.io", "go"},
+		DomainSettings: map[string]config.DomainSettings{
+			"go": {CaseInsensitive: true},
+		},
+	})
+}
+
+func TestDomainMove_MovesLinksAndInvalidatesCache(t *testing.T) {
+	r := setupMoveRouter(t)
+	moved := createLink(t, r, "abc", "old.io", "https://example.com/a")
+	createLink(t, r, "def", "old.io", "https://example.com/b")
+	createLink(t, r, "keep", "new.io", "https://example.com/c")
+
+	// Warm the cache for the old key
+	if rr := redirectOn(r, "old.io", "/abc"); rr.Code != http.StatusFound {
+		t.Fatalf("pre-move redirect: status = %d", rr.Code)
+	}
+
+	rr := doRequest(r, authReq("POST", "/api/domains/move", `{"from":"old.io","to":"new.io"}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Links int `json:"links"`
+		Moved int `json:"moved"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Links != 2 || resp.Moved != 2 {
+		t.Errorf("links = %d, moved = %d; want 2, 2", resp.Links, resp.Moved)
+	}
+
+	if rr := redirectOn(r, "old.io", "/abc"); rr.Code != http.StatusNotFound {
+		t.Errorf("old key after move: status = %d, want 404", rr.Code)
+	}
+	if rr := redirectOn(r, "new.io", "/abc"); rr.Code != http.StatusFound {
+		t.Errorf("new key after move: status = %d, want 302", rr.Code)
+	}
+
+	// The link keeps its ID, and with it its click history
+	rr = doRequest(r, authReq("GET", fmt.Sprintf("/api/links/%d", moved), ""))
+	var got models.Link
+	json.NewDecoder(rr.Body).Decode(&got)
+	if got.Domain != "new.io" || got.Slug != "abc" {
+		t.Errorf("link = %s/%s, want new.io/abc", got.Domain, got.Slug)
+	}
+}
+
+func TestDomainMove_ReportsCollisionsWithoutMoving(t *testing.T) {
+	r := setupMoveRouter(t)
+	clash := createLink(t, r, "abc", "old.io", "https://example.com/a")
+	createLink(t, r, "def", "old.io", "https://example.com/b")
+	existing := createLink(t, r, "abc", "new.io", "https://example.com/c")
+
+	for _, body := range []string{
+		`{"from":"old.io","to":"new.io","dry_run":true}`,
+		`{"from":"old.io","to":"new.io"}`,
+	} {
+		rr := doRequest(r, authReq("POST", "/api/domains/move", body))
+		if rr.Code != http.StatusConflict {
+			t.Fatalf("%s: status = %d, want 409", body, rr.Code)
+		}
+		var resp struct {
+			Moved      int                    `json:"moved"`
+			Collisions []models.MoveCollision `json:"collisions"`
+		}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		if resp.Moved != 0 || len(resp.Collisions) != 1 {
+			t.Fatalf("moved = %d, collisions = %v", resp.Moved, resp.Collisions)
+		}
+		if c := resp.Collisions[0]; c.LinkID != clash || c.ConflictingLinkID != existing {
+			t.Errorf("collision = %+v", c)
+		}
+	}
+
+	if rr := redirectOn(r, "old.io", "/def"); rr.Code != http.StatusFound {
+		t.Errorf("non-colliding link should not move: status = %d", rr.Code)
+	}
+}
+
+func TestDomainMove_DryRun(t *testing.T) {
+	r := setupMoveRouter(t)
+	createLink(t, r, "abc", "old.io", "https://example.com/a")
+
+	rr := doRequest(r, authReq("POST", "/api/domains/move", `{"from":"old.io","to":"new.io","dry_run":true}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rr.Code)
+	}
+	if rr := redirectOn(r, "old.io", "/abc"); rr.Code != http.StatusFound {
+		t.Errorf("dry run should not move links: status = %d", rr.Code)
+	}
+}
+
+func TestDomainMove_CaseInsensitiveTarget(t *testing.T) {
+	r := setupMoveRouter(t)
+	createLink(t, r, "Docs", "old.io", "https://example.com/a")
+	createLink(t, r, "docs", "old.io", "https://example.com/b")
+
+	rr := doRequest(r, authReq("POST", "/api/domains/move", `{"from":"old.io","to":"go","dry_run":true}`))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("slugs differing only in case should collide: status = %d", rr.Code)
+	}
+}
+
+func TestDomainMove_Validation(t *testing.T) {
+	r := setupMoveRouter(t)
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"from":"old.io"}`, http.StatusBadRequest},
+		{`{"from":"old.io","to":"old.io"}`, http.StatusBadRequest},
+		{`{"from":"old.io","to":"evil.com"}`, http.StatusBadRequest},
+		{`{"from":"old.io","to":"new.io","extra":1}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rr := doRequest(r, authReq("POST", "/api/domains/move", tt.body)); rr.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.body, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestTLSAsk_Alias(t *testing.T) {
+	cfg := &config.Config{Domains: []string{"new.io"}, DomainAliases: map[string]string{"old.io": "new.io"}}
+	r := chi.NewRouter()
+	r.Get("/internal/tls-allowed", (&handlers.TLSAskHandler{Cfg: cfg}).ServeHTTP)
+
+	if rr := doRequest(r, httptest.NewRequest("GET", "/internal/tls-allowed?domain=old.io", nil)); rr.Code != http.StatusOK {
+		t.Errorf("alias: status = %d, want 200", rr.Code)
+	}
+}
+
+// --- Link alias tests ---
+
+func createAlias(t *testing.T, r *chi.Mux, linkID int64, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequest(r, authReq("POST", fmt.Sprintf("/api/links/%d/aliases", linkID), body))
+}
+
+func TestLinkAlias_CreateAndRedirect(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "main", "short.io", "https://example.com")
+
+	rr := createAlias(t, r, id, `{"slug":"promo"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; body: %s", rr.Code, rr.Body.String())
+	}
+	var alias models.LinkAlias
+	json.NewDecoder(rr.Body).Decode(&alias)
+	if alias.Domain != "short.io" || alias.ShortURL != "https://short.io/promo" {
+		t.Errorf("alias = %+v", alias)
+	}
+
+	rr = redirectOn(r, "short.io", "/promo")
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "https://example.com" {
+		t.Errorf("redirect = %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rr = doRequest(r, authReq("GET", fmt.Sprintf("/api/links/%d/aliases", id), ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list status = %d", rr.Code)
+	}
+	var list struct {
+		Aliases []models.LinkAlias `json:"aliases"`
+	}
+	json.NewDecoder(rr.Body).Decode(&list)
+	if len(list.Aliases) != 1 || list.Aliases[0].Slug != "promo" {
+		t.Errorf("aliases = %+v", list.Aliases)
+	}
+}
+
+func TestLinkAlias_Conflicts(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "main", "short.io", "https://example.com")
+	createLink(t, r, "taken", "short.io", "https://example.com")
+
+	for _, body := range []string{`{"slug":"taken"}`, `{"slug":"main"}`} {
+		if rr := createAlias(t, r, id, body); rr.Code != http.StatusConflict {
+			t.Errorf("%s: status = %d, want 409", body, rr.Code)
+		}
+	}
+	if rr := createAlias(t, r, id, `{"slug":"promo"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", rr.Code)
+	}
+
+	// Neither a new link nor a renamed link may take the alias's slug
+	body := `{"slug":"promo","domain":"short.io","destination":"https://other.com"}`
+	if rr := doRequest(r, authReq("POST", "/api/links", body)); rr.Code != http.StatusConflict {
+		t.Errorf("create link on alias slug: status = %d, want 409", rr.Code)
+	}
+	path := fmt.Sprintf("/api/links/%d", id)
+	if rr := doRequest(r, authReq("PATCH", path, `{"slug":"promo"}`)); rr.Code != http.StatusConflict {
+		t.Errorf("rename link to alias slug: status = %d, want 409", rr.Code)
+	}
+}
+
+func TestLinkAlias_Validation(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "main", "short.io", "https://example.com")
+
+	for _, body := range []string{`{"slug":""}`, `{"slug":"api"}`, `{"slug":"x","domain":"evil.com"}`} {
+		if rr := createAlias(t, r, id, body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rr.Code)
+		}
+	}
+	if rr := createAlias(t, r, 99999, `{"slug":"x"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown link: status = %d, want 404", rr.Code)
+	}
+}
+
+func TestLinkAlias_UpdateInvalidatesAliasCache(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "main", "short.io", "https://example.com")
+	createAlias(t, r, id, `{"slug":"promo"}`)
+
+	// Warm the cache through the alias
+	redirectOn(r, "short.io", "/promo")
+
+	path := fmt.Sprintf("/api/links/%d", id)
+	doRequest(r, authReq("PATCH", path, `{"destination":"https://new.example.com"}`))
+
+	rr := redirectOn(r, "short.io", "/promo")
+	if loc := rr.Header().Get("Location"); loc != "https://new.example.com" {
+		t.Errorf("Location = %q, want the updated destination", loc)
+	}
+}
+
+func TestLinkAlias_Delete(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "main", "short.io", "https://example.com")
+	rr := createAlias(t, r, id, `{"slug":"promo"}`)
+	var alias models.LinkAlias
+	json.NewDecoder(rr.Body).Decode(&alias)
+
+	redirectOn(r, "short.io", "/promo")
+
+	path := fmt.Sprintf("/api/links/%d/aliases/%d", id, alias.ID)
+	if rr := doRequest(r, authReq("DELETE", path, "")); rr.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", rr.Code)
+	}
+	if rr := redirectOn(r, "short.io", "/promo"); rr.Code != http.StatusNotFound {
+		t.Errorf("redirect after delete = %d, want 404", rr.Code)
+	}
+	if rr := doRequest(r, authReq("DELETE", path, "")); rr.Code != http.StatusNotFound {
+		t.Errorf("second delete = %d, want 404", rr.Code)
+	}
+}
+
+// --- Tag tests ---
+
+func TestCreateLink_TagsArrayOrString(t *testing.T) {
+	r := setupRouter(t)
+
+	for _, tc := range []struct{ tags, want string }{
+		{`["AI", "docs", "ai"]`, "ai,docs"},
+		{`"Mail, blog"`, "mail,blog"},
+	} {
+		body := fmt.Sprintf(`{"domain":"short.io","destination":"https://example.com","tags":%s}`, tc.tags)
+		rr := doRequest(r, authReq("POST", "/api/links", body))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("tags %s: status = %d; body: %s", tc.tags, rr.Code, rr.Body.String())
+		}
+		var link models.Link
+		json.NewDecoder(rr.Body).Decode(&link)
+		if link.Tags != tc.want {
+			t.Errorf("tags %s: stored %q, want %q", tc.tags, link.Tags, tc.want)
+		}
+	}
+
+	body := `{"domain":"short.io","destination":"https://example.com","tags":42}`
+	if rr := doRequest(r, authReq("POST", "/api/links", body)); rr.Code != http.StatusBadRequest {
+		t.Errorf("numeric tags: status = %d, want 400", rr.Code)
+	}
+}
+
+func TestUpdateLink_TagsArray(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "tagged", "short.io", "https://example.com")
+
+	rr := doRequest(r, authReq("PATCH", fmt.Sprintf("/api/links/%d", id), `{"tags":["launch","Q3"]}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d; body: %s", rr.Code, rr.Body.String())
+	}
+	var link models.Link
+	json.NewDecoder(rr.Body).Decode(&link)
+	if link.Tags != "launch,q3" {
+		t.Errorf("tags = %q, want %q", link.Tags, "launch,q3")
+	}
+}
+
+func TestListLinks_TagFilter(t *testing.T) {
+	r := setupRouter(t)
+	for i, tags := range []string{`["ai"]`, `["mail"]`, `["ai","docs"]`} {
+		body := fmt.Sprintf(`{"slug":"t%d","domain":"short.io","destination":"https://example.com","tags":%s}`, i, tags)
+		doRequest(r, authReq("POST", "/api/links", body))
+	}
+
+	rr := doRequest(r, authReq("GET", "/api/links?tag=ai", ""))
+	var resp struct {
+		Links []models.Link `json:"links"`
+		Total int           `json:"total"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Total != 2 {
+		t.Errorf("tag=ai total = %d, want 2 (mail must not match)", resp.Total)
+	}
+
+	rr = doRequest(r, authReq("GET", "/api/links?tag=ai&tag=docs", ""))
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Total != 1 || resp.Links[0].Slug != "t2" {
+		t.Errorf("tag=ai&tag=docs = %+v", resp)
+	}
+}
+
+func TestListTags(t *testing.T) {
+	r := setupRouter(t)
+
+	rr := doRequest(r, authReq("GET", "/api/tags", ""))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"tags":[]`) {
+		t.Errorf("empty = %d %s", rr.Code, rr.Body.String())
+	}
+
+	for i, tags := range []string{`["docs","blog"]`, `["docs"]`} {
+		body := fmt.Sprintf(`{"slug":"t%d","domain":"short.io","destination":"https://example.com","tags":%s}`, i, tags)
+		doRequest(r, authReq("POST", "/api/links", body))
+	}
+	rr = doRequest(r, authReq("GET", "/api/tags", ""))
+	var resp struct {
+		Tags []models.TagCount `json:"tags"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if len(resp.Tags) != 2 || resp.Tags[0] != (models.TagCount{Name: "docs", Links: 2}) {
+		t.Errorf("tags = %+v", resp.Tags)
+	}
+}
+
+// --- UTM tests ---
+
+func TestCreateLink_UTMFields(t *testing.T) {
+	r := setupRouter(t)
+	rr := doRequest(r, authReq("POST", "/api/links", `{"domain":"short.io","slug":"u","destination":"https://example.com/?utm_source=old&ref=1","utm_source":"google","utm_medium":"cpc"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var link struct {
+		Destination string `json:"destination"`
+		UTMSource   string `json:"utm_source"`
+		UTMMedium   string `json:"utm_medium"`
+	}
+	json.NewDecoder(rr.Body).Decode(&link)
+	if link.Destination != "https://example.com/?ref=1&utm_medium=cpc&utm_source=google" {
+		t.Errorf("destination = %q", link.Destination)
+	}
+	if link.UTMSource != "google" || link.UTMMedium != "cpc" {
+		t.Errorf("utm fields = %+v", link)
+	}
+}
+
+func TestUTMPreset_AppliedByName(t *testing.T) {
+	r := setupRouter(t)
+	rr := doRequest(r, authReq("POST", "/api/utm-presets", `{"name":"Newsletter","utm_source":"newsletter","utm_medium":"email"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create preset: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	if rr := doRequest(r, authReq("POST", "/api/utm-presets", `{"name":"newsletter","utm_source":"x"}`)); rr.Code != http.StatusConflict {
+		t.Errorf("duplicate preset: status = %d, want 409", rr.Code)
+	}
+	if rr := doRequest(r, authReq("POST", "/api/utm-presets", `{"name":"Empty"}`)); rr.Code != http.StatusBadRequest {
+		t.Errorf("empty preset: status = %d, want 400", rr.Code)
+	}
+
+	// Explicit fields win over the preset
+	rr = doRequest(r, authReq("POST", "/api/links", `{"domain":"short.io","slug":"p","destination":"https://example.com","utm_preset":"newsletter","utm_medium":"social"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create link: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var link struct {
+		ID          int64  `json:"id"`
+		Destination string `json:"destination"`
+	}
+	json.NewDecoder(rr.Body).Decode(&link)
+	if link.Destination != "https://example.com?utm_medium=social&utm_source=newsletter" {
+		t.Errorf("destination = %q", link.Destination)
+	}
+
+	rr = doRequest(r, authReq("POST", "/api/links", `{"domain":"short.io","destination":"https://example.com","utm_preset":"missing"}`))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown preset: status = %d, want 400", rr.Code)
+	}
+
+	rr = doRequest(r, authReq("GET", "/api/utm-presets", ""))
+	if !strings.Contains(rr.Body.String(), `"name":"Newsletter"`) {
+		t.Errorf("list = %s", rr.Body.String())
+	}
+}
+
+func TestUpdateLink_UTMFields(t *testing.T) {
+	r := setupRouter(t)
+	id := createLink(t, r, "u", "short.io", "https://example.com/?utm_source=google&utm_medium=cpc")
+	redirectOn(r, "short.io", "/u")
+
+	rr := doRequest(r, authReq("PATCH", fmt.Sprintf("/api/links/%d", id), `{"utm_medium":"","utm_campaign":"spring"}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	want := "https://example.com/?utm_campaign=spring&utm_source=google"
+	if !strings.Contains(rr.Body.String(), `"utm_campaign":"spring"`) || !strings.Contains(rr.Body.String(), `"utm_medium":""`) {
+		t.Errorf("body = %s", rr.Body.String())
+	}
+	if loc := redirectOn(r, "short.io", "/u").Header().Get("Location"); loc != want {
+		t.Errorf("Location = %q, want %q", loc, want)
+	}
+
+	// Updates without UTM fields leave the destination's query untouched
+	rr = doRequest(r, authReq("PATCH", fmt.Sprintf("/api/links/%d", id), `{"destination":"https://example.com/?b=2&a=1"}`))
+	var link struct {
+		Destination string `json:"destination"`
+	}
+	json.NewDecoder(rr.Body).Decode(&link)
+	if link.Destination != "https://example.com/?b=2&a=1" {
+		t.Errorf("destination = %q, want it unchanged", link.Destination)
+	}
+
+	// Clearing every field clears them from the destination too
+	doRequest(r, authReq("PATCH", fmt.Sprintf("/api/links/%d", id), `{"destination":"https://example.com/?utm_source=x"}`))
+	rr = doRequest(r, authReq("PATCH", fmt.Sprintf("/api/links/%d", id), `{"utm_source":""}`))
+	json.NewDecoder(rr.Body).Decode(&link)
+	if link.Destination != "https://example.com/" {
+		t.Errorf("destination = %q, want its UTM parameters cleared", link.Destination)
+	}
+}
+
+// --- Campaign tests ---
+
+func createCampaign(t *testing.T, r *chi.Mux, body string) int64 {
+	t.Helper()
+	rr := doRequest(r, authReq("POST", "/api/campaigns", body))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("createCampaign: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var c struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	return c.ID
+}
+
+func TestCreateCampaign_Validation(t *testing.T) {
+	r := setupRouter(t)
+	createCampaign(t, r, `{"name":"Launch","utm_source":"newsletter"}`)
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"name":"Launch"}`, http.StatusConflict},
+		{`{"name":""}`, http.StatusBadRequest},
+		{`{"name":"Spring","starts_on":"2026-04-01","ends_on":"2026-03-01"}`, http.StatusBadRequest},
+		{`{"name":"Spring","budget":100}`, http.StatusBadRequest},
+	} {
+		rr := doRequest(r, authReq("POST", "/api/campaigns", tc.body))
+		if rr.Code != tc.want {
+			t.Errorf("POST %s: status = %d, want %d", tc.body, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestCampaign_AttachAppliesUTMAndInvalidatesCache(t *testing.T) {
+	r := setupRouter(t)
+	linkID := createLink(t, r, "launch", "short.io", "https://example.com/?utm_source=twitter")
+	campaignID := createCampaign(t, r, `{"name":"Launch","utm_source":"newsletter","utm_campaign":"launch"}`)
+
+	// Warm the cache with the pre-campaign destination
+	if rr := redirectOn(r, "short.io", "/launch"); rr.Code != http.StatusFound {
+		t.Fatalf("redirect status = %d", rr.Code)
+	}
+
+	rr := doRequest(r, authReq("POST", fmt.Sprintf("/api/campaigns/%d/links", campaignID), fmt.Sprintf(`{"link_ids":[%d]}`, linkID)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("attach status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Status string `json:"status"`
+		Links  []struct {
+			ID          int64  `json:"id"`
+			Destination string `json:"destination"`
+		} `json:"links"`
+		Stats struct {
+			Clicks    int               `json:"clicks"`
+			Referrers []json.RawMessage `json:"referrers"`
+		} `json:"stats"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	want := "https://example.com/?utm_campaign=launch&utm_source=twitter"
+	if len(resp.Links) != 1 || resp.Links[0].Destination != want {
+		t.Errorf("links = %+v, want destination %q", resp.Links, want)
+	}
+	if resp.Status != "active" || resp.Stats.Referrers == nil {
+		t.Errorf("status = %q, referrers = %v", resp.Status, resp.Stats.Referrers)
+	}
+
+	if loc := redirectOn(r, "short.io", "/launch").Header().Get("Location"); loc != want {
+		t.Errorf("Location after attach = %q, want %q", loc, want)
+	}
+}
+
+func TestCampaign_AttachUnknownLink(t *testing.T) {
+	r := setupRouter(t)
+	campaignID := createCampaign(t, r, `{"name":"Launch"}`)
+
+	rr := doRequest(r, authReq("POST", fmt.Sprintf("/api/campaigns/%d/links", campaignID), `{"link_ids":[999]}`))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rr.Code)
+	}
+	rr = doRequest(r, authReq("POST", "/api/campaigns/999/links", `{"link_ids":[1]}`))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("unknown campaign: status = %d, want 404", rr.Code)
+	}
+}
+
+func TestCampaign_DetachAndDelete(t *testing.T) {
+	r := setupRouter(t)
+	linkID := createLink(t, r, "launch", "short.io", "https://example.com")
+	campaignID := createCampaign(t, r, `{"name":"Launch"}`)
+	doRequest(r, authReq("POST", fmt.Sprintf("/api/campaigns/%d/links", campaignID), fmt.Sprintf(`{"link_ids":[%d]}`, linkID)))
+
+	path := fmt.Sprintf("/api/campaigns/%d/links/%d", campaignID, linkID)
+	if rr := doRequest(r, authReq("DELETE", path, "")); rr.Code != http.StatusNoContent {
+		t.Errorf("detach status = %d, want 204", rr.Code)
+	}
+	if rr := doRequest(r, authReq("DELETE", path, "")); rr.Code != http.StatusNotFound {
+		t.Errorf("second detach status = %d, want 404", rr.Code)
+	}
+
+	rr := doRequest(r, authReq("PATCH", fmt.Sprintf("/api/campaigns/%d", campaignID), `{"ends_on":"2026-12-31"}`))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"ends_on":"2026-12-31"`) {
+		t.Errorf("update status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := doRequest(r, authReq("DELETE", fmt.Sprintf("/api/campaigns/%d", campaignID), "")); rr.Code != http.StatusNoContent {
+		t.Errorf("delete status = %d, want 204", rr.Code)
+	}
+	if rr := doRequest(r, authReq("GET", fmt.Sprintf("/api/links/%d", linkID), "")); rr.Code != http.StatusOK {
+		t.Errorf("link after campaign delete: status = %d, want 200", rr.Code)
+	}
+}
+
+// --- TLS ask tests ---
+
+func setupTLSAsk(token string) *chi.Mux {
+	cfg := &config.Config{
+		Domains:     []string{"short.io", "Go.Example.com"},
+		TLSAskToken: token,
+	}
+	r := chi.NewRouter()
+	r.Get("/internal/tls-allowed", (&handlers.TLSAskHandler{Cfg: cfg}).ServeHTTP)
+	return r
+}
+
+func TestTLSAsk_AllowedDomain(t *testing.T) {
+	r := setupTLSAsk("")
+	for _, domain := range []string{"short.io", "go.example.com", "SHORT.IO", "short.io."} {
+		rr := doRequest(r, httptest.NewRequest("GET", "/internal/tls-allowed?domain="+domain, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("domain %q: status = %d, want 200", domain, rr.Code)
+		}
+	}
+}
+
+func TestTLSAsk_UnknownDomain(t *testing.T) {
+	r := setupTLSAsk("")
+	rr := doRequest(r, httptest.NewRequest("GET", "/internal/tls-allowed?domain=evil.com", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", rr.Code)
+	}
+}
+
+func TestTLSAsk_WildcardHostNotAllowed(t *testing.T) {
+	r := setupTLSAsk("")
+	for _, domain := range []string{"sub.short.io", "*.short.io", "short.io.evil.com"} {
+		rr := doRequest(r, httptest.NewRequest("GET", "/internal/tls-allowed?domain="+domain, nil))
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("domain %q: status = %d, want 403", domain, rr.Code)
+		}
+	}
+}
+
+func TestTLSAsk_WildcardEntry(t *testing.T) {
+	cfg := &config.Config{Domains: []string{"*.links.example.com"}}
+	r := chi.NewRouter()
+	r.Get("/internal/tls-allowed", (&handlers.TLSAskHandler{Cfg: cfg}).ServeHTTP)
+
+	tests := map[string]int{
+		"alice.links.example.com": http.StatusOK,
+		"links.example.com":       http.StatusForbidden,
+		"a.b.links.example.com":   http.StatusForbidden,
+		"*.links.example.com":     http.StatusForbidden,
+	}
+	for domain, want := range tests {
+		rr := doRequest(r, httptest.NewRequest("GET", "/internal/tls-allowed?domain="+domain, nil))
+		if rr.Code != want {
+			t.Errorf("domain %q: status = %d, want %d", domain, rr.Code, want)
+		}
+	}
+}
+
+func TestTLSAsk_MissingDomain(t *testing.T) {
+	r := setupTLSAsk("")
+	rr := doRequest(r, httptest.NewRequest("GET", "/internal/tls-allowed", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rr.Code)
+	}
+}
+
+func TestTLSAsk_Token(t *testing.T) {
+	r := setupTLSAsk("ask-secret")
+
+	rr := doRequest(r, httptest.NewRequest("GET", "/internal/tls-allowed?domain=short.io", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("missing token: status = %d, want 401", rr.Code)
+	}
+
+	rr = doRequest(r, httptest.NewRequest("GET", "/internal/tls-allowed?domain=short.io&token=wrong", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", rr.Code)
+	}
+
+	rr = doRequest(r, httptest.NewRequest("GET", "/internal/tls-allowed?domain=short.io&token=ask-secret", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("valid token: status = %d, want 200", rr.Code)
+	}
+}
+
+// --- Domain status tests ---
+
+func TestListDomains_ReturnsStoredChecks(t *testing.T) {
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	expiry := time.Now().Add(5 * 24 * time.Hour).UTC()
+	models.UpsertDomainCheck(database, &models.DomainCheck{
+		Domain:      "short.io",
+		IPs:         []string{"1.2.3.4"},
+		TLSIssuer:   "R3 (Let's Encrypt)",
+		TLSSANs:     []string{"short.io"},
+		TLSNotAfter: &expiry,
+		TLSValid:    true,
+		CheckedAt:   time.Now(),
+	})
+
+	cfg := &config.Config{
+		Password:       testPassword,
+		Domains:        []string{"short.io", "unchecked.io"},
+		CertWarnWindow: 14 * 24 * time.Hour,
+	}
+	r := chi.NewRouter()
+	r.Get("/api/domains", (&handlers.DomainHandler{DB: database, Cfg: cfg}).List)
+
+	rr := doRequest(r, authReq("GET", "/api/domains", ""))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rr.Code)
+	}
+
+	var resp struct {
+		Domains []struct {
+			Domain       string `json:"domain"`
+			ExpiringSoon bool   `json:"expiring_soon"`
+			Check        *struct {
+				TLSValid  bool     `json:"tls_valid"`
+				TLSIssuer string   `json:"tls_issuer"`
+				IPs       []string `json:"ips"`
+			} `json:"check"`
+		} `json:"domains"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Domains) != 2 {
+		t.Fatalf("got %d domains, want 2", len(resp.Domains))
+	}
+	checked := resp.Domains[0]
+	if checked.Check == nil || !checked.Check.TLSValid || checked.Check.TLSIssuer != "R3 (Let's Encrypt)" {
+		t.Errorf("short.io check = %+v", checked.Check)
+	}
+	if !checked.ExpiringSoon {
+		t.Error("certificate expiring in 5 days should be flagged")
+	}
+	if resp.Domains[1].Check != nil {
+		t.Errorf("unchecked.io check = %+v, want null", resp.Domains[1].Check)
 	}
 }
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// Idempotent makes a handler safe to retry: the response to a request with
+// an Idempotency-Key header is stored and replayed, without running the
+// handler again, to any request repeating the key within window. Requests
+// without the header pass straight through.
+//
+// Reusing a key for a different request is refused with 422, and a retry
+// arriving while the first request is still running gets 409. Server errors
+// aren't stored, so the request can be retried with the same key.
+func Idempotent(db *sql.DB, window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				jsonError(w, "Idempotency-Key must be at most 255 characters", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+			if err != nil {
+				jsonError(w, "failed to read request", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			sum := sha256.Sum256([]byte(r.Method + " " + r.URL.RequestURI() + "\n" + string(body)))
+			hash := hex.EncodeToString(sum[:])
+
+			stored, reserved, err := models.ReserveIdempotencyKey(db, key, hash, window)
+			if err != nil {
+				jsonError(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if !reserved {
+				switch {
+				case stored.RequestHash != hash:
+					jsonError(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+				case stored.Status == 0:
+					jsonError(w, "a request with this Idempotency-Key is still in progress", http.StatusConflict)
+				default:
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("Idempotent-Replayed", "true")
+					w.WriteHeader(stored.Status)
+					w.Write(stored.Body)
+				}
+				return
+			}
+
+			rec := &recordingWriter{ResponseWriter: w}
+			saved := false
+			defer func() {
+				// Free the key if the handler panicked or failed on our side
+				if !saved {
+					if err := models.ReleaseIdempotencyKey(db, key); err != nil {
+						log.Printf("idempotency: %v", err)
+					}
+				}
+			}()
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			if rec.status < http.StatusInternalServerError {
+				if err := models.SaveIdempotentResponse(db, key, rec.status, rec.body.Bytes()); err != nil {
+					log.Printf("idempotency: %v", err)
+					return
+				}
+				saved = true
+			}
+		})
+	}
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
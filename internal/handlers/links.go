@@ -3,15 +3,19 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/scmmishra/dubly/internal/cache"
 	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/linkquery"
 	"github.com/scmmishra/dubly/internal/models"
 	"github.com/scmmishra/dubly/internal/slug"
 )
@@ -25,129 +29,338 @@ type LinkHandler struct {
 }
 
 type createLinkRequest struct {
-	Slug        string `json:"slug"`
-	Domain      string `json:"domain"`
-	Destination string `json:"destination"`
-	Title       string `json:"title"`
-	Tags        string `json:"tags"`
-	Notes       string `json:"notes"`
-}
-
-type updateLinkRequest struct {
 	Slug        string  `json:"slug"`
 	Domain      string  `json:"domain"`
 	Destination string  `json:"destination"`
-	Title       *string `json:"title"`
-	Tags        *string `json:"tags"`
-	Notes       *string `json:"notes"`
+	Title       string  `json:"title"`
+	Tags        tagList `json:"tags"`
+	Notes       string  `json:"notes"`
+	utmFields
+}
+
+type updateLinkRequest struct {
+	Slug        string   `json:"slug"`
+	Domain      string   `json:"domain"`
+	Destination string   `json:"destination"`
+	Title       *string  `json:"title"`
+	Tags        *tagList `json:"tags"`
+	Notes       *string  `json:"notes"`
+	utmFields
 }
 
 type listResponse struct {
-	Links  []models.Link `json:"links"`
-	Total  int           `json:"total"`
-	Limit  int           `json:"limit"`
-	Offset int           `json:"offset"`
+	Links      []models.Link `json:"links"`
+	Total      int           `json:"total"`
+	Limit      int           `json:"limit"`
+	Offset     int           `json:"offset"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	PrevCursor string        `json:"prev_cursor,omitempty"`
 }
 
 func (h *LinkHandler) Create(w http.ResponseWriter, r *http.Request) {
+	dedupe := false
+	if v := r.URL.Query().Get("dedupe"); v != "" {
+		var err error
+		if dedupe, err = strconv.ParseBool(v); err != nil {
+			jsonError(w, "dedupe must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
 	var req createLinkRequest
 	if err := decodeJSON(r, &req); err != nil {
 		jsonError(w, "invalid JSON", http.StatusBadRequest)
 		return
 	}
+	if dedupe {
+		h.getOrCreate(w, &req)
+		return
+	}
 
-	if req.Destination == "" {
-		jsonError(w, "destination is required", http.StatusBadRequest)
+	link, err := h.prepareCreate(h.DB, &req, nil)
+	if err != nil {
+		writeAPIError(w, err)
 		return
 	}
-	if req.Domain == "" {
-		jsonError(w, "domain is required", http.StatusBadRequest)
+	if err := models.CreateLink(h.DB, link); err != nil {
+		if isConstraintError(err) {
+			jsonError(w, "slug already exists for this domain", http.StatusConflict)
+			return
+		}
+		jsonError(w, "failed to create link", http.StatusInternalServerError)
 		return
 	}
-	req.Domain = strings.ToLower(req.Domain)
-	if !h.Cfg.IsDomainAllowed(req.Domain) {
-		jsonError(w, "domain not allowed", http.StatusBadRequest)
+
+	link.FillShortURL(h.Cfg.ShortURLBase)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(link))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(link)
+}
+
+// PutByDestination returns the active link on the request's domain whose
+// destination matches the request's, creating it when there is none; see
+// getOrCreate.
+func (h *LinkHandler) PutByDestination(w http.ResponseWriter, r *http.Request) {
+	var req createLinkRequest
+	if err := decodeJSON(r, &req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
 		return
 	}
+	h.getOrCreate(w, &req)
+}
 
-	// Generate slug if not provided, with collision retry
-	if req.Slug == "" {
-		for range 10 {
-			candidate, err := slug.Generate()
-			if err != nil {
-				jsonError(w, "internal error", http.StatusInternalServerError)
-				return
-			}
-			exists, err := models.SlugExists(h.DB, candidate, req.Domain)
-			if err != nil {
-				jsonError(w, "internal error", http.StatusInternalServerError)
-				return
-			}
-			if !exists {
-				req.Slug = candidate
-				break
-			}
-		}
-		if req.Slug == "" {
-			jsonError(w, "failed to generate unique slug", http.StatusInternalServerError)
-			return
+// getOrCreate responds with the oldest active link on req's domain whose
+// destination, with req's UTM parameters applied, normalizes the same as
+// req's, with 200. Failing that it creates the link as Create does, with
+// 201. The lookup and insert share a transaction, so concurrent requests
+// can't both create it.
+func (h *LinkHandler) getOrCreate(w http.ResponseWriter, req *createLinkRequest) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	status := http.StatusOK
+	link, err := h.findDuplicate(tx, req)
+	if err == nil && link == nil {
+		status = http.StatusCreated
+		if link, err = h.prepareCreate(tx, req, nil); err == nil {
+			err = models.CreateLinkTx(tx, link)
 		}
 	}
-
-	link := &models.Link{
-		Slug:        req.Slug,
-		Domain:      req.Domain,
-		Destination: req.Destination,
-		Title:       req.Title,
-		Tags:        req.Tags,
-		Notes:       req.Notes,
+	if err == nil {
+		err = tx.Commit()
 	}
-
-	if err := models.CreateLink(h.DB, link); err != nil {
+	if err != nil {
 		if isConstraintError(err) {
 			jsonError(w, "slug already exists for this domain", http.StatusConflict)
 			return
 		}
-		jsonError(w, "failed to create link", http.StatusInternalServerError)
+		writeAPIError(w, err)
 		return
 	}
 
+	link.FillShortURL(h.Cfg.ShortURLBase)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("ETag", etag(link))
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(link)
 }
 
-func (h *LinkHandler) List(w http.ResponseWriter, r *http.Request) {
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	if limit <= 0 {
-		limit = 25
-	} else if limit > 100 {
-		limit = 100
+// findDuplicate returns the existing link getOrCreate would return for req,
+// or nil when there is none.
+func (h *LinkHandler) findDuplicate(q models.Querier, req *createLinkRequest) (*models.Link, error) {
+	// Leave invalid requests for prepareCreate to turn away
+	domain := config.CanonicalDomain(req.Domain)
+	if req.Destination == "" || !h.Cfg.IsDomainAllowed(domain) {
+		return nil, nil
+	}
+	want := &models.Link{}
+	want.SetDestination(req.Destination)
+	if err := req.utmFields.apply(q, want); err != nil {
+		if err == errUnknownPreset {
+			return nil, badRequest(err.Error())
+		}
+		return nil, err
+	}
+	want.BuildDestination()
+	link, err := models.FindLinkByDestination(q, domain, want.Destination)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return link, err
+}
+
+// prepareCreate validates req and builds the link it describes, generating
+// a slug when none is given. Slug checks and preset lookups go through q so
+// bulk creates see the links inserted earlier in their transaction; batch,
+// when not nil, answers the slug checks it already knows.
+func (h *LinkHandler) prepareCreate(q models.Querier, req *createLinkRequest, batch *bulkSlugs) (*models.Link, error) {
+	if req.Destination == "" {
+		return nil, badRequest("destination is required")
+	}
+	if req.Domain == "" {
+		return nil, badRequest("domain is required")
+	}
+	req.Domain = config.CanonicalDomain(req.Domain)
+	if !h.Cfg.IsDomainAllowed(req.Domain) {
+		return nil, badRequest("domain not allowed")
+	}
+
+	settings := h.Cfg.SettingsFor(req.Domain)
+	slugExists := models.SlugExists
+	if settings.CaseInsensitive {
+		slugExists = models.SlugExistsFold
+	}
+	slugTaken := func(candidate string) (bool, error) {
+		if taken, ok := batch.lookup(req.Domain, candidate); ok {
+			return taken, nil
+		}
+		return slugExists(q, candidate, req.Domain)
+	}
+	req.Slug = h.Cfg.NormalizeSlug(req.Domain, req.Slug)
+	validator := h.Cfg.SlugValidator()
+	if req.Slug != "" {
+		if err := validator.Validate(req.Slug); err != nil {
+			return nil, badRequest(err.Error())
+		}
+	}
+
+	// Generate slug if not provided, with collision retry
+	if req.Slug == "" {
+		gen, err := h.Cfg.SlugGenerator(req.Domain)
+		if err != nil {
+			return nil, err
+		}
+		req.Slug, err = slug.Unique(batch.generator(req, gen), slug.Input{Title: req.Title}, validator, slugTaken)
+		if err != nil {
+			return nil, &apiError{http.StatusInternalServerError, "failed to generate unique slug"}
+		}
+	} else {
+		// The unique index doesn't cover aliases or slugs that differ only by
+		// case on case-insensitive domains
+		exists, err := slugTaken(req.Slug)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, errSlugTaken
+		}
+	}
+
+	if req.Tags == "" {
+		req.Tags = tagList(settings.DefaultTags)
+	}
+	link := &models.Link{
+		Slug:   req.Slug,
+		Domain: req.Domain,
+		Title:  req.Title,
+		Tags:   string(req.Tags),
+		Notes:  req.Notes,
 	}
-	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
-	if offset < 0 {
-		offset = 0
+	link.SetDestination(req.Destination)
+	if err := req.utmFields.apply(q, link); err != nil {
+		if err == errUnknownPreset {
+			return nil, badRequest(err.Error())
+		}
+		return nil, err
 	}
-	search := r.URL.Query().Get("search")
+	return link, nil
+}
 
-	links, total, err := models.ListLinks(h.DB, limit, offset, search)
+func (h *LinkHandler) List(w http.ResponseWriter, r *http.Request) {
+	q, err := parseLinkQuery(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := models.PageLinks(h.DB, q)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		jsonError(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	links := page.Links
 	if links == nil {
 		links = []models.Link{}
 	}
+	for i := range links {
+		links[i].FillShortURL(h.Cfg.ShortURLBase)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(listResponse{
-		Links:  links,
-		Total:  total,
-		Limit:  limit,
-		Offset: offset,
+		Links:      links,
+		Total:      page.Total,
+		Limit:      q.Limit,
+		Offset:     q.Offset,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
 	})
 }
 
+// parseLinkQuery reads the listing parameters of GET /api/links. q takes
+// the search language of the linkquery package and combines with the other
+// filters. Offset is ignored once a cursor is given.
+func parseLinkQuery(r *http.Request) (models.LinkQuery, error) {
+	params := r.URL.Query()
+	q := models.LinkQuery{
+		Filter: models.LinkFilter{
+			Search: params.Get("search"),
+			Tags:   params["tag"],
+			Domain: config.CanonicalDomain(params.Get("domain")),
+		},
+		Cursor: params.Get("cursor"),
+	}
+
+	q.Limit, _ = strconv.Atoi(params.Get("limit"))
+	if q.Limit <= 0 {
+		q.Limit = 25
+	} else if q.Limit > 100 {
+		q.Limit = 100
+	}
+	if q.Cursor == "" {
+		q.Offset, _ = strconv.Atoi(params.Get("offset"))
+		if q.Offset < 0 {
+			q.Offset = 0
+		}
+	}
+
+	var err error
+	if q.Sort, err = models.ParseLinkSort(params.Get("sort"), params.Get("order")); err != nil {
+		return q, err
+	}
+	if q.Filter.Active, err = parseLinkStatus(params.Get("status")); err != nil {
+		return q, err
+	}
+	bounds := []struct {
+		param string
+		dst   **time.Time
+		upper bool
+	}{
+		{"created_after", &q.Filter.CreatedAfter, false},
+		{"created_before", &q.Filter.CreatedBefore, true},
+		{"last_clicked_after", &q.Filter.LastClickedAfter, false},
+		{"last_clicked_before", &q.Filter.LastClickedBefore, true},
+	}
+	for _, b := range bounds {
+		t, err := models.ParseFilterTime(params.Get(b.param), b.upper)
+		if err != nil {
+			return q, fmt.Errorf("%s: %w", b.param, err)
+		}
+		*b.dst = t
+	}
+	// The query language goes last so its terms narrow the parameters
+	// above rather than being reset by them
+	if err := linkquery.Apply(&q.Filter, params.Get("q")); err != nil {
+		return q, fmt.Errorf("q: %w", err)
+	}
+	return q, nil
+}
+
+// parseLinkStatus maps a status parameter to LinkFilter.Active. Deleting a
+// link deactivates it, so "deleted" is another name for "inactive".
+func parseLinkStatus(s string) (*bool, error) {
+	var active bool
+	switch s {
+	case "", "all":
+		return nil, nil
+	case "active":
+		active = true
+	case "inactive", "deleted":
+	default:
+		return nil, fmt.Errorf("unknown status %q: use active, inactive or all", s)
+	}
+	return &active, nil
+}
+
 func (h *LinkHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -165,6 +378,12 @@ func (h *LinkHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", etag(link))
+	if etagMatches(r.Header.Get("If-None-Match"), link, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	link.FillShortURL(h.Cfg.ShortURLBase)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(link)
 }
@@ -186,6 +405,12 @@ func (h *LinkHandler) Update(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && !etagMatches(ifMatch, existing, false) {
+		preconditionFailed(w, existing)
+		return
+	}
+	version := existing.Version()
 
 	var req updateLinkRequest
 	if err := decodeJSON(r, &req); err != nil {
@@ -193,13 +418,50 @@ func (h *LinkHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	req.Domain = strings.ToLower(req.Domain)
-	if req.Domain != "" && !h.Cfg.IsDomainAllowed(req.Domain) {
-		jsonError(w, "domain not allowed", http.StatusBadRequest)
+	// Capture old key before mutation for cache invalidation
+	oldDomain, oldSlug := existing.Domain, existing.Slug
+
+	if err := h.prepareUpdate(h.DB, existing, &req); err != nil {
+		writeAPIError(w, err)
 		return
 	}
 
-	// Capture old key before mutation for cache invalidation
+	// Invalidate old cache entries (using pre-mutation key) and every alias,
+	// whose cached copies would otherwise keep the old destination
+	h.Cache.InvalidateLink(h.DB, id, oldDomain, oldSlug)
+
+	if ifMatch != "" {
+		err = models.UpdateLinkIfUnchanged(h.DB, existing, version)
+	} else {
+		err = models.UpdateLink(h.DB, existing)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrLinkChanged) {
+			preconditionFailed(w, nil)
+			return
+		}
+		if isConstraintError(err) {
+			jsonError(w, "slug already exists for this domain", http.StatusConflict)
+			return
+		}
+		jsonError(w, "failed to update link", http.StatusInternalServerError)
+		return
+	}
+
+	existing.FillShortURL(h.Cfg.ShortURLBase)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(existing))
+	json.NewEncoder(w).Encode(existing)
+}
+
+// prepareUpdate applies req to existing, validating the result. Slug checks
+// and preset lookups go through q, as in prepareCreate.
+func (h *LinkHandler) prepareUpdate(q models.Querier, existing *models.Link, req *updateLinkRequest) error {
+	req.Domain = config.CanonicalDomain(req.Domain)
+	if req.Domain != "" && !h.Cfg.IsDomainAllowed(req.Domain) {
+		return badRequest("domain not allowed")
+	}
+
 	oldDomain, oldSlug := existing.Domain, existing.Slug
 
 	// Apply updates — only overwrite if provided
@@ -210,32 +472,44 @@ func (h *LinkHandler) Update(w http.ResponseWriter, r *http.Request) {
 		existing.Domain = req.Domain
 	}
 	if req.Destination != "" {
-		existing.Destination = req.Destination
+		existing.SetDestination(req.Destination)
+	}
+	if err := req.utmFields.apply(q, existing); err != nil {
+		if err == errUnknownPreset {
+			return badRequest(err.Error())
+		}
+		return err
 	}
 	if req.Title != nil {
 		existing.Title = *req.Title
 	}
 	if req.Tags != nil {
-		existing.Tags = *req.Tags
+		existing.Tags = string(*req.Tags)
 	}
 	if req.Notes != nil {
 		existing.Notes = *req.Notes
 	}
-
-	// Invalidate old cache entry (using pre-mutation key)
-	h.Cache.Invalidate(oldDomain, oldSlug)
-
-	if err := models.UpdateLink(h.DB, existing); err != nil {
-		if isConstraintError(err) {
-			jsonError(w, "slug already exists for this domain", http.StatusConflict)
-			return
+	existing.Slug = h.Cfg.NormalizeSlug(existing.Domain, existing.Slug)
+	if req.Slug != "" {
+		if err := h.Cfg.SlugValidator().Validate(existing.Slug); err != nil {
+			return badRequest(err.Error())
 		}
-		jsonError(w, "failed to update link", http.StatusInternalServerError)
-		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(existing)
+	if existing.Slug != oldSlug || existing.Domain != oldDomain {
+		slugExists := models.SlugExists
+		if h.Cfg.SettingsFor(existing.Domain).CaseInsensitive && !strings.EqualFold(existing.Slug, oldSlug) {
+			slugExists = models.SlugExistsFold
+		}
+		exists, err := slugExists(q, existing.Slug, existing.Domain)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return errSlugTaken
+		}
+	}
+	return nil
 }
 
 func (h *LinkHandler) Delete(w http.ResponseWriter, r *http.Request) {
@@ -245,13 +519,33 @@ func (h *LinkHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the link first to invalidate cache
+	// Get the link first to check preconditions and invalidate cache
 	link := &models.Link{ID: id}
-	if err := models.GetLinkByID(h.DB, link); err == nil {
-		h.Cache.Invalidate(link.Domain, link.Slug)
+	if err := models.GetLinkByID(h.DB, link); err != nil {
+		if err == sql.ErrNoRows {
+			jsonError(w, "not found", http.StatusNotFound)
+			return
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && !etagMatches(ifMatch, link, false) {
+		preconditionFailed(w, link)
+		return
 	}
+	h.Cache.InvalidateLink(h.DB, id, link.Domain, link.Slug)
 
-	if err := models.SoftDeleteLink(h.DB, id); err != nil {
+	if ifMatch != "" {
+		err = models.SoftDeleteLinkIfUnchanged(h.DB, id, link.Version())
+	} else {
+		err = models.SoftDeleteLink(h.DB, id)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrLinkChanged) {
+			preconditionFailed(w, nil)
+			return
+		}
 		if err == sql.ErrNoRows {
 			jsonError(w, "not found", http.StatusNotFound)
 			return
@@ -263,6 +557,37 @@ func (h *LinkHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// etag is the entity tag for the saved state of l, derived from when it
+// was last updated.
+func etag(l *models.Link) string {
+	return `"` + l.Version() + `"`
+}
+
+// etagMatches reports whether header, an If-Match or If-None-Match list of
+// entity tags, names the saved state of l. "*" matches any link. Weak tags
+// (W/"...") only count when weak is set, as If-None-Match allows.
+func etagMatches(header string, l *models.Link, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed reports a failed If-Match. current, when known, is the
+// link as it stands, whose ETag is sent so the client can re-read and retry.
+func preconditionFailed(w http.ResponseWriter, current *models.Link) {
+	if current != nil {
+		w.Header().Set("ETag", etag(current))
+	}
+	jsonError(w, "link has changed since it was read; fetch it again and retry", http.StatusPreconditionFailed)
+}
+
 func decodeJSON(r *http.Request, dst any) error {
 	dec := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
 	dec.DisallowUnknownFields()
@@ -273,6 +598,30 @@ func isConstraintError(err error) bool {
 	return strings.Contains(err.Error(), "UNIQUE constraint failed")
 }
 
+// apiError is a failure with the status and message to report to the
+// client.
+type apiError struct {
+	status int
+	msg    string
+}
+
+func (e *apiError) Error() string { return e.msg }
+
+var errSlugTaken = &apiError{http.StatusConflict, "slug already exists for this domain"}
+
+func badRequest(msg string) *apiError {
+	return &apiError{http.StatusBadRequest, msg}
+}
+
+// writeAPIError reports err, hiding the details of anything but an apiError.
+func writeAPIError(w http.ResponseWriter, err error) {
+	if ae, ok := err.(*apiError); ok {
+		jsonError(w, ae.msg, ae.status)
+		return
+	}
+	jsonError(w, "internal error", http.StatusInternalServerError)
+}
+
 func jsonError(w http.ResponseWriter, msg string, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -4,17 +4,20 @@ import (
 	"database/sql"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/scmmishra/dubly/internal/analytics"
 	"github.com/scmmishra/dubly/internal/cache"
+	"github.com/scmmishra/dubly/internal/config"
 	"github.com/scmmishra/dubly/internal/datacenter"
 	"github.com/scmmishra/dubly/internal/models"
 )
 
 type RedirectHandler struct {
 	DB        *sql.DB
+	Cfg       *config.Config
 	Cache     *cache.LinkCache
 	Collector *analytics.Collector
 	DC        *datacenter.Checker
@@ -26,19 +29,27 @@ func (h *RedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if h, _, err := net.SplitHostPort(host); err == nil {
 		host = h
 	}
-	host = strings.ToLower(host)
+	// Alias hosts serve their target domain's links
+	host = h.Cfg.ResolveAlias(config.CanonicalDomain(host))
 
-	slug := strings.TrimPrefix(r.URL.Path, "/")
-	if slug == "" {
+	// Decode the path as sent so "%C3%A9" and "é" reach the same slug, then
+	// normalize it the way slugs are stored.
+	slug, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/"))
+	if err != nil || slug == "" {
 		http.NotFound(w, r)
 		return
 	}
+	slug = h.Cfg.NormalizeSlug(host, slug)
+
+	getLink := models.GetLinkBySlugAndDomain
+	if h.Cfg.SettingsFor(host).CaseInsensitive {
+		getLink = models.GetLinkBySlugAndDomainFold
+	}
 
 	// Check cache first
 	link, found := h.Cache.Get(host, slug)
 	if !found {
-		var err error
-		link, err = models.GetLinkBySlugAndDomain(h.DB, slug, host)
+		link, err = getLink(h.DB, slug, host)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				http.NotFound(w, r)
@@ -69,6 +80,7 @@ func (h *RedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			IP:        ip,
 			UserAgent: r.UserAgent(),
 			Referer:   r.Referer(),
+			AliasID:   link.AliasID,
 		})
 	}
 
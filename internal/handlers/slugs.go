@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/slug"
+)
+
+const maxSuggestions = 3
+
+type slugCheckResponse struct {
+	Domain      string   `json:"domain"`
+	Slug        string   `json:"slug"`
+	Available   bool     `json:"available"`
+	Reason      string   `json:"reason,omitempty"` // "invalid", "reserved" or "taken"
+	Error       string   `json:"error,omitempty"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// CheckSlug reports whether slug can be used on domain. Taken and reserved
+// slugs come with a few available suggestions.
+func (h *LinkHandler) CheckSlug(w http.ResponseWriter, r *http.Request) {
+	domain := config.CanonicalDomain(r.URL.Query().Get("domain"))
+	if domain == "" {
+		jsonError(w, "domain is required", http.StatusBadRequest)
+		return
+	}
+	if !h.Cfg.IsDomainAllowed(domain) {
+		jsonError(w, "domain not allowed", http.StatusBadRequest)
+		return
+	}
+	s := h.Cfg.NormalizeSlug(domain, r.URL.Query().Get("slug"))
+	if s == "" {
+		jsonError(w, "slug is required", http.StatusBadRequest)
+		return
+	}
+
+	slugExists := models.SlugExists
+	if h.Cfg.SettingsFor(domain).CaseInsensitive {
+		slugExists = models.SlugExistsFold
+	}
+	available := func(candidate string) (bool, error) {
+		exists, err := slugExists(h.DB, candidate, domain)
+		return !exists, err
+	}
+
+	resp := slugCheckResponse{Domain: domain, Slug: s, Suggestions: []string{}}
+	validator := h.Cfg.SlugValidator()
+	if err := validator.Validate(s); err != nil {
+		resp.Reason = "invalid"
+		if errors.Is(err, slug.ErrReserved) {
+			resp.Reason = "reserved"
+		}
+		resp.Error = err.Error()
+	} else {
+		free, err := available(s)
+		if err != nil {
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		resp.Available = free
+		if !free {
+			resp.Reason = "taken"
+		}
+	}
+
+	if resp.Reason == "taken" || resp.Reason == "reserved" {
+		suggestions, err := slug.Suggest(s, maxSuggestions, validator, available)
+		if err != nil {
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		resp.Suggestions = append(resp.Suggestions, suggestions...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
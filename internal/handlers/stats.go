@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+const (
+	defaultStatsLimit = 10
+	maxStatsLimit     = 100
+)
+
+type slugStat struct {
+	AliasID int64  `json:"alias_id"` // 0 for the link's own slug
+	Slug    string `json:"slug"`
+	Domain  string `json:"domain"`
+	Clicks  int    `json:"clicks"`
+}
+
+type linkStatsResponse struct {
+	LinkID           int64                    `json:"link_id"`
+	Clicks           int                      `json:"clicks"`
+	ClicksToday      int                      `json:"clicks_today"`
+	ClicksThisWeek   int                      `json:"clicks_this_week"`
+	ClicksPrevWeek   int                      `json:"clicks_prev_week"`
+	Referrers        []models.ValueCount      `json:"referrers"`
+	Countries        []models.ValueCount      `json:"countries"`
+	Browsers         []models.ValueCount      `json:"browsers"`
+	Devices          []models.ValueCount      `json:"devices"`
+	Slugs            []slugStat               `json:"slugs"`
+	HistoricalClicks *models.HistoricalClicks `json:"historical_clicks"`
+}
+
+// Stats returns a link's click totals and its top referrers, countries,
+// browsers and devices, the same figures as its analytics page. ?limit=
+// caps each breakdown.
+func (h *LinkHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	link, ok := h.linkFromURL(w, r)
+	if !ok {
+		return
+	}
+	limit := defaultStatsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			jsonError(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxStatsLimit)
+	}
+
+	resp, err := h.linkStats(link, limit)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *LinkHandler) linkStats(link *models.Link, limit int) (*linkStatsResponse, error) {
+	resp := &linkStatsResponse{LinkID: link.ID, Slugs: []slugStat{}}
+	var err error
+	for _, total := range []struct {
+		dst *int
+		fn  func(*sql.DB, int64) (int, error)
+	}{
+		{&resp.Clicks, models.ClickCountForLink},
+		{&resp.ClicksToday, models.ClicksTodayForLink},
+		{&resp.ClicksThisWeek, models.ClicksThisWeekForLink},
+		{&resp.ClicksPrevWeek, models.ClicksPrevWeekForLink},
+	} {
+		if *total.dst, err = total.fn(h.DB, link.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	scope := models.LinkClicks(link.ID)
+	for _, breakdown := range []struct {
+		dst *[]models.ValueCount
+		dim models.Dimension
+	}{
+		{&resp.Referrers, models.DimensionReferrer},
+		{&resp.Countries, models.DimensionCountry},
+		{&resp.Browsers, models.DimensionBrowser},
+		{&resp.Devices, models.DimensionDevice},
+	} {
+		if *breakdown.dst, err = models.TopValues(h.DB, scope, breakdown.dim, limit); err != nil {
+			return nil, err
+		}
+	}
+
+	slugs, err := models.ClicksByAliasForLink(h.DB, link)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range slugs {
+		resp.Slugs = append(resp.Slugs, slugStat{c.AliasID, c.Slug, c.Domain, c.Count})
+	}
+	if resp.HistoricalClicks, err = models.GetHistoricalClicks(h.DB, link.ID); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return resp, nil
+}
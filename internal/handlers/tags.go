@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/tags"
+)
+
+// tagList is the tags field of link requests. It accepts a JSON array of
+// tags or, for older clients, a comma-separated string, and holds the
+// normalized comma-separated form either way.
+type tagList string
+
+func (t *tagList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*t = tagList(tags.Join(tags.Clean(list)))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("tags must be an array of strings or a comma-separated string")
+	}
+	*t = tagList(tags.Join(tags.Parse(s)))
+	return nil
+}
+
+type TagHandler struct {
+	DB *sql.DB
+}
+
+type tagListResponse struct {
+	Tags []models.TagCount `json:"tags"`
+}
+
+// List returns every tag in use with its link and click counts.
+func (h *TagHandler) List(w http.ResponseWriter, r *http.Request) {
+	list, err := models.ListTags(h.DB)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []models.TagCount{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tagListResponse{Tags: list})
+}
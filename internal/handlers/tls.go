@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/scmmishra/dubly/internal/config"
+)
+
+// TLSAskHandler answers Caddy's on_demand_tls "ask" requests. It returns 200
+// only for configured domains and their aliases so certificates are never
+// issued for arbitrary hosts pointed at the server.
+type TLSAskHandler struct {
+	Cfg *config.Config
+}
+
+func (h *TLSAskHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Caddy can't send custom headers on ask requests, so the token travels
+	// in the query string of the configured ask URL.
+	if h.Cfg.TLSAskToken != "" {
+		token := r.URL.Query().Get("token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.Cfg.TLSAskToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	domain := config.CanonicalDomain(r.URL.Query().Get("domain"))
+	if domain == "" {
+		http.Error(w, "domain is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.Cfg.ServesHost(domain) {
+		http.Error(w, "domain not allowed", http.StatusForbidden)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
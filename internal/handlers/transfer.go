@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/cache"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/transfer"
+)
+
+const maxImportSize = 100 << 20 // 100 MB
+
+type TransferHandler struct {
+	DB    *sql.DB
+	Cfg   *config.Config
+	Cache *cache.LinkCache
+}
+
+// Export streams every link, and with include=clicks every click, as CSV or
+// NDJSON.
+func (h *TransferHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format, err := transfer.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	includeClicks := false
+	for _, inc := range strings.Split(r.URL.Query().Get("include"), ",") {
+		includeClicks = includeClicks || strings.TrimSpace(inc) == "clicks"
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+format.Filename(time.Now().UTC())+`"`)
+	if err := transfer.Export(h.DB, w, format, includeClicks); err != nil {
+		// Headers are gone by now; all we can do is cut the stream short
+		log.Printf("export: %v", err)
+	}
+}
+
+// Import loads a file produced by Export, or an export from Bitly, YOURLS,
+// Shlink or a browser. The format comes from ?format= or the Content-Type;
+// ?conflict= picks skip, overwrite or rename for taken slugs, ?domain= puts
+// links from unconfigured domains on one of ours, ?historical_clicks=true
+// keeps other tools' click totals and ?dry_run=true validates without
+// writing.
+func (h *TransferHandler) Import(w http.ResponseWriter, r *http.Request) {
+	formatName := r.URL.Query().Get("format")
+	if formatName == "" {
+		formatName = formatFromContentType(r.Header.Get("Content-Type"))
+	}
+	format, err := transfer.ParseImportFormat(formatName)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	conflict, err := transfer.ParseConflict(r.URL.Query().Get("conflict"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	historical, _ := strconv.ParseBool(r.URL.Query().Get("historical_clicks"))
+
+	im := &transfer.Importer{DB: h.DB, Cfg: h.Cfg, Cache: h.Cache}
+	summary, err := im.Import(http.MaxBytesReader(w, r.Body, maxImportSize), transfer.Options{
+		Format:           format,
+		Conflict:         conflict,
+		Domain:           r.URL.Query().Get("domain"),
+		HistoricalClicks: historical,
+		DryRun:           dryRun,
+	})
+	if err != nil {
+		writeImportError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// formatFromContentType maps an upload's Content-Type to a format name,
+// leaving it empty for ParseFormat's default.
+func formatFromContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-ndjson", "application/jsonl", "application/json":
+		return string(transfer.NDJSON)
+	case "text/html":
+		return string(transfer.Bookmarks)
+	}
+	return ""
+}
+
+func writeImportError(w http.ResponseWriter, err error) {
+	var inputErr *transfer.InputError
+	var tooBig *http.MaxBytesError
+	switch {
+	case errors.As(err, &inputErr):
+		jsonError(w, err.Error(), http.StatusBadRequest)
+	case errors.As(err, &tooBig):
+		jsonError(w, "import is larger than 100 MB", http.StatusRequestEntityTooLarge)
+	default:
+		log.Printf("import: %v", err)
+		jsonError(w, "import failed", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/utm"
+)
+
+var errUnknownPreset = errors.New("utm preset not found")
+
+// utmFields are the UTM parameters accepted by link requests. A set field
+// wins over the preset named by UTMPreset, which in turn wins over the
+// link's current values, or those in a new destination. An empty string
+// removes one.
+type utmFields struct {
+	UTMPreset   string  `json:"utm_preset"`
+	UTMSource   *string `json:"utm_source"`
+	UTMMedium   *string `json:"utm_medium"`
+	UTMCampaign *string `json:"utm_campaign"`
+	UTMTerm     *string `json:"utm_term"`
+	UTMContent  *string `json:"utm_content"`
+}
+
+func (f *utmFields) values() map[string]*string {
+	return map[string]*string{
+		"utm_source":   f.UTMSource,
+		"utm_medium":   f.UTMMedium,
+		"utm_campaign": f.UTMCampaign,
+		"utm_term":     f.UTMTerm,
+		"utm_content":  f.UTMContent,
+	}
+}
+
+// set reports whether the request touches UTM parameters at all.
+func (f *utmFields) set() bool {
+	if f.UTMPreset != "" {
+		return true
+	}
+	for _, v := range f.values() {
+		if v != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets the requested UTM parameters on l, which then replace any on
+// its destination, so clearing every field clears them there too. It
+// returns errUnknownPreset when UTMPreset names no preset.
+func (f *utmFields) apply(db models.Querier, l *models.Link) error {
+	if !f.set() {
+		return nil
+	}
+	values := l.UTM()
+	if f.UTMPreset != "" {
+		p, err := models.GetUTMPresetByName(db, f.UTMPreset)
+		if err == sql.ErrNoRows {
+			return errUnknownPreset
+		}
+		if err != nil {
+			return err
+		}
+		for k, v := range p.UTM() {
+			if v != "" {
+				values[k] = v
+			}
+		}
+	}
+	for k, v := range f.values() {
+		if v != nil {
+			values[k] = *v
+		}
+	}
+	l.Destination = utm.Strip(l.Destination)
+	l.SetUTM(values)
+	return nil
+}
+
+type UTMPresetHandler struct {
+	DB *sql.DB
+}
+
+type utmPresetRequest struct {
+	Name        string `json:"name"`
+	UTMSource   string `json:"utm_source"`
+	UTMMedium   string `json:"utm_medium"`
+	UTMCampaign string `json:"utm_campaign"`
+	UTMTerm     string `json:"utm_term"`
+	UTMContent  string `json:"utm_content"`
+}
+
+type utmPresetListResponse struct {
+	Presets []models.UTMPreset `json:"presets"`
+}
+
+func (h *UTMPresetHandler) List(w http.ResponseWriter, r *http.Request) {
+	list, err := models.ListUTMPresets(h.DB)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []models.UTMPreset{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(utmPresetListResponse{Presets: list})
+}
+
+func (h *UTMPresetHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req utmPresetRequest
+	if err := decodeJSON(r, &req); err != nil {
+		jsonError(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	p := &models.UTMPreset{
+		Name:        req.Name,
+		UTMSource:   req.UTMSource,
+		UTMMedium:   req.UTMMedium,
+		UTMCampaign: req.UTMCampaign,
+		UTMTerm:     req.UTMTerm,
+		UTMContent:  req.UTMContent,
+	}
+	if err := p.Validate(); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := models.CreateUTMPreset(h.DB, p); err != nil {
+		if isConstraintError(err) {
+			jsonError(w, "utm preset name already exists", http.StatusConflict)
+			return
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+func (h *UTMPresetHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		jsonError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if err := models.DeleteUTMPreset(h.DB, id); err != nil {
+		if err == sql.ErrNoRows {
+			jsonError(w, "not found", http.StatusNotFound)
+			return
+		}
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,340 @@
+// Package linkquery parses the link search language used by the admin
+// search box and GET /api/links?q=. A query is a list of space-separated
+// terms:
+//
+//	tag:launch domain:go.example.com clicks:>100 created:<2026-01-01 -is:deleted
+//
+// Filters are written key:value, and a leading - negates one. Values may
+// be double-quoted to include spaces, as in tag:"spring sale". Everything
+// else is full-text search, with double quotes marking exact phrases.
+//
+// Keys:
+//
+//	tag:NAME         tagged NAME (-tag: not tagged NAME)
+//	domain:HOST      on HOST (-domain: on any other domain)
+//	is:active        active links; is:inactive and is:deleted the reverse
+//	clicks:N         N clicks; also clicks:>N, >=N, <N and <=N
+//	created:DATE     created on DATE; also <, <=, > and >= DATE
+//	clicked:DATE     last clicked on DATE, with the same comparisons
+//
+// DATE is YYYY-MM-DD, or RFC 3339 for a moment in time.
+package linkquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/tags"
+)
+
+// Error describes a term Parse couldn't make sense of.
+type Error struct {
+	Term string // the term as written, empty for errors about the whole query
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Term == "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", e.Term, e.Msg)
+}
+
+// Parse returns the filter described by query.
+func Parse(query string) (models.LinkFilter, error) {
+	var f models.LinkFilter
+	err := Apply(&f, query)
+	return f, err
+}
+
+// Apply narrows f by the terms of query, on top of whatever f already
+// filters on. Search text is appended to f.Search.
+func Apply(f *models.LinkFilter, query string) error {
+	terms, err := split(query)
+	if err != nil {
+		return err
+	}
+	var search []string
+	for _, t := range terms {
+		if t.key == "" {
+			if t.negated {
+				return &Error{t.raw, "only filters can be negated; plain words can't"}
+			}
+			if t.quoted {
+				search = append(search, `"`+t.value+`"`)
+			} else {
+				search = append(search, t.value)
+			}
+			continue
+		}
+		if err := apply(f, t); err != nil {
+			return &Error{t.raw, err.Error()}
+		}
+	}
+	if len(search) > 0 {
+		f.Search = strings.TrimSpace(f.Search + " " + strings.Join(search, " "))
+	}
+	return nil
+}
+
+// term is one space-separated part of a query.
+type term struct {
+	raw     string // as written, for errors
+	negated bool
+	key     string // lowercased filter key, empty for search text
+	value   string // unquoted
+	quoted  bool
+}
+
+var keys = map[string]bool{"tag": true, "domain": true, "is": true, "clicks": true, "created": true, "clicked": true}
+
+// split breaks query into terms. A word:value term is only a filter when
+// word is a known key, so URLs and other text with colons still search as
+// text; an unknown key that looks like an attempt at a filter is an error.
+func split(query string) ([]term, error) {
+	var terms []term
+	s := query
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return terms, nil
+		}
+		end, err := termEnd(s)
+		if err != nil {
+			return nil, err
+		}
+		raw := s[:end]
+		s = s[end:]
+
+		t := term{raw: raw}
+		body := raw
+		if len(body) > 1 && body[0] == '-' {
+			t.negated = true
+			body = body[1:]
+		}
+		if key, value, ok := strings.Cut(body, ":"); ok && !strings.HasPrefix(key, `"`) {
+			switch {
+			case keys[strings.ToLower(key)]:
+				t.key = strings.ToLower(key)
+				body = value
+			case isWord(key) && !strings.HasPrefix(value, "//"):
+				return nil, &Error{raw, fmt.Sprintf("unknown filter %q; use tag, domain, is, clicks, created or clicked", key+":")}
+			}
+		}
+		if unquoted, ok := strings.CutPrefix(body, `"`); ok {
+			t.value, t.quoted = strings.TrimSuffix(unquoted, `"`), true
+		} else {
+			t.value = body
+		}
+		if t.key != "" && t.value == "" {
+			return nil, &Error{raw, "missing value"}
+		}
+		if t.key == "" && t.negated && !strings.ContainsFunc(t.value, isWordChar) {
+			// A lone "-" or "--" is just punctuation
+			t.negated, t.value = false, raw
+		}
+		terms = append(terms, t)
+	}
+}
+
+// termEnd finds the end of the term starting s: the next space outside
+// double quotes.
+func termEnd(s string) (int, error) {
+	inQuote := false
+	for i, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case unicode.IsSpace(r) && !inQuote:
+			return i, nil
+		}
+	}
+	if inQuote {
+		return 0, &Error{Msg: fmt.Sprintf("unclosed quote in %q", s)}
+	}
+	return len(s), nil
+}
+
+func apply(f *models.LinkFilter, t term) error {
+	switch t.key {
+	case "tag":
+		tag := tags.Normalize(t.value)
+		if t.negated {
+			f.ExcludeTags = append(f.ExcludeTags, tag)
+		} else {
+			f.Tags = append(f.Tags, tag)
+		}
+	case "domain":
+		domain := config.CanonicalDomain(t.value)
+		if t.negated {
+			f.ExcludeDomains = append(f.ExcludeDomains, domain)
+		} else if f.Domain != "" && f.Domain != domain {
+			return fmt.Errorf("a link has only one domain, and this filter already asks for %s", f.Domain)
+		} else {
+			f.Domain = domain
+		}
+	case "is":
+		return applyStatus(f, strings.ToLower(t.value), t.negated)
+	case "clicks":
+		return applyClicks(f, t.value, t.negated)
+	case "created":
+		return applyTime(&f.CreatedAfter, &f.CreatedBefore, t.value, t.negated)
+	case "clicked":
+		return applyTime(&f.LastClickedAfter, &f.LastClickedBefore, t.value, t.negated)
+	}
+	return nil
+}
+
+func applyStatus(f *models.LinkFilter, value string, negated bool) error {
+	var active bool
+	switch value {
+	case "active":
+		active = true
+	case "inactive", "deleted":
+	default:
+		return fmt.Errorf("unknown status %q; use active, inactive or deleted", value)
+	}
+	if negated {
+		active = !active
+	}
+	if f.Active != nil && *f.Active != active {
+		return fmt.Errorf("contradicts an earlier status filter")
+	}
+	f.Active = &active
+	return nil
+}
+
+// comparison splits a value into its operator (one of =, <, <=, > and >=)
+// and operand.
+func comparison(value string) (string, string) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if rest, ok := strings.CutPrefix(value, op); ok {
+			return op, rest
+		}
+	}
+	return "=", value
+}
+
+// negate returns the operator matching exactly what op doesn't.
+func negate(op string) (string, error) {
+	switch op {
+	case "<":
+		return ">=", nil
+	case "<=":
+		return ">", nil
+	case ">":
+		return "<=", nil
+	case ">=":
+		return "<", nil
+	}
+	return "", fmt.Errorf("can't negate an exact match; use a comparison such as < or >")
+}
+
+func applyClicks(f *models.LinkFilter, value string, negated bool) error {
+	op, operand := comparison(value)
+	n, err := strconv.Atoi(operand)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%q isn't a number of clicks", operand)
+	}
+	if negated {
+		if op, err = negate(op); err != nil {
+			return err
+		}
+	}
+	// Clicks are whole numbers, so strict bounds become inclusive ones
+	switch op {
+	case "=":
+		tightenMin(&f.MinClicks, n)
+		tightenMax(&f.MaxClicks, n)
+	case ">":
+		tightenMin(&f.MinClicks, n+1)
+	case ">=":
+		tightenMin(&f.MinClicks, n)
+	case "<":
+		tightenMax(&f.MaxClicks, n-1)
+	case "<=":
+		tightenMax(&f.MaxClicks, n)
+	}
+	return nil
+}
+
+func tightenMin(bound **int, n int) {
+	if *bound == nil || n > **bound {
+		*bound = &n
+	}
+}
+
+func tightenMax(bound **int, n int) {
+	if *bound == nil || n < **bound {
+		*bound = &n
+	}
+}
+
+// applyTime narrows the half-open range [after, before) by a date
+// comparison. A date stands for the whole day, so created:>2026-01-01
+// starts the next day and created:2026-01-01 covers just that day.
+func applyTime(after, before **time.Time, value string, negated bool) error {
+	op, operand := comparison(value)
+	start, end, err := parseWhen(operand)
+	if err != nil {
+		return err
+	}
+	if negated {
+		if op, err = negate(op); err != nil {
+			return err
+		}
+	}
+	switch op {
+	case "=":
+		tightenAfter(after, start)
+		tightenBefore(before, end)
+	case ">":
+		tightenAfter(after, end)
+	case ">=":
+		tightenAfter(after, start)
+	case "<":
+		tightenBefore(before, start)
+	case "<=":
+		tightenBefore(before, end)
+	}
+	return nil
+}
+
+// parseWhen returns the span a DATE value covers: a whole UTC day for a
+// date, or the second containing an RFC 3339 time, the precision times are
+// compared at.
+func parseWhen(s string) (time.Time, time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, t.AddDate(0, 0, 1), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		t = t.Truncate(time.Second)
+		return t, t.Add(time.Second), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("%q isn't a date; use YYYY-MM-DD", s)
+}
+
+func tightenAfter(bound **time.Time, t time.Time) {
+	if *bound == nil || t.After(**bound) {
+		*bound = &t
+	}
+}
+
+func tightenBefore(bound **time.Time, t time.Time) {
+	if *bound == nil || t.Before(**bound) {
+		*bound = &t
+	}
+}
+
+func isWord(s string) bool {
+	return s != "" && !strings.ContainsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && r != '_' })
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,123 @@
+package linkquery
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+func day(s string) *time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func num(n int) *int { return &n }
+
+func flag(b bool) *bool { return &b }
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  models.LinkFilter
+	}{
+		{"", models.LinkFilter{}},
+		{"spring sale", models.LinkFilter{Search: "spring sale"}},
+		{`"spring sale" docs`, models.LinkFilter{Search: `"spring sale" docs`}},
+		{"https://example.com/a", models.LinkFilter{Search: "https://example.com/a"}},
+		{"-", models.LinkFilter{Search: "-"}},
+		{
+			"tag:launch domain:Go.Example.com clicks:>100 created:<2026-01-01 -is:deleted",
+			models.LinkFilter{
+				Tags:          []string{"launch"},
+				Domain:        "go.example.com",
+				MinClicks:     num(101),
+				CreatedBefore: day("2026-01-01"),
+				Active:        flag(true),
+			},
+		},
+		{`TAG:"Spring Sale" -tag:old`, models.LinkFilter{Tags: []string{"spring sale"}, ExcludeTags: []string{"old"}}},
+		{"-domain:a.co -domain:b.co", models.LinkFilter{ExcludeDomains: []string{"a.co", "b.co"}}},
+		{"is:inactive", models.LinkFilter{Active: flag(false)}},
+		{"-is:active", models.LinkFilter{Active: flag(false)}},
+		{"clicks:5", models.LinkFilter{MinClicks: num(5), MaxClicks: num(5)}},
+		{"clicks:>=5 clicks:<10", models.LinkFilter{MinClicks: num(5), MaxClicks: num(9)}},
+		{"clicks:<=10 clicks:<=3", models.LinkFilter{MaxClicks: num(3)}},
+		{"-clicks:>100", models.LinkFilter{MaxClicks: num(100)}},
+		{"created:2026-03-01", models.LinkFilter{CreatedAfter: day("2026-03-01"), CreatedBefore: day("2026-03-02")}},
+		{"created:>2026-03-01", models.LinkFilter{CreatedAfter: day("2026-03-02")}},
+		{"created:<=2026-03-01", models.LinkFilter{CreatedBefore: day("2026-03-02")}},
+		{"-created:<2026-03-01", models.LinkFilter{CreatedAfter: day("2026-03-01")}},
+		{"clicked:>=2026-01-01 clicked:<2026-02-01", models.LinkFilter{LastClickedAfter: day("2026-01-01"), LastClickedBefore: day("2026-02-01")}},
+		{"docs tag:ai launch", models.LinkFilter{Search: "docs launch", Tags: []string{"ai"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_RFC3339(t *testing.T) {
+	f, err := Parse("created:>=2026-03-01T10:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC); f.CreatedAfter == nil || !f.CreatedAfter.Equal(want) {
+		t.Errorf("CreatedAfter = %v, want %v", f.CreatedAfter, want)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{`tag:"spring sale`, "unclosed quote"},
+		{"tga:launch", `tga:launch: unknown filter "tga:"`},
+		{"tag:", "tag:: missing value"},
+		{"clicks:>lots", `clicks:>lots: "lots" isn't a number of clicks`},
+		{"clicks:-1", "isn't a number of clicks"},
+		{"-clicks:5", "can't negate an exact match"},
+		{"created:<yesterday", `"yesterday" isn't a date`},
+		{"is:archived", `unknown status "archived"`},
+		{"is:active is:deleted", "contradicts an earlier status filter"},
+		{"domain:a.co domain:b.co", "only one domain"},
+		{"-launch", "-launch: only filters can be negated"},
+		{`-"spring sale"`, "only filters can be negated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			var qerr *Error
+			if !errors.As(err, &qerr) {
+				t.Fatalf("Parse(%q) err = %v, want an *Error", tt.query, err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse(%q) err = %q, want it to contain %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply_AddsToExistingFilter(t *testing.T) {
+	f := models.LinkFilter{Search: "docs", Tags: []string{"ai"}, MaxClicks: num(50)}
+	if err := Apply(&f, "tag:ml clicks:<100 guide"); err != nil {
+		t.Fatal(err)
+	}
+	want := models.LinkFilter{Search: "docs guide", Tags: []string{"ai", "ml"}, MaxClicks: num(50)}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("f = %+v, want %+v", f, want)
+	}
+}
@@ -5,26 +5,6 @@ import (
 	"fmt"
 )
 
-type ReferrerCount struct {
-	Domain string
-	Count  int
-}
-
-type CountryCount struct {
-	Country string
-	Count   int
-}
-
-type BrowserCount struct {
-	Browser string
-	Count   int
-}
-
-type DeviceCount struct {
-	DeviceType string
-	Count      int
-}
-
 type LinkWithClicks struct {
 	Link       Link
 	ClickCount int
@@ -89,90 +69,6 @@ func ClickCountsForLinks(db *sql.DB, ids []int64) (map[int64]int, error) {
 	return counts, rows.Err()
 }
 
-func TopReferrersForLink(db *sql.DB, linkID int64, limit int) ([]ReferrerCount, error) {
-	rows, err := db.Query(
-		`SELECT referer_domain, COUNT(*) as cnt FROM clicks WHERE link_id = ? AND referer_domain != '' GROUP BY referer_domain ORDER BY cnt DESC LIMIT ?`,
-		linkID, limit,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("top referrers: %w", err)
-	}
-	defer rows.Close()
-
-	var results []ReferrerCount
-	for rows.Next() {
-		var r ReferrerCount
-		if err := rows.Scan(&r.Domain, &r.Count); err != nil {
-			return nil, fmt.Errorf("scan referrer: %w", err)
-		}
-		results = append(results, r)
-	}
-	return results, rows.Err()
-}
-
-func TopCountriesForLink(db *sql.DB, linkID int64, limit int) ([]CountryCount, error) {
-	rows, err := db.Query(
-		`SELECT country, COUNT(*) as cnt FROM clicks WHERE link_id = ? AND country != '' GROUP BY country ORDER BY cnt DESC LIMIT ?`,
-		linkID, limit,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("top countries: %w", err)
-	}
-	defer rows.Close()
-
-	var results []CountryCount
-	for rows.Next() {
-		var c CountryCount
-		if err := rows.Scan(&c.Country, &c.Count); err != nil {
-			return nil, fmt.Errorf("scan country: %w", err)
-		}
-		results = append(results, c)
-	}
-	return results, rows.Err()
-}
-
-func TopBrowsersForLink(db *sql.DB, linkID int64, limit int) ([]BrowserCount, error) {
-	rows, err := db.Query(
-		`SELECT browser, COUNT(*) as cnt FROM clicks WHERE link_id = ? AND browser != '' GROUP BY browser ORDER BY cnt DESC LIMIT ?`,
-		linkID, limit,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("top browsers: %w", err)
-	}
-	defer rows.Close()
-
-	var results []BrowserCount
-	for rows.Next() {
-		var b BrowserCount
-		if err := rows.Scan(&b.Browser, &b.Count); err != nil {
-			return nil, fmt.Errorf("scan browser: %w", err)
-		}
-		results = append(results, b)
-	}
-	return results, rows.Err()
-}
-
-func TopDevicesForLink(db *sql.DB, linkID int64, limit int) ([]DeviceCount, error) {
-	rows, err := db.Query(
-		`SELECT device_type, COUNT(*) as cnt FROM clicks WHERE link_id = ? AND device_type != '' GROUP BY device_type ORDER BY cnt DESC LIMIT ?`,
-		linkID, limit,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("top devices: %w", err)
-	}
-	defer rows.Close()
-
-	var results []DeviceCount
-	for rows.Next() {
-		var d DeviceCount
-		if err := rows.Scan(&d.DeviceType, &d.Count); err != nil {
-			return nil, fmt.Errorf("scan device: %w", err)
-		}
-		results = append(results, d)
-	}
-	return results, rows.Err()
-}
-
 func TotalLinkCount(db *sql.DB) (int, error) {
 	var count int
 	err := db.QueryRow(`SELECT COUNT(*) FROM links WHERE is_active = 1`).Scan(&count)
@@ -193,12 +89,12 @@ func ClicksAllTime(db *sql.DB) (int, error) {
 
 func TopLinksByClicks(db *sql.DB, limit int) ([]LinkWithClicks, error) {
 	rows, err := db.Query(
-		`SELECT l.id, l.slug, l.domain, l.destination, l.title, l.tags, l.notes, l.is_active, l.created_at, l.updated_at, COUNT(c.id) as click_count
+		`SELECT `+qualifiedLinkColumns("l")+`, COUNT(c.id) AS counted
 		FROM links l
 		LEFT JOIN clicks c ON c.link_id = l.id
 		WHERE l.is_active = 1
 		GROUP BY l.id
-		ORDER BY click_count DESC
+		ORDER BY counted DESC
 		LIMIT ?`, limit,
 	)
 	if err != nil {
@@ -209,101 +105,10 @@ func TopLinksByClicks(db *sql.DB, limit int) ([]LinkWithClicks, error) {
 	var results []LinkWithClicks
 	for rows.Next() {
 		var lc LinkWithClicks
-		var active int
-		if err := rows.Scan(
-			&lc.Link.ID, &lc.Link.Slug, &lc.Link.Domain, &lc.Link.Destination,
-			&lc.Link.Title, &lc.Link.Tags, &lc.Link.Notes, &active,
-			&lc.Link.CreatedAt, &lc.Link.UpdatedAt, &lc.ClickCount,
-		); err != nil {
+		if err := scanLink(rows, &lc.Link, &lc.ClickCount); err != nil {
 			return nil, fmt.Errorf("scan link with clicks: %w", err)
 		}
-		lc.Link.IsActive = active == 1
-		lc.Link.FillShortURL()
 		results = append(results, lc)
 	}
 	return results, rows.Err()
 }
-
-func TopBrowsersGlobal(db *sql.DB, limit int) ([]BrowserCount, error) {
-	rows, err := db.Query(
-		`SELECT browser, COUNT(*) as cnt FROM clicks WHERE browser != '' GROUP BY browser ORDER BY cnt DESC LIMIT ?`,
-		limit,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("global browsers: %w", err)
-	}
-	defer rows.Close()
-
-	var results []BrowserCount
-	for rows.Next() {
-		var b BrowserCount
-		if err := rows.Scan(&b.Browser, &b.Count); err != nil {
-			return nil, fmt.Errorf("scan browser: %w", err)
-		}
-		results = append(results, b)
-	}
-	return results, rows.Err()
-}
-
-func TopDevicesGlobal(db *sql.DB, limit int) ([]DeviceCount, error) {
-	rows, err := db.Query(
-		`SELECT device_type, COUNT(*) as cnt FROM clicks WHERE device_type != '' GROUP BY device_type ORDER BY cnt DESC LIMIT ?`,
-		limit,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("global devices: %w", err)
-	}
-	defer rows.Close()
-
-	var results []DeviceCount
-	for rows.Next() {
-		var d DeviceCount
-		if err := rows.Scan(&d.DeviceType, &d.Count); err != nil {
-			return nil, fmt.Errorf("scan device: %w", err)
-		}
-		results = append(results, d)
-	}
-	return results, rows.Err()
-}
-
-func TopCountriesGlobal(db *sql.DB, limit int) ([]CountryCount, error) {
-	rows, err := db.Query(
-		`SELECT country, COUNT(*) as cnt FROM clicks WHERE country != '' GROUP BY country ORDER BY cnt DESC LIMIT ?`,
-		limit,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("global countries: %w", err)
-	}
-	defer rows.Close()
-
-	var results []CountryCount
-	for rows.Next() {
-		var c CountryCount
-		if err := rows.Scan(&c.Country, &c.Count); err != nil {
-			return nil, fmt.Errorf("scan country: %w", err)
-		}
-		results = append(results, c)
-	}
-	return results, rows.Err()
-}
-
-func TopReferrersGlobal(db *sql.DB, limit int) ([]ReferrerCount, error) {
-	rows, err := db.Query(
-		`SELECT referer_domain, COUNT(*) as cnt FROM clicks WHERE referer_domain != '' GROUP BY referer_domain ORDER BY cnt DESC LIMIT ?`,
-		limit,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("global referrers: %w", err)
-	}
-	defer rows.Close()
-
-	var results []ReferrerCount
-	for rows.Next() {
-		var r ReferrerCount
-		if err := rows.Scan(&r.Domain, &r.Count); err != nil {
-			return nil, fmt.Errorf("scan referrer: %w", err)
-		}
-		results = append(results, r)
-	}
-	return results, rows.Err()
-}
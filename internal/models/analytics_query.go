@@ -0,0 +1,404 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/tags"
+)
+
+// Dimension is a property of clicks that analytics group and filter by.
+type Dimension string
+
+const (
+	DimensionLink     Dimension = "link"
+	DimensionTag      Dimension = "tag"
+	DimensionDomain   Dimension = "domain"
+	DimensionCountry  Dimension = "country"
+	DimensionRegion   Dimension = "region"
+	DimensionCity     Dimension = "city"
+	DimensionReferrer Dimension = "referrer"
+	DimensionBrowser  Dimension = "browser"
+	DimensionOS       Dimension = "os"
+	DimensionDevice   Dimension = "device"
+)
+
+// Dimensions lists every dimension, in the order errors name them.
+var Dimensions = []Dimension{
+	DimensionLink, DimensionTag, DimensionDomain, DimensionCountry, DimensionRegion,
+	DimensionCity, DimensionReferrer, DimensionBrowser, DimensionOS, DimensionDevice,
+}
+
+// dimensionExprs are the SQL expressions clicks are grouped by. Domain needs
+// the click's link joined as l, and tag its tags joined as t.
+var dimensionExprs = map[Dimension]string{
+	DimensionLink:     "c.link_id",
+	DimensionTag:      "t.name",
+	DimensionDomain:   "l.domain",
+	DimensionCountry:  "COALESCE(c.country, '')",
+	DimensionRegion:   "COALESCE(c.region, '')",
+	DimensionCity:     "COALESCE(c.city, '')",
+	DimensionReferrer: "COALESCE(c.referer_domain, '')",
+	DimensionBrowser:  "COALESCE(c.browser, '')",
+	DimensionOS:       "COALESCE(c.os, '')",
+	DimensionDevice:   "COALESCE(c.device_type, '')",
+}
+
+// ParseDimension returns the dimension named s.
+func ParseDimension(s string) (Dimension, error) {
+	d := Dimension(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := dimensionExprs[d]; !ok {
+		names := make([]string, len(Dimensions))
+		for i, d := range Dimensions {
+			names[i] = string(d)
+		}
+		return "", fmt.Errorf("unknown dimension %q: use %s", s, strings.Join(names, ", "))
+	}
+	return d, nil
+}
+
+// Interval is the width of the buckets a click series is counted in.
+type Interval string
+
+const (
+	IntervalHour  Interval = "hour"
+	IntervalDay   Interval = "day"
+	IntervalWeek  Interval = "week"
+	IntervalMonth Interval = "month"
+)
+
+// ParseInterval returns the interval named s. Empty means no series.
+func ParseInterval(s string) (Interval, error) {
+	switch iv := Interval(strings.ToLower(s)); iv {
+	case "", IntervalHour, IntervalDay, IntervalWeek, IntervalMonth:
+		return iv, nil
+	}
+	return "", fmt.Errorf("unknown interval %q: use hour, day, week or month", s)
+}
+
+// truncate returns the start of the bucket t falls in, in t's location.
+// Weeks start on Monday.
+func (iv Interval) truncate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch iv {
+	case IntervalHour:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	case IntervalWeek:
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+	case IntervalMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// next returns the start of the bucket after the one starting at t.
+func (iv Interval) next(t time.Time) time.Time {
+	switch iv {
+	case IntervalHour:
+		return t.Add(time.Hour)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// MaxClickBuckets caps the buckets in a series, however long its range.
+const MaxClickBuckets = 1000
+
+// ErrTooManyBuckets is returned for a series with more than MaxClickBuckets
+// buckets.
+var ErrTooManyBuckets = fmt.Errorf("more than %d intervals in range", MaxClickBuckets)
+
+// ClickFilters keep clicks whose value for each dimension is one of the
+// listed values. Links are given by ID and tags are matched cleaned.
+type ClickFilters map[Dimension][]string
+
+// ClickQuery selects clicks and says how to count them. From is inclusive
+// and To exclusive; either may be nil. Clicks are counted per distinct
+// combination of the GroupBy dimensions, most clicked first, and with an
+// Interval also per bucket in Location (UTC when nil). Limit caps the
+// groups; 0 keeps them all. Grouping by tag counts a click once for each
+// tag on its link.
+type ClickQuery struct {
+	From, To *time.Time
+	Filters  ClickFilters
+	Campaign *Campaign // only the campaign's clicks, within its dates
+	GroupBy  []Dimension
+	Interval Interval
+	Location *time.Location
+	Limit    int
+
+	skipEmpty bool // leave out groups with an empty value
+}
+
+// LinkClicks selects the clicks on one link.
+func LinkClicks(id int64) ClickQuery {
+	return ClickQuery{Filters: ClickFilters{DimensionLink: {fmt.Sprint(id)}}}
+}
+
+// TagClicks selects the clicks on links tagged name.
+func TagClicks(name string) ClickQuery {
+	return ClickQuery{Filters: ClickFilters{DimensionTag: {name}}}
+}
+
+// CampaignClicks selects the clicks that count towards a campaign.
+func CampaignClicks(c *Campaign) ClickQuery {
+	return ClickQuery{Campaign: c}
+}
+
+// ClickGroup is the clicks on one combination of grouped values.
+type ClickGroup struct {
+	Values []string // one per ClickQuery.GroupBy dimension
+	Clicks int
+	Series []ClickBucket // with an Interval, every bucket in range
+}
+
+// ClickBucket is the clicks in the bucket starting at Start.
+type ClickBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int       `json:"clicks"`
+}
+
+// ValueCount is one value of a dimension and its clicks.
+type ValueCount struct {
+	Value  string `json:"value"`
+	Clicks int    `json:"clicks"`
+}
+
+// quarterHour is the UTC quarter hour a click falls in, as "2006-01-02
+// 15:04". Series are counted by quarter hour in SQL and folded into buckets
+// in Go, where any time zone's bucket boundaries fall on a quarter hour.
+const quarterHour = `substr(c.clicked_at, 1, 14) || printf('%02d', CAST(substr(c.clicked_at, 15, 2) AS INTEGER) / 15 * 15)`
+
+// from returns the FROM clause and WHERE condition selecting q's clicks,
+// with the condition's args.
+func (q ClickQuery) from() (string, string, []any) {
+	dims := map[Dimension]bool{}
+	for _, d := range q.GroupBy {
+		dims[d] = true
+	}
+	for d := range q.Filters {
+		dims[d] = true
+	}
+
+	from := "clicks c"
+	if dims[DimensionDomain] {
+		from += " JOIN links l ON l.id = c.link_id"
+	}
+	if slices.Contains(q.GroupBy, DimensionTag) {
+		from += " JOIN link_tags lt ON lt.link_id = c.link_id JOIN tags t ON t.id = lt.tag_id"
+	}
+
+	where := "1=1"
+	var args []any
+	if q.From != nil {
+		where += " AND c.clicked_at >= ?"
+		args = append(args, q.From.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if q.To != nil {
+		where += " AND c.clicked_at < ?"
+		args = append(args, q.To.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if q.Campaign != nil {
+		window, windowArgs := q.Campaign.clickWindow("c.clicked_at")
+		where += " AND c.link_id IN (SELECT link_id FROM campaign_links WHERE campaign_id = ?) AND " + window
+		args = append(append(args, q.Campaign.ID), windowArgs...)
+	}
+	for _, d := range Dimensions {
+		values, ok := q.Filters[d]
+		if !ok {
+			continue
+		}
+		if d == DimensionTag {
+			values = tags.Clean(values)
+		}
+		if len(values) == 0 {
+			where += " AND 0"
+			continue
+		}
+		marks := placeholders(len(values))
+		if d == DimensionTag {
+			where += " AND c.link_id IN (SELECT ft.link_id FROM link_tags ft JOIN tags ftn ON ftn.id = ft.tag_id WHERE ftn.name IN (" + marks + "))"
+		} else {
+			where += " AND " + dimensionExprs[d] + " IN (" + marks + ")"
+		}
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	if q.skipEmpty {
+		for _, d := range q.GroupBy {
+			where += " AND " + dimensionExprs[d] + " != ''"
+		}
+	}
+	return from, where, args
+}
+
+// QueryClicks counts the clicks q selects. Without GroupBy it returns a
+// single group of every selected click.
+func QueryClicks(db Querier, q ClickQuery) ([]ClickGroup, error) {
+	for _, d := range q.GroupBy {
+		if _, ok := dimensionExprs[d]; !ok {
+			return nil, fmt.Errorf("unknown dimension %q", d)
+		}
+	}
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	var buckets []time.Time
+	if q.Interval != "" && q.From != nil && q.To != nil {
+		for b := q.Interval.truncate(q.From.In(loc)); b.Before(*q.To); b = q.Interval.next(b) {
+			if len(buckets) == MaxClickBuckets {
+				return nil, ErrTooManyBuckets
+			}
+			buckets = append(buckets, b)
+		}
+	}
+
+	from, where, args := q.from()
+	exprs := make([]string, len(q.GroupBy))
+	for i, d := range q.GroupBy {
+		exprs[i] = dimensionExprs[d]
+	}
+
+	query := `SELECT COUNT(*) FROM ` + from + ` WHERE ` + where
+	groupArgs := slices.Clip(args)
+	if len(exprs) > 0 {
+		groupBy := positions(len(exprs))
+		query = `SELECT ` + strings.Join(exprs, ", ") + `, COUNT(*) AS clicks FROM ` + from + ` WHERE ` + where +
+			` GROUP BY ` + groupBy + ` ORDER BY clicks DESC, ` + groupBy
+		if q.Limit > 0 {
+			query += ` LIMIT ?`
+			groupArgs = append(groupArgs, q.Limit)
+		}
+	}
+	groups, err := scanClickGroups(db, query, groupArgs, len(exprs))
+	if err != nil {
+		return nil, err
+	}
+	if q.Interval == "" || len(groups) == 0 {
+		return groups, nil
+	}
+
+	// Count each group's clicks by quarter hour, then fold them into buckets
+	series := `SELECT ` + strings.Join(append(exprs, quarterHour), ", ") + `, COUNT(*) FROM ` + from + ` WHERE ` + where
+	if q.Limit > 0 && len(groups) == q.Limit && len(exprs) > 0 {
+		row := "(" + placeholders(len(exprs)) + ")"
+		series += ` AND (` + strings.Join(exprs, ", ") + `) IN (VALUES ` + strings.TrimSuffix(strings.Repeat(row+", ", len(groups)), ", ") + `)`
+		for _, g := range groups {
+			for _, v := range g.Values {
+				args = append(args, v)
+			}
+		}
+	}
+	series += ` GROUP BY ` + positions(len(exprs)+1)
+	counts, err := scanClickGroups(db, series, args, len(exprs)+1)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]map[time.Time]int, len(groups))
+	for _, c := range counts {
+		start, err := time.ParseInLocation("2006-01-02 15:04", c.Values[len(exprs)], time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("click time %q: %w", c.Values[len(exprs)], err)
+		}
+		key := strings.Join(c.Values[:len(exprs)], "\x00")
+		if byKey[key] == nil {
+			byKey[key] = map[time.Time]int{}
+		}
+		byKey[key][q.Interval.truncate(start.In(loc))] += c.Clicks
+	}
+	if buckets == nil {
+		// Open ranges span the buckets that saw clicks
+		var first, last time.Time
+		for _, m := range byKey {
+			for b := range m {
+				if first.IsZero() || b.Before(first) {
+					first = b
+				}
+				if b.After(last) {
+					last = b
+				}
+			}
+		}
+		for b := first; !b.After(last); b = q.Interval.next(b) {
+			if len(buckets) == MaxClickBuckets {
+				return nil, ErrTooManyBuckets
+			}
+			buckets = append(buckets, b)
+		}
+	}
+	for i := range groups {
+		m := byKey[strings.Join(groups[i].Values, "\x00")]
+		groups[i].Series = make([]ClickBucket, len(buckets))
+		for j, b := range buckets {
+			groups[i].Series[j] = ClickBucket{Start: b, Clicks: m[b]}
+		}
+	}
+	return groups, nil
+}
+
+// TopValues returns the most clicked non-empty values of dim among the
+// clicks q selects.
+func TopValues(db Querier, q ClickQuery, dim Dimension, limit int) ([]ValueCount, error) {
+	q.GroupBy = []Dimension{dim}
+	q.Interval = ""
+	q.Limit = limit
+	q.skipEmpty = true
+	groups, err := QueryClicks(db, q)
+	if err != nil {
+		return nil, fmt.Errorf("top %s: %w", dim, err)
+	}
+	results := make([]ValueCount, len(groups))
+	for i, g := range groups {
+		results[i] = ValueCount{Value: g.Values[0], Clicks: g.Clicks}
+	}
+	return results, nil
+}
+
+// scanClickGroups runs a query selecting n values and a count per row.
+func scanClickGroups(db Querier, query string, args []any, n int) ([]ClickGroup, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query clicks: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []ClickGroup
+	for rows.Next() {
+		g := ClickGroup{Values: make([]string, n)}
+		dest := make([]any, n+1)
+		for i := range g.Values {
+			dest[i] = &g.Values[i]
+		}
+		dest[n] = &g.Clicks
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan clicks: %w", err)
+		}
+		if n == 0 {
+			g.Values = nil
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// placeholders returns n comma-separated query placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// positions returns the column positions 1 to n, for GROUP BY and ORDER BY.
+func positions(n int) string {
+	p := make([]string, n)
+	for i := range p {
+		p[i] = fmt.Sprint(i + 1)
+	}
+	return strings.Join(p, ", ")
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueryClicks_GroupAndFilter(t *testing.T) {
+	d := testDB(t)
+	a := &Link{Slug: "a", Domain: "one.co", Destination: "https://example.com/a", Tags: "launch,docs"}
+	b := &Link{Slug: "b", Domain: "two.co", Destination: "https://example.com/b", Tags: "launch"}
+	for _, l := range []*Link{a, b} {
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now().UTC()
+	insertTestClicks(t, d, []Click{
+		{LinkID: a.ID, ClickedAt: now, Country: "US", DeviceType: "mobile"},
+		{LinkID: a.ID, ClickedAt: now, Country: "US", DeviceType: "desktop"},
+		{LinkID: a.ID, ClickedAt: now, Country: "DE", DeviceType: "mobile"},
+		{LinkID: b.ID, ClickedAt: now, Country: "US", DeviceType: "mobile"},
+	})
+
+	groups, err := QueryClicks(d, ClickQuery{GroupBy: []Dimension{DimensionCountry, DimensionDevice}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 3 || groups[0].Clicks != 2 || groups[0].Values[0] != "US" || groups[0].Values[1] != "mobile" {
+		t.Errorf("country and device = %+v, want US/mobile first with 2", groups)
+	}
+
+	groups, err = QueryClicks(d, ClickQuery{
+		GroupBy: []Dimension{DimensionDomain},
+		Filters: ClickFilters{DimensionCountry: {"US"}, DimensionTag: {"Launch"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].Values[0] != "one.co" || groups[0].Clicks != 2 || groups[1].Clicks != 1 {
+		t.Errorf("US clicks by domain = %+v", groups)
+	}
+
+	// Grouping by tag counts a click once per tag on its link
+	groups, err = QueryClicks(d, ClickQuery{GroupBy: []Dimension{DimensionTag}, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Values[0] != "launch" || groups[0].Clicks != 4 {
+		t.Errorf("top tag = %+v, want launch with 4", groups)
+	}
+
+	groups, err = QueryClicks(d, ClickQuery{Filters: ClickFilters{DimensionLink: {"999"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Clicks != 0 {
+		t.Errorf("ungrouped = %+v, want one group of 0", groups)
+	}
+}
+
+func TestQueryClicks_SeriesInTimeZone(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	insertTestClicks(t, d, []Click{
+		{LinkID: l.ID, ClickedAt: time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC), Country: "IN"},
+		{LinkID: l.ID, ClickedAt: time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC), Country: "IN"},
+		{LinkID: l.ID, ClickedAt: time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC), Country: "US"},
+	})
+
+	// 20:00 UTC is already March 2 in Kolkata (+05:30)
+	kolkata := time.FixedZone("IST", 5*3600+1800)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, kolkata)
+	to := time.Date(2024, 3, 4, 0, 0, 0, 0, kolkata)
+	groups, err := QueryClicks(d, ClickQuery{
+		From: &from, To: &to, Location: kolkata, Interval: IntervalDay,
+		GroupBy: []Dimension{DimensionCountry}, Limit: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Values[0] != "IN" {
+		t.Fatalf("groups = %+v, want IN only", groups)
+	}
+	var got []int
+	for _, b := range groups[0].Series {
+		got = append(got, b.Clicks)
+	}
+	if len(got) != 3 || got[0] != 0 || got[1] != 2 || got[2] != 0 {
+		t.Errorf("daily clicks = %v, want [0 2 0]", got)
+	}
+	if s := groups[0].Series[1].Start; !s.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, kolkata)) {
+		t.Errorf("second bucket starts %v, want March 2 in Kolkata", s)
+	}
+
+	// Weeks start on Monday; March 1 2024 was a Friday
+	groups, err = QueryClicks(d, ClickQuery{Interval: IntervalWeek})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups[0].Series) != 1 || !groups[0].Series[0].Start.Equal(time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC)) || groups[0].Series[0].Clicks != 3 {
+		t.Errorf("weekly series = %+v", groups[0].Series)
+	}
+
+	from = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := QueryClicks(d, ClickQuery{From: &from, To: &to, Interval: IntervalHour}); !errors.Is(err, ErrTooManyBuckets) {
+		t.Errorf("err = %v, want ErrTooManyBuckets", err)
+	}
+}
+
+func TestParseDimension(t *testing.T) {
+	if d, err := ParseDimension(" Referrer "); err != nil || d != DimensionReferrer {
+		t.Errorf("ParseDimension = %q, %v", d, err)
+	}
+	if _, err := ParseDimension("planet"); err == nil {
+		t.Error("unknown dimension accepted")
+	}
+}
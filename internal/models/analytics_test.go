@@ -82,7 +82,7 @@ func TestClickCountsForLinks_Empty(t *testing.T) {
 	}
 }
 
-func TestTopReferrersForLink(t *testing.T) {
+func TestTopValues_Link(t *testing.T) {
 	d := testDB(t)
 	l := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com"}
 	if err := CreateLink(d, l); err != nil {
@@ -96,22 +96,22 @@ func TestTopReferrersForLink(t *testing.T) {
 		{LinkID: l.ID, ClickedAt: time.Now(), RefererDomain: ""},
 	})
 
-	refs, err := TopReferrersForLink(d, l.ID, 5)
+	refs, err := TopValues(d, LinkClicks(l.ID), DimensionReferrer, 5)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(refs) != 2 {
 		t.Fatalf("len = %d, want 2", len(refs))
 	}
-	if refs[0].Domain != "google.com" || refs[0].Count != 2 {
+	if refs[0] != (ValueCount{"google.com", 2}) {
 		t.Errorf("first = %v, want google.com:2", refs[0])
 	}
-	if refs[1].Domain != "twitter.com" || refs[1].Count != 1 {
+	if refs[1] != (ValueCount{"twitter.com", 1}) {
 		t.Errorf("second = %v, want twitter.com:1", refs[1])
 	}
 }
 
-func TestTopCountriesForLink(t *testing.T) {
+func TestTopValues_SkipsEmpty(t *testing.T) {
 	d := testDB(t)
 	l := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com"}
 	if err := CreateLink(d, l); err != nil {
@@ -125,14 +125,14 @@ func TestTopCountriesForLink(t *testing.T) {
 		{LinkID: l.ID, ClickedAt: time.Now(), Country: ""},
 	})
 
-	countries, err := TopCountriesForLink(d, l.ID, 5)
+	countries, err := TopValues(d, LinkClicks(l.ID), DimensionCountry, 5)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(countries) != 2 {
 		t.Fatalf("len = %d, want 2", len(countries))
 	}
-	if countries[0].Country != "US" || countries[0].Count != 2 {
+	if countries[0] != (ValueCount{"US", 2}) {
 		t.Errorf("first = %v, want US:2", countries[0])
 	}
 }
@@ -261,7 +261,7 @@ func TestTopLinksByClicks_ExcludesInactive(t *testing.T) {
 	}
 }
 
-func TestTopReferrersGlobal(t *testing.T) {
+func TestTopValues_Global(t *testing.T) {
 	d := testDB(t)
 	l1 := &Link{Slug: "a", Domain: "d.co", Destination: "https://a.com"}
 	l2 := &Link{Slug: "b", Domain: "d.co", Destination: "https://b.com"}
@@ -278,14 +278,14 @@ func TestTopReferrersGlobal(t *testing.T) {
 		{LinkID: l1.ID, ClickedAt: time.Now(), RefererDomain: "twitter.com"},
 	})
 
-	refs, err := TopReferrersGlobal(d, 5)
+	refs, err := TopValues(d, ClickQuery{}, DimensionReferrer, 5)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(refs) != 2 {
 		t.Fatalf("len = %d, want 2", len(refs))
 	}
-	if refs[0].Domain != "google.com" || refs[0].Count != 2 {
+	if refs[0] != (ValueCount{"google.com", 2}) {
 		t.Errorf("first = %v, want google.com:2", refs[0])
 	}
 }
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/urlnorm"
+	"github.com/scmmishra/dubly/internal/utm"
+)
+
+// campaignDateLayout is the format of Campaign.StartsOn and EndsOn.
+const campaignDateLayout = "2006-01-02"
+
+// Campaign groups links under shared UTM defaults. Clicks on member links
+// count towards the campaign only within its date range; an empty StartsOn
+// or EndsOn leaves that side open.
+type Campaign struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	StartsOn    string    `json:"starts_on"`
+	EndsOn      string    `json:"ends_on"`
+	UTMSource   string    `json:"utm_source"`
+	UTMMedium   string    `json:"utm_medium"`
+	UTMCampaign string    `json:"utm_campaign"`
+	UTMTerm     string    `json:"utm_term"`
+	UTMContent  string    `json:"utm_content"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CampaignSummary is a campaign with its member link count and the clicks
+// they received within the campaign's date range.
+type CampaignSummary struct {
+	Campaign
+	Links  int `json:"links"`
+	Clicks int `json:"clicks"`
+}
+
+// UTM returns the campaign's UTM defaults keyed by parameter name.
+func (c *Campaign) UTM() map[string]string {
+	return map[string]string{
+		"utm_source":   c.UTMSource,
+		"utm_medium":   c.UTMMedium,
+		"utm_campaign": c.UTMCampaign,
+		"utm_term":     c.UTMTerm,
+		"utm_content":  c.UTMContent,
+	}
+}
+
+// SetUTM sets the campaign's UTM defaults from a map keyed by parameter name.
+func (c *Campaign) SetUTM(values map[string]string) {
+	c.UTMSource = values["utm_source"]
+	c.UTMMedium = values["utm_medium"]
+	c.UTMCampaign = values["utm_campaign"]
+	c.UTMTerm = values["utm_term"]
+	c.UTMContent = values["utm_content"]
+}
+
+// Validate checks the name and date range, trimming the name in place.
+func (c *Campaign) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	var starts, ends time.Time
+	var err error
+	if c.StartsOn != "" {
+		if starts, err = time.Parse(campaignDateLayout, c.StartsOn); err != nil {
+			return errors.New("starts_on must be a date like 2026-03-01")
+		}
+	}
+	if c.EndsOn != "" {
+		if ends, err = time.Parse(campaignDateLayout, c.EndsOn); err != nil {
+			return errors.New("ends_on must be a date like 2026-03-31")
+		}
+	}
+	if !starts.IsZero() && !ends.IsZero() && ends.Before(starts) {
+		return errors.New("ends_on is before starts_on")
+	}
+	return nil
+}
+
+// Status reports whether the campaign is "scheduled", "active" or "ended"
+// on the given day.
+func (c *Campaign) Status(now time.Time) string {
+	today := now.Format(campaignDateLayout)
+	switch {
+	case c.StartsOn != "" && today < c.StartsOn:
+		return "scheduled"
+	case c.EndsOn != "" && today > c.EndsOn:
+		return "ended"
+	}
+	return "active"
+}
+
+// clickWindow returns the condition limiting clicks.clicked_at to the
+// campaign's date range, with its args.
+func (c *Campaign) clickWindow(column string) (string, []any) {
+	where := "1=1"
+	var args []any
+	if c.StartsOn != "" {
+		where += " AND " + column + " >= ?"
+		args = append(args, c.StartsOn)
+	}
+	if c.EndsOn != "" {
+		where += " AND " + column + " < date(?, '+1 day')"
+		args = append(args, c.EndsOn)
+	}
+	return where, args
+}
+
+// clickScope returns the condition selecting the campaign's clicks: those
+// on member links within the date range.
+func (c *Campaign) clickScope() (string, []any) {
+	window, args := c.clickWindow("clicked_at")
+	return "link_id IN (SELECT link_id FROM campaign_links WHERE campaign_id = ?) AND " + window, append([]any{c.ID}, args...)
+}
+
+const campaignColumns = `id, name, starts_on, ends_on, utm_source, utm_medium, utm_campaign, utm_term, utm_content, created_at, updated_at`
+
+func scanCampaign(scan func(...any) error, c *Campaign, extra ...any) error {
+	return scan(append([]any{
+		&c.ID, &c.Name, &c.StartsOn, &c.EndsOn,
+		&c.UTMSource, &c.UTMMedium, &c.UTMCampaign, &c.UTMTerm, &c.UTMContent,
+		&c.CreatedAt, &c.UpdatedAt,
+	}, extra...)...)
+}
+
+func CreateCampaign(db *sql.DB, c *Campaign) error {
+	res, err := db.Exec(
+		`INSERT INTO campaigns (name, starts_on, ends_on, utm_source, utm_medium, utm_campaign, utm_term, utm_content) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.Name, c.StartsOn, c.EndsOn, c.UTMSource, c.UTMMedium, c.UTMCampaign, c.UTMTerm, c.UTMContent,
+	)
+	if err != nil {
+		return fmt.Errorf("insert campaign: %w", err)
+	}
+	c.ID, _ = res.LastInsertId()
+	return GetCampaign(db, c)
+}
+
+func GetCampaign(db *sql.DB, c *Campaign) error {
+	return scanCampaign(db.QueryRow(`SELECT `+campaignColumns+` FROM campaigns WHERE id = ?`, c.ID).Scan, c)
+}
+
+func UpdateCampaign(db *sql.DB, c *Campaign) error {
+	res, err := db.Exec(
+		`UPDATE campaigns SET name = ?, starts_on = ?, ends_on = ?, utm_source = ?, utm_medium = ?, utm_campaign = ?, utm_term = ?, utm_content = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		c.Name, c.StartsOn, c.EndsOn, c.UTMSource, c.UTMMedium, c.UTMCampaign, c.UTMTerm, c.UTMContent, c.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update campaign: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return GetCampaign(db, c)
+}
+
+// DeleteCampaign removes a campaign and detaches its links. The links keep
+// the UTM values they were given.
+func DeleteCampaign(db *sql.DB, id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin delete campaign: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM campaign_links WHERE campaign_id = ?`, id); err != nil {
+		return fmt.Errorf("detach campaign links: %w", err)
+	}
+	res, err := tx.Exec(`DELETE FROM campaigns WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete campaign: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// ListCampaigns returns every campaign, newest first, with link and click
+// counts.
+func ListCampaigns(db *sql.DB) ([]CampaignSummary, error) {
+	rows, err := db.Query(
+		`SELECT ` + campaignColumns + `,
+			(SELECT COUNT(*) FROM campaign_links cl WHERE cl.campaign_id = campaigns.id),
+			(SELECT COUNT(*) FROM clicks k JOIN campaign_links cl ON cl.link_id = k.link_id
+				WHERE cl.campaign_id = campaigns.id
+				AND (campaigns.starts_on = '' OR k.clicked_at >= campaigns.starts_on)
+				AND (campaigns.ends_on = '' OR k.clicked_at < date(campaigns.ends_on, '+1 day')))
+		FROM campaigns ORDER BY created_at DESC, id DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	var results []CampaignSummary
+	for rows.Next() {
+		var cs CampaignSummary
+		if err := scanCampaign(rows.Scan, &cs.Campaign, &cs.Links, &cs.Clicks); err != nil {
+			return nil, fmt.Errorf("scan campaign: %w", err)
+		}
+		results = append(results, cs)
+	}
+	return results, rows.Err()
+}
+
+// AttachLinks adds links to a campaign, moving them out of any other one,
+// and fills UTM parameters missing from their destinations with the
+// campaign's defaults. It returns the links as they were before the change
+// so callers can invalidate cached copies. A missing link aborts the whole
+// attach with sql.ErrNoRows.
+func AttachLinks(db *sql.DB, c *Campaign, linkIDs []int64) ([]Link, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin attach links: %w", err)
+	}
+	defer tx.Rollback()
+
+	defaults := c.UTM()
+	var attached []Link
+	for _, id := range linkIDs {
+		l := Link{ID: id}
+		if err := GetLinkByID(tx, &l); err != nil {
+			return nil, err
+		}
+		attached = append(attached, l)
+		if _, err := tx.Exec(
+			`INSERT INTO campaign_links (link_id, campaign_id) VALUES (?, ?) ON CONFLICT(link_id) DO UPDATE SET campaign_id = excluded.campaign_id`,
+			id, c.ID,
+		); err != nil {
+			return nil, fmt.Errorf("attach link %d: %w", id, err)
+		}
+		if values := utm.WithDefaults(l.UTM(), defaults); !maps.Equal(values, l.UTM()) {
+			l.SetUTM(values)
+			l.BuildDestination()
+			if _, err := tx.Exec(
+				`UPDATE links SET destination = ?, normalized_destination = ?,
+					utm_source = ?, utm_medium = ?, utm_campaign = ?, utm_term = ?, utm_content = ?, updated_at = `+nowMillis+` WHERE id = ?`,
+				l.Destination, urlnorm.Normalize(l.Destination), l.UTMSource, l.UTMMedium, l.UTMCampaign, l.UTMTerm, l.UTMContent, id,
+			); err != nil {
+				return nil, fmt.Errorf("apply campaign utm to link %d: %w", id, err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit attach links: %w", err)
+	}
+	return attached, nil
+}
+
+// DetachLink removes a link from a campaign. Its destination is left as is.
+func DetachLink(db *sql.DB, campaignID, linkID int64) error {
+	res, err := db.Exec(`DELETE FROM campaign_links WHERE campaign_id = ? AND link_id = ?`, campaignID, linkID)
+	if err != nil {
+		return fmt.Errorf("detach link: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CampaignForLink returns the campaign a link belongs to, or sql.ErrNoRows.
+func CampaignForLink(db *sql.DB, linkID int64) (*Campaign, error) {
+	c := &Campaign{}
+	err := scanCampaign(db.QueryRow(
+		`SELECT `+campaignColumns+` FROM campaigns WHERE id = (SELECT campaign_id FROM campaign_links WHERE link_id = ?)`,
+		linkID,
+	).Scan, c)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CampaignLinks returns a campaign's links with the clicks each received
+// within the campaign's date range, most clicked first.
+func CampaignLinks(db *sql.DB, c *Campaign) ([]LinkWithClicks, error) {
+	window, windowArgs := c.clickWindow("k.clicked_at")
+	args := append(windowArgs, c.ID)
+	rows, err := db.Query(
+		`SELECT `+qualifiedLinkColumns("l")+`, COUNT(k.id) AS counted
+		FROM links l
+		JOIN campaign_links cl ON cl.link_id = l.id
+		LEFT JOIN clicks k ON k.link_id = l.id AND `+window+`
+		WHERE cl.campaign_id = ?
+		GROUP BY l.id
+		ORDER BY counted DESC, l.id DESC`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("campaign links: %w", err)
+	}
+	defer rows.Close()
+
+	var results []LinkWithClicks
+	for rows.Next() {
+		var lc LinkWithClicks
+		if err := scanLink(rows, &lc.Link, &lc.ClickCount); err != nil {
+			return nil, fmt.Errorf("scan link with clicks: %w", err)
+		}
+		results = append(results, lc)
+	}
+	return results, rows.Err()
+}
+
+// ClicksForCampaign returns the campaign's clicks within its date range.
+func ClicksForCampaign(db *sql.DB, c *Campaign) (int, error) {
+	scope, args := c.clickScope()
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM clicks WHERE `+scope, args...).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestCampaign_Validate(t *testing.T) {
+	cases := []struct {
+		c       Campaign
+		wantErr bool
+	}{
+		{Campaign{Name: "Launch"}, false},
+		{Campaign{Name: "  "}, true},
+		{Campaign{Name: "Launch", StartsOn: "2026-03-01", EndsOn: "2026-03-31"}, false},
+		{Campaign{Name: "Launch", StartsOn: "March 1"}, true},
+		{Campaign{Name: "Launch", StartsOn: "2026-03-31", EndsOn: "2026-03-01"}, true},
+	}
+	for _, tc := range cases {
+		err := tc.c.Validate()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("Validate(%+v) = %v, wantErr %v", tc.c, err, tc.wantErr)
+		}
+	}
+}
+
+func TestCampaign_Status(t *testing.T) {
+	c := Campaign{StartsOn: "2026-03-01", EndsOn: "2026-03-31"}
+	for day, want := range map[string]string{
+		"2026-02-28": "scheduled",
+		"2026-03-01": "active",
+		"2026-03-31": "active",
+		"2026-04-01": "ended",
+	} {
+		now, _ := time.Parse("2006-01-02", day)
+		if got := c.Status(now); got != want {
+			t.Errorf("Status(%s) = %q, want %q", day, got, want)
+		}
+	}
+}
+
+func TestAttachLinks_FillsMissingUTM(t *testing.T) {
+	d := testDB(t)
+	c := &Campaign{Name: "Launch", UTMSource: "newsletter", UTMCampaign: "launch"}
+	if err := CreateCampaign(d, c); err != nil {
+		t.Fatal(err)
+	}
+	l := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com/?utm_source=twitter"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+
+	attached, err := AttachLinks(d, c, []int64{l.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attached) != 1 || attached[0].Destination != l.Destination {
+		t.Errorf("attached = %+v, want the link as it was", attached)
+	}
+
+	got := &Link{ID: l.ID}
+	if err := GetLinkByID(d, got); err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://example.com/?utm_campaign=launch&utm_source=twitter"; got.Destination != want {
+		t.Errorf("Destination = %q, want %q", got.Destination, want)
+	}
+	if got.UTMSource != "twitter" || got.UTMCampaign != "launch" {
+		t.Errorf("utm fields = %+v, want the link's source and the campaign's name", got.UTM())
+	}
+
+	member, err := CampaignForLink(d, l.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member.ID != c.ID {
+		t.Errorf("CampaignForLink = %d, want %d", member.ID, c.ID)
+	}
+
+	if _, err := AttachLinks(d, c, []int64{l.ID + 100}); err != sql.ErrNoRows {
+		t.Errorf("attach missing link: err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestCampaign_CountsClicksWithinWindow(t *testing.T) {
+	d := testDB(t)
+	c := &Campaign{Name: "March", StartsOn: "2026-03-01", EndsOn: "2026-03-31"}
+	if err := CreateCampaign(d, c); err != nil {
+		t.Fatal(err)
+	}
+	in := &Link{Slug: "in", Domain: "d.co", Destination: "https://example.com"}
+	out := &Link{Slug: "out", Domain: "d.co", Destination: "https://example.com"}
+	for _, l := range []*Link{in, out} {
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := AttachLinks(d, c, []int64{in.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(s string) time.Time {
+		ts, _ := time.Parse("2006-01-02 15:04", s)
+		return ts
+	}
+	insertTestClicks(t, d, []Click{
+		{LinkID: in.ID, ClickedAt: day("2026-02-28 23:59"), Country: "US"},
+		{LinkID: in.ID, ClickedAt: day("2026-03-01 00:00"), Country: "US"},
+		{LinkID: in.ID, ClickedAt: day("2026-03-31 23:59"), Country: "DE"},
+		{LinkID: in.ID, ClickedAt: day("2026-04-01 00:00"), Country: "US"},
+		{LinkID: out.ID, ClickedAt: day("2026-03-15 12:00"), Country: "US"},
+	})
+
+	clicks, err := ClicksForCampaign(d, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clicks != 2 {
+		t.Errorf("clicks = %d, want 2", clicks)
+	}
+
+	countries, err := TopValues(d, CampaignClicks(c), DimensionCountry, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(countries) != 2 {
+		t.Errorf("countries = %+v, want US and DE once each", countries)
+	}
+
+	links, err := CampaignLinks(d, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0].ClickCount != 2 {
+		t.Errorf("links = %+v", links)
+	}
+
+	list, err := ListCampaigns(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Links != 1 || list[0].Clicks != 2 {
+		t.Errorf("summary = %+v", list)
+	}
+}
+
+func TestDeleteCampaign_DetachesLinks(t *testing.T) {
+	d := testDB(t)
+	c := &Campaign{Name: "Launch", UTMSource: "newsletter"}
+	if err := CreateCampaign(d, c); err != nil {
+		t.Fatal(err)
+	}
+	l := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AttachLinks(d, c, []int64{l.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteCampaign(d, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CampaignForLink(d, l.ID); err != sql.ErrNoRows {
+		t.Errorf("CampaignForLink after delete: err = %v, want sql.ErrNoRows", err)
+	}
+	got := &Link{ID: l.ID}
+	if err := GetLinkByID(d, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Destination != "https://example.com?utm_source=newsletter" {
+		t.Errorf("Destination = %q, want the campaign UTM kept", got.Destination)
+	}
+	if err := DeleteCampaign(d, c.ID); err != sql.ErrNoRows {
+		t.Errorf("second delete: err = %v, want sql.ErrNoRows", err)
+	}
+}
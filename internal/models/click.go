@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -23,6 +24,7 @@ type Click struct {
 	BrowserVersion string
 	OS             string
 	DeviceType     string
+	AliasID        int64 // alias the click came through; 0 for the link's own slug
 }
 
 func BatchInsertClicks(db *sql.DB, clicks []Click) error {
@@ -32,22 +34,153 @@ func BatchInsertClicks(db *sql.DB, clicks []Click) error {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`INSERT INTO clicks (link_id, clicked_at, ip, user_agent, referer, referer_domain, country, city, region, latitude, longitude, browser, browser_version, os, device_type) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err := InsertClicksTx(tx, clicks); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertClicksTx is BatchInsertClicks within a caller-managed transaction.
+// Clicks on a link since merged into another are recorded against that one.
+func InsertClicksTx(tx *sql.Tx, clicks []Click) error {
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, c := range clicks {
+		if !seen[c.LinkID] {
+			seen[c.LinkID] = true
+			ids = append(ids, c.LinkID)
+		}
+	}
+	merges, err := mergedLinks(tx, ids)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO clicks (link_id, clicked_at, ip, user_agent, referer, referer_domain, country, city, region, latitude, longitude, browser, browser_version, os, device_type, alias_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return fmt.Errorf("prepare: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, c := range clicks {
+		if m, ok := merges[c.LinkID]; ok {
+			c.LinkID = m.into
+			if c.AliasID == 0 {
+				c.AliasID = m.alias
+			}
+		}
 		_, err := stmt.Exec(
 			c.LinkID, c.ClickedAt, c.IP, c.UserAgent, c.Referer, c.RefererDomain,
 			c.Country, c.City, c.Region, c.Latitude, c.Longitude,
-			c.Browser, c.BrowserVersion, c.OS, c.DeviceType,
+			c.Browser, c.BrowserVersion, c.OS, c.DeviceType, nullableID(c.AliasID),
 		)
 		if err != nil {
 			return fmt.Errorf("insert click: %w", err)
 		}
 	}
+	return nil
+}
 
-	return tx.Commit()
+// LinkClick is a click with the domain and slug of its link.
+type LinkClick struct {
+	Click
+	Domain string
+	Slug   string
+}
+
+// EachClick calls fn for every click in ID order, reading in batches like
+// EachLink.
+func EachClick(db *sql.DB, fn func(*LinkClick) error) error {
+	var after int64
+	for {
+		rows, err := db.Query(
+			`SELECT c.id, c.link_id, c.clicked_at, c.ip, c.user_agent, c.referer, c.referer_domain,
+				c.country, c.city, c.region, c.latitude, c.longitude,
+				c.browser, c.browser_version, c.os, c.device_type, l.domain, l.slug
+			FROM clicks c JOIN links l ON l.id = c.link_id
+			WHERE c.id > ? ORDER BY c.id LIMIT ?`,
+			after, eachBatchSize,
+		)
+		if err != nil {
+			return fmt.Errorf("list clicks: %w", err)
+		}
+		var batch []LinkClick
+		for rows.Next() {
+			var c LinkClick
+			if err := rows.Scan(
+				&c.ID, &c.LinkID, &c.ClickedAt, &c.IP, &c.UserAgent, &c.Referer, &c.RefererDomain,
+				&c.Country, &c.City, &c.Region, &c.Latitude, &c.Longitude,
+				&c.Browser, &c.BrowserVersion, &c.OS, &c.DeviceType, &c.Domain, &c.Slug,
+			); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan click: %w", err)
+			}
+			batch = append(batch, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if len(batch) < eachBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].ID
+	}
+}
+
+// ClickFilter selects clicks to purge. Zero fields match every click.
+type ClickFilter struct {
+	LinkID int64
+	Before *time.Time // clicks strictly before this moment
+}
+
+func (f ClickFilter) where() (string, []any) {
+	var conds []string
+	var args []any
+	if f.LinkID != 0 {
+		conds = append(conds, "link_id = ?")
+		args = append(args, f.LinkID)
+	}
+	if f.Before != nil {
+		conds = append(conds, "clicked_at < ?")
+		args = append(args, f.Before.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// CountClicks returns how many clicks f matches.
+func CountClicks(db Querier, f ClickFilter) (int, error) {
+	where, args := f.where()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM clicks`+where, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count clicks: %w", err)
+	}
+	return n, nil
+}
+
+// PurgeClicks deletes the clicks f matches and returns how many there
+// were. The clicks_count_delete trigger keeps each link's click count and
+// last click time right.
+func PurgeClicks(db *sql.DB, f ClickFilter) (int64, error) {
+	where, args := f.where()
+	res, err := db.Exec(`DELETE FROM clicks`+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("purge clicks: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// nullableID stores zero IDs as NULL.
+func nullableID(id int64) any {
+	if id == 0 {
+		return nil
+	}
+	return id
 }
@@ -71,3 +71,52 @@ func TestBatchInsertClicks_RollsBackOnFailure(t *testing.T) {
 		t.Errorf("count = %d, want 0 (rolled back)", count)
 	}
 }
+
+func TestPurgeClicks_KeepsCountsRight(t *testing.T) {
+	d := testDB(t)
+	a := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com/a"}
+	b := &Link{Slug: "b", Domain: "d.co", Destination: "https://example.com/b"}
+	for _, l := range []*Link{a, b} {
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	recent := time.Now().UTC().Add(-time.Hour)
+	err := BatchInsertClicks(d, []Click{
+		{LinkID: a.ID, ClickedAt: old},
+		{LinkID: a.ID, ClickedAt: old.Add(time.Hour)},
+		{LinkID: a.ID, ClickedAt: recent},
+		{LinkID: b.ID, ClickedAt: old},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cutoff := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	f := ClickFilter{LinkID: a.ID, Before: &cutoff}
+	if n, err := CountClicks(d, f); err != nil || n != 2 {
+		t.Fatalf("CountClicks = %d, %v; want 2", n, err)
+	}
+	if n, err := PurgeClicks(d, f); err != nil || n != 2 {
+		t.Fatalf("PurgeClicks = %d, %v; want 2", n, err)
+	}
+
+	GetLinkByID(d, a)
+	if a.Clicks != 1 || a.LastClickedAt == nil || a.LastClickedAt.Before(cutoff) {
+		t.Errorf("a: clicks = %d, last clicked %v; want the recent click only", a.Clicks, a.LastClickedAt)
+	}
+	GetLinkByID(d, b)
+	if b.Clicks != 1 {
+		t.Errorf("b: clicks = %d, want 1 untouched", b.Clicks)
+	}
+
+	// Purging a link's last click clears its last click time
+	if _, err := PurgeClicks(d, ClickFilter{LinkID: b.ID}); err != nil {
+		t.Fatal(err)
+	}
+	GetLinkByID(d, b)
+	if b.Clicks != 0 || b.LastClickedAt != nil {
+		t.Errorf("b: clicks = %d, last clicked %v; want 0 and nil", b.Clicks, b.LastClickedAt)
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DomainCheck is the latest DNS and TLS status recorded for a domain.
+type DomainCheck struct {
+	Domain      string     `json:"domain"`
+	IPs         []string   `json:"ips"`
+	DNSError    string     `json:"dns_error,omitempty"`
+	TLSIssuer   string     `json:"tls_issuer,omitempty"`
+	TLSSANs     []string   `json:"tls_sans"`
+	TLSNotAfter *time.Time `json:"tls_not_after,omitempty"`
+	TLSValid    bool       `json:"tls_valid"`
+	TLSError    string     `json:"tls_error,omitempty"`
+	CheckedAt   time.Time  `json:"checked_at"`
+}
+
+// ExpiresWithin reports whether the certificate expires within d from now.
+// Domains without a certificate never report as expiring.
+func (c *DomainCheck) ExpiresWithin(d time.Duration) bool {
+	if c.TLSNotAfter == nil {
+		return false
+	}
+	return time.Until(*c.TLSNotAfter) < d
+}
+
+func UpsertDomainCheck(db *sql.DB, c *DomainCheck) error {
+	var notAfter any
+	if c.TLSNotAfter != nil {
+		notAfter = c.TLSNotAfter.UTC()
+	}
+	valid := 0
+	if c.TLSValid {
+		valid = 1
+	}
+	_, err := db.Exec(
+		`INSERT INTO domain_checks (domain, ips, dns_error, tls_issuer, tls_sans, tls_not_after, tls_valid, tls_error, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(domain) DO UPDATE SET
+			ips = excluded.ips, dns_error = excluded.dns_error, tls_issuer = excluded.tls_issuer,
+			tls_sans = excluded.tls_sans, tls_not_after = excluded.tls_not_after, tls_valid = excluded.tls_valid,
+			tls_error = excluded.tls_error, checked_at = excluded.checked_at`,
+		c.Domain, strings.Join(c.IPs, ","), c.DNSError, c.TLSIssuer, strings.Join(c.TLSSANs, ","),
+		notAfter, valid, c.TLSError, c.CheckedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("upsert domain check: %w", err)
+	}
+	return nil
+}
+
+// ListDomainChecks returns stored checks keyed by domain.
+func ListDomainChecks(db *sql.DB) (map[string]*DomainCheck, error) {
+	rows, err := db.Query(`SELECT domain, ips, dns_error, tls_issuer, tls_sans, tls_not_after, tls_valid, tls_error, checked_at FROM domain_checks`)
+	if err != nil {
+		return nil, fmt.Errorf("list domain checks: %w", err)
+	}
+	defer rows.Close()
+
+	checks := make(map[string]*DomainCheck)
+	for rows.Next() {
+		var c DomainCheck
+		var ips, sans string
+		var notAfter sql.NullTime
+		var valid int
+		if err := rows.Scan(&c.Domain, &ips, &c.DNSError, &c.TLSIssuer, &sans, &notAfter, &valid, &c.TLSError, &c.CheckedAt); err != nil {
+			return nil, fmt.Errorf("scan domain check: %w", err)
+		}
+		c.IPs = splitList(ips)
+		c.TLSSANs = splitList(sans)
+		if notAfter.Valid {
+			t := notAfter.Time
+			c.TLSNotAfter = &t
+		}
+		c.TLSValid = valid == 1
+		checks[c.Domain] = &c
+	}
+	return checks, rows.Err()
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpsertDomainCheck_InsertAndUpdate(t *testing.T) {
+	d := testDB(t)
+	expiry := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+
+	err := UpsertDomainCheck(d, &DomainCheck{
+		Domain:      "short.io",
+		IPs:         []string{"1.2.3.4", "5.6.7.8"},
+		TLSIssuer:   "R3",
+		TLSSANs:     []string{"short.io", "www.short.io"},
+		TLSNotAfter: &expiry,
+		TLSValid:    true,
+		CheckedAt:   time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks, err := ListDomainChecks(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := checks["short.io"]
+	if c == nil {
+		t.Fatal("check not stored")
+	}
+	if len(c.IPs) != 2 || len(c.TLSSANs) != 2 || !c.TLSValid || c.TLSIssuer != "R3" {
+		t.Errorf("stored check = %+v", c)
+	}
+	if c.TLSNotAfter == nil || !c.TLSNotAfter.Equal(expiry) {
+		t.Errorf("not after = %v, want %v", c.TLSNotAfter, expiry)
+	}
+
+	// Overwrite with a failed check
+	err = UpsertDomainCheck(d, &DomainCheck{
+		Domain:    "short.io",
+		DNSError:  "no such host",
+		TLSError:  "connection refused",
+		CheckedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks, _ = ListDomainChecks(d)
+	c = checks["short.io"]
+	if len(checks) != 1 || c.TLSValid || c.TLSNotAfter != nil || len(c.IPs) != 0 {
+		t.Errorf("updated check = %+v", c)
+	}
+}
+
+func TestDomainCheck_ExpiresWithin(t *testing.T) {
+	soon := time.Now().Add(2 * 24 * time.Hour)
+	c := &DomainCheck{TLSNotAfter: &soon}
+	if !c.ExpiresWithin(7 * 24 * time.Hour) {
+		t.Error("expected expiry within 7 days")
+	}
+	if c.ExpiresWithin(24 * time.Hour) {
+		t.Error("did not expect expiry within 1 day")
+	}
+	if (&DomainCheck{}).ExpiresWithin(7 * 24 * time.Hour) {
+		t.Error("check without certificate should never be expiring")
+	}
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMoveCollisions is returned by ApplyDomainMove when the plan still has
+// collisions; nothing is moved in that case.
+var ErrMoveCollisions = errors.New("domain move has slug collisions")
+
+// MoveCollision is a link that can't move because its slug is already taken
+// on the target domain, either by an existing link or by another moving link.
+type MoveCollision struct {
+	LinkID            int64  `json:"link_id"`
+	Slug              string `json:"slug"`
+	ConflictingLinkID int64  `json:"conflicting_link_id"`
+	ConflictingSlug   string `json:"conflicting_slug"`
+}
+
+// DomainMove describes re-keying every link and alias on From to To. Links
+// and Aliases hold the rows as they are before the move so callers can
+// invalidate old cache keys.
+type DomainMove struct {
+	From       string
+	To         string
+	Fold       bool // target matches slugs case-insensitively; slugs are lowercased
+	Links      []Link
+	Aliases    []LinkAlias
+	Collisions []MoveCollision
+}
+
+// NewSlug returns the slug l will have on the target domain.
+func (m *DomainMove) NewSlug(l Link) string {
+	return m.key(l.Slug)
+}
+
+func (m *DomainMove) key(slug string) string {
+	if m.Fold {
+		return strings.ToLower(slug)
+	}
+	return slug
+}
+
+// PlanDomainMove loads the links on from and reports every slug that would
+// collide on to. When fold is set, slugs are compared and stored lowercased.
+func PlanDomainMove(db *sql.DB, from, to string, fold bool) (*DomainMove, error) {
+	m := &DomainMove{From: from, To: to, Fold: fold}
+
+	var err error
+	if m.Links, err = linksOnDomain(db, from); err != nil {
+		return nil, err
+	}
+	if m.Aliases, err = aliasesOnDomain(db, from); err != nil {
+		return nil, err
+	}
+	existing, err := linksOnDomain(db, to)
+	if err != nil {
+		return nil, err
+	}
+	existingAliases, err := aliasesOnDomain(db, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Links and aliases share one slug namespace per domain
+	type owner struct {
+		linkID int64
+		slug   string
+	}
+	taken := make(map[string]owner, len(existing)+len(existingAliases)+len(m.Links)+len(m.Aliases))
+	for _, l := range existing {
+		taken[m.key(l.Slug)] = owner{l.ID, l.Slug}
+	}
+	for _, a := range existingAliases {
+		taken[m.key(a.Slug)] = owner{a.LinkID, a.Slug}
+	}
+	claim := func(linkID int64, slug string) {
+		k := m.key(slug)
+		if other, ok := taken[k]; ok {
+			m.Collisions = append(m.Collisions, MoveCollision{
+				LinkID:            linkID,
+				Slug:              slug,
+				ConflictingLinkID: other.linkID,
+				ConflictingSlug:   other.slug,
+			})
+			return
+		}
+		taken[k] = owner{linkID, slug}
+	}
+	for _, l := range m.Links {
+		claim(l.ID, l.Slug)
+	}
+	for _, a := range m.Aliases {
+		claim(a.LinkID, a.Slug)
+	}
+	return m, nil
+}
+
+// ApplyDomainMove re-keys the planned links in one transaction. Link IDs are
+// unchanged, so click history follows the links to the new domain.
+func ApplyDomainMove(db *sql.DB, m *DomainMove) error {
+	if len(m.Collisions) > 0 {
+		return ErrMoveCollisions
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin domain move: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE links SET domain = ?, slug = ?, updated_at = ` + nowMillis + ` WHERE id = ? AND domain = ?`)
+	if err != nil {
+		return fmt.Errorf("prepare domain move: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, l := range m.Links {
+		if _, err := stmt.Exec(m.To, m.NewSlug(l), l.ID, m.From); err != nil {
+			return fmt.Errorf("move link %d: %w", l.ID, err)
+		}
+	}
+	for _, a := range m.Aliases {
+		if _, err := tx.Exec(`UPDATE link_aliases SET domain = ?, slug = ? WHERE id = ? AND domain = ?`, m.To, m.key(a.Slug), a.ID, m.From); err != nil {
+			return fmt.Errorf("move alias %d: %w", a.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// linksOnDomain returns every link on domain, active or not.
+func linksOnDomain(db *sql.DB, domain string) ([]Link, error) {
+	rows, err := db.Query(
+		`SELECT `+linkColumns+` FROM links WHERE domain = ? ORDER BY id`,
+		domain,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list domain links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []Link
+	for rows.Next() {
+		var l Link
+		if err := scanLink(rows, &l); err != nil {
+			return nil, fmt.Errorf("scan link: %w", err)
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// DomainLinkCount is the number of links stored on a domain.
+type DomainLinkCount struct {
+	Domain string
+	Links  int
+}
+
+// CountLinksByDomain returns link counts for every domain that has links,
+// including domains that are no longer configured.
+func CountLinksByDomain(db *sql.DB) ([]DomainLinkCount, error) {
+	rows, err := db.Query(`SELECT domain, COUNT(*) FROM links GROUP BY domain ORDER BY domain`)
+	if err != nil {
+		return nil, fmt.Errorf("count links by domain: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []DomainLinkCount
+	for rows.Next() {
+		var c DomainLinkCount
+		if err := rows.Scan(&c.Domain, &c.Links); err != nil {
+			return nil, fmt.Errorf("scan domain count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPlanDomainMove_Collisions(t *testing.T) {
+	d := testDB(t)
+	mustCreate := func(slug, domain string) *Link {
+		t.Helper()
+		l := &Link{Slug: slug, Domain: domain, Destination: "https://example.com"}
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+	a := mustCreate("abc", "old.io")
+	mustCreate("def", "old.io")
+	b := mustCreate("abc", "new.io")
+
+	plan, err := PlanDomainMove(d, "old.io", "new.io", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Links) != 2 {
+		t.Errorf("links = %d, want 2", len(plan.Links))
+	}
+	if len(plan.Collisions) != 1 || plan.Collisions[0].LinkID != a.ID || plan.Collisions[0].ConflictingLinkID != b.ID {
+		t.Fatalf("collisions = %+v", plan.Collisions)
+	}
+	if err := ApplyDomainMove(d, plan); !errors.Is(err, ErrMoveCollisions) {
+		t.Errorf("ApplyDomainMove err = %v, want ErrMoveCollisions", err)
+	}
+}
+
+func TestApplyDomainMove_KeepsIDsAndLowercases(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "Docs", Domain: "old.io", Destination: "https://example.com"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	if err := BatchInsertClicks(d, []Click{{LinkID: l.ID, ClickedAt: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := PlanDomainMove(d, "old.io", "go", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyDomainMove(d, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := GetLinkBySlugAndDomain(d, "docs", "go")
+	if err != nil {
+		t.Fatalf("moved link not found: %v", err)
+	}
+	if moved.ID != l.ID {
+		t.Errorf("ID = %d, want %d", moved.ID, l.ID)
+	}
+	var clicks int
+	d.QueryRow(`SELECT COUNT(*) FROM clicks WHERE link_id = ?`, l.ID).Scan(&clicks)
+	if clicks != 1 {
+		t.Errorf("clicks = %d, want 1", clicks)
+	}
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// HistoricalClicks is a click total carried over from another shortener,
+// for which there are no individual clicks to import.
+type HistoricalClicks struct {
+	LinkID     int64     `json:"link_id"`
+	Source     string    `json:"source"`
+	Clicks     int       `json:"clicks"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+// SetHistoricalClicks records a link's historical click total, replacing
+// any earlier one.
+func SetHistoricalClicks(db Querier, linkID int64, source string, clicks int) error {
+	_, err := db.Exec(
+		`INSERT INTO historical_clicks (link_id, source, clicks) VALUES (?, ?, ?)
+		 ON CONFLICT(link_id) DO UPDATE SET source = excluded.source, clicks = excluded.clicks, imported_at = CURRENT_TIMESTAMP`,
+		linkID, source, clicks,
+	)
+	if err != nil {
+		return fmt.Errorf("set historical clicks: %w", err)
+	}
+	return nil
+}
+
+// GetHistoricalClicks returns sql.ErrNoRows for links without a historical
+// total.
+func GetHistoricalClicks(db Querier, linkID int64) (*HistoricalClicks, error) {
+	h := &HistoricalClicks{LinkID: linkID}
+	err := db.QueryRow(`SELECT source, clicks, imported_at FROM historical_clicks WHERE link_id = ?`, linkID).
+		Scan(&h.Source, &h.Clicks, &h.ImportedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get historical clicks: %w", err)
+	}
+	return h, nil
+}
+
+// HistoricalClickTotals maps link IDs to their historical click totals.
+func HistoricalClickTotals(db Querier) (map[int64]int, error) {
+	rows, err := db.Query(`SELECT link_id, clicks FROM historical_clicks`)
+	if err != nil {
+		return nil, fmt.Errorf("list historical clicks: %w", err)
+	}
+	defer rows.Close()
+
+	totals := map[int64]int{}
+	for rows.Next() {
+		var id int64
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, fmt.Errorf("scan historical clicks: %w", err)
+		}
+		totals[id] = n
+	}
+	return totals, rows.Err()
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestHistoricalClicks_SetReplaces(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "abc", Domain: "d.co", Destination: "https://example.com"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetHistoricalClicks(d, l.ID); err != sql.ErrNoRows {
+		t.Fatalf("err = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := SetHistoricalClicks(d, l.ID, "bitly", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetHistoricalClicks(d, l.ID, "shlink", 25); err != nil {
+		t.Fatal(err)
+	}
+	h, err := GetHistoricalClicks(d, l.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Source != "shlink" || h.Clicks != 25 {
+		t.Errorf("historical = %+v", h)
+	}
+
+	totals, err := HistoricalClickTotals(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 1 || totals[l.ID] != 25 {
+		t.Errorf("totals = %v", totals)
+	}
+}
+
+func TestFindLinkByDestination(t *testing.T) {
+	d := testDB(t)
+	a := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com"}
+	b := &Link{Slug: "b", Domain: "d.co", Destination: "https://example.com"}
+	other := &Link{Slug: "a", Domain: "e.co", Destination: "https://example.com/other"}
+	for _, l := range []*Link{a, b, other} {
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := FindLinkByDestination(d, "d.co", "https://example.com")
+	if err != nil || got.ID != a.ID {
+		t.Fatalf("got %+v, err %v; want link a", got, err)
+	}
+	if _, err := FindLinkByDestination(d, "e.co", "https://example.com"); err != sql.ErrNoRows {
+		t.Errorf("err = %v, want sql.ErrNoRows for another domain", err)
+	}
+
+	// Disabled links don't count
+	if err := SoftDeleteLink(d, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := FindLinkByDestination(d, "d.co", "https://example.com"); err != nil || got.ID != b.ID {
+		t.Errorf("got %+v, err %v; want link b", got, err)
+	}
+}
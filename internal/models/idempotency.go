@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// IdempotentResponse is the stored outcome of a request made with an
+// Idempotency-Key. Status is 0 while the request is still in progress.
+type IdempotentResponse struct {
+	Key         string
+	RequestHash string
+	Status      int
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+// ReserveIdempotencyKey claims key for a request whose content hashes to
+// requestHash, first forgetting keys claimed more than window ago. When key
+// is already claimed, its stored response is returned instead and reserved
+// is false.
+func ReserveIdempotencyKey(db Querier, key, requestHash string, window time.Duration) (resp *IdempotentResponse, reserved bool, err error) {
+	cutoff := time.Now().Add(-window).UTC().Format("2006-01-02 15:04:05")
+	if _, err := db.Exec(`DELETE FROM idempotency_keys WHERE created_at < ?`, cutoff); err != nil {
+		return nil, false, fmt.Errorf("prune idempotency keys: %w", err)
+	}
+
+	res, err := db.Exec(
+		`INSERT INTO idempotency_keys (key, request_hash) VALUES (?, ?) ON CONFLICT(key) DO NOTHING`,
+		key, requestHash,
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return nil, true, nil
+	}
+
+	resp = &IdempotentResponse{Key: key}
+	err = db.QueryRow(`SELECT request_hash, status, body, created_at FROM idempotency_keys WHERE key = ?`, key).
+		Scan(&resp.RequestHash, &resp.Status, &resp.Body, &resp.CreatedAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("get idempotency key: %w", err)
+	}
+	return resp, false, nil
+}
+
+// SaveIdempotentResponse stores the response to the request that reserved
+// key, for replaying to retries.
+func SaveIdempotentResponse(db Querier, key string, status int, body []byte) error {
+	if _, err := db.Exec(`UPDATE idempotency_keys SET status = ?, body = ? WHERE key = ?`, status, body, key); err != nil {
+		return fmt.Errorf("save idempotent response: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets key, so a retry runs the request afresh.
+// It's for requests that failed without doing anything worth replaying.
+func ReleaseIdempotencyKey(db Querier, key string) error {
+	if _, err := db.Exec(`DELETE FROM idempotency_keys WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	d := testDB(t)
+
+	if _, reserved, err := ReserveIdempotencyKey(d, "k", "hash", time.Hour); err != nil || !reserved {
+		t.Fatalf("first reserve: reserved = %v, err = %v", reserved, err)
+	}
+	resp, reserved, err := ReserveIdempotencyKey(d, "k", "hash", time.Hour)
+	if err != nil || reserved {
+		t.Fatalf("second reserve: reserved = %v, err = %v", reserved, err)
+	}
+	if resp.Status != 0 || resp.RequestHash != "hash" {
+		t.Errorf("in progress = %+v, want status 0 and the first hash", resp)
+	}
+
+	if err := SaveIdempotentResponse(d, "k", 201, []byte(`{"id":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	resp, _, err = ReserveIdempotencyKey(d, "k", "hash", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != 201 || string(resp.Body) != `{"id":1}` {
+		t.Errorf("stored = %d %s, want 201 {\"id\":1}", resp.Status, resp.Body)
+	}
+
+	if err := ReleaseIdempotencyKey(d, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, reserved, _ := ReserveIdempotencyKey(d, "k", "other", time.Hour); !reserved {
+		t.Error("released key should be free to reserve again")
+	}
+}
+
+func TestReserveIdempotencyKey_ForgetsExpiredKeys(t *testing.T) {
+	d := testDB(t)
+	if _, _, err := ReserveIdempotencyKey(d, "old", "hash", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveIdempotentResponse(d, "old", 201, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(`UPDATE idempotency_keys SET created_at = ?`, time.Now().Add(-2*time.Hour).UTC().Format("2006-01-02 15:04:05")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, reserved, err := ReserveIdempotencyKey(d, "old", "new-hash", time.Hour); err != nil || !reserved {
+		t.Errorf("expired key: reserved = %v, err = %v, want it reserved afresh", reserved, err)
+	}
+}
@@ -2,112 +2,340 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"maps"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/scmmishra/dubly/internal/tags"
+	"github.com/scmmishra/dubly/internal/urlnorm"
+	"github.com/scmmishra/dubly/internal/utm"
 )
 
 type Link struct {
-	ID          int64     `json:"id"`
-	Slug        string    `json:"slug"`
-	Domain      string    `json:"domain"`
-	ShortURL    string    `json:"short_url"`
-	Destination string    `json:"destination"`
-	Title       string    `json:"title"`
-	Tags        string    `json:"tags"`
-	Notes       string    `json:"notes"`
-	IsActive    bool      `json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64  `json:"id"`
+	Slug        string `json:"slug"`
+	Domain      string `json:"domain"`
+	ShortURL    string `json:"short_url"` // set by callers with FillShortURL
+	Destination string `json:"destination"`
+
+	// The link's UTM parameters. Saving the link writes them onto
+	// Destination, replacing any utm_* parameters already there.
+	UTMSource   string `json:"utm_source"`
+	UTMMedium   string `json:"utm_medium"`
+	UTMCampaign string `json:"utm_campaign"`
+	UTMTerm     string `json:"utm_term"`
+	UTMContent  string `json:"utm_content"`
+
+	Title     string    `json:"title"`
+	Tags      string    `json:"tags"`
+	Notes     string    `json:"notes"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Clicks and LastClickedAt are kept current as clicks are recorded.
+	Clicks        int        `json:"clicks"`
+	LastClickedAt *time.Time `json:"last_clicked_at"`
+
+	// AliasID is set when the link was resolved through one of its aliases
+	// rather than its own slug, so clicks can record which one was used.
+	AliasID int64 `json:"-"`
+}
+
+// nowMillis is the current time to the millisecond, for updated_at: link
+// versions are derived from it, so saves within the same second must still
+// tell apart.
+const nowMillis = "strftime('%Y-%m-%d %H:%M:%f', 'now')"
+
+// ErrLinkChanged is returned by the conditional writes when the link was
+// saved by someone else after the caller read it.
+var ErrLinkChanged = errors.New("link changed since it was read")
+
+// Version identifies this saved state of the link. It changes every time
+// the link is saved, so callers can detect edits made since they read it.
+func (l *Link) Version() string {
+	return strconv.FormatInt(l.UpdatedAt.UnixMilli(), 10)
+}
+
+// FillShortURL sets ShortURL from base, which returns the scheme and host
+// that prefix short URLs on a domain, such as (*config.Config).ShortURLBase.
+// The slug is percent-encoded, so Unicode and emoji slugs produce valid URLs.
+func (l *Link) FillShortURL(base func(domain string) string) {
+	l.ShortURL = base(l.Domain) + "/" + url.PathEscape(l.Slug)
+}
+
+// UTM returns the link's UTM parameters keyed by name.
+func (l *Link) UTM() map[string]string {
+	return map[string]string{
+		"utm_source":   l.UTMSource,
+		"utm_medium":   l.UTMMedium,
+		"utm_campaign": l.UTMCampaign,
+		"utm_term":     l.UTMTerm,
+		"utm_content":  l.UTMContent,
+	}
+}
+
+// SetUTM sets the UTM fields from values keyed by parameter name.
+func (l *Link) SetUTM(values map[string]string) {
+	l.UTMSource = values["utm_source"]
+	l.UTMMedium = values["utm_medium"]
+	l.UTMCampaign = values["utm_campaign"]
+	l.UTMTerm = values["utm_term"]
+	l.UTMContent = values["utm_content"]
+}
+
+// SetDestination sets Destination to dest, taking the UTM fields from its
+// utm_* parameters, for destinations given as a whole URL.
+func (l *Link) SetDestination(dest string) {
+	l.Destination = dest
+	l.SetUTM(utm.Extract(dest))
+}
+
+// BuildDestination writes the UTM fields onto Destination. A link with no
+// UTM fields set takes them from Destination instead, as SetDestination
+// does, so a destination given whole keeps its parameters. A destination
+// that already carries exactly the fields is left as it is, so its query
+// keeps its order.
+func (l *Link) BuildDestination() {
+	values := l.UTM()
+	if l.UTMSource == "" && l.UTMMedium == "" && l.UTMCampaign == "" && l.UTMTerm == "" && l.UTMContent == "" {
+		l.SetUTM(utm.Extract(l.Destination))
+		return
+	}
+	if maps.Equal(utm.Extract(l.Destination), values) {
+		return
+	}
+	l.Destination = utm.Apply(l.Destination, values)
 }
 
-func (l *Link) FillShortURL() {
-	l.ShortURL = "https://" + l.Domain + "/" + l.Slug
+// Querier is the query side shared by *sql.DB and *sql.Tx, for lookups that
+// must also work inside a transaction.
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
 }
 
+// CreateLink inserts l and its tags. Tags are normalized, so l.Tags may be
+// rewritten.
 func CreateLink(db *sql.DB, l *Link) error {
-	res, err := db.Exec(
-		`INSERT INTO links (slug, domain, destination, title, tags, notes) VALUES (?, ?, ?, ?, ?, ?)`,
-		l.Slug, l.Domain, l.Destination, l.Title, l.Tags, l.Notes,
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin create link: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := CreateLinkTx(tx, l); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit link: %w", err)
+	}
+	return nil
+}
+
+// CreateLinkTx is CreateLink within a caller-managed transaction.
+func CreateLinkTx(tx *sql.Tx, l *Link) error {
+	names := tags.Parse(l.Tags)
+	l.Tags = tags.Join(names)
+	l.BuildDestination()
+
+	res, err := tx.Exec(
+		`INSERT INTO links (slug, domain, destination, normalized_destination, title, tags, notes,
+			utm_source, utm_medium, utm_campaign, utm_term, utm_content) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		l.Slug, l.Domain, l.Destination, urlnorm.Normalize(l.Destination), l.Title, l.Tags, l.Notes,
+		l.UTMSource, l.UTMMedium, l.UTMCampaign, l.UTMTerm, l.UTMContent,
 	)
 	if err != nil {
 		return fmt.Errorf("insert link: %w", err)
 	}
 	id, _ := res.LastInsertId()
 	l.ID = id
+	if err := setLinkTags(tx, id, names); err != nil {
+		return err
+	}
 
 	// Re-read to get timestamps
-	return GetLinkByID(db, l)
+	return GetLinkByID(tx, l)
 }
 
-func GetLinkByID(db *sql.DB, l *Link) error {
-	row := db.QueryRow(`SELECT id, slug, domain, destination, title, tags, notes, is_active, created_at, updated_at FROM links WHERE id = ?`, l.ID)
+func GetLinkByID(db Querier, l *Link) error {
+	row := db.QueryRow(`SELECT `+linkColumns+` FROM links WHERE id = ?`, l.ID)
 	return scanLink(row, l)
 }
 
-func GetLinkBySlugAndDomain(db *sql.DB, slug, domain string) (*Link, error) {
+// GetLinkBySlugAndDomain returns the link whose slug or alias is slug on
+// domain. Links resolved through an alias have AliasID set.
+func GetLinkBySlugAndDomain(db Querier, slug, domain string) (*Link, error) {
 	l := &Link{}
 	row := db.QueryRow(
-		`SELECT id, slug, domain, destination, title, tags, notes, is_active, created_at, updated_at FROM links WHERE domain = ? AND slug = ?`,
+		`SELECT `+linkColumns+` FROM links WHERE domain = ? AND slug = ?`,
 		domain, slug,
 	)
-	if err := scanLink(row, l); err != nil {
+	err := scanLink(row, l)
+	if err == sql.ErrNoRows {
+		return getLinkByAlias(db, `a.domain = ? AND a.slug = ?`, domain, slug)
+	}
+	if err != nil {
 		return nil, err
 	}
-	l.FillShortURL()
 	return l, nil
 }
 
-func ListLinks(db *sql.DB, limit, offset int, search string) ([]Link, int, error) {
-	var args []any
-	where := "1=1"
-	if search != "" {
-		where = "(slug LIKE ? OR destination LIKE ? OR title LIKE ? OR tags LIKE ?)"
-		s := "%" + search + "%"
-		args = append(args, s, s, s, s)
+// GetLinkBySlugAndDomainFold is like GetLinkBySlugAndDomain but matches the
+// slug case-insensitively, preferring an exact match when several exist.
+func GetLinkBySlugAndDomainFold(db Querier, slug, domain string) (*Link, error) {
+	l := &Link{}
+	row := db.QueryRow(
+		`SELECT `+linkColumns+` FROM links
+		WHERE domain = ? AND slug = ? COLLATE NOCASE ORDER BY slug = ? DESC, id LIMIT 1`,
+		domain, slug, slug,
+	)
+	err := scanLink(row, l)
+	if err == sql.ErrNoRows {
+		return getLinkByAlias(db, `a.domain = ? AND a.slug = ? COLLATE NOCASE ORDER BY a.slug = ? DESC, a.id LIMIT 1`, domain, slug, slug)
 	}
-
-	var total int
-	countQuery := "SELECT COUNT(*) FROM links WHERE " + where
-	if err := db.QueryRow(countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, fmt.Errorf("count links: %w", err)
+	if err != nil {
+		return nil, err
 	}
+	return l, nil
+}
 
-	query := "SELECT id, slug, domain, destination, title, tags, notes, is_active, created_at, updated_at FROM links WHERE " + where + " ORDER BY created_at DESC LIMIT ? OFFSET ?"
-	args = append(args, limit, offset)
+func getLinkByAlias(db Querier, where string, args ...any) (*Link, error) {
+	l := &Link{}
+	row := db.QueryRow(
+		`SELECT `+qualifiedLinkColumns("l")+`, a.id
+		FROM link_aliases a JOIN links l ON l.id = a.link_id WHERE `+where,
+		args...,
+	)
+	if err := scanLink(row, l, &l.AliasID); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
 
-	rows, err := db.Query(query, args...)
+// UpdateLink saves l and replaces its tags. Tags are normalized as in
+// CreateLink.
+func UpdateLink(db *sql.DB, l *Link) error {
+	tx, err := db.Begin()
 	if err != nil {
-		return nil, 0, fmt.Errorf("list links: %w", err)
+		return fmt.Errorf("begin update link: %w", err)
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var links []Link
-	for rows.Next() {
-		var l Link
-		var active int
-		if err := rows.Scan(&l.ID, &l.Slug, &l.Domain, &l.Destination, &l.Title, &l.Tags, &l.Notes, &active, &l.CreatedAt, &l.UpdatedAt); err != nil {
-			return nil, 0, fmt.Errorf("scan link: %w", err)
-		}
-		l.IsActive = active == 1
-		l.FillShortURL()
-		links = append(links, l)
+	if err := UpdateLinkTx(tx, l); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit link: %w", err)
 	}
-	return links, total, rows.Err()
+	return nil
 }
 
-func UpdateLink(db *sql.DB, l *Link) error {
-	_, err := db.Exec(
-		`UPDATE links SET slug = ?, domain = ?, destination = ?, title = ?, tags = ?, notes = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
-		l.Slug, l.Domain, l.Destination, l.Title, l.Tags, l.Notes, l.ID,
+// UpdateLinkTx is UpdateLink within a caller-managed transaction.
+func UpdateLinkTx(tx *sql.Tx, l *Link) error {
+	names := tags.Parse(l.Tags)
+	l.Tags = tags.Join(names)
+	l.BuildDestination()
+
+	_, err := tx.Exec(
+		`UPDATE links SET slug = ?, domain = ?, destination = ?, normalized_destination = ?, title = ?, tags = ?, notes = ?,
+			utm_source = ?, utm_medium = ?, utm_campaign = ?, utm_term = ?, utm_content = ?, updated_at = `+nowMillis+` WHERE id = ?`,
+		l.Slug, l.Domain, l.Destination, urlnorm.Normalize(l.Destination), l.Title, l.Tags, l.Notes,
+		l.UTMSource, l.UTMMedium, l.UTMCampaign, l.UTMTerm, l.UTMContent, l.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("update link: %w", err)
 	}
-	return GetLinkByID(db, l)
+	if err := setLinkTags(tx, l.ID, names); err != nil {
+		return err
+	}
+	return GetLinkByID(tx, l)
+}
+
+// Savepoint runs fn inside a savepoint on tx, undoing only fn's writes when
+// it returns an error, which is passed through.
+func Savepoint(tx *sql.Tx, fn func() error) error {
+	if _, err := tx.Exec(`SAVEPOINT item`); err != nil {
+		return fmt.Errorf("savepoint: %w", err)
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := tx.Exec(`ROLLBACK TO item`); rbErr != nil {
+			return fmt.Errorf("rollback to savepoint: %w", rbErr)
+		}
+		tx.Exec(`RELEASE item`)
+		return err
+	}
+	if _, err := tx.Exec(`RELEASE item`); err != nil {
+		return fmt.Errorf("release savepoint: %w", err)
+	}
+	return nil
+}
+
+// UpdateLinkIfUnchanged is UpdateLink for a link read at version: it
+// returns ErrLinkChanged instead of saving when the link has been saved
+// since.
+func UpdateLinkIfUnchanged(db *sql.DB, l *Link, version string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin update link: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkLinkVersion(tx, l.ID, version); err != nil {
+		return err
+	}
+	if err := UpdateLinkTx(tx, l); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit link: %w", err)
+	}
+	return nil
+}
+
+// SoftDeleteLinkIfUnchanged is SoftDeleteLink for a link read at version,
+// returning ErrLinkChanged when the link has been saved since.
+func SoftDeleteLinkIfUnchanged(db *sql.DB, id int64, version string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin soft delete link: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkLinkVersion(tx, id, version); err != nil {
+		return err
+	}
+	if err := SoftDeleteLink(tx, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit soft delete link: %w", err)
+	}
+	return nil
+}
+
+// checkLinkVersion returns ErrLinkChanged unless link id is at version.
+func checkLinkVersion(q Querier, id int64, version string) error {
+	l := Link{ID: id}
+	if err := q.QueryRow(`SELECT updated_at FROM links WHERE id = ?`, id).Scan(&l.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		return fmt.Errorf("check link version: %w", err)
+	}
+	if l.Version() != version {
+		return ErrLinkChanged
+	}
+	return nil
 }
 
-func SoftDeleteLink(db *sql.DB, id int64) error {
-	res, err := db.Exec(`UPDATE links SET is_active = 0, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, id)
+func SoftDeleteLink(db Querier, id int64) error {
+	res, err := db.Exec(`UPDATE links SET is_active = 0, updated_at = `+nowMillis+` WHERE id = ?`, id)
 	if err != nil {
 		return fmt.Errorf("soft delete link: %w", err)
 	}
@@ -118,18 +346,176 @@ func SoftDeleteLink(db *sql.DB, id int64) error {
 	return nil
 }
 
-func SlugExists(db *sql.DB, slug, domain string) (bool, error) {
+// SetLinkActive enables or disables a link.
+func SetLinkActive(db Querier, id int64, active bool) error {
+	res, err := db.Exec(`UPDATE links SET is_active = ?, updated_at = `+nowMillis+` WHERE id = ?`, active, id)
+	if err != nil {
+		return fmt.Errorf("set link active: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetLinkCreatedAt backdates a link, for links carried over from elsewhere.
+func SetLinkCreatedAt(db Querier, id int64, t time.Time) error {
+	if _, err := db.Exec(`UPDATE links SET created_at = ? WHERE id = ?`, t.UTC(), id); err != nil {
+		return fmt.Errorf("set link created_at: %w", err)
+	}
+	return nil
+}
+
+// EachLink calls fn for every link, soft-deleted ones included, in ID
+// order. Links are read in batches so large tables are never held in
+// memory and the connection is free between batches.
+func EachLink(db *sql.DB, fn func(*Link) error) error {
+	var after int64
+	for {
+		rows, err := db.Query(
+			`SELECT `+linkColumns+` FROM links WHERE id > ? ORDER BY id LIMIT ?`,
+			after, eachBatchSize,
+		)
+		if err != nil {
+			return fmt.Errorf("list links: %w", err)
+		}
+		var batch []Link
+		for rows.Next() {
+			var l Link
+			if err := scanLink(rows, &l); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan link: %w", err)
+			}
+			batch = append(batch, l)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if len(batch) < eachBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].ID
+	}
+}
+
+// FindLinkByDestination returns the oldest active link on domain that points
+// at destination, or sql.ErrNoRows. Destinations match when they normalize
+// the same; see urlnorm.Normalize.
+func FindLinkByDestination(db Querier, domain, destination string) (*Link, error) {
+	var id int64
+	err := db.QueryRow(
+		`SELECT id FROM links WHERE domain = ? AND normalized_destination = ? AND is_active = 1 ORDER BY id LIMIT 1`,
+		domain, urlnorm.Normalize(destination),
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find link by destination: %w", err)
+	}
+	l := &Link{ID: id}
+	if err := GetLinkByID(db, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// SlugExists reports whether slug is taken on domain by a link or an alias.
+func SlugExists(db Querier, slug, domain string) (bool, error) {
 	var count int
-	err := db.QueryRow(`SELECT COUNT(*) FROM links WHERE slug = ? AND domain = ?`, slug, domain).Scan(&count)
+	err := db.QueryRow(
+		`SELECT (SELECT COUNT(*) FROM links WHERE slug = ? AND domain = ?) + (SELECT COUNT(*) FROM link_aliases WHERE slug = ? AND domain = ?)`,
+		slug, domain, slug, domain,
+	).Scan(&count)
 	return count > 0, err
 }
 
-func scanLink(row *sql.Row, l *Link) error {
+// SlugExistsFold reports whether slug is taken on domain ignoring case.
+func SlugExistsFold(db Querier, slug, domain string) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT (SELECT COUNT(*) FROM links WHERE slug = ? COLLATE NOCASE AND domain = ?) + (SELECT COUNT(*) FROM link_aliases WHERE slug = ? COLLATE NOCASE AND domain = ?)`,
+		slug, domain, slug, domain,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// SlugRef names a slug on a domain for TakenSlugs. Fold matches the slug
+// ignoring case, as SlugExistsFold does.
+type SlugRef struct {
+	Domain string
+	Slug   string
+	Fold   bool
+}
+
+// TakenSlugs reports which of refs are already used by a link or alias,
+// checking them all in one query.
+func TakenSlugs(db Querier, refs []SlugRef) (map[SlugRef]bool, error) {
+	taken := make(map[SlugRef]bool)
+	if len(refs) == 0 {
+		return taken, nil
+	}
+	args := make([]any, 0, len(refs)*4)
+	for i, ref := range refs {
+		args = append(args, i, ref.Domain, ref.Slug, ref.Fold)
+	}
+	values := strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?), ", len(refs)), ", ")
+	rows, err := db.Query(`WITH wanted(i, domain, slug, fold) AS (VALUES `+values+`)
+		SELECT w.i FROM wanted w
+		WHERE EXISTS (SELECT 1 FROM links l WHERE l.domain = w.domain AND (l.slug = w.slug OR (w.fold AND l.slug = w.slug COLLATE NOCASE)))
+		   OR EXISTS (SELECT 1 FROM link_aliases a WHERE a.domain = w.domain AND (a.slug = w.slug OR (w.fold AND a.slug = w.slug COLLATE NOCASE)))`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i int
+		if err := rows.Scan(&i); err != nil {
+			return nil, err
+		}
+		taken[refs[i]] = true
+	}
+	return taken, rows.Err()
+}
+
+const eachBatchSize = 500
+
+// linkColumns are the links columns scanLink reads, in order.
+const linkColumns = "id, slug, domain, destination, title, tags, notes, is_active, created_at, updated_at, click_count, last_clicked_at, " +
+	"utm_source, utm_medium, utm_campaign, utm_term, utm_content"
+
+// qualifiedLinkColumns is linkColumns for a query that names links table.
+func qualifiedLinkColumns(table string) string {
+	return table + "." + strings.ReplaceAll(linkColumns, ", ", ", "+table+".")
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanLink reads a row selected with linkColumns into l, followed by any
+// extra columns into extra.
+func scanLink(row rowScanner, l *Link, extra ...any) error {
 	var active int
-	if err := row.Scan(&l.ID, &l.Slug, &l.Domain, &l.Destination, &l.Title, &l.Tags, &l.Notes, &active, &l.CreatedAt, &l.UpdatedAt); err != nil {
+	var lastClicked sql.NullTime
+	dest := append([]any{
+		&l.ID, &l.Slug, &l.Domain, &l.Destination, &l.Title, &l.Tags, &l.Notes, &active, &l.CreatedAt, &l.UpdatedAt, &l.Clicks, &lastClicked,
+		&l.UTMSource, &l.UTMMedium, &l.UTMCampaign, &l.UTMTerm, &l.UTMContent,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
 		return err
 	}
 	l.IsActive = active == 1
-	l.FillShortURL()
+	l.LastClickedAt = nil
+	if lastClicked.Valid {
+		l.LastClickedAt = &lastClicked.Time
+	}
 	return nil
 }
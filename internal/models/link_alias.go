@@ -0,0 +1,135 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// LinkAlias is an extra slug/domain pair that resolves to a link.
+type LinkAlias struct {
+	ID        int64     `json:"id"`
+	LinkID    int64     `json:"link_id"`
+	Slug      string    `json:"slug"`
+	Domain    string    `json:"domain"`
+	ShortURL  string    `json:"short_url"`
+	Clicks    int       `json:"clicks"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FillShortURL sets ShortURL from base, as Link.FillShortURL does.
+func (a *LinkAlias) FillShortURL(base func(domain string) string) {
+	a.ShortURL = base(a.Domain) + "/" + url.PathEscape(a.Slug)
+}
+
+func CreateLinkAlias(db *sql.DB, a *LinkAlias) error {
+	res, err := db.Exec(`INSERT INTO link_aliases (link_id, slug, domain) VALUES (?, ?, ?)`, a.LinkID, a.Slug, a.Domain)
+	if err != nil {
+		return fmt.Errorf("insert link alias: %w", err)
+	}
+	a.ID, _ = res.LastInsertId()
+	if err := db.QueryRow(`SELECT created_at FROM link_aliases WHERE id = ?`, a.ID).Scan(&a.CreatedAt); err != nil {
+		return fmt.Errorf("read link alias: %w", err)
+	}
+	return nil
+}
+
+// ListLinkAliases returns a link's aliases, oldest first, with the number of
+// clicks that came through each.
+func ListLinkAliases(db *sql.DB, linkID int64) ([]LinkAlias, error) {
+	rows, err := db.Query(
+		`SELECT a.id, a.link_id, a.slug, a.domain, a.created_at,
+			(SELECT COUNT(*) FROM clicks c WHERE c.link_id = a.link_id AND c.alias_id = a.id)
+		FROM link_aliases a WHERE a.link_id = ? ORDER BY a.id`,
+		linkID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list link aliases: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []LinkAlias
+	for rows.Next() {
+		var a LinkAlias
+		if err := rows.Scan(&a.ID, &a.LinkID, &a.Slug, &a.Domain, &a.CreatedAt, &a.Clicks); err != nil {
+			return nil, fmt.Errorf("scan link alias: %w", err)
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+// DeleteLinkAlias removes an alias of linkID and returns it so callers can
+// invalidate its cache key. Clicks keep their alias_id.
+func DeleteLinkAlias(db *sql.DB, linkID, aliasID int64) (*LinkAlias, error) {
+	a := &LinkAlias{}
+	err := db.QueryRow(
+		`SELECT id, link_id, slug, domain, created_at FROM link_aliases WHERE id = ? AND link_id = ?`,
+		aliasID, linkID,
+	).Scan(&a.ID, &a.LinkID, &a.Slug, &a.Domain, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`DELETE FROM link_aliases WHERE id = ?`, aliasID); err != nil {
+		return nil, fmt.Errorf("delete link alias: %w", err)
+	}
+	return a, nil
+}
+
+// AliasClickCount is the number of clicks a link received through one of
+// its slugs. AliasID is 0 for the link's own slug.
+type AliasClickCount struct {
+	AliasID int64
+	Slug    string
+	Domain  string
+	Count   int
+}
+
+// ClicksByAliasForLink breaks a link's clicks down by the slug they came
+// through: its own slug first, then each alias, including removed ones.
+func ClicksByAliasForLink(db *sql.DB, l *Link) ([]AliasClickCount, error) {
+	rows, err := db.Query(
+		`SELECT COALESCE(c.alias_id, 0), COALESCE(a.slug, ''), COALESCE(a.domain, ''), COUNT(*)
+		FROM clicks c LEFT JOIN link_aliases a ON a.id = c.alias_id
+		WHERE c.link_id = ?
+		GROUP BY COALESCE(c.alias_id, 0) ORDER BY COALESCE(c.alias_id, 0) = 0 DESC, COUNT(*) DESC`,
+		l.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("clicks by alias: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []AliasClickCount
+	for rows.Next() {
+		var c AliasClickCount
+		if err := rows.Scan(&c.AliasID, &c.Slug, &c.Domain, &c.Count); err != nil {
+			return nil, fmt.Errorf("scan alias count: %w", err)
+		}
+		if c.AliasID == 0 {
+			c.Slug, c.Domain = l.Slug, l.Domain
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// aliasesOnDomain returns every alias on domain.
+func aliasesOnDomain(db *sql.DB, domain string) ([]LinkAlias, error) {
+	rows, err := db.Query(`SELECT id, link_id, slug, domain, created_at FROM link_aliases WHERE domain = ? ORDER BY id`, domain)
+	if err != nil {
+		return nil, fmt.Errorf("list domain aliases: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []LinkAlias
+	for rows.Next() {
+		var a LinkAlias
+		if err := rows.Scan(&a.ID, &a.LinkID, &a.Slug, &a.Domain, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan link alias: %w", err)
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
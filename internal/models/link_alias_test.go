@@ -0,0 +1,189 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestLinkAlias_ResolvesToLink(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "main", Domain: "d.co", Destination: "https://example.com"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	a := &LinkAlias{LinkID: l.ID, Slug: "Promo", Domain: "d.co"}
+	if err := CreateLinkAlias(d, a); err != nil {
+		t.Fatal(err)
+	}
+	if a.ID == 0 || a.CreatedAt.IsZero() {
+		t.Errorf("alias = %+v", a)
+	}
+
+	got, err := GetLinkBySlugAndDomain(d, "Promo", "d.co")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != l.ID || got.AliasID != a.ID {
+		t.Errorf("resolved link %d alias %d, want %d alias %d", got.ID, got.AliasID, l.ID, a.ID)
+	}
+
+	got, err = GetLinkBySlugAndDomainFold(d, "promo", "d.co")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AliasID != a.ID {
+		t.Errorf("fold lookup alias = %d, want %d", got.AliasID, a.ID)
+	}
+
+	got, err = GetLinkBySlugAndDomain(d, "main", "d.co")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AliasID != 0 {
+		t.Errorf("primary slug AliasID = %d, want 0", got.AliasID)
+	}
+}
+
+func TestLinkAlias_SharesSlugNamespace(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "main", Domain: "d.co", Destination: "https://example.com"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateLinkAlias(d, &LinkAlias{LinkID: l.ID, Slug: "alt", Domain: "d.co"}); err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := SlugExists(d, "alt", "d.co")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("SlugExists(alt) = false, want true")
+	}
+	exists, err = SlugExistsFold(d, "ALT", "d.co")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("SlugExistsFold(ALT) = false, want true")
+	}
+	if exists, _ := SlugExists(d, "alt", "other.co"); exists {
+		t.Error("SlugExists on another domain = true, want false")
+	}
+
+	if err := CreateLinkAlias(d, &LinkAlias{LinkID: l.ID, Slug: "alt", Domain: "d.co"}); err == nil {
+		t.Error("duplicate alias created, want constraint error")
+	}
+}
+
+func TestClicksByAliasForLink(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "main", Domain: "d.co", Destination: "https://example.com"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	a := &LinkAlias{LinkID: l.ID, Slug: "alt", Domain: "d.co"}
+	if err := CreateLinkAlias(d, a); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if err := BatchInsertClicks(d, []Click{
+		{LinkID: l.ID, ClickedAt: now},
+		{LinkID: l.ID, AliasID: a.ID, ClickedAt: now},
+		{LinkID: l.ID, AliasID: a.ID, ClickedAt: now},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := ClicksByAliasForLink(d, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 2 {
+		t.Fatalf("got %d rows, want 2: %+v", len(counts), counts)
+	}
+	if counts[0].AliasID != 0 || counts[0].Slug != "main" || counts[0].Count != 1 {
+		t.Errorf("primary row = %+v", counts[0])
+	}
+	if counts[1].AliasID != a.ID || counts[1].Slug != "alt" || counts[1].Count != 2 {
+		t.Errorf("alias row = %+v", counts[1])
+	}
+
+	aliases, err := ListLinkAliases(d, l.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aliases) != 1 || aliases[0].Clicks != 2 {
+		t.Errorf("aliases = %+v", aliases)
+	}
+}
+
+func TestDeleteLinkAlias(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "main", Domain: "d.co", Destination: "https://example.com"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	a := &LinkAlias{LinkID: l.ID, Slug: "alt", Domain: "d.co"}
+	if err := CreateLinkAlias(d, a); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DeleteLinkAlias(d, l.ID+1, a.ID); err != sql.ErrNoRows {
+		t.Errorf("delete via wrong link: err = %v, want sql.ErrNoRows", err)
+	}
+	deleted, err := DeleteLinkAlias(d, l.ID, a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Slug != "alt" || deleted.Domain != "d.co" {
+		t.Errorf("deleted = %+v", deleted)
+	}
+	if _, err := GetLinkBySlugAndDomain(d, "alt", "d.co"); err != sql.ErrNoRows {
+		t.Errorf("lookup after delete: err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestPlanDomainMove_IncludesAliases(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "main", Domain: "old.co", Destination: "https://example.com"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateLinkAlias(d, &LinkAlias{LinkID: l.ID, Slug: "alt", Domain: "old.co"}); err != nil {
+		t.Fatal(err)
+	}
+	taken := &Link{Slug: "alt", Domain: "new.co", Destination: "https://example.com"}
+	if err := CreateLink(d, taken); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := PlanDomainMove(d, "old.co", "new.co", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Collisions) != 1 || plan.Collisions[0].Slug != "alt" || plan.Collisions[0].ConflictingLinkID != taken.ID {
+		t.Fatalf("collisions = %+v", plan.Collisions)
+	}
+
+	if _, err := d.Exec(`DELETE FROM links WHERE id = ?`, taken.ID); err != nil {
+		t.Fatal(err)
+	}
+	plan, err = PlanDomainMove(d, "old.co", "new.co", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyDomainMove(d, plan); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetLinkBySlugAndDomain(d, "alt", "new.co")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != l.ID {
+		t.Errorf("alias resolves to %d, want %d", got.ID, l.ID)
+	}
+}
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/scmmishra/dubly/internal/tags"
+)
+
+// ErrNotDuplicate is returned by MergeLinks for a link that isn't an active
+// duplicate of the one being kept.
+var ErrNotDuplicate = errors.New("link is not a duplicate")
+
+// DuplicateGroup is a set of active links on one domain whose destinations
+// normalize the same.
+type DuplicateGroup struct {
+	Domain      string
+	Destination string // normalized
+	Links       []Link // oldest first
+}
+
+// Clicks is the combined click count of the group's links.
+func (g *DuplicateGroup) Clicks() int {
+	n := 0
+	for _, l := range g.Links {
+		n += l.Clicks
+	}
+	return n
+}
+
+// FindDuplicateLinks returns every group of duplicate active links, the
+// largest groups first.
+func FindDuplicateLinks(db Querier) ([]DuplicateGroup, error) {
+	rows, err := db.Query(
+		`SELECT ` + linkColumns + `, normalized_destination FROM links
+		WHERE is_active = 1 AND (domain, normalized_destination) IN (
+			SELECT domain, normalized_destination FROM links WHERE is_active = 1
+			GROUP BY domain, normalized_destination HAVING COUNT(*) > 1)
+		ORDER BY domain, normalized_destination, id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find duplicate links: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []DuplicateGroup
+	for rows.Next() {
+		var l Link
+		var norm string
+		if err := scanLink(rows, &l, &norm); err != nil {
+			return nil, fmt.Errorf("scan duplicate link: %w", err)
+		}
+		if n := len(groups); n == 0 || groups[n-1].Domain != l.Domain || groups[n-1].Destination != norm {
+			groups = append(groups, DuplicateGroup{Domain: l.Domain, Destination: norm})
+		}
+		g := &groups[len(groups)-1]
+		g.Links = append(g.Links, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("find duplicate links: %w", err)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i].Links) > len(groups[j].Links) })
+	return groups, nil
+}
+
+// MergeLinks folds the links in mergeIDs into link keepID. Each must be an
+// active duplicate of it, or ErrNotDuplicate is returned and nothing
+// changes. Their clicks, historical click totals, aliases and tags move to
+// the kept link, and each merged link's slug becomes an alias of it, so its
+// short URL keeps working and its clicks still show separately. The merged
+// links are then deleted, and returned as they were so callers can drop
+// cached copies.
+func MergeLinks(db *sql.DB, keepID int64, mergeIDs []int64) ([]Link, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin merge links: %w", err)
+	}
+	defer tx.Rollback()
+
+	keep := &Link{ID: keepID}
+	var keepNorm string
+	if err := scanLink(tx.QueryRow(`SELECT `+linkColumns+`, normalized_destination FROM links WHERE id = ?`, keepID), keep, &keepNorm); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("get link: %w", err)
+	}
+	if !keep.IsActive {
+		return nil, fmt.Errorf("link %d: %w", keepID, ErrNotDuplicate)
+	}
+
+	names := tags.Parse(keep.Tags)
+	var merged []Link
+	seen := map[int64]bool{keepID: true}
+	for _, id := range mergeIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		dup := Link{ID: id}
+		var norm string
+		if err := scanLink(tx.QueryRow(`SELECT `+linkColumns+`, normalized_destination FROM links WHERE id = ?`, id), &dup, &norm); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("link %d: %w", id, ErrNotDuplicate)
+			}
+			return nil, fmt.Errorf("get link: %w", err)
+		}
+		if !dup.IsActive || dup.Domain != keep.Domain || norm != keepNorm {
+			return nil, fmt.Errorf("link %d: %w", id, ErrNotDuplicate)
+		}
+		if err := mergeLink(tx, keepID, &dup); err != nil {
+			return nil, err
+		}
+		names = append(names, tags.Parse(dup.Tags)...)
+		merged = append(merged, dup)
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+
+	names = tags.Clean(names)
+	if _, err := tx.Exec(
+		`UPDATE links SET tags = ?, updated_at = `+nowMillis+`,
+			click_count = (SELECT COUNT(*) FROM clicks WHERE link_id = links.id),
+			last_clicked_at = (SELECT MAX(clicked_at) FROM clicks WHERE link_id = links.id)
+		WHERE id = ?`,
+		tags.Join(names), keepID,
+	); err != nil {
+		return nil, fmt.Errorf("update merged link: %w", err)
+	}
+	if err := setLinkTags(tx, keepID, names); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit merge links: %w", err)
+	}
+	return merged, nil
+}
+
+// linkMerge is where clicks on a merged link go.
+type linkMerge struct {
+	into, alias int64
+}
+
+// mergedLinks maps those of ids that name merged links to where their
+// clicks go.
+func mergedLinks(q Querier, ids []int64) (map[int64]linkMerge, error) {
+	merges := map[int64]linkMerge{}
+	if len(ids) == 0 {
+		return merges, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := q.Query(`SELECT link_id, into_id, alias_id FROM merged_links WHERE link_id IN (`+placeholders(len(ids))+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list merged links: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var m linkMerge
+		if err := rows.Scan(&id, &m.into, &m.alias); err != nil {
+			return nil, fmt.Errorf("scan merged link: %w", err)
+		}
+		merges[id] = m
+	}
+	return merges, rows.Err()
+}
+
+// mergeLink moves everything of dup's onto link keepID and deletes dup.
+// Clicks move with an UPDATE, which the click counter triggers don't see,
+// so MergeLinks recounts the kept link's clicks afterwards.
+func mergeLink(tx *sql.Tx, keepID int64, dup *Link) error {
+	res, err := tx.Exec(`INSERT INTO link_aliases (link_id, slug, domain) VALUES (?, ?, ?)`, keepID, dup.Slug, dup.Domain)
+	if err != nil {
+		return fmt.Errorf("alias merged link %d: %w", dup.ID, err)
+	}
+	aliasID, _ := res.LastInsertId()
+
+	// Clicks on dup may still be buffered; InsertClicksTx reroutes them
+	if _, err := tx.Exec(`UPDATE merged_links SET into_id = ? WHERE into_id = ?`, keepID, dup.ID); err != nil {
+		return fmt.Errorf("reroute links merged into %d: %w", dup.ID, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO merged_links (link_id, into_id, alias_id) VALUES (?, ?, ?)`, dup.ID, keepID, aliasID); err != nil {
+		return fmt.Errorf("record merged link %d: %w", dup.ID, err)
+	}
+
+	steps := []struct {
+		what, query string
+		args        []any
+	}{
+		// Clicks through dup's own slug now count as clicks through its alias
+		{"move clicks", `UPDATE clicks SET link_id = ?, alias_id = COALESCE(alias_id, ?) WHERE link_id = ?`, []any{keepID, aliasID, dup.ID}},
+		{"move historical clicks", `INSERT INTO historical_clicks (link_id, source, clicks) SELECT ?, source, clicks FROM historical_clicks WHERE link_id = ?
+			ON CONFLICT(link_id) DO UPDATE SET clicks = historical_clicks.clicks + excluded.clicks`, []any{keepID, dup.ID}},
+		{"clear historical clicks", `DELETE FROM historical_clicks WHERE link_id = ?`, []any{dup.ID}},
+		{"move aliases", `UPDATE link_aliases SET link_id = ? WHERE link_id = ?`, []any{keepID, dup.ID}},
+		{"clear tags", `DELETE FROM link_tags WHERE link_id = ?`, []any{dup.ID}},
+		{"clear campaign", `DELETE FROM campaign_links WHERE link_id = ?`, []any{dup.ID}},
+		{"delete", `DELETE FROM links WHERE id = ?`, []any{dup.ID}},
+	}
+	for _, s := range steps {
+		if _, err := tx.Exec(s.query, s.args...); err != nil {
+			return fmt.Errorf("%s of merged link %d: %w", s.what, dup.ID, err)
+		}
+	}
+	return nil
+}
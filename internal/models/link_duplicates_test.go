@@ -0,0 +1,159 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFindLinkByDestination_MatchesNormalized(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "a", Domain: "d.co", Destination: "https://Example.com:443/page?b=2&a=1"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	got, err := FindLinkByDestination(d, "d.co", "https://example.com/page?a=1&b=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != l.ID {
+		t.Errorf("got link %d, want %d", got.ID, l.ID)
+	}
+}
+
+func TestFindDuplicateLinks(t *testing.T) {
+	d := testDB(t)
+	for _, l := range []*Link{
+		{Slug: "a1", Domain: "d.co", Destination: "https://a.com/x?b=1&a=2"},
+		{Slug: "a2", Domain: "d.co", Destination: "https://A.com/x?a=2&b=1"},
+		{Slug: "a3", Domain: "d.co", Destination: "https://a.com/x?a=2&b=1"},
+		{Slug: "b1", Domain: "d.co", Destination: "https://b.com"},
+		{Slug: "b2", Domain: "d.co", Destination: "https://b.com/"},
+		{Slug: "c1", Domain: "d.co", Destination: "https://c.com"},
+		{Slug: "b3", Domain: "e.co", Destination: "https://b.com"}, // other domain
+	} {
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+		if l.Slug == "a3" {
+			SoftDeleteLink(d, l.ID) // inactive links aren't duplicates
+		}
+	}
+
+	groups, err := FindDuplicateLinks(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2: %+v", len(groups), groups)
+	}
+	var slugs [][]string
+	for _, g := range groups {
+		var s []string
+		for _, l := range g.Links {
+			s = append(s, l.Slug)
+		}
+		slugs = append(slugs, s)
+	}
+	if slugs[0][0] != "a1" || slugs[0][1] != "a2" || slugs[1][0] != "b1" || slugs[1][1] != "b2" {
+		t.Errorf("groups = %v, want [[a1 a2] [b1 b2]]", slugs)
+	}
+	if groups[1].Destination != "https://b.com/" {
+		t.Errorf("destination = %q, want the normalized form", groups[1].Destination)
+	}
+}
+
+func TestMergeLinks(t *testing.T) {
+	d := testDB(t)
+	keep := &Link{Slug: "keep", Domain: "d.co", Destination: "https://a.com", Tags: "one"}
+	dup := &Link{Slug: "dup", Domain: "d.co", Destination: "https://a.com/", Tags: "two"}
+	for _, l := range []*Link{keep, dup} {
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := &LinkAlias{LinkID: dup.ID, Slug: "dup-alias", Domain: "d.co"}
+	if err := CreateLinkAlias(d, old); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	if err := BatchInsertClicks(d, []Click{
+		{LinkID: keep.ID, ClickedAt: now.Add(-time.Hour)},
+		{LinkID: dup.ID, ClickedAt: now},
+		{LinkID: dup.ID, ClickedAt: now, AliasID: old.ID},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	SetHistoricalClicks(d, keep.ID, "bitly", 10)
+	SetHistoricalClicks(d, dup.ID, "bitly", 5)
+
+	merged, err := MergeLinks(d, keep.ID, []int64{dup.ID, keep.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 1 || merged[0].Slug != "dup" {
+		t.Fatalf("merged = %+v, want just dup", merged)
+	}
+
+	if err := GetLinkByID(d, keep); err != nil {
+		t.Fatal(err)
+	}
+	if keep.Clicks != 3 || keep.LastClickedAt == nil || keep.LastClickedAt.Before(now.Add(-time.Second)) {
+		t.Errorf("clicks = %d, last clicked %v, want 3 and the dup's click", keep.Clicks, keep.LastClickedAt)
+	}
+	if keep.Tags != "one,two" {
+		t.Errorf("tags = %q, want one,two", keep.Tags)
+	}
+	if h, err := GetHistoricalClicks(d, keep.ID); err != nil || h.Clicks != 15 {
+		t.Errorf("historical = %+v, %v, want 15", h, err)
+	}
+	if err := GetLinkByID(d, &Link{ID: dup.ID}); err == nil {
+		t.Error("merged link should be gone")
+	}
+
+	// The dup's slug and alias now resolve to the kept link, with the dup's
+	// clicks attributed to them
+	aliases, err := ListLinkAliases(d, keep.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, a := range aliases {
+		counts[a.Slug] = a.Clicks
+	}
+	if len(counts) != 2 || counts["dup"] != 1 || counts["dup-alias"] != 1 {
+		t.Errorf("alias clicks = %v, want dup:1 dup-alias:1", counts)
+	}
+	if l, err := GetLinkBySlugAndDomain(d, "dup", "d.co"); err != nil || l.ID != keep.ID {
+		t.Errorf("dup slug resolves to %+v, %v, want the kept link", l, err)
+	}
+
+	// Clicks still buffered for the merged link land on the kept one
+	if err := BatchInsertClicks(d, []Click{{LinkID: dup.ID, ClickedAt: now}}); err != nil {
+		t.Fatalf("late click: %v", err)
+	}
+	GetLinkByID(d, keep)
+	if keep.Clicks != 4 {
+		t.Errorf("clicks after a late click = %d, want 4", keep.Clicks)
+	}
+}
+
+func TestMergeLinks_RejectsNonDuplicates(t *testing.T) {
+	d := testDB(t)
+	keep := &Link{Slug: "keep", Domain: "d.co", Destination: "https://a.com"}
+	other := &Link{Slug: "other", Domain: "d.co", Destination: "https://b.com"}
+	elsewhere := &Link{Slug: "elsewhere", Domain: "e.co", Destination: "https://a.com"}
+	for _, l := range []*Link{keep, other, elsewhere} {
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []int64{other.ID, elsewhere.ID, 99999} {
+		if _, err := MergeLinks(d, keep.ID, []int64{id}); !errors.Is(err, ErrNotDuplicate) {
+			t.Errorf("merge %d: err = %v, want ErrNotDuplicate", id, err)
+		}
+	}
+	if err := GetLinkByID(d, other); err != nil {
+		t.Errorf("rejected merge should leave links alone: %v", err)
+	}
+}
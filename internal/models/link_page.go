@@ -0,0 +1,399 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/tags"
+)
+
+// ErrInvalidCursor is returned by PageLinks for a cursor it didn't issue, or
+// one issued for a different sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// LinkFilter narrows ListLinks and PageLinks. Search is full-text search
+// over the slug, title, destination, tags and notes (see searchQuery). Each
+// of Tags must match a tag exactly and none of ExcludeTags may. Active, when
+// set, keeps only active or only inactive (deleted) links. Click bounds are
+// inclusive; time bounds are inclusive below and exclusive above, and links
+// never clicked never match a LastClicked bound.
+type LinkFilter struct {
+	Search         string
+	Tags           []string
+	ExcludeTags    []string
+	Domain         string
+	ExcludeDomains []string
+	Active         *bool
+
+	MinClicks *int
+	MaxClicks *int
+
+	CreatedAfter      *time.Time
+	CreatedBefore     *time.Time
+	LastClickedAfter  *time.Time
+	LastClickedBefore *time.Time
+}
+
+// where returns the SQL condition for f and its arguments.
+func (f LinkFilter) where() (string, []any) {
+	var args []any
+	where := "1=1"
+	if f.Search != "" {
+		if match := searchQuery(f.Search); match != "" {
+			where += " AND id IN (SELECT rowid FROM links_fts WHERE links_fts MATCH ?)"
+			args = append(args, match)
+		} else {
+			// Input with no words to search for matches nothing
+			where += " AND 0"
+		}
+	}
+	for _, t := range tags.Clean(f.Tags) {
+		where += " AND id IN (" + taggedLinks + ")"
+		args = append(args, t)
+	}
+	for _, t := range tags.Clean(f.ExcludeTags) {
+		where += " AND id NOT IN (" + taggedLinks + ")"
+		args = append(args, t)
+	}
+	if f.Domain != "" {
+		where += " AND domain = ?"
+		args = append(args, f.Domain)
+	}
+	for _, d := range f.ExcludeDomains {
+		where += " AND domain != ?"
+		args = append(args, d)
+	}
+	if f.MinClicks != nil {
+		where += " AND click_count >= ?"
+		args = append(args, *f.MinClicks)
+	}
+	if f.MaxClicks != nil {
+		where += " AND click_count <= ?"
+		args = append(args, *f.MaxClicks)
+	}
+	if f.Active != nil {
+		where += " AND is_active = ?"
+		args = append(args, *f.Active)
+	}
+	bounds := []struct {
+		cond string
+		t    *time.Time
+	}{
+		{"created_at >= ?", f.CreatedAfter},
+		{"created_at < ?", f.CreatedBefore},
+		{"last_clicked_at >= ?", f.LastClickedAfter},
+		{"last_clicked_at < ?", f.LastClickedBefore},
+	}
+	for _, b := range bounds {
+		if b.t != nil {
+			where += " AND " + b.cond
+			args = append(args, b.t.UTC().Format("2006-01-02 15:04:05"))
+		}
+	}
+	return where, args
+}
+
+// ParseFilterTime reads a LinkFilter bound given as RFC 3339 or as a bare
+// date. A bare date upper bound covers the whole day, so "created before
+// 2024-03-01" includes links created that day.
+func ParseFilterTime(s string, upper bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q: use YYYY-MM-DD or RFC 3339", s)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// LinkSortField is a column links can be listed by.
+type LinkSortField string
+
+const (
+	SortCreated     LinkSortField = "created"
+	SortUpdated     LinkSortField = "updated"
+	SortTitle       LinkSortField = "title"
+	SortClicks      LinkSortField = "clicks"
+	SortLastClicked LinkSortField = "last_clicked"
+	SortRelevance   LinkSortField = "relevance"
+)
+
+// sortExprs are the indexed expressions each field sorts by. Links never
+// clicked sort as the oldest. search_rank is joined in by PageLinks.
+var sortExprs = map[LinkSortField]string{
+	SortCreated:     "created_at",
+	SortUpdated:     "updated_at",
+	SortTitle:       "title COLLATE NOCASE",
+	SortClicks:      "click_count",
+	SortLastClicked: "IFNULL(last_clicked_at, '')",
+	SortRelevance:   "search_rank",
+}
+
+// LinkSort orders a listing. Ties are broken by id in the same direction.
+type LinkSort struct {
+	Field LinkSortField
+	Desc  bool
+}
+
+// DefaultLinkSort lists the newest links first.
+var DefaultLinkSort = LinkSort{Field: SortCreated, Desc: true}
+
+// ParseLinkSort reads a sort field and an order of "asc" or "desc". An empty
+// field is the zero LinkSort, which PageLinks takes as best matches first
+// when searching and DefaultLinkSort otherwise. An empty order is ascending
+// for titles and descending for everything else.
+func ParseLinkSort(field, order string) (LinkSort, error) {
+	if field == "" {
+		if order != "" && order != "asc" && order != "desc" {
+			return LinkSort{}, fmt.Errorf("unknown order %q", order)
+		}
+		return LinkSort{}, nil
+	}
+	s := LinkSort{Field: LinkSortField(field)}
+	if _, ok := sortExprs[s.Field]; !ok {
+		return LinkSort{}, fmt.Errorf("unknown sort %q", field)
+	}
+	switch order {
+	case "":
+		s.Desc = s.Field != SortTitle
+	case "asc":
+	case "desc":
+		s.Desc = true
+	default:
+		return LinkSort{}, fmt.Errorf("unknown order %q", order)
+	}
+	return s, nil
+}
+
+// resolve fills in the default sort. Relevance only means something when
+// searching, so without a search it's the default too.
+func (s LinkSort) resolve(searching bool) LinkSort {
+	if s.Field == "" || (s.Field == SortRelevance && !searching) {
+		if searching {
+			return LinkSort{Field: SortRelevance, Desc: true}
+		}
+		return DefaultLinkSort
+	}
+	return s
+}
+
+func (s LinkSort) orderBy(reverse bool) string {
+	dir := "ASC"
+	if s.Desc != reverse {
+		dir = "DESC"
+	}
+	return sortExprs[s.Field] + " " + dir + ", id " + dir
+}
+
+// LinkPage is one page of a cursor listing. NextCursor and PrevCursor are
+// empty at either end.
+type LinkPage struct {
+	Links      []Link
+	Total      int
+	NextCursor string
+	PrevCursor string
+}
+
+// linkCursor is the position of a row in a sorted listing: the raw sort
+// value and id of the last row on one side of the page. Before marks a
+// cursor that pages backwards from that row.
+type linkCursor struct {
+	Sort   LinkSortField `json:"s"`
+	Desc   bool          `json:"d,omitempty"`
+	Value  string        `json:"v"`
+	ID     int64         `json:"i"`
+	Before bool          `json:"b,omitempty"`
+}
+
+func (c linkCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeLinkCursor(s string, sort LinkSort) (linkCursor, error) {
+	var c linkCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort.Field || c.Desc != sort.Desc {
+		return c, ErrInvalidCursor
+	}
+	if _, err := c.arg(); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// arg is the cursor's sort value as the sort expression compares it.
+func (c linkCursor) arg() (any, error) {
+	switch c.Sort {
+	case SortClicks:
+		return strconv.ParseInt(c.Value, 10, 64)
+	case SortRelevance:
+		return strconv.ParseFloat(c.Value, 64)
+	}
+	return c.Value, nil
+}
+
+// sortValue selects the sort value a cursor records. Text values are cast
+// so the driver doesn't turn timestamps into time.Time and back.
+func sortValue(field LinkSortField) string {
+	if field == SortClicks || field == SortRelevance {
+		return sortExprs[field]
+	}
+	return "CAST(" + sortExprs[field] + " AS TEXT)"
+}
+
+func formatSortValue(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
+
+// LinkQuery selects a page of links. A page starts after Cursor, or ends
+// before it for a PrevCursor; without a cursor it starts Offset rows from
+// the top.
+type LinkQuery struct {
+	Filter LinkFilter
+	Sort   LinkSort
+	Cursor string
+	Offset int
+	Limit  int
+}
+
+// PageLinks returns a page of links. Cursor pages are found by seeking on
+// the sort index, so deep pages cost the same as the first and rows added
+// meanwhile don't shift them. Relevance is the exception: it's computed per
+// search, and ranks shift as links are added.
+func PageLinks(db *sql.DB, q LinkQuery) (*LinkPage, error) {
+	sort := q.Sort.resolve(q.Filter.Search != "")
+	if _, ok := sortExprs[sort.Field]; !ok {
+		return nil, fmt.Errorf("unknown sort %q", sort.Field)
+	}
+	var c linkCursor
+	if q.Cursor != "" {
+		var err error
+		if c, err = decodeLinkCursor(q.Cursor, sort); err != nil {
+			return nil, err
+		}
+	}
+	where, args := q.Filter.where()
+
+	page := &LinkPage{}
+	if err := db.QueryRow("SELECT COUNT(*) FROM links WHERE "+where, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("count links: %w", err)
+	}
+	if page.Total == 0 {
+		return page, nil
+	}
+
+	// Ranking joins in the search, which then does the filtering too
+	from := "links"
+	if sort.Field == SortRelevance {
+		from = "links JOIN (SELECT rowid AS search_id, " + searchRank + " AS search_rank FROM links_fts WHERE links_fts MATCH ?) ON search_id = id"
+		unsearched := q.Filter
+		unsearched.Search = ""
+		where, args = unsearched.where()
+		args = append([]any{searchQuery(q.Filter.Search)}, args...)
+	}
+
+	offset := q.Offset
+	if q.Cursor != "" {
+		offset = 0
+		// Rows after the cursor come later in sort order, which for a
+		// descending sort means smaller values.
+		op := ">"
+		if sort.Desc != c.Before {
+			op = "<"
+		}
+		v, _ := c.arg()
+		where += " AND (" + sortExprs[sort.Field] + ", id) " + op + " (?, ?)"
+		args = append(args, v, c.ID)
+	}
+
+	query := "SELECT " + linkColumns + ", " + sortValue(sort.Field) + " FROM " + from + " WHERE " + where +
+		" ORDER BY " + sort.orderBy(c.Before) + " LIMIT ? OFFSET ?"
+	args = append(args, q.Limit+1, offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("page links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []Link
+	var values []string
+	for rows.Next() {
+		var l Link
+		var v any
+		if err := scanLink(rows, &l, &v); err != nil {
+			return nil, fmt.Errorf("scan link: %w", err)
+		}
+		links = append(links, l)
+		values = append(values, formatSortValue(v))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("page links: %w", err)
+	}
+
+	more := len(links) > q.Limit
+	if more {
+		links, values = links[:q.Limit], values[:q.Limit]
+	}
+	if c.Before {
+		for i, j := 0, len(links)-1; i < j; i, j = i+1, j-1 {
+			links[i], links[j] = links[j], links[i]
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+	page.Links = links
+	if len(links) == 0 {
+		return page, nil
+	}
+
+	// Going forwards there's a previous page whenever we didn't start at
+	// the top; going backwards there's always a next one.
+	hasNext, hasPrev := more, q.Cursor != "" || offset > 0
+	if c.Before {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		last := len(links) - 1
+		page.NextCursor = linkCursor{Sort: sort.Field, Desc: sort.Desc, Value: values[last], ID: links[last].ID}.encode()
+	}
+	if hasPrev {
+		page.PrevCursor = linkCursor{Sort: sort.Field, Desc: sort.Desc, Value: values[0], ID: links[0].ID, Before: true}.encode()
+	}
+	return page, nil
+}
+
+// ListLinks returns a page of links matching f, in the default order, and
+// the number of links matching f.
+func ListLinks(db *sql.DB, limit, offset int, f LinkFilter) ([]Link, int, error) {
+	page, err := PageLinks(db, LinkQuery{Filter: f, Offset: offset, Limit: limit})
+	if err != nil {
+		return nil, 0, err
+	}
+	return page.Links, page.Total, nil
+}
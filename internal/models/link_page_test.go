@@ -0,0 +1,335 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestClickCounters_FollowClicks(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "c", Domain: "d.co", Destination: "https://example.com"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	if err := BatchInsertClicks(d, []Click{
+		{LinkID: l.ID, ClickedAt: newer},
+		{LinkID: l.ID, ClickedAt: older},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := reloadLink(t, d, l.ID)
+	if got.Clicks != 2 || got.LastClickedAt == nil || !got.LastClickedAt.Equal(newer) {
+		t.Errorf("clicks = %d, last clicked = %v; want 2, %v", got.Clicks, got.LastClickedAt, newer)
+	}
+
+	// Deleting the latest click falls back to the one before it
+	if _, err := d.Exec(`DELETE FROM clicks WHERE link_id = ? AND clicked_at = ?`, l.ID, newer); err != nil {
+		t.Fatal(err)
+	}
+	got = reloadLink(t, d, l.ID)
+	if got.Clicks != 1 || got.LastClickedAt == nil || !got.LastClickedAt.Equal(older) {
+		t.Errorf("after delete: clicks = %d, last clicked = %v; want 1, %v", got.Clicks, got.LastClickedAt, older)
+	}
+
+	if _, err := d.Exec(`DELETE FROM clicks WHERE link_id = ?`, l.ID); err != nil {
+		t.Fatal(err)
+	}
+	got = reloadLink(t, d, l.ID)
+	if got.Clicks != 0 || got.LastClickedAt != nil {
+		t.Errorf("after purge: clicks = %d, last clicked = %v", got.Clicks, got.LastClickedAt)
+	}
+}
+
+func reloadLink(t *testing.T, d *sql.DB, id int64) *Link {
+	t.Helper()
+	l := &Link{ID: id}
+	if err := GetLinkByID(d, l); err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func slugsOf(links []Link) string {
+	s := ""
+	for _, l := range links {
+		s += l.Slug + " "
+	}
+	return s
+}
+
+func TestPageLinks_SortsAndPages(t *testing.T) {
+	tests := []struct {
+		field, order string
+		want         []string
+	}{
+		{"", "", []string{"p4 p3 ", "p2 p1 ", "p0 "}},
+		{"created", "asc", []string{"p0 p1 ", "p2 p3 ", "p4 "}},
+		{"title", "", []string{"p1 p4 ", "p3 p0 ", "p2 "}},
+		{"clicks", "", []string{"p4 p3 ", "p2 p1 ", "p0 "}},
+		{"last_clicked", "asc", []string{"p0 p1 ", "p2 p3 ", "p4 "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field+" "+tt.order, func(t *testing.T) {
+			d := testDB(t)
+			base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			titles := []string{"delta", "Alpha", "echo", "charlie", "bravo"}
+			for i, title := range titles {
+				l := &Link{Slug: fmt.Sprintf("p%d", i), Domain: "d.co", Destination: "https://example.com", Title: title}
+				if err := CreateLink(d, l); err != nil {
+					t.Fatal(err)
+				}
+				if err := SetLinkCreatedAt(d, l.ID, base.AddDate(0, 0, i)); err != nil {
+					t.Fatal(err)
+				}
+				var clicks []Click
+				for j := range i {
+					clicks = append(clicks, Click{LinkID: l.ID, ClickedAt: base.AddDate(0, 1, i+j)})
+				}
+				if err := BatchInsertClicks(d, clicks); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sort, err := ParseLinkSort(tt.field, tt.order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pages []*LinkPage
+			cursor := ""
+			for i := range tt.want {
+				page, err := PageLinks(d, LinkQuery{Sort: sort, Cursor: cursor, Limit: 2})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := slugsOf(page.Links); got != tt.want[i] {
+					t.Fatalf("page %d = %q, want %q", i, got, tt.want[i])
+				}
+				if page.Total != 5 {
+					t.Errorf("total = %d, want 5", page.Total)
+				}
+				if (page.PrevCursor == "") != (i == 0) || (page.NextCursor == "") != (i == len(tt.want)-1) {
+					t.Errorf("page %d cursors: prev %q, next %q", i, page.PrevCursor, page.NextCursor)
+				}
+				pages = append(pages, page)
+				cursor = page.NextCursor
+			}
+
+			// Walking back from the last page retraces the same pages
+			for i := len(pages) - 1; i > 0; i-- {
+				page, err := PageLinks(d, LinkQuery{Sort: sort, Cursor: pages[i].PrevCursor, Limit: 2})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := slugsOf(page.Links); got != tt.want[i-1] {
+					t.Errorf("back to page %d = %q, want %q", i-1, got, tt.want[i-1])
+				}
+			}
+		})
+	}
+}
+
+func TestPageLinks_RejectsForeignCursor(t *testing.T) {
+	d := testDB(t)
+	for _, slug := range []string{"a", "b"} {
+		if err := CreateLink(d, &Link{Slug: slug, Domain: "d.co", Destination: "https://example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page, err := PageLinks(d, LinkQuery{Sort: DefaultLinkSort, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byTitle, _ := ParseLinkSort("title", "")
+	for _, cursor := range []string{"not a cursor", page.NextCursor} {
+		if _, err := PageLinks(d, LinkQuery{Sort: byTitle, Cursor: cursor, Limit: 1}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: err = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestParseLinkSort_RejectsUnknown(t *testing.T) {
+	if _, err := ParseLinkSort("slug", ""); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+	if _, err := ParseLinkSort("title", "up"); err == nil {
+		t.Error("expected an error for an unknown order")
+	}
+}
+
+func TestPageLinks_Filters(t *testing.T) {
+	d := testDB(t)
+	jan := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	links := []*Link{
+		{Slug: "old", Domain: "a.co", Destination: "https://example.com", Tags: "docs"},
+		{Slug: "new", Domain: "a.co", Destination: "https://example.com"},
+		{Slug: "other", Domain: "b.co", Destination: "https://example.com", Tags: "docs"},
+		{Slug: "gone", Domain: "a.co", Destination: "https://example.com"},
+	}
+	for i, l := range links {
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+		created := mar
+		if i == 0 {
+			created = jan
+		}
+		if err := SetLinkCreatedAt(d, l.ID, created); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SoftDeleteLink(d, links[3].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := BatchInsertClicks(d, []Click{{LinkID: links[0].ID, ClickedAt: mar}}); err != nil {
+		t.Fatal(err)
+	}
+
+	active, inactive := true, false
+	zero, one := 0, 1
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		filter LinkFilter
+		want   string
+	}{
+		{"domain", LinkFilter{Domain: "a.co"}, "gone new old "},
+		{"tag", LinkFilter{Tags: []string{"docs"}}, "other old "},
+		{"active", LinkFilter{Domain: "a.co", Active: &active}, "new old "},
+		{"deleted", LinkFilter{Active: &inactive}, "gone "},
+		{"created after", LinkFilter{CreatedAfter: &feb}, "gone other new "},
+		{"created before", LinkFilter{CreatedBefore: &feb}, "old "},
+		{"last clicked after", LinkFilter{LastClickedAfter: &feb}, "old "},
+		{"last clicked before", LinkFilter{LastClickedBefore: &feb}, ""},
+		{"not tagged", LinkFilter{ExcludeTags: []string{"docs"}}, "gone new "},
+		{"not on domain", LinkFilter{ExcludeDomains: []string{"a.co"}}, "other "},
+		{"clicked at least once", LinkFilter{MinClicks: &one}, "old "},
+		{"never clicked", LinkFilter{MaxClicks: &zero, Domain: "a.co"}, "gone new "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := PageLinks(d, LinkQuery{Filter: tt.filter, Sort: DefaultLinkSort, Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := slugsOf(page.Links); got != tt.want {
+				t.Errorf("links = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"launch", `"launch"*`},
+		{"  spring   sale ", `"spring"* "sale"*`},
+		{`"spring sale" 2024`, `"spring sale" "2024"*`},
+		{`"spring sa`, `"spring sa"*`},
+		{`example.com/docs`, `"example.com/docs"*`},
+		{`a"b`, `"a"* "b"*`},
+		{`NEAR(x) OR -y`, `"NEAR(x)"* "OR"* "-y"*`},
+		{"- ! ?", ""},
+	}
+	for _, tt := range tests {
+		if got := searchQuery(tt.in); got != tt.want {
+			t.Errorf("searchQuery(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPageLinks_SearchRanksAndStaysInSync(t *testing.T) {
+	d := testDB(t)
+	links := []*Link{
+		{Slug: "notes", Domain: "d.co", Destination: "https://example.com/a", Notes: "mentions the launch once"},
+		{Slug: "launch", Domain: "d.co", Destination: "https://example.com/b", Title: "Launch day"},
+		{Slug: "blog", Domain: "d.co", Destination: "https://example.com/launch-plan"},
+		{Slug: "other", Domain: "d.co", Destination: "https://other.com"},
+	}
+	for _, l := range links {
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(s string) string {
+		t.Helper()
+		page, err := PageLinks(d, LinkQuery{Filter: LinkFilter{Search: s}, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slugsOf(page.Links)
+	}
+
+	// Slug and title matches outrank destination and notes matches
+	if got := search("laun"); got != "launch blog notes " {
+		t.Errorf("search laun = %q", got)
+	}
+	if got := search(`"launch day"`); got != "launch " {
+		t.Errorf("phrase search = %q", got)
+	}
+	if got := search(`"day launch"`); got != "" {
+		t.Errorf("phrase in the wrong order = %q", got)
+	}
+	if got := search("!!"); got != "" {
+		t.Errorf("search with no words = %q", got)
+	}
+
+	// Edits reach the index
+	links[3].Title = "Launch retro"
+	if err := UpdateLink(d, links[3]); err != nil {
+		t.Fatal(err)
+	}
+	links[1].Title = ""
+	links[1].Slug = "go"
+	if err := UpdateLink(d, links[1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := search("launch"); got != "other blog notes " {
+		t.Errorf("search after edits = %q", got)
+	}
+
+	// Other sorts still apply to search results
+	page, err := PageLinks(d, LinkQuery{Filter: LinkFilter{Search: "launch"}, Sort: LinkSort{Field: SortTitle}, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := slugsOf(page.Links); got != "notes blog other " {
+		t.Errorf("search sorted by title = %q", got)
+	}
+}
+
+func TestPageLinks_RelevanceCursor(t *testing.T) {
+	d := testDB(t)
+	for i := range 5 {
+		l := &Link{Slug: fmt.Sprintf("s%d", i), Domain: "d.co", Destination: "https://example.com", Title: "docs"}
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := map[string]bool{}
+	q := LinkQuery{Filter: LinkFilter{Search: "docs"}, Limit: 2}
+	for range 3 {
+		page, err := PageLinks(d, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range page.Links {
+			if seen[l.Slug] {
+				t.Errorf("%s listed twice", l.Slug)
+			}
+			seen[l.Slug] = true
+		}
+		q.Cursor = page.NextCursor
+	}
+	if len(seen) != 5 || q.Cursor != "" {
+		t.Errorf("paged through %d links, final cursor %q", len(seen), q.Cursor)
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+// searchRank scores a links_fts match, higher being better. bm25 ranks
+// better matches lower; the weights favour slug and title matches over the
+// same words in a destination or notes. Columns are in links_fts order:
+// slug, title, destination, tags, notes.
+const searchRank = "-bm25(links_fts, 10.0, 5.0, 2.0, 3.0, 1.0)"
+
+// searchQuery turns search box input into an FTS5 query. Double-quoted
+// text matches as an exact phrase and every other word as a prefix, so
+// "laun" finds "launch"; all of them must match. Words are quoted before
+// they reach FTS5, so its operators are never interpreted. It returns ""
+// when s has nothing to search for.
+func searchQuery(s string) string {
+	var terms []string
+	add := func(text string, prefix bool) {
+		if !strings.ContainsFunc(text, isWordChar) {
+			return
+		}
+		term := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+
+	for s != "" {
+		if rest, ok := strings.CutPrefix(s, `"`); ok {
+			phrase, after, closed := strings.Cut(rest, `"`)
+			if !closed {
+				// An unclosed quote is still being typed; treat it as
+				// a phrase whose last word is incomplete.
+				add(phrase, true)
+				break
+			}
+			add(phrase, false)
+			s = after
+			continue
+		}
+		end := strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end < 0 {
+			end = len(s)
+		}
+		add(s[:end], true)
+		s = strings.TrimLeftFunc(s[end:], unicode.IsSpace)
+	}
+	return strings.Join(terms, " ")
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
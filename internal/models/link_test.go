@@ -94,8 +94,13 @@ func TestGetLinkBySlugAndDomain_Found(t *testing.T) {
 	if got.Title != "Test" {
 		t.Errorf("Title = %q, want %q", got.Title, "Test")
 	}
-	if got.ShortURL != "https://d.co/found" {
-		t.Errorf("ShortURL = %q, want %q", got.ShortURL, "https://d.co/found")
+}
+
+func TestFillShortURL(t *testing.T) {
+	l := &Link{Slug: "café", Domain: "d.co"}
+	l.FillShortURL(func(domain string) string { return "http://" + domain + ":8080" })
+	if l.ShortURL != "http://d.co:8080/caf%C3%A9" {
+		t.Errorf("ShortURL = %q, want the base and an escaped slug", l.ShortURL)
 	}
 }
 
@@ -156,6 +161,31 @@ func TestSlugExists_ReturnsFalseForNonexistent(t *testing.T) {
 	}
 }
 
+func TestTakenSlugs(t *testing.T) {
+	d := testDB(t)
+	if err := CreateLink(d, &Link{Slug: "Taken", Domain: "d.co", Destination: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	refs := []SlugRef{
+		{Domain: "d.co", Slug: "Taken"},
+		{Domain: "d.co", Slug: "taken"},
+		{Domain: "d.co", Slug: "taken", Fold: true},
+		{Domain: "e.co", Slug: "Taken"},
+		{Domain: "d.co", Slug: "free"},
+	}
+	taken, err := TakenSlugs(d, refs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []bool{true, false, true, false, false}
+	for i, ref := range refs {
+		if taken[ref] != want[i] {
+			t.Errorf("taken[%+v] = %v, want %v", ref, taken[ref], want[i])
+		}
+	}
+}
+
 func TestListLinks_PaginationAndTotal(t *testing.T) {
 	d := testDB(t)
 	for i := range 3 {
@@ -165,7 +195,7 @@ func TestListLinks_PaginationAndTotal(t *testing.T) {
 		}
 	}
 
-	links, total, err := ListLinks(d, 2, 0, "")
+	links, total, err := ListLinks(d, 2, 0, LinkFilter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -177,7 +207,7 @@ func TestListLinks_PaginationAndTotal(t *testing.T) {
 	}
 
 	// Offset past all results
-	links2, total2, err := ListLinks(d, 2, 3, "")
+	links2, total2, err := ListLinks(d, 2, 3, LinkFilter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -204,7 +234,7 @@ func TestListLinks_Search(t *testing.T) {
 		}
 	}
 
-	results, total, err := ListLinks(d, 100, 0, "findme")
+	results, total, err := ListLinks(d, 100, 0, LinkFilter{Search: "findme"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -236,6 +266,49 @@ func TestUpdateLink_Success(t *testing.T) {
 	}
 }
 
+func TestUpdateLinkIfUnchanged(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "cas", Domain: "d.co", Destination: "https://v1.com"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	stale := l.Version()
+
+	// Saves within the same second still get a new version
+	l.Destination = "https://v2.com"
+	if err := UpdateLinkIfUnchanged(d, l, stale); err != nil {
+		t.Fatal(err)
+	}
+	if l.Version() == stale {
+		t.Fatalf("Version() = %q after saving, want it to change", l.Version())
+	}
+
+	other := &Link{ID: l.ID}
+	if err := GetLinkByID(d, other); err != nil {
+		t.Fatal(err)
+	}
+	other.Destination = "https://v3.com"
+	if err := UpdateLinkIfUnchanged(d, other, stale); err != ErrLinkChanged {
+		t.Fatalf("err = %v, want ErrLinkChanged", err)
+	}
+	if err := SoftDeleteLinkIfUnchanged(d, l.ID, stale); err != ErrLinkChanged {
+		t.Fatalf("soft delete err = %v, want ErrLinkChanged", err)
+	}
+	if err := GetLinkByID(d, other); err != nil {
+		t.Fatal(err)
+	}
+	if other.Destination != "https://v2.com" || !other.IsActive {
+		t.Errorf("link = %q active=%v, want the v2 save untouched", other.Destination, other.IsActive)
+	}
+
+	if err := SoftDeleteLinkIfUnchanged(d, l.ID, l.Version()); err != nil {
+		t.Fatal(err)
+	}
+	if err := SoftDeleteLinkIfUnchanged(d, 99999, l.Version()); err != sql.ErrNoRows {
+		t.Errorf("missing link err = %v, want sql.ErrNoRows", err)
+	}
+}
+
 func TestUpdateLink_UniqueConstraintViolation(t *testing.T) {
 	d := testDB(t)
 	l1 := &Link{Slug: "one", Domain: "d.co", Destination: "https://a.com"}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// TagCount is a tag with the number of links carrying it and the clicks
+// those links received.
+type TagCount struct {
+	Name   string `json:"name"`
+	Links  int    `json:"links"`
+	Clicks int    `json:"clicks"`
+}
+
+// setLinkTags replaces a link's tags with names, which must already be
+// normalized, and drops tags no link uses any more.
+func setLinkTags(tx *sql.Tx, linkID int64, names []string) error {
+	if _, err := tx.Exec(`DELETE FROM link_tags WHERE link_id = ?`, linkID); err != nil {
+		return fmt.Errorf("clear link tags: %w", err)
+	}
+	for _, name := range names {
+		if _, err := tx.Exec(`INSERT INTO tags (name) VALUES (?) ON CONFLICT(name) DO NOTHING`, name); err != nil {
+			return fmt.Errorf("insert tag: %w", err)
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO link_tags (link_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`, linkID, name); err != nil {
+			return fmt.Errorf("tag link: %w", err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM link_tags)`); err != nil {
+		return fmt.Errorf("prune tags: %w", err)
+	}
+	return nil
+}
+
+// ListTags returns every tag in use with link and click counts, most used
+// first.
+func ListTags(db *sql.DB) ([]TagCount, error) {
+	rows, err := db.Query(
+		`SELECT t.name, COUNT(*),
+			(SELECT COUNT(*) FROM clicks c JOIN link_tags x ON x.link_id = c.link_id WHERE x.tag_id = t.id)
+		FROM tags t JOIN link_tags lt ON lt.tag_id = t.id
+		GROUP BY t.id ORDER BY COUNT(*) DESC, t.name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	defer rows.Close()
+
+	var results []TagCount
+	for rows.Next() {
+		var tc TagCount
+		if err := rows.Scan(&tc.Name, &tc.Links, &tc.Clicks); err != nil {
+			return nil, fmt.Errorf("scan tag: %w", err)
+		}
+		results = append(results, tc)
+	}
+	return results, rows.Err()
+}
+
+// GetTag returns the counts for a single tag, or sql.ErrNoRows if no link
+// carries it.
+func GetTag(db *sql.DB, name string) (*TagCount, error) {
+	tc := &TagCount{Name: name}
+	err := db.QueryRow(
+		`SELECT COUNT(*),
+			(SELECT COUNT(*) FROM clicks c JOIN link_tags x ON x.link_id = c.link_id WHERE x.tag_id = t.id)
+		FROM tags t JOIN link_tags lt ON lt.tag_id = t.id
+		WHERE t.name = ? GROUP BY t.id`,
+		name,
+	).Scan(&tc.Links, &tc.Clicks)
+	if err != nil {
+		return nil, err
+	}
+	return tc, nil
+}
+
+// taggedLinks is the subquery selecting the IDs of links carrying a tag.
+const taggedLinks = `SELECT lt.link_id FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE t.name = ?`
+
+// ClicksTodayForTag returns today's clicks across every link tagged name.
+func ClicksTodayForTag(db *sql.DB, name string) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM clicks WHERE link_id IN (`+taggedLinks+`) AND date(clicked_at) = date('now')`, name).Scan(&count)
+	return count, err
+}
+
+// TopLinksForTag returns the links tagged name with the most clicks.
+func TopLinksForTag(db *sql.DB, name string, limit int) ([]LinkWithClicks, error) {
+	rows, err := db.Query(
+		`SELECT `+qualifiedLinkColumns("l")+`, COUNT(c.id) AS counted
+		FROM links l
+		LEFT JOIN clicks c ON c.link_id = l.id
+		WHERE l.id IN (`+taggedLinks+`)
+		GROUP BY l.id
+		ORDER BY counted DESC, l.id DESC
+		LIMIT ?`, name, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("top tag links: %w", err)
+	}
+	defer rows.Close()
+
+	var results []LinkWithClicks
+	for rows.Next() {
+		var lc LinkWithClicks
+		if err := scanLink(rows, &lc.Link, &lc.ClickCount); err != nil {
+			return nil, fmt.Errorf("scan link with clicks: %w", err)
+		}
+		results = append(results, lc)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestCreateLink_NormalizesTags(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com", Tags: " AI, mail ,ai,"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	if l.Tags != "ai,mail" {
+		t.Errorf("Tags = %q, want %q", l.Tags, "ai,mail")
+	}
+
+	list, err := ListTags(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("tags = %+v, want 2", list)
+	}
+}
+
+func TestListLinks_TagFilterIsExact(t *testing.T) {
+	d := testDB(t)
+	for _, l := range []*Link{
+		{Slug: "a", Domain: "d.co", Destination: "https://example.com", Tags: "ai"},
+		{Slug: "b", Domain: "d.co", Destination: "https://example.com", Tags: "mail"},
+		{Slug: "c", Domain: "d.co", Destination: "https://example.com", Tags: "ai,docs"},
+	} {
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links, total, err := ListLinks(d, 10, 0, LinkFilter{Tags: []string{"AI"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(links) != 2 {
+		t.Errorf("tag=ai: total = %d, len = %d, want 2", total, len(links))
+	}
+	for _, l := range links {
+		if l.Slug == "b" {
+			t.Error("tag=ai matched a link tagged mail")
+		}
+	}
+
+	_, total, err = ListLinks(d, 10, 0, LinkFilter{Tags: []string{"ai", "docs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Errorf("tag=ai&tag=docs: total = %d, want 1", total)
+	}
+}
+
+func TestUpdateLink_ReplacesTagsAndPrunes(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com", Tags: "old"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	l.Tags = "new"
+	if err := UpdateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetTag(d, "old"); err != sql.ErrNoRows {
+		t.Errorf("GetTag(old) err = %v, want sql.ErrNoRows", err)
+	}
+	tag, err := GetTag(d, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Links != 1 {
+		t.Errorf("new links = %d, want 1", tag.Links)
+	}
+}
+
+func TestListTags_Counts(t *testing.T) {
+	d := testDB(t)
+	a := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com", Tags: "docs,blog"}
+	b := &Link{Slug: "b", Domain: "d.co", Destination: "https://example.com", Tags: "docs"}
+	for _, l := range []*Link{a, b} {
+		if err := CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	insertTestClicks(t, d, []Click{
+		{LinkID: a.ID, ClickedAt: now, Country: "US"},
+		{LinkID: b.ID, ClickedAt: now, Country: "US"},
+		{LinkID: b.ID, ClickedAt: now, Country: "DE"},
+	})
+
+	list, err := ListTags(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TagCount{{Name: "docs", Links: 2, Clicks: 3}, {Name: "blog", Links: 1, Clicks: 1}}
+	if len(list) != len(want) {
+		t.Fatalf("tags = %+v, want %+v", list, want)
+	}
+	for i := range want {
+		if list[i] != want[i] {
+			t.Errorf("tags[%d] = %+v, want %+v", i, list[i], want[i])
+		}
+	}
+
+	top, err := TopLinksForTag(d, "docs", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].Link.ID != b.ID || top[0].ClickCount != 2 {
+		t.Errorf("top links = %+v", top)
+	}
+	countries, err := TopValues(d, TagClicks("blog"), DimensionCountry, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(countries) != 1 || countries[0].Value != "US" {
+		t.Errorf("countries = %+v", countries)
+	}
+}
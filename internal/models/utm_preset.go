@@ -0,0 +1,115 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UTMPreset is a named set of UTM parameters that can be applied to links.
+type UTMPreset struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	UTMSource   string    `json:"utm_source"`
+	UTMMedium   string    `json:"utm_medium"`
+	UTMCampaign string    `json:"utm_campaign"`
+	UTMTerm     string    `json:"utm_term"`
+	UTMContent  string    `json:"utm_content"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UTM returns the preset's parameters keyed by name.
+func (p *UTMPreset) UTM() map[string]string {
+	return map[string]string{
+		"utm_source":   p.UTMSource,
+		"utm_medium":   p.UTMMedium,
+		"utm_campaign": p.UTMCampaign,
+		"utm_term":     p.UTMTerm,
+		"utm_content":  p.UTMContent,
+	}
+}
+
+// SetUTM sets the preset's parameters from a map keyed by name.
+func (p *UTMPreset) SetUTM(values map[string]string) {
+	p.UTMSource = values["utm_source"]
+	p.UTMMedium = values["utm_medium"]
+	p.UTMCampaign = values["utm_campaign"]
+	p.UTMTerm = values["utm_term"]
+	p.UTMContent = values["utm_content"]
+}
+
+// Validate trims the name in place and checks the preset sets something.
+func (p *UTMPreset) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	for _, v := range p.UTM() {
+		if v != "" {
+			return nil
+		}
+	}
+	return errors.New("at least one UTM parameter is required")
+}
+
+const utmPresetColumns = `id, name, utm_source, utm_medium, utm_campaign, utm_term, utm_content, created_at`
+
+func scanUTMPreset(scan func(...any) error, p *UTMPreset) error {
+	return scan(&p.ID, &p.Name, &p.UTMSource, &p.UTMMedium, &p.UTMCampaign, &p.UTMTerm, &p.UTMContent, &p.CreatedAt)
+}
+
+func CreateUTMPreset(db *sql.DB, p *UTMPreset) error {
+	res, err := db.Exec(
+		`INSERT INTO utm_presets (name, utm_source, utm_medium, utm_campaign, utm_term, utm_content) VALUES (?, ?, ?, ?, ?, ?)`,
+		p.Name, p.UTMSource, p.UTMMedium, p.UTMCampaign, p.UTMTerm, p.UTMContent,
+	)
+	if err != nil {
+		return fmt.Errorf("insert utm preset: %w", err)
+	}
+	p.ID, _ = res.LastInsertId()
+	return scanUTMPreset(db.QueryRow(`SELECT `+utmPresetColumns+` FROM utm_presets WHERE id = ?`, p.ID).Scan, p)
+}
+
+// GetUTMPresetByName looks a preset up by name, ignoring case. It returns
+// sql.ErrNoRows when there is none.
+func GetUTMPresetByName(db Querier, name string) (*UTMPreset, error) {
+	p := &UTMPreset{}
+	if err := scanUTMPreset(db.QueryRow(`SELECT `+utmPresetColumns+` FROM utm_presets WHERE name = ?`, strings.TrimSpace(name)).Scan, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ListUTMPresets returns every preset ordered by name.
+func ListUTMPresets(db *sql.DB) ([]UTMPreset, error) {
+	rows, err := db.Query(`SELECT ` + utmPresetColumns + ` FROM utm_presets ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list utm presets: %w", err)
+	}
+	defer rows.Close()
+
+	var results []UTMPreset
+	for rows.Next() {
+		var p UTMPreset
+		if err := scanUTMPreset(rows.Scan, &p); err != nil {
+			return nil, fmt.Errorf("scan utm preset: %w", err)
+		}
+		results = append(results, p)
+	}
+	return results, rows.Err()
+}
+
+// DeleteUTMPreset removes a preset. Links it was applied to keep their
+// parameters.
+func DeleteUTMPreset(db *sql.DB, id int64) error {
+	res, err := db.Exec(`DELETE FROM utm_presets WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete utm preset: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
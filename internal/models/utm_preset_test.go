@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestUTMPreset_Validate(t *testing.T) {
+	if err := (&UTMPreset{Name: "Newsletter"}).Validate(); err == nil {
+		t.Error("preset without parameters should be invalid")
+	}
+	if err := (&UTMPreset{Name: " ", UTMSource: "x"}).Validate(); err == nil {
+		t.Error("preset without a name should be invalid")
+	}
+	p := &UTMPreset{Name: " Newsletter ", UTMSource: "newsletter"}
+	if err := p.Validate(); err != nil || p.Name != "Newsletter" {
+		t.Errorf("Validate = %v, name %q", err, p.Name)
+	}
+}
+
+func TestUTMPreset_LookupIgnoresCase(t *testing.T) {
+	d := testDB(t)
+	p := &UTMPreset{Name: "Newsletter", UTMSource: "newsletter", UTMMedium: "email"}
+	if err := CreateUTMPreset(d, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateUTMPreset(d, &UTMPreset{Name: "NEWSLETTER", UTMSource: "x"}); err == nil {
+		t.Error("duplicate name differing by case created, want constraint error")
+	}
+
+	got, err := GetUTMPresetByName(d, "newsletter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != p.ID || got.UTMMedium != "email" {
+		t.Errorf("preset = %+v", got)
+	}
+
+	if err := DeleteUTMPreset(d, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetUTMPresetByName(d, "Newsletter"); err != sql.ErrNoRows {
+		t.Errorf("lookup after delete: err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestLink_UTMFieldsBuildDestination(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com/?utm_source=old&ref=1", UTMSource: "x", UTMTerm: "shoes"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://example.com/?ref=1&utm_source=x&utm_term=shoes"; l.Destination != want {
+		t.Errorf("Destination = %q, want %q", l.Destination, want)
+	}
+
+	// The fields are stored, and a destination given whole brings its own
+	l.SetDestination("https://example.com/new?utm_medium=email")
+	if err := UpdateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	got := &Link{ID: l.ID}
+	if err := GetLinkByID(d, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.UTMMedium != "email" || got.UTMSource != "" || got.Destination != "https://example.com/new?utm_medium=email" {
+		t.Errorf("after update = %+v", got)
+	}
+}
+
+func TestLink_UTMFieldsTakenFromDestination(t *testing.T) {
+	d := testDB(t)
+	l := &Link{Slug: "a", Domain: "d.co", Destination: "https://example.com/?utm_source=news&ref=1"}
+	if err := CreateLink(d, l); err != nil {
+		t.Fatal(err)
+	}
+	got := &Link{ID: l.ID}
+	if err := GetLinkByID(d, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Destination != "https://example.com/?utm_source=news&ref=1" || got.UTMSource != "news" {
+		t.Errorf("link = %+v, want the destination kept and its source stored", got)
+	}
+}
@@ -0,0 +1,57 @@
+// Package qr renders short URLs as QR code PNGs for the admin UI and
+// dublyctl.
+package qr
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+
+	qrcode "github.com/yeqown/go-qrcode/v2"
+	"github.com/yeqown/go-qrcode/writer/standard"
+)
+
+var hexColorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Options styles a QR code. The background is always transparent.
+type Options struct {
+	Circle bool   // round modules instead of square ones
+	Color  string // foreground as #rrggbb; black when empty or invalid
+}
+
+// ValidColor reports whether s is a #rrggbb color.
+func ValidColor(s string) bool {
+	return hexColorRe.MatchString(s)
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// PNG encodes content as a QR code image. Pass short URLs as
+// percent-encoded with a punycode host, which every scanner can open,
+// unlike a raw Unicode URL.
+func PNG(content string, opts Options) ([]byte, error) {
+	imgOpts := []standard.ImageOption{
+		standard.WithBuiltinImageEncoder(standard.PNG_FORMAT),
+		standard.WithQRWidth(10),
+		standard.WithBorderWidth(20),
+		standard.WithBgTransparent(),
+	}
+	if opts.Circle {
+		imgOpts = append(imgOpts, standard.WithCircleShape())
+	}
+	if ValidColor(opts.Color) {
+		imgOpts = append(imgOpts, standard.WithFgColorRGBHex(opts.Color))
+	}
+
+	qrc, err := qrcode.New(content)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := qrc.Save(standard.NewWithWriter(nopCloser{&buf}, imgOpts...)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,26 @@
+package qr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPNG(t *testing.T) {
+	for _, opts := range []Options{{}, {Circle: true, Color: "#ff0000"}, {Color: "red"}} {
+		png, err := PNG("https://short.io/launch", opts)
+		if err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		if !bytes.HasPrefix(png, []byte("\x89PNG\r\n\x1a\n")) {
+			t.Errorf("%+v: not a PNG", opts)
+		}
+	}
+}
+
+func TestValidColor(t *testing.T) {
+	for s, want := range map[string]bool{"#00ff7F": true, "00ff7f": false, "#0f0": false, "": false} {
+		if got := ValidColor(s); got != want {
+			t.Errorf("ValidColor(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
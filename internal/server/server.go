@@ -0,0 +1,62 @@
+// Package server assembles the HTTP routes the handlers package serves, so
+// the server binary and the tests of every API client share one router.
+package server
+
+import (
+	"database/sql"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/scmmishra/dubly/internal/cache"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/handlers"
+)
+
+// APIRouter serves the JSON API, to be mounted at /api. Every route but
+// the OpenAPI document needs the API key; openapi.json describes them all,
+// and the tests hold the two in step.
+func APIRouter(database *sql.DB, cfg *config.Config, linkCache *cache.LinkCache) chi.Router {
+	linkHandler := &handlers.LinkHandler{DB: database, Cfg: cfg, Cache: linkCache}
+	domainHandler := &handlers.DomainHandler{DB: database, Cfg: cfg, Cache: linkCache}
+	tagHandler := &handlers.TagHandler{DB: database}
+	utmPresetHandler := &handlers.UTMPresetHandler{DB: database}
+	transferHandler := &handlers.TransferHandler{DB: database, Cfg: cfg, Cache: linkCache}
+	campaignHandler := &handlers.CampaignHandler{DB: database, Cfg: cfg, Cache: linkCache}
+	analyticsHandler := &handlers.AnalyticsHandler{DB: database}
+
+	r := chi.NewRouter()
+	r.Get("/openapi.json", handlers.OpenAPI)
+	r.Group(func(r chi.Router) {
+		r.Use(handlers.AuthMiddleware(cfg.Password))
+		r.With(handlers.Idempotent(database, cfg.IdempotencyWindow)).Post("/links", linkHandler.Create)
+		r.Put("/links/by-destination", linkHandler.PutByDestination)
+		r.Post("/links/bulk", linkHandler.BulkCreate)
+		r.Patch("/links/bulk", linkHandler.BulkUpdate)
+		r.Get("/links", linkHandler.List)
+		r.Get("/links/{id}", linkHandler.Get)
+		r.Patch("/links/{id}", linkHandler.Update)
+		r.Delete("/links/{id}", linkHandler.Delete)
+		r.Get("/links/{id}/stats", linkHandler.Stats)
+		r.Get("/links/{id}/aliases", linkHandler.ListAliases)
+		r.Post("/links/{id}/aliases", linkHandler.CreateAlias)
+		r.Delete("/links/{id}/aliases/{aliasID}", linkHandler.DeleteAlias)
+		r.Get("/slugs/check", linkHandler.CheckSlug)
+		r.Get("/export", transferHandler.Export)
+		r.Post("/import", transferHandler.Import)
+		r.Get("/tags", tagHandler.List)
+		r.Get("/utm-presets", utmPresetHandler.List)
+		r.Post("/utm-presets", utmPresetHandler.Create)
+		r.Delete("/utm-presets/{id}", utmPresetHandler.Delete)
+		r.Get("/campaigns", campaignHandler.List)
+		r.Post("/campaigns", campaignHandler.Create)
+		r.Get("/campaigns/{id}", campaignHandler.Get)
+		r.Patch("/campaigns/{id}", campaignHandler.Update)
+		r.Delete("/campaigns/{id}", campaignHandler.Delete)
+		r.Post("/campaigns/{id}/links", campaignHandler.AttachLinks)
+		r.Delete("/campaigns/{id}/links/{linkID}", campaignHandler.DetachLink)
+		r.Get("/domains", domainHandler.List)
+		r.Post("/domains/move", domainHandler.Move)
+		r.Get("/analytics", analyticsHandler.Query)
+	})
+	return r
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/scmmishra/dubly/internal/cache"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/db"
+)
+
+type openAPIParam struct {
+	Name string `json:"name"`
+	In   string `json:"in"`
+	Ref  string `json:"$ref"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Parameters  []openAPIParam             `json:"parameters"`
+	Responses   map[string]json.RawMessage `json:"responses"`
+}
+
+// testAPI returns the API router on an empty database, and the OpenAPI
+// document it serves.
+func testAPI(t *testing.T) (chi.Router, []byte) {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	linkCache, err := cache.New(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Password: "secret", Domains: []string{"short.io"}, IdempotencyWindow: time.Hour}
+	r := APIRouter(database, cfg, linkCache)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d", rr.Code)
+	}
+	return r, rr.Body.Bytes()
+}
+
+func TestOpenAPI_MatchesRouter(t *testing.T) {
+	r, spec := testAPI(t)
+	var doc struct {
+		Paths map[string]map[string]openAPIOperation `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	documented := map[string]bool{}
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	routed := map[string]bool{}
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routed[method+" /api"+route] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, route := range sortedKeys(routed) {
+		if !documented[route] {
+			t.Errorf("%s is routed but missing from openapi.json", route)
+		}
+	}
+	for _, route := range sortedKeys(documented) {
+		if !routed[route] {
+			t.Errorf("%s is in openapi.json but not routed", route)
+		}
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+func TestOpenAPI_Operations(t *testing.T) {
+	_, spec := testAPI(t)
+	var doc struct {
+		Paths map[string]map[string]openAPIOperation `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := map[string]string{}
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			where := strings.ToUpper(method) + " " + path
+			if op.OperationID == "" {
+				t.Errorf("%s has no operationId", where)
+			} else if other, ok := ids[op.OperationID]; ok {
+				t.Errorf("%s reuses operationId %q of %s", where, op.OperationID, other)
+			}
+			ids[op.OperationID] = where
+			if len(op.Responses) == 0 {
+				t.Errorf("%s documents no responses", where)
+			}
+
+			declared := map[string]bool{}
+			for _, p := range op.Parameters {
+				if p.In == "path" {
+					declared[p.Name] = true
+				}
+			}
+			for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+				if !declared[m[1]] {
+					t.Errorf("%s doesn't declare path parameter %s", where, m[1])
+				}
+				delete(declared, m[1])
+			}
+			for name := range declared {
+				t.Errorf("%s declares path parameter %s that isn't in the path", where, name)
+			}
+		}
+	}
+}
+
+var schemaRef = regexp.MustCompile(`"\$ref":\s*"#/([^"]+)"`)
+
+func TestOpenAPI_RefsResolve(t *testing.T) {
+	_, spec := testAPI(t)
+	var doc map[string]any
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range schemaRef.FindAllStringSubmatch(string(spec), -1) {
+		var node any = doc
+		for _, part := range strings.Split(m[1], "/") {
+			obj, ok := node.(map[string]any)
+			if !ok {
+				node = nil
+				break
+			}
+			node = obj[part]
+		}
+		if node == nil {
+			t.Errorf("$ref #/%s doesn't resolve", m[1])
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,56 @@
+package slug
+
+import "strings"
+
+// defaultBlocked are substrings never allowed in generated slugs. Random
+// slugs occasionally spell words nobody wants on a printed flyer.
+var defaultBlocked = []string{
+	"anal", "anus", "arse", "ass", "bitch", "boob", "butt", "cock", "crap",
+	"cum", "cunt", "dick", "dildo", "fag", "fuck", "jizz", "nazi", "nigg",
+	"penis", "piss", "porn", "pussy", "rape", "sex", "shit", "slut", "tit",
+	"twat", "vagina", "wank", "whore",
+}
+
+// lookalikes maps digits and symbols commonly used to dodge filters back to
+// the letters they stand in for.
+var lookalikes = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b", "@", "a", "$", "s",
+)
+
+// Filter rejects slugs containing blocked substrings, ignoring case and
+// separators and treating lookalike digits as letters.
+type Filter struct {
+	blocked []string
+}
+
+// NewFilter returns a filter with the built-in list plus extra.
+func NewFilter(extra []string) *Filter {
+	f := &Filter{blocked: append([]string(nil), defaultBlocked...)}
+	for _, s := range extra {
+		if s = normalizeForFilter(s); s != "" {
+			f.blocked = append(f.blocked, s)
+		}
+	}
+	return f
+}
+
+// Blocked reports whether s contains a blocked substring. A nil filter
+// blocks nothing.
+func (f *Filter) Blocked(s string) bool {
+	if f == nil {
+		return false
+	}
+	plain := strings.ToLower(s)
+	plain = strings.NewReplacer("-", "", "_", "", ".", "").Replace(plain)
+	subbed := lookalikes.Replace(plain)
+	for _, b := range f.blocked {
+		if strings.Contains(plain, b) || strings.Contains(subbed, b) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeForFilter(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
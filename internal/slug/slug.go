@@ -2,22 +2,298 @@ package slug
 
 import (
 	"crypto/rand"
+	"errors"
+	"fmt"
 	"math/big"
+	"strings"
+	"unicode"
 )
 
-const charset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+const (
+	// Base62 is the default slug alphabet.
+	Base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// Base36 is used for domains that match slugs case-insensitively.
+	Base36 = "0123456789abcdefghijklmnopqrstuvwxyz"
+	// Unambiguous drops characters that are easily confused when read aloud
+	// or printed: 0/O/o, 1/l/I.
+	Unambiguous = "23456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz"
+	// UnambiguousLower is Unambiguous for case-insensitive domains.
+	UnambiguousLower = "23456789abcdefghijkmnpqrstuvwxyz"
 
-var maxIdx = big.NewInt(int64(len(charset)))
+	DefaultLength = 6
+
+	// MaxTitleLength caps slugs derived from link titles.
+	MaxTitleLength = 48
+)
+
+// Mode selects how slugs are generated.
+type Mode string
+
+const (
+	ModeRandom      Mode = "random"      // Length characters from Alphabet
+	ModeUnambiguous Mode = "unambiguous" // Length characters from Unambiguous
+	ModeWords       Mode = "words"       // adjective-noun-number, e.g. "brave-otter-42"
+	ModeTitle       Mode = "title"       // derived from the link title, random fallback
+)
+
+// Modes lists every supported mode.
+var Modes = []Mode{ModeRandom, ModeUnambiguous, ModeWords, ModeTitle}
+
+// growEvery is how many collisions are tolerated before generators widen
+// their output.
+const growEvery = 3
+
+// maxAttempts bounds Unique's retries.
+const maxAttempts = 15
+
+// maxFilterRetries bounds how often a generator redraws output the filter
+// rejects.
+const maxFilterRetries = 50
+
+// ErrExhausted is returned by Unique when no free slug was found.
+var ErrExhausted = errors.New("slug: failed to generate unique slug")
+
+// Input carries the link details a generator may derive a slug from.
+type Input struct {
+	Title string
+}
+
+// Generator produces candidate slugs. attempt counts the candidates already
+// rejected for in; generators widen their output as it grows so crowded
+// domains get longer slugs instead of failing.
+type Generator interface {
+	Generate(in Input, attempt int) (string, error)
+}
+
+// Options configures New.
+type Options struct {
+	Mode            Mode
+	Length          int
+	Alphabet        string  // used by ModeRandom; ignored by the other modes
+	CaseInsensitive bool    // restricts generated slugs to lowercase
+	Filter          *Filter // rejects random output that spells blocked words; may be nil
+}
+
+// New returns the generator for opts.Mode. An empty mode means ModeRandom.
+func New(opts Options) (Generator, error) {
+	if opts.Length <= 0 {
+		opts.Length = DefaultLength
+	}
+	switch opts.Mode {
+	case "", ModeRandom:
+		alphabet := opts.Alphabet
+		if alphabet == "" {
+			alphabet = Base62
+			if opts.CaseInsensitive {
+				alphabet = Base36
+			}
+		}
+		if len([]rune(alphabet)) < 2 {
+			return nil, fmt.Errorf("slug: alphabet %q needs at least 2 characters", alphabet)
+		}
+		return randomGenerator{length: opts.Length, alphabet: alphabet, filter: opts.Filter}, nil
+	case ModeUnambiguous:
+		alphabet := Unambiguous
+		if opts.CaseInsensitive {
+			alphabet = UnambiguousLower
+		}
+		return randomGenerator{length: opts.Length, alphabet: alphabet, filter: opts.Filter}, nil
+	case ModeWords:
+		return wordGenerator{filter: opts.Filter}, nil
+	case ModeTitle:
+		fallback, err := New(Options{Length: opts.Length, Alphabet: opts.Alphabet, CaseInsensitive: opts.CaseInsensitive, Filter: opts.Filter})
+		if err != nil {
+			return nil, err
+		}
+		return titleGenerator{fallback: fallback, filter: opts.Filter}, nil
+	}
+	return nil, fmt.Errorf("slug: unknown mode %q", opts.Mode)
+}
+
+// ValidMode reports whether m is a supported mode.
+func ValidMode(m Mode) bool {
+	for _, v := range Modes {
+		if m == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Unique asks gen for candidates until exists reports one free. It is the
+// single collision-retry loop shared by the API and the admin UI. Candidates
+// v rejects, e.g. a title that spells a reserved path, count as collisions.
+// v may be nil.
+func Unique(gen Generator, in Input, v *Validator, exists func(string) (bool, error)) (string, error) {
+	for attempt := range maxAttempts {
+		candidate, err := gen.Generate(in, attempt)
+		if err != nil {
+			return "", err
+		}
+		if v != nil && v.Validate(candidate) != nil {
+			continue
+		}
+		taken, err := exists(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+	return "", ErrExhausted
+}
 
 // Generate returns a random 6-character Base62 string.
 func Generate() (string, error) {
-	b := make([]byte, 6)
+	return GenerateWith(DefaultLength, Base62)
+}
+
+// GenerateWith returns a random string of length characters drawn from alphabet.
+func GenerateWith(length int, alphabet string) (string, error) {
+	chars := []rune(alphabet)
+	if length <= 0 || len(chars) < 2 {
+		return "", fmt.Errorf("slug: invalid length %d or alphabet %q", length, alphabet)
+	}
+	maxIdx := big.NewInt(int64(len(chars)))
+
+	b := make([]rune, length)
 	for i := range b {
 		n, err := rand.Int(rand.Reader, maxIdx)
 		if err != nil {
 			return "", err
 		}
-		b[i] = charset[n.Int64()]
+		b[i] = chars[n.Int64()]
 	}
 	return string(b), nil
 }
+
+type randomGenerator struct {
+	length   int
+	alphabet string
+	filter   *Filter
+}
+
+func (g randomGenerator) Generate(_ Input, attempt int) (string, error) {
+	return redraw(g.filter, func() (string, error) {
+		return GenerateWith(g.length+grow(attempt), g.alphabet)
+	})
+}
+
+// wordGenerator builds "adjective-noun-NN" slugs. Growing adds a digit to
+// the number.
+type wordGenerator struct {
+	filter *Filter
+}
+
+func (g wordGenerator) Generate(_ Input, attempt int) (string, error) {
+	return redraw(g.filter, func() (string, error) {
+		return words(attempt)
+	})
+}
+
+func words(attempt int) (string, error) {
+	adj, err := pick(adjectives)
+	if err != nil {
+		return "", err
+	}
+	noun, err := pick(nouns)
+	if err != nil {
+		return "", err
+	}
+	digits, err := GenerateWith(2+grow(attempt), "0123456789")
+	if err != nil {
+		return "", err
+	}
+	return adj + "-" + noun + "-" + digits, nil
+}
+
+// titleGenerator turns the title into a lowercase, hyphenated slug. The
+// first candidate is the bare title; collisions append a random suffix. Links
+// without a usable title fall back to random slugs. The title itself is the
+// author's own words, so only the random suffix is filtered.
+type titleGenerator struct {
+	fallback Generator
+	filter   *Filter
+}
+
+func (g titleGenerator) Generate(in Input, attempt int) (string, error) {
+	base := FromTitle(in.Title)
+	if base == "" {
+		return g.fallback.Generate(in, attempt)
+	}
+	if attempt == 0 {
+		return base, nil
+	}
+	suffix, err := redraw(g.filter, func() (string, error) {
+		return GenerateWith(2+grow(attempt), Base36)
+	})
+	if err != nil {
+		return "", err
+	}
+	return base + "-" + suffix, nil
+}
+
+// FromTitle lowercases title and joins its letters and digits, in any
+// script, with hyphens, truncated to MaxTitleLength characters at a word
+// boundary where possible.
+func FromTitle(title string) string {
+	var words [][]rune
+	var word []rune
+	for _, r := range Normalize(strings.ToLower(title)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) && len(word) > 0 {
+			word = append(word, r)
+			continue
+		}
+		if len(word) > 0 {
+			words = append(words, word)
+			word = nil
+		}
+	}
+	if len(word) > 0 {
+		words = append(words, word)
+	}
+
+	var out []rune
+	for _, w := range words {
+		sep := 0
+		if len(out) > 0 {
+			sep = 1
+		}
+		if len(out)+sep+len(w) > MaxTitleLength {
+			if len(out) == 0 {
+				out = w[:MaxTitleLength] // a single overlong word is cut mid-word
+			}
+			break
+		}
+		if sep == 1 {
+			out = append(out, '-')
+		}
+		out = append(out, w...)
+	}
+	return string(out)
+}
+
+// redraw calls gen until its output passes filter.
+func redraw(filter *Filter, gen func() (string, error)) (string, error) {
+	for range maxFilterRetries {
+		s, err := gen()
+		if err != nil || !filter.Blocked(s) {
+			return s, err
+		}
+	}
+	return "", errors.New("slug: every candidate was blocked by the filter")
+}
+
+// grow returns how many extra characters to add after attempt collisions.
+func grow(attempt int) int {
+	return attempt / growEvery
+}
+
+func pick(words []string) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(words))))
+	if err != nil {
+		return "", err
+	}
+	return words[n.Int64()], nil
+}
@@ -2,6 +2,7 @@ package slug
 
 import (
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -43,3 +44,175 @@ func TestGenerate_Uniqueness(t *testing.T) {
 		seen[s] = true
 	}
 }
+
+func TestGenerateWith_LengthAndAlphabet(t *testing.T) {
+	re := regexp.MustCompile(`^[ab]{10}$`)
+	for i := 0; i < 100; i++ {
+		s, err := GenerateWith(10, "ab")
+		if err != nil {
+			t.Fatalf("iteration %d: unexpected error: %v", i, err)
+		}
+		if !re.MatchString(s) {
+			t.Fatalf("iteration %d: slug %q does not match [ab]{10}", i, s)
+		}
+	}
+}
+
+func TestGenerateWith_Invalid(t *testing.T) {
+	if _, err := GenerateWith(0, Base62); err == nil {
+		t.Error("expected error for zero length")
+	}
+	if _, err := GenerateWith(6, "a"); err == nil {
+		t.Error("expected error for single-character alphabet")
+	}
+}
+
+func TestNew_Modes(t *testing.T) {
+	tests := []struct {
+		opts Options
+		re   string
+	}{
+		{Options{}, `^[0-9A-Za-z]{6}$`},
+		{Options{Mode: ModeRandom, Length: 8, Alphabet: "xyz"}, `^[xyz]{8}$`},
+		{Options{Mode: ModeRandom, CaseInsensitive: true}, `^[0-9a-z]{6}$`},
+		{Options{Mode: ModeUnambiguous}, `^[2-9A-HJ-NP-Za-km-z]{6}$`},
+		{Options{Mode: ModeUnambiguous, CaseInsensitive: true}, `^[2-9a-km-z]{6}$`},
+		{Options{Mode: ModeWords}, `^[a-z]+-[a-z]+-[0-9]{2}$`},
+	}
+	for _, tt := range tests {
+		gen, err := New(tt.opts)
+		if err != nil {
+			t.Fatalf("New(%+v): %v", tt.opts, err)
+		}
+		re := regexp.MustCompile(tt.re)
+		for range 50 {
+			s, err := gen.Generate(Input{}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !re.MatchString(s) {
+				t.Fatalf("mode %q: slug %q does not match %s", tt.opts.Mode, s, tt.re)
+			}
+		}
+	}
+}
+
+func TestNew_UnknownMode(t *testing.T) {
+	if _, err := New(Options{Mode: "emoji"}); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestTitleMode(t *testing.T) {
+	gen, err := New(Options{Mode: ModeTitle})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ := gen.Generate(Input{Title: "Spring Sale: 50% off!"}, 0)
+	if s != "spring-sale-50-off" {
+		t.Errorf("first candidate = %q, want spring-sale-50-off", s)
+	}
+	s, _ = gen.Generate(Input{Title: "Spring Sale: 50% off!"}, 1)
+	if !regexp.MustCompile(`^spring-sale-50-off-[0-9a-z]{2}$`).MatchString(s) {
+		t.Errorf("retry candidate = %q, want a random suffix", s)
+	}
+	s, _ = gen.Generate(Input{Title: "!!!"}, 0)
+	if !regexp.MustCompile(`^[0-9A-Za-z]{6}$`).MatchString(s) {
+		t.Errorf("untitled candidate = %q, want random fallback", s)
+	}
+}
+
+func TestFromTitle_Truncates(t *testing.T) {
+	s := FromTitle("a very long title that keeps going well past the limit for slugs")
+	if len(s) > MaxTitleLength || strings.HasSuffix(s, "-") {
+		t.Errorf("FromTitle = %q (len %d)", s, len(s))
+	}
+	if s != "a-very-long-title-that-keeps-going-well-past-the" {
+		t.Errorf("FromTitle = %q, want cut at a word boundary", s)
+	}
+}
+
+func TestUnique_GrowsOnCollisions(t *testing.T) {
+	gen, _ := New(Options{Length: 4})
+	calls := 0
+	s, err := Unique(gen, Input{}, nil, func(candidate string) (bool, error) {
+		calls++
+		return len(candidate) < 6, nil // only 6+ character slugs are free
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 6 {
+		t.Errorf("slug = %q, want length 6 after growing", s)
+	}
+	if calls != 2*growEvery+1 {
+		t.Errorf("exists called %d times, want %d", calls, 2*growEvery+1)
+	}
+}
+
+func TestUnique_Exhausted(t *testing.T) {
+	gen, _ := New(Options{})
+	_, err := Unique(gen, Input{}, nil, func(string) (bool, error) { return true, nil })
+	if err != ErrExhausted {
+		t.Errorf("err = %v, want ErrExhausted", err)
+	}
+}
+
+func TestFilter_Blocked(t *testing.T) {
+	f := NewFilter([]string{"Acme"})
+	tests := map[string]bool{
+		"xFuCkz":         true,
+		"sh1t99":         true,
+		"b-u-t-t":        true,
+		"acme42":         true,
+		"Ab3dE9":         false,
+		"brave-otter-42": false,
+	}
+	for s, want := range tests {
+		if got := f.Blocked(s); got != want {
+			t.Errorf("Blocked(%q) = %v, want %v", s, got, want)
+		}
+	}
+	var none *Filter
+	if none.Blocked("fuck") {
+		t.Error("nil filter should block nothing")
+	}
+}
+
+func TestFilter_RedrawsBlockedOutput(t *testing.T) {
+	// With a two-letter alphabet every "ab" is blocked, leaving only aa/ba/bb-style runs
+	gen, _ := New(Options{Length: 2, Alphabet: "ab", Filter: NewFilter([]string{"ab"})})
+	for range 50 {
+		s, err := gen.Generate(Input{}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s == "ab" {
+			t.Fatal("blocked slug was returned")
+		}
+	}
+}
+
+func TestWordLists_PassFilter(t *testing.T) {
+	f := NewFilter(nil)
+	for _, w := range append(append([]string{}, adjectives...), nouns...) {
+		if f.Blocked(w) {
+			t.Errorf("word %q is blocked by the default filter", w)
+		}
+	}
+}
+
+func TestFromTitle_Unicode(t *testing.T) {
+	tests := map[string]string{
+		"Café Crème":         "café-crème",
+		"東京 オフィス":            "東京-オフィス",
+		"Привет, мир!":       "привет-мир",
+		"cafe\u0301 au lait": "caf\u00e9-au-lait",
+	}
+	for in, want := range tests {
+		if got := FromTitle(in); got != want {
+			t.Errorf("FromTitle(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package slug
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultMaxLength is the longest custom slug accepted by default.
+const DefaultMaxLength = 64
+
+// reserved are slugs that would shadow routes or paths browsers and crawlers
+// request on their own. The first three are the top-level prefixes mounted
+// in cmd/server/main.go and AdminHandler.RegisterRoutes.
+var reserved = []string{
+	"api", "admin", "internal",
+	".well-known", "favicon.ico", "robots.txt", "sitemap.xml", "humans.txt",
+	"security.txt", "ads.txt", "app-ads.txt", "manifest.json", "site.webmanifest",
+	"browserconfig.xml", "apple-touch-icon.png", "apple-touch-icon-precomposed.png",
+	"apple-app-site-association", "crossdomain.xml",
+}
+
+// ErrReserved is wrapped by Validate for slugs on the reserved list.
+var ErrReserved = errors.New("slug is reserved")
+
+// Validator checks custom slugs against the character policy, a maximum
+// length and the reserved list.
+type Validator struct {
+	maxLength int
+	reserved  map[string]bool
+}
+
+// NewValidator returns a validator with the built-in reserved list plus
+// extra. maxLength <= 0 means DefaultMaxLength.
+func NewValidator(maxLength int, extra []string) *Validator {
+	if maxLength <= 0 {
+		maxLength = DefaultMaxLength
+	}
+	v := &Validator{maxLength: maxLength, reserved: make(map[string]bool, len(reserved)+len(extra))}
+	for _, s := range reserved {
+		v.reserved[s] = true
+	}
+	for _, s := range extra {
+		if s = strings.ToLower(strings.Trim(strings.TrimSpace(s), "/")); s != "" {
+			v.reserved[s] = true
+		}
+	}
+	return v
+}
+
+// IsReserved reports whether s is on the reserved list, ignoring case.
+func (v *Validator) IsReserved(s string) bool {
+	return v.reserved[strings.ToLower(s)]
+}
+
+// Normalize returns s in Unicode NFC, the form slugs are stored and looked
+// up in, so "é" typed as one code point or as "e" plus a combining accent
+// resolves to the same link.
+func Normalize(s string) string {
+	return norm.NFC.String(s)
+}
+
+// Validate returns an error describing why s can't be used as a slug. Slugs
+// are single path segments of letters and digits in any script, emoji, '-',
+// '_' and '.', so every accepted slug can be matched by the redirect handler.
+// Callers should Normalize first.
+func (v *Validator) Validate(s string) error {
+	if s == "" {
+		return errors.New("slug is empty")
+	}
+	if n := len([]rune(s)); n > v.maxLength {
+		return fmt.Errorf("slug is longer than %d characters", v.maxLength)
+	}
+	for _, r := range s {
+		if !validRune(r) {
+			return fmt.Errorf("slug can't contain %q; use letters, digits, emoji, '-', '_' or '.'", r)
+		}
+	}
+	if strings.HasPrefix(s, ".") {
+		return errors.New("slug can't start with '.'")
+	}
+	if v.IsReserved(s) {
+		return fmt.Errorf("%w: %s", ErrReserved, s)
+	}
+	return nil
+}
+
+func validRune(r rune) bool {
+	switch {
+	case r == '-' || r == '_' || r == '.':
+		return true
+	case r < unicode.MaxASCII:
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+	case unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r):
+		return true
+	case unicode.Is(unicode.So, r) || unicode.Is(unicode.Sk, r):
+		return true // emoji and their skin-tone modifiers
+	case r == '\u200d' || unicode.Is(unicode.Variation_Selector, r):
+		return true // joiners and presentation selectors inside emoji sequences
+	}
+	return false
+}
+
+// Suggest returns up to n available variations of base, e.g. "launch-2" or
+// "launch-k3". available should report whether a candidate is free; invalid
+// candidates are skipped.
+func Suggest(base string, n int, v *Validator, available func(string) (bool, error)) ([]string, error) {
+	base = strings.Trim(base, "-_.")
+	if base == "" || n <= 0 {
+		return nil, nil
+	}
+
+	var candidates []string
+	for i := 2; i <= 4; i++ {
+		candidates = append(candidates, fmt.Sprintf("%s-%d", base, i))
+	}
+	for range 2 * n {
+		suffix, err := GenerateWith(2, Base36)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, base+"-"+suffix)
+	}
+
+	var out []string
+	for _, c := range candidates {
+		if len(out) == n {
+			break
+		}
+		if v.Validate(c) != nil {
+			continue
+		}
+		ok, err := available(c)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
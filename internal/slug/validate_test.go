@@ -0,0 +1,93 @@
+package slug
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	v := NewValidator(12, []string{"Pricing", "/docs/"})
+	tests := []struct {
+		slug    string
+		wantErr string
+	}{
+		{"launch", ""},
+		{"Q3_report.v2", ""},
+		{"a-b-c", ""},
+		{"", "empty"},
+		{"has space", "can't contain"},
+		{"a/b", "can't contain"},
+		{"what?", "can't contain"},
+		{"thirteenchars", "longer than 12"},
+		{".hidden", "start with '.'"},
+		{"admin", "reserved"},
+		{"API", "reserved"},
+		{"favicon.ico", "reserved"},
+		{"pricing", "reserved"},
+		{"docs", "reserved"},
+	}
+	for _, tt := range tests {
+		err := v.Validate(tt.slug)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("Validate(%q) = %v, want nil", tt.slug, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Validate(%q) = %v, want error containing %q", tt.slug, err, tt.wantErr)
+		}
+	}
+	if err := v.Validate("admin"); !errors.Is(err, ErrReserved) {
+		t.Errorf("reserved error should wrap ErrReserved, got %v", err)
+	}
+}
+
+func TestValidator_DefaultMaxLength(t *testing.T) {
+	v := NewValidator(0, nil)
+	if err := v.Validate(strings.Repeat("a", DefaultMaxLength)); err != nil {
+		t.Errorf("slug at the limit rejected: %v", err)
+	}
+	if err := v.Validate(strings.Repeat("a", DefaultMaxLength+1)); err == nil {
+		t.Error("slug over the limit accepted")
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	v := NewValidator(0, nil)
+	taken := map[string]bool{"launch-2": true}
+	got, err := Suggest("launch", 3, v, func(s string) (bool, error) { return !taken[s], nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != "launch-3" || got[1] != "launch-4" {
+		t.Errorf("Suggest = %v, want launch-3, launch-4 and one random", got)
+	}
+	for _, s := range got {
+		if taken[s] {
+			t.Errorf("suggested taken slug %q", s)
+		}
+	}
+}
+
+func TestNormalize_NFC(t *testing.T) {
+	decomposed := "cafe\u0301"
+	if got := Normalize(decomposed); got != "caf\u00e9" {
+		t.Errorf("Normalize(%q) = %q, want precomposed é", decomposed, got)
+	}
+}
+
+func TestValidator_Unicode(t *testing.T) {
+	v := NewValidator(0, nil)
+	for _, s := range []string{"日本語", "café", "привет", "مرحبا", "🚀", "launch-🚀", "👍🏽", "\U0001F468\u200d\U0001F469\u200d\U0001F467", "❤️"} {
+		if err := v.Validate(Normalize(s)); err != nil {
+			t.Errorf("Validate(%q) = %v, want nil", s, err)
+		}
+	}
+	for _, s := range []string{"日本 語", "100%", "a b", "a\u200bb", "a?b"} {
+		if err := v.Validate(s); err == nil {
+			t.Errorf("Validate(%q) = nil, want error", s)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package slug
+
+// Word lists for ModeWords. Kept short, friendly and easy to spell so
+// "brave-otter-42" can be read over the phone.
+var adjectives = []string{
+	"amber", "bold", "brave", "breezy", "bright", "calm", "clever", "cosy",
+	"crisp", "curious", "daring", "eager", "early", "fancy", "fast", "fluffy",
+	"gentle", "giant", "glad", "golden", "grand", "happy", "hidden", "humble",
+	"jolly", "keen", "kind", "lively", "lucky", "mellow", "merry", "mighty",
+	"misty", "modest", "noble", "proud", "quick", "quiet", "rapid", "rosy",
+	"rustic", "shiny", "silent", "silver", "sleepy", "smooth", "snowy", "sunny",
+	"swift", "tidy", "tiny", "vivid", "warm", "wise", "witty", "young",
+}
+
+var nouns = []string{
+	"badger", "beacon", "bear", "brook", "canyon", "cedar", "comet", "coral",
+	"crane", "dolphin", "eagle", "falcon", "fern", "finch", "fox", "glacier",
+	"harbor", "harp", "hawk", "heron", "island", "koala", "lagoon", "lantern",
+	"lark", "lemur", "lynx", "maple", "meadow", "moose", "nova", "oak",
+	"orca", "orchid", "otter", "owl", "panda", "pebble", "pine", "planet",
+	"quartz", "raven", "reef", "river", "robin", "rocket", "sparrow", "summit",
+	"tiger", "tulip", "valley", "walrus", "willow", "wombat", "yak", "zebra",
+}
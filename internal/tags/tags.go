@@ -0,0 +1,40 @@
+// Package tags normalizes link tags. Tags are stored lowercased and trimmed,
+// without duplicates, and joined with commas in the links.tags column.
+package tags
+
+import "strings"
+
+// Normalize returns the stored form of a single tag: trimmed, lowercased
+// and with runs of whitespace collapsed to one space.
+func Normalize(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// Parse splits a comma-separated tag string into normalized tags, dropping
+// empties and duplicates while keeping the original order.
+func Parse(s string) []string {
+	return Clean(strings.Split(s, ","))
+}
+
+// Clean normalizes a list of tags, dropping empties and duplicates. Commas
+// inside a tag would break the stored form, so they split it.
+func Clean(in []string) []string {
+	var out []string
+	seen := make(map[string]bool, len(in))
+	for _, raw := range in {
+		for _, part := range strings.Split(raw, ",") {
+			t := Normalize(part)
+			if t == "" || seen[t] {
+				continue
+			}
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Join returns the comma-separated form stored in links.tags.
+func Join(tags []string) string {
+	return strings.Join(tags, ",")
+}
@@ -0,0 +1,34 @@
+package tags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"docs", []string{"docs"}},
+		{" Docs , features,docs,,", []string{"docs", "features"}},
+		{"Machine   Learning,AI", []string{"machine learning", "ai"}},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestClean_SplitsCommas(t *testing.T) {
+	got := Clean([]string{"a,b", "B", " c "})
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Clean = %q, want %q", got, want)
+	}
+	if Join(got) != "a,b,c" {
+		t.Errorf("Join = %q", Join(got))
+	}
+}
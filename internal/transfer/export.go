@@ -0,0 +1,151 @@
+package transfer
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/tags"
+)
+
+// Export writes every link to w, followed by every click when
+// includeClicks is set. Rows are streamed as they are read.
+func Export(db *sql.DB, w io.Writer, f Format, includeClicks bool) error {
+	historical, err := models.HistoricalClickTotals(db)
+	if err != nil {
+		return err
+	}
+	enc := newEncoder(w, f)
+	err = models.EachLink(db, func(l *models.Link) error {
+		rec := linkRecord(l)
+		rec.HistoricalClicks = historical[l.ID]
+		return enc.write(rec)
+	})
+	if err != nil {
+		return err
+	}
+	if includeClicks {
+		err = models.EachClick(db, func(c *models.LinkClick) error {
+			return enc.write(clickRecord(c))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return enc.close()
+}
+
+func linkRecord(l *models.Link) *record {
+	active := l.IsActive
+	created, updated := l.CreatedAt.UTC(), l.UpdatedAt.UTC()
+	return &record{
+		Type:        typeLink,
+		Domain:      l.Domain,
+		Slug:        l.Slug,
+		Destination: l.Destination,
+		Title:       l.Title,
+		Tags:        tags.Parse(l.Tags),
+		Notes:       l.Notes,
+		IsActive:    &active,
+		CreatedAt:   &created,
+		UpdatedAt:   &updated,
+	}
+}
+
+func clickRecord(c *models.LinkClick) *record {
+	clicked := c.ClickedAt.UTC()
+	return &record{
+		Type:           typeClick,
+		Domain:         c.Domain,
+		Slug:           c.Slug,
+		ClickedAt:      &clicked,
+		IP:             c.IP,
+		UserAgent:      c.UserAgent,
+		Referer:        c.Referer,
+		RefererDomain:  c.RefererDomain,
+		Country:        c.Country,
+		City:           c.City,
+		Region:         c.Region,
+		Latitude:       c.Latitude,
+		Longitude:      c.Longitude,
+		Browser:        c.Browser,
+		BrowserVersion: c.BrowserVersion,
+		OS:             c.OS,
+		DeviceType:     c.DeviceType,
+	}
+}
+
+type encoder interface {
+	write(*record) error
+	close() error
+}
+
+func newEncoder(w io.Writer, f Format) encoder {
+	if f == NDJSON {
+		return &jsonEncoder{enc: json.NewEncoder(w)}
+	}
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+type jsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonEncoder) write(rec *record) error { return e.enc.Encode(rec) }
+func (e *jsonEncoder) close() error            { return nil }
+
+type csvEncoder struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (e *csvEncoder) write(rec *record) error {
+	if !e.wroteHeader {
+		if err := e.w.Write(csvColumns); err != nil {
+			return err
+		}
+		e.wroteHeader = true
+	}
+	return e.w.Write(rec.csvRow())
+}
+
+func (e *csvEncoder) close() error {
+	if !e.wroteHeader {
+		e.w.Write(csvColumns)
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (r *record) csvRow() []string {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	formatFloat := func(f float64) string {
+		if f == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	active := ""
+	if r.IsActive != nil {
+		active = strconv.FormatBool(*r.IsActive)
+	}
+	historical := ""
+	if r.HistoricalClicks > 0 {
+		historical = strconv.Itoa(r.HistoricalClicks)
+	}
+	return []string{
+		r.Type, r.Domain, r.Slug, r.Destination, r.Title, tags.Join(r.Tags), r.Notes, active,
+		formatTime(r.CreatedAt), formatTime(r.UpdatedAt), historical,
+		formatTime(r.ClickedAt), r.IP, r.UserAgent, r.Referer, r.RefererDomain, r.Country, r.City, r.Region,
+		formatFloat(r.Latitude), formatFloat(r.Longitude), r.Browser, r.BrowserVersion, r.OS, r.DeviceType,
+	}
+}
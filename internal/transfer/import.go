@@ -0,0 +1,600 @@
+package transfer
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/cache"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/slug"
+	"github.com/scmmishra/dubly/internal/tags"
+)
+
+// Conflict says what an import does with a link whose slug is already taken.
+type Conflict string
+
+const (
+	Skip      Conflict = "skip"
+	Overwrite Conflict = "overwrite"
+	Rename    Conflict = "rename"
+)
+
+// ParseConflict accepts a conflict policy name, defaulting to Skip.
+func ParseConflict(s string) (Conflict, error) {
+	switch Conflict(strings.ToLower(strings.TrimSpace(s))) {
+	case Skip, "":
+		return Skip, nil
+	case Overwrite:
+		return Overwrite, nil
+	case Rename:
+		return Rename, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q: use skip, overwrite or rename", s)
+}
+
+type Options struct {
+	Format   Format
+	Conflict Conflict
+	// Domain receives links whose own domain is missing or not configured
+	// here, such as bit.ly links. YOURLS and bookmark imports require it.
+	Domain string
+	// HistoricalClicks keeps the click totals in other tools' exports.
+	// Dubly's own exports always bring theirs.
+	HistoricalClicks bool
+	// DryRun validates and counts everything, then rolls the import back.
+	DryRun bool
+}
+
+// maxReported caps the conflicts and errors listed in a Summary; the
+// counts always cover every line.
+const maxReported = 100
+
+type Summary struct {
+	DryRun    bool             `json:"dry_run"`
+	Links     LinkCounts       `json:"links"`
+	Clicks    ClickCounts      `json:"clicks"`
+	Conflicts []ConflictReport `json:"conflicts"`
+	Errors    []LineError      `json:"errors"`
+}
+
+type LinkCounts struct {
+	Created int `json:"created"`
+	// Unchanged links were already here with the same slug and
+	// destination, usually from an earlier run of the same import.
+	Unchanged   int `json:"unchanged"`
+	Overwritten int `json:"overwritten"`
+	Renamed     int `json:"renamed"`
+	Skipped     int `json:"skipped"`
+	Failed      int `json:"failed"`
+}
+
+type ClickCounts struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+	// Historical counts the links given a historical click total.
+	Historical int `json:"historical"`
+}
+
+// ConflictReport records a link whose slug was taken and what was done.
+type ConflictReport struct {
+	Line    int      `json:"line"`
+	Domain  string   `json:"domain"`
+	Slug    string   `json:"slug"`
+	Action  Conflict `json:"action"`
+	NewSlug string   `json:"new_slug,omitempty"`
+}
+
+type LineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Importer loads exported files into the database.
+type Importer struct {
+	DB  *sql.DB
+	Cfg *config.Config
+	// Cache, when set, drops the cached copies of overwritten links.
+	Cache *cache.LinkCache
+}
+
+// Import reads links and clicks from r in one transaction. Bad lines are
+// reported in the summary and skipped; only read and database failures
+// abort the import. Links already imported are left unchanged and clicks
+// are imported only for links the import creates, so re-running an import
+// adds nothing.
+func (im *Importer) Import(r io.Reader, opts Options) (*Summary, error) {
+	if opts.Conflict == "" {
+		opts.Conflict = Skip
+	}
+	if opts.Domain != "" {
+		opts.Domain = config.CanonicalDomain(opts.Domain)
+		if !im.Cfg.IsDomainAllowed(opts.Domain) {
+			return nil, &InputError{fmt.Sprintf("domain %s is not configured", opts.Domain)}
+		}
+	}
+	var dec decoder
+	var err error
+	if opts.Format.foreign() {
+		dec, err = newSourceDecoder(r, opts)
+	} else {
+		dec, err = newDecoder(r, opts.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return im.run(dec, opts)
+}
+
+func (im *Importer) run(dec decoder, opts Options) (*Summary, error) {
+	tx, err := im.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	s := &session{
+		im:      im,
+		tx:      tx,
+		opts:    opts,
+		summary: &Summary{DryRun: opts.DryRun, Conflicts: []ConflictReport{}, Errors: []LineError{}},
+		linkIDs: map[string]int64{},
+	}
+	for {
+		rec, line, err := dec.next()
+		if err == io.EOF {
+			break
+		}
+		var le *lineError
+		if errors.As(err, &le) {
+			s.fail(line, le.msg)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch rec.Type {
+		case typeLink, "":
+			err = s.link(line, rec)
+		case typeClick:
+			err = s.click(line, rec)
+		default:
+			s.fail(line, fmt.Sprintf("unknown record type %q", rec.Type))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := s.flushClicks(); err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		return s.summary, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit import: %w", err)
+	}
+	if im.Cache != nil {
+		for _, l := range s.overwritten {
+			im.Cache.Invalidate(l.Domain, l.Slug)
+			if aliases, err := models.ListLinkAliases(im.DB, l.ID); err == nil {
+				im.Cache.InvalidateAliases(aliases)
+			}
+		}
+	}
+	return s.summary, nil
+}
+
+const clickBatchSize = 500
+
+type session struct {
+	im      *Importer
+	tx      *sql.Tx
+	opts    Options
+	summary *Summary
+
+	// linkIDs maps the domain/slug keys in the file to the links created
+	// for them, or to 0 for links that were skipped or overwritten.
+	linkIDs     map[string]int64
+	clicks      []models.Click
+	overwritten []models.Link
+}
+
+func (s *session) fail(line int, msg string) {
+	s.summary.Links.Failed++
+	if len(s.summary.Errors) < maxReported {
+		s.summary.Errors = append(s.summary.Errors, LineError{Line: line, Error: msg})
+	}
+}
+
+func (s *session) conflict(c ConflictReport) {
+	if len(s.summary.Conflicts) < maxReported {
+		s.summary.Conflicts = append(s.summary.Conflicts, c)
+	}
+}
+
+// key canonicalizes the domain and slug a record names, moving it to the
+// target domain when its own isn't configured.
+func (s *session) key(rec *record) (domain, slugVal string) {
+	domain = config.CanonicalDomain(rec.Domain)
+	if s.opts.Domain != "" && (domain == "" || !s.im.Cfg.IsDomainAllowed(domain)) {
+		domain = s.opts.Domain
+	}
+	return domain, s.im.Cfg.NormalizeSlug(domain, strings.TrimSpace(rec.Slug))
+}
+
+func (s *session) link(line int, rec *record) error {
+	cfg := s.im.Cfg
+	domain, slugVal := s.key(rec)
+	switch {
+	case strings.TrimSpace(rec.Destination) == "":
+		s.fail(line, "destination is required")
+		return nil
+	case domain == "":
+		s.fail(line, "domain is required")
+		return nil
+	case !cfg.IsDomainAllowed(domain):
+		s.fail(line, fmt.Sprintf("domain %s is not configured", domain))
+		return nil
+	}
+
+	fold := cfg.SettingsFor(domain).CaseInsensitive
+	slugExists, getLink := models.SlugExists, models.GetLinkBySlugAndDomain
+	if fold {
+		slugExists, getLink = models.SlugExistsFold, models.GetLinkBySlugAndDomainFold
+	}
+	available := func(candidate string) (bool, error) {
+		exists, err := slugExists(s.tx, candidate, domain)
+		return !exists, err
+	}
+	validator := cfg.SlugValidator()
+
+	l := &models.Link{
+		Slug:   slugVal,
+		Domain: domain,
+		Title:  rec.Title,
+		Tags:   tags.Join(tags.Clean(rec.Tags)),
+		Notes:  rec.Notes,
+	}
+	l.SetDestination(strings.TrimSpace(rec.Destination))
+	key := domain + "/" + slugVal
+
+	if slugVal == "" {
+		// Without a slug to match on, a link to the same destination is
+		// taken to be this one from an earlier run
+		existing, err := models.FindLinkByDestination(s.tx, domain, l.Destination)
+		if err == nil {
+			return s.unchanged(rec, existing)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+		gen, err := cfg.SlugGenerator(domain)
+		if err != nil {
+			return err
+		}
+		l.Slug, err = slug.Unique(gen, slug.Input{Title: rec.Title}, validator, func(candidate string) (bool, error) {
+			return slugExists(s.tx, candidate, domain)
+		})
+		if err != nil {
+			s.fail(line, "failed to generate unique slug")
+			return nil
+		}
+		return s.create(rec, l, "")
+	}
+	if err := validator.Validate(slugVal); err != nil {
+		s.fail(line, err.Error())
+		return nil
+	}
+
+	existing, err := getLink(s.tx, slugVal, domain)
+	if err == sql.ErrNoRows {
+		return s.create(rec, l, key)
+	}
+	if err != nil {
+		return err
+	}
+	if existing.AliasID == 0 && existing.Destination == l.Destination && s.opts.Conflict != Overwrite {
+		s.linkIDs[key] = 0
+		return s.unchanged(rec, existing)
+	}
+
+	report := ConflictReport{Line: line, Domain: domain, Slug: slugVal, Action: s.opts.Conflict}
+	switch s.opts.Conflict {
+	case Overwrite:
+		if existing.AliasID != 0 {
+			s.fail(line, fmt.Sprintf("%s/%s is an alias of another link", domain, slugVal))
+			return nil
+		}
+		old := *existing
+		existing.SetDestination(l.Destination)
+		existing.Title, existing.Tags, existing.Notes = l.Title, l.Tags, l.Notes
+		if err := models.UpdateLinkTx(s.tx, existing); err != nil {
+			return err
+		}
+		if rec.IsActive != nil && *rec.IsActive != existing.IsActive {
+			if err := models.SetLinkActive(s.tx, existing.ID, *rec.IsActive); err != nil {
+				return err
+			}
+		}
+		if err := s.historical(rec, existing.ID); err != nil {
+			return err
+		}
+		s.overwritten = append(s.overwritten, old)
+		s.linkIDs[key] = 0
+		s.summary.Links.Overwritten++
+	case Rename:
+		suggestions, err := slug.Suggest(slugVal, 1, validator, available)
+		if err != nil {
+			return err
+		}
+		if len(suggestions) == 0 {
+			s.fail(line, fmt.Sprintf("no free slug to rename %s/%s to", domain, slugVal))
+			return nil
+		}
+		l.Slug = suggestions[0]
+		report.NewSlug = l.Slug
+		if err := s.create(rec, l, key); err != nil {
+			return err
+		}
+		// create counted it as new
+		s.summary.Links.Created--
+		s.summary.Links.Renamed++
+	default:
+		s.linkIDs[key] = 0
+		s.summary.Links.Skipped++
+	}
+	s.conflict(report)
+	return nil
+}
+
+// create inserts l with the record's state and timestamps, mapping key to
+// it so the file's clicks for key follow.
+func (s *session) create(rec *record, l *models.Link, key string) error {
+	if err := models.CreateLinkTx(s.tx, l); err != nil {
+		return err
+	}
+	if rec.IsActive != nil && !*rec.IsActive {
+		if err := models.SetLinkActive(s.tx, l.ID, false); err != nil {
+			return err
+		}
+	}
+	if rec.CreatedAt != nil && !rec.CreatedAt.IsZero() {
+		if err := models.SetLinkCreatedAt(s.tx, l.ID, *rec.CreatedAt); err != nil {
+			return err
+		}
+	}
+	if err := s.historical(rec, l.ID); err != nil {
+		return err
+	}
+	if key != "" {
+		s.linkIDs[key] = l.ID
+	}
+	s.summary.Links.Created++
+	return nil
+}
+
+// unchanged counts a link that an earlier import already brought in,
+// filling in its historical clicks if that import left them out.
+func (s *session) unchanged(rec *record, existing *models.Link) error {
+	s.summary.Links.Unchanged++
+	return s.historical(rec, existing.ID)
+}
+
+func (s *session) historical(rec *record, linkID int64) error {
+	if rec.HistoricalClicks <= 0 || (s.opts.Format.foreign() && !s.opts.HistoricalClicks) {
+		return nil
+	}
+	source := string(s.opts.Format)
+	if !s.opts.Format.foreign() {
+		source = "dubly"
+	}
+	if err := models.SetHistoricalClicks(s.tx, linkID, source, rec.HistoricalClicks); err != nil {
+		return err
+	}
+	s.summary.Clicks.Historical++
+	return nil
+}
+
+func (s *session) click(line int, rec *record) error {
+	domain, slugVal := s.key(rec)
+	id := s.linkIDs[domain+"/"+slugVal]
+	if id == 0 || rec.ClickedAt == nil || rec.ClickedAt.IsZero() {
+		s.summary.Clicks.Skipped++
+		return nil
+	}
+	s.clicks = append(s.clicks, models.Click{
+		LinkID:         id,
+		ClickedAt:      rec.ClickedAt.UTC(),
+		IP:             rec.IP,
+		UserAgent:      rec.UserAgent,
+		Referer:        rec.Referer,
+		RefererDomain:  rec.RefererDomain,
+		Country:        rec.Country,
+		City:           rec.City,
+		Region:         rec.Region,
+		Latitude:       rec.Latitude,
+		Longitude:      rec.Longitude,
+		Browser:        rec.Browser,
+		BrowserVersion: rec.BrowserVersion,
+		OS:             rec.OS,
+		DeviceType:     rec.DeviceType,
+	})
+	s.summary.Clicks.Imported++
+	if len(s.clicks) >= clickBatchSize {
+		return s.flushClicks()
+	}
+	return nil
+}
+
+func (s *session) flushClicks() error {
+	if len(s.clicks) == 0 {
+		return nil
+	}
+	err := models.InsertClicksTx(s.tx, s.clicks)
+	s.clicks = s.clicks[:0]
+	return err
+}
+
+// InputError means the input as a whole can't be read, such as a CSV file
+// without a destination column.
+type InputError struct {
+	msg string
+}
+
+func (e *InputError) Error() string { return e.msg }
+
+// lineError is a problem with one line of input; the import reports it and
+// moves on.
+type lineError struct {
+	msg string
+}
+
+func (e *lineError) Error() string { return e.msg }
+
+// decoder yields records with their line numbers until io.EOF.
+type decoder interface {
+	next() (*record, int, error)
+}
+
+func newDecoder(r io.Reader, f Format) (decoder, error) {
+	if f == NDJSON {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), 1<<20)
+		return &jsonDecoder{sc: sc}, nil
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return &csvDecoder{r: cr}, nil
+	}
+	if err != nil {
+		return nil, &InputError{"read csv header: " + err.Error()}
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := cols["destination"]; !ok {
+		return nil, &InputError{"csv header has no destination column"}
+	}
+	return &csvDecoder{r: cr, cols: cols}, nil
+}
+
+type jsonDecoder struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func (d *jsonDecoder) next() (*record, int, error) {
+	for d.sc.Scan() {
+		d.line++
+		text := strings.TrimSpace(d.sc.Text())
+		if text == "" {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal([]byte(text), &rec); err != nil {
+			return nil, d.line, &lineError{"invalid JSON: " + err.Error()}
+		}
+		return &rec, d.line, nil
+	}
+	if err := d.sc.Err(); err != nil {
+		// The scanner can't skip past an overlong line, so it ends the import
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, d.line + 1, &InputError{fmt.Sprintf("line %d is longer than 1 MB", d.line+1)}
+		}
+		return nil, d.line, fmt.Errorf("read ndjson: %w", err)
+	}
+	return nil, d.line, io.EOF
+}
+
+type csvDecoder struct {
+	r    *csv.Reader
+	cols map[string]int
+}
+
+func (d *csvDecoder) next() (*record, int, error) {
+	row, err := d.r.Read()
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		return nil, pe.StartLine, &lineError{"invalid CSV: " + pe.Err.Error()}
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("read csv: %w", err)
+	}
+	line, _ := d.r.FieldPos(0)
+
+	get := func(name string) string {
+		if i, ok := d.cols[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	rec := &record{
+		Type:           get("type"),
+		Domain:         get("domain"),
+		Slug:           get("slug"),
+		Destination:    get("destination"),
+		Title:          get("title"),
+		Tags:           tags.Parse(get("tags")),
+		Notes:          get("notes"),
+		IP:             get("ip"),
+		UserAgent:      get("user_agent"),
+		Referer:        get("referer"),
+		RefererDomain:  get("referer_domain"),
+		Country:        get("country"),
+		City:           get("city"),
+		Region:         get("region"),
+		Browser:        get("browser"),
+		BrowserVersion: get("browser_version"),
+		OS:             get("os"),
+		DeviceType:     get("device_type"),
+	}
+	if v := get("historical_clicks"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, line, &lineError{fmt.Sprintf("historical_clicks %q is not a count", v)}
+		}
+		rec.HistoricalClicks = n
+	}
+	if v := get("is_active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, line, &lineError{fmt.Sprintf("is_active %q is not a boolean", v)}
+		}
+		rec.IsActive = &active
+	}
+	for name, dst := range map[string]**time.Time{"created_at": &rec.CreatedAt, "updated_at": &rec.UpdatedAt, "clicked_at": &rec.ClickedAt} {
+		if v := get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, line, &lineError{fmt.Sprintf("%s %q is not an RFC 3339 time", name, v)}
+			}
+			*dst = &t
+		}
+	}
+	for name, dst := range map[string]*float64{"latitude": &rec.Latitude, "longitude": &rec.Longitude} {
+		if v := get(name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, line, &lineError{fmt.Sprintf("%s %q is not a number", name, v)}
+			}
+			*dst = f
+		}
+	}
+	return rec, line, nil
+}
@@ -0,0 +1,244 @@
+package transfer
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+func importString(t *testing.T, im *Importer, input string, opts Options) *Summary {
+	t.Helper()
+	summary, err := im.Import(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func mustGetLink(t *testing.T, d *sql.DB, slug string) *models.Link {
+	t.Helper()
+	l, err := models.GetLinkBySlugAndDomain(d, slug, "short.io")
+	if err != nil {
+		t.Fatalf("get %s: %v", slug, err)
+	}
+	return l
+}
+
+func historicalClicks(t *testing.T, d *sql.DB, id int64) int {
+	t.Helper()
+	h, err := models.GetHistoricalClicks(d, id)
+	if err == sql.ErrNoRows {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h.Clicks
+}
+
+func TestImport_Bitly(t *testing.T) {
+	input := "Date Created,Title,Bitlink,Custom Bitlinks,Long URL,Tags,Clicks\n" +
+		"2021-04-20T14:31:41+0000,Spring sale,https://bit.ly/3xYz,https://bit.ly/spring,https://example.com/spring,sales;2021,\"1,204\"\n" +
+		"2021-05-01 09:00:00,,bit.ly/abc,,https://example.com/abc,,7\n" +
+		"2021-05-02,No link,,,https://example.com/none,,0\n"
+
+	im := testImporter(t)
+	opts := Options{Format: Bitly, Domain: "short.io", HistoricalClicks: true}
+	summary := importString(t, im, input, opts)
+	if summary.Links.Created != 2 || summary.Links.Failed != 1 || summary.Clicks.Historical != 2 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if len(summary.Errors) != 1 || summary.Errors[0].Line != 4 {
+		t.Errorf("errors = %+v", summary.Errors)
+	}
+
+	spring := mustGetLink(t, im.DB, "spring")
+	if spring.Title != "Spring sale" || spring.Tags != "sales,2021" {
+		t.Errorf("spring = %+v", spring)
+	}
+	if want := time.Date(2021, 4, 20, 14, 31, 41, 0, time.UTC); !spring.CreatedAt.Equal(want) {
+		t.Errorf("created_at = %v, want %v", spring.CreatedAt, want)
+	}
+	if n := historicalClicks(t, im.DB, spring.ID); n != 1204 {
+		t.Errorf("historical clicks = %d, want 1204", n)
+	}
+	mustGetLink(t, im.DB, "abc")
+
+	// Re-running the import finds everything already there
+	summary = importString(t, im, input, opts)
+	if summary.Links.Created != 0 || summary.Links.Unchanged != 2 || len(summary.Conflicts) != 0 {
+		t.Errorf("second import summary = %+v", summary)
+	}
+}
+
+func TestImport_BitlyReportsConflicts(t *testing.T) {
+	im := testImporter(t)
+	if err := models.CreateLink(im.DB, &models.Link{Slug: "abc", Domain: "short.io", Destination: "https://example.com/mine"}); err != nil {
+		t.Fatal(err)
+	}
+	input := "bitlink,long_url\nbit.ly/abc,https://example.com/theirs\n"
+
+	summary := importString(t, im, input, Options{Format: Bitly, Domain: "short.io"})
+	if summary.Links.Skipped != 1 || len(summary.Conflicts) != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if c := summary.Conflicts[0]; c.Domain != "short.io" || c.Slug != "abc" || c.Action != Skip {
+		t.Errorf("conflict = %+v", c)
+	}
+
+	// Without a target domain bit.ly links have nowhere to go
+	summary = importString(t, im, input, Options{Format: Bitly})
+	if summary.Links.Failed != 1 || !strings.Contains(summary.Errors[0].Error, "bit.ly") {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+func TestImport_HistoricalClicksAreOptional(t *testing.T) {
+	im := testImporter(t)
+	importString(t, im, "bitlink,long_url,clicks\nbit.ly/abc,https://example.com/abc,12\n", Options{Format: Bitly, Domain: "short.io"})
+	if n := historicalClicks(t, im.DB, mustGetLink(t, im.DB, "abc").ID); n != 0 {
+		t.Errorf("historical clicks = %d without the option", n)
+	}
+}
+
+const yourlsDump = `-- MySQL dump 10.13
+/*!40101 SET NAMES utf8mb4 */;
+DROP TABLE IF EXISTS ` + "`yourls_url`" + `;
+CREATE TABLE ` + "`yourls_url`" + ` (
+  ` + "`keyword`" + ` varchar(100) NOT NULL,
+  ` + "`url`" + ` text NOT NULL,
+  ` + "`title`" + ` text DEFAULT 'a;b',
+  PRIMARY KEY (` + "`keyword`" + `)
+);
+INSERT INTO ` + "`yourls_options`" + ` VALUES (1,'version','1.9');
+INSERT INTO ` + "`yourls_url`" + ` VALUES ('docs','https://example.com/docs?a=1&b=2','It\'s the \"docs\"','2019-03-01 10:20:30','127.0.0.1',42),
+('blog','https://example.com/blog',NULL,'2019-03-02 00:00:00','127.0.0.1',0);
+INSERT INTO ` + "`yourls`.`yourls_url`" + ` (` + "`url`, `keyword`, `clicks`" + `) VALUES ('https://example.com/x','x',5);
+`
+
+func TestImport_YOURLSSQL(t *testing.T) {
+	im := testImporter(t)
+	summary := importString(t, im, yourlsDump, Options{Format: YOURLS, Domain: "short.io", HistoricalClicks: true})
+	if summary.Links.Created != 3 || summary.Links.Failed != 0 {
+		t.Fatalf("summary = %+v", summary)
+	}
+
+	docs := mustGetLink(t, im.DB, "docs")
+	if docs.Destination != "https://example.com/docs?a=1&b=2" || docs.Title != `It's the "docs"` {
+		t.Errorf("docs = %+v", docs)
+	}
+	if want := time.Date(2019, 3, 1, 10, 20, 30, 0, time.UTC); !docs.CreatedAt.Equal(want) {
+		t.Errorf("created_at = %v, want %v", docs.CreatedAt, want)
+	}
+	if n := historicalClicks(t, im.DB, docs.ID); n != 42 {
+		t.Errorf("historical clicks = %d, want 42", n)
+	}
+	if x := mustGetLink(t, im.DB, "x"); x.Destination != "https://example.com/x" || historicalClicks(t, im.DB, x.ID) != 5 {
+		t.Errorf("x = %+v", x)
+	}
+	mustGetLink(t, im.DB, "blog")
+}
+
+func TestImport_YOURLSCSV(t *testing.T) {
+	im := testImporter(t)
+	input := "keyword,url,title,timestamp,ip,clicks\ndocs,https://example.com/docs,Docs,2019-03-01 10:20:30,127.0.0.1,3\n"
+	summary := importString(t, im, input, Options{Format: YOURLS, Domain: "short.io"})
+	if summary.Links.Created != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if docs := mustGetLink(t, im.DB, "docs"); docs.Title != "Docs" {
+		t.Errorf("docs = %+v", docs)
+	}
+}
+
+func TestImport_YOURLSNeedsDomain(t *testing.T) {
+	im := testImporter(t)
+	_, err := im.Import(strings.NewReader(yourlsDump), Options{Format: YOURLS})
+	var inputErr *InputError
+	if !errors.As(err, &inputErr) {
+		t.Errorf("err = %v, want an InputError", err)
+	}
+}
+
+func TestImport_Shlink(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"api response", `{"shortUrls":{"data":[{
+			"shortCode":"abc","shortUrl":"https://s.test/abc","longUrl":"https://example.com/abc",
+			"dateCreated":"2022-01-02T03:04:05+00:00","visitsSummary":{"total":9,"nonBots":8},
+			"tags":["docs"],"domain":null,"title":"ABC"}],"pagination":{"currentPage":1}}}`},
+		{"legacy array", `[{
+			"shortCode":"abc","shortUrl":"https://s.test/abc","longUrl":"https://example.com/abc",
+			"dateCreated":"2022-01-02T03:04:05+00:00","visitsCount":9,
+			"tags":["docs"],"meta":{"title":"ABC"}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			im := testImporter(t)
+			summary := importString(t, im, tt.input, Options{Format: Shlink, Domain: "short.io", HistoricalClicks: true})
+			if summary.Links.Created != 1 {
+				t.Fatalf("summary = %+v", summary)
+			}
+			l := mustGetLink(t, im.DB, "abc")
+			if l.Title != "ABC" || l.Tags != "docs" || l.CreatedAt.Year() != 2022 {
+				t.Errorf("link = %+v", l)
+			}
+			if n := historicalClicks(t, im.DB, l.ID); n != 9 {
+				t.Errorf("historical clicks = %d, want 9", n)
+			}
+		})
+	}
+}
+
+func TestImport_Bookmarks(t *testing.T) {
+	input := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<TITLE>Bookmarks</TITLE>
+<DL><p>
+    <DT><H3 ADD_DATE="1600000000">Reading</H3>
+    <DL><p>
+        <DT><A HREF="https://example.com/a?x=1&amp;y=2" ADD_DATE="1600000000" TAGS="go,blog">Go &amp; you</A>
+        <DT><A HREF="javascript:alert(1)">Bookmarklet</A>
+        <DT><A HREF="https://example.com/b" ADD_DATE="1600000000000000">Chrome time</A>
+        <DT><A HREF="https://example.com/c"
+               ADD_DATE="1600000000000">Split
+            over lines</A>
+    </DL><p>
+</DL><p>
+`
+	im := testImporter(t)
+	summary := importString(t, im, input, Options{Format: Bookmarks, Domain: "short.io"})
+	if summary.Links.Created != 3 || summary.Links.Failed != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if len(summary.Errors) != 1 || summary.Errors[0].Line != 7 {
+		t.Errorf("errors = %+v", summary.Errors)
+	}
+
+	l, err := models.FindLinkByDestination(im.DB, "short.io", "https://example.com/a?x=1&y=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Title != "Go & you" || l.Tags != "go,blog" || l.CreatedAt.Unix() != 1600000000 {
+		t.Errorf("link = %+v", l)
+	}
+	b, err := models.FindLinkByDestination(im.DB, "short.io", "https://example.com/b")
+	if err != nil || b.CreatedAt.Unix() != 1600000000 {
+		t.Errorf("b = %+v, err = %v", b, err)
+	}
+	c, err := models.FindLinkByDestination(im.DB, "short.io", "https://example.com/c")
+	if err != nil || c.CreatedAt.Unix() != 1600000000 || c.Title != "Split over lines" {
+		t.Errorf("c = %+v, err = %v", c, err)
+	}
+
+	// Bookmarks have no slugs, so re-runs match on destination
+	summary = importString(t, im, input, Options{Format: Bookmarks, Domain: "short.io"})
+	if summary.Links.Created != 0 || summary.Links.Unchanged != 3 {
+		t.Errorf("second import summary = %+v", summary)
+	}
+}
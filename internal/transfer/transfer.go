@@ -0,0 +1,113 @@
+// Package transfer moves links and clicks in and out of the database as CSV
+// or newline-delimited JSON, for backups, spreadsheets and moving between
+// instances. It also imports exports from other shorteners and browsers.
+package transfer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format is a serialization for exports and imports.
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+
+	// Formats written by other tools, which can only be imported.
+	Bitly     Format = "bitly"
+	YOURLS    Format = "yourls"
+	Shlink    Format = "shlink"
+	Bookmarks Format = "bookmarks"
+)
+
+// ParseFormat accepts an export format name, defaulting to CSV when empty.
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(s))) {
+	case CSV, "":
+		return CSV, nil
+	case NDJSON, "jsonl", "json":
+		return NDJSON, nil
+	}
+	return "", fmt.Errorf("unknown format %q: use csv or ndjson", s)
+}
+
+// ParseImportFormat accepts the export formats plus those of other tools.
+func ParseImportFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case Bitly, YOURLS, Shlink, Bookmarks:
+		return f, nil
+	case "netscape", "html":
+		return Bookmarks, nil
+	}
+	if f, err := ParseFormat(s); err == nil {
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q: use csv, ndjson, bitly, yourls, shlink or bookmarks", s)
+}
+
+// foreign reports whether f comes from another tool rather than an export.
+func (f Format) foreign() bool {
+	return f != CSV && f != NDJSON
+}
+
+// ContentType is the MIME type for the format.
+func (f Format) ContentType() string {
+	if f == NDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Filename is the download name for an export taken at t.
+func (f Format) Filename(t time.Time) string {
+	return "dubly-export-" + t.Format("2006-01-02") + "." + string(f)
+}
+
+const (
+	typeLink  = "link"
+	typeClick = "click"
+)
+
+// record is one exported line: a link, or a click on the link identified by
+// Domain and Slug. IDs are left out so files can move between instances.
+type record struct {
+	Type   string `json:"type"`
+	Domain string `json:"domain"`
+	Slug   string `json:"slug"`
+
+	Destination string     `json:"destination,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Notes       string     `json:"notes,omitempty"`
+	IsActive    *bool      `json:"is_active,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	// HistoricalClicks is a click total from before the link was imported.
+	HistoricalClicks int `json:"historical_clicks,omitempty"`
+
+	ClickedAt      *time.Time `json:"clicked_at,omitempty"`
+	IP             string     `json:"ip,omitempty"`
+	UserAgent      string     `json:"user_agent,omitempty"`
+	Referer        string     `json:"referer,omitempty"`
+	RefererDomain  string     `json:"referer_domain,omitempty"`
+	Country        string     `json:"country,omitempty"`
+	City           string     `json:"city,omitempty"`
+	Region         string     `json:"region,omitempty"`
+	Latitude       float64    `json:"latitude,omitempty"`
+	Longitude      float64    `json:"longitude,omitempty"`
+	Browser        string     `json:"browser,omitempty"`
+	BrowserVersion string     `json:"browser_version,omitempty"`
+	OS             string     `json:"os,omitempty"`
+	DeviceType     string     `json:"device_type,omitempty"`
+}
+
+// csvColumns is the CSV header. Link rows leave the click columns empty
+// and click rows fill only domain and slug of the link columns.
+var csvColumns = []string{
+	"type", "domain", "slug", "destination", "title", "tags", "notes", "is_active", "created_at", "updated_at", "historical_clicks",
+	"clicked_at", "ip", "user_agent", "referer", "referer_domain", "country", "city", "region",
+	"latitude", "longitude", "browser", "browser_version", "os", "device_type",
+}
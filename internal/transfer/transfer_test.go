@@ -0,0 +1,177 @@
+package transfer
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/db"
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func testImporter(t *testing.T) *Importer {
+	return &Importer{DB: testDB(t), Cfg: &config.Config{Domains: []string{"short.io"}}}
+}
+
+// seed creates two links, one disabled, with a click on the first.
+func seed(t *testing.T, d *sql.DB) {
+	t.Helper()
+	a := &models.Link{Slug: "a", Domain: "short.io", Destination: "https://example.com/a", Title: "A, with comma", Tags: "docs,launch"}
+	b := &models.Link{Slug: "b", Domain: "short.io", Destination: "https://example.com/b"}
+	for _, l := range []*models.Link{a, b} {
+		if err := models.CreateLink(d, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := models.SoftDeleteLink(d, b.ID); err != nil {
+		t.Fatal(err)
+	}
+	clicked := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := models.BatchInsertClicks(d, []models.Click{{LinkID: a.ID, ClickedAt: clicked, Country: "US", Latitude: 37.5}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	for _, f := range []Format{CSV, NDJSON} {
+		t.Run(string(f), func(t *testing.T) {
+			src := testDB(t)
+			seed(t, src)
+			var buf bytes.Buffer
+			if err := Export(src, &buf, f, true); err != nil {
+				t.Fatal(err)
+			}
+
+			im := testImporter(t)
+			summary, err := im.Import(bytes.NewReader(buf.Bytes()), Options{Format: f, Conflict: Skip})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Links.Created != 2 || summary.Clicks.Imported != 1 || len(summary.Errors) != 0 {
+				t.Fatalf("summary = %+v", summary)
+			}
+
+			a, err := models.GetLinkBySlugAndDomain(im.DB, "a", "short.io")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Title != "A, with comma" || a.Tags != "docs,launch" {
+				t.Errorf("link a = %+v", a)
+			}
+			b, _ := models.GetLinkBySlugAndDomain(im.DB, "b", "short.io")
+			if b.IsActive {
+				t.Error("disabled link imported as active")
+			}
+			countries, _ := models.TopValues(im.DB, models.LinkClicks(a.ID), models.DimensionCountry, 10)
+			if len(countries) != 1 || countries[0].Value != "US" {
+				t.Errorf("countries = %+v", countries)
+			}
+
+			// Importing the same file again changes nothing, even with rename
+			summary, err = im.Import(bytes.NewReader(buf.Bytes()), Options{Format: f, Conflict: Rename})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Links.Unchanged != 2 || summary.Links.Renamed != 0 || len(summary.Conflicts) != 0 ||
+				summary.Clicks.Imported != 0 || summary.Clicks.Skipped != 1 {
+				t.Errorf("second import summary = %+v", summary)
+			}
+		})
+	}
+}
+
+func TestImport_ConflictPolicies(t *testing.T) {
+	input := "domain,slug,destination\nshort.io,taken,https://example.com/new\n"
+
+	im := testImporter(t)
+	if err := models.CreateLink(im.DB, &models.Link{Slug: "taken", Domain: "short.io", Destination: "https://example.com/old"}); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := im.Import(strings.NewReader(input), Options{Format: CSV, Conflict: Rename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Links.Renamed != 1 || summary.Links.Created != 0 || len(summary.Conflicts) != 1 {
+		t.Fatalf("rename summary = %+v", summary)
+	}
+	renamed := summary.Conflicts[0].NewSlug
+	if renamed != "taken-2" {
+		t.Errorf("new slug = %q, want taken-2", renamed)
+	}
+
+	summary, err = im.Import(strings.NewReader(input), Options{Format: CSV, Conflict: Overwrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Links.Overwritten != 1 {
+		t.Fatalf("overwrite summary = %+v", summary)
+	}
+	l, _ := models.GetLinkBySlugAndDomain(im.DB, "taken", "short.io")
+	if l.Destination != "https://example.com/new" {
+		t.Errorf("destination = %q, want overwritten", l.Destination)
+	}
+}
+
+func TestImport_DryRunAndLineErrors(t *testing.T) {
+	input := strings.Join([]string{
+		`{"domain":"short.io","slug":"ok","destination":"https://example.com"}`,
+		`{"domain":"other.io","slug":"x","destination":"https://example.com"}`,
+		`not json`,
+		``,
+		`{"domain":"short.io","slug":"no-dest"}`,
+		`{"type":"widget"}`,
+	}, "\n")
+
+	im := testImporter(t)
+	summary, err := im.Import(strings.NewReader(input), Options{Format: NDJSON, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !summary.DryRun || summary.Links.Created != 1 || summary.Links.Failed != 4 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	lines := []int{}
+	for _, e := range summary.Errors {
+		lines = append(lines, e.Line)
+	}
+	if len(lines) != 4 || lines[0] != 2 || lines[1] != 3 || lines[2] != 5 || lines[3] != 6 {
+		t.Errorf("error lines = %v", lines)
+	}
+	if _, err := models.GetLinkBySlugAndDomain(im.DB, "ok", "short.io"); err != sql.ErrNoRows {
+		t.Errorf("dry run wrote a link: err = %v", err)
+	}
+}
+
+func TestImport_NDJSONLineTooLong(t *testing.T) {
+	input := `{"domain":"short.io","slug":"ok","destination":"https://example.com"}` + "\n" +
+		`{"domain":"short.io","slug":"big","destination":"https://example.com/?q=` + strings.Repeat("a", 1<<20) + `"}`
+
+	im := testImporter(t)
+	_, err := im.Import(strings.NewReader(input), Options{Format: NDJSON})
+	var inputErr *InputError
+	if !errors.As(err, &inputErr) || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("err = %v, want an InputError naming line 2", err)
+	}
+}
+
+func TestImport_CSVNeedsDestinationColumn(t *testing.T) {
+	im := testImporter(t)
+	if _, err := im.Import(strings.NewReader("slug,url\na,https://example.com\n"), Options{Format: CSV}); err == nil {
+		t.Error("import without a destination column succeeded")
+	}
+}
@@ -0,0 +1,92 @@
+// Package urlnorm normalizes destination URLs so that links pointing at the
+// same page can be found however the URL was written.
+package urlnorm
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// Normalize returns the form of rawURL used to match duplicate
+// destinations. The scheme and host are lowercased, with internationalized
+// hosts in punycode and default ports dropped; an empty path becomes "/";
+// percent-encoding is made consistent; and query parameters are sorted by
+// name, keeping the order of repeated ones. Paths keep their case and
+// trailing slashes, and fragments are kept, since servers may tell those
+// apart. Strings that don't parse as absolute URLs are only trimmed.
+func Normalize(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Opaque != "" {
+		return u.String()
+	}
+
+	host, port := u.Hostname(), u.Port()
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		host = ascii
+	}
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]" // IPv6
+	default:
+		u.Host = host
+	}
+
+	if u.Path == "" && u.Host != "" {
+		u.Path = "/"
+	}
+	u.RawPath = ""
+	u.RawQuery = sortQuery(u.RawQuery)
+	u.ForceQuery = false
+	u.RawFragment = ""
+	return u.String()
+}
+
+// sortQuery re-encodes a raw query with its parameters sorted by name.
+// The sort is stable, so repeated parameters keep their order.
+func sortQuery(raw string) string {
+	type param struct{ key, value string }
+	var params []param
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		params = append(params, param{unescape(key), unescape(value)})
+	}
+	sort.SliceStable(params, func(i, j int) bool { return params[i].key < params[j].key })
+
+	var b strings.Builder
+	for i, p := range params {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(url.QueryEscape(p.key))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(p.value))
+	}
+	return b.String()
+}
+
+func unescape(s string) string {
+	if u, err := url.QueryUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
@@ -0,0 +1,36 @@
+package urlnorm
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com/a", "https://example.com/a"},
+		{"  HTTPS://Example.COM/a  ", "https://example.com/a"},
+		{"https://example.com", "https://example.com/"},
+		{"https://example.com:443/a", "https://example.com/a"},
+		{"http://example.com:80/a", "http://example.com/a"},
+		{"http://example.com:8080/a", "http://example.com:8080/a"},
+		{"https://example.com./a", "https://example.com/a"},
+		{"https://bücher.de/", "https://xn--bcher-kva.de/"},
+		{"https://example.com/A/b/", "https://example.com/A/b/"},
+		{"https://example.com/%7Euser", "https://example.com/~user"},
+		{"https://example.com/a?b=2&a=1", "https://example.com/a?a=1&b=2"},
+		{"https://example.com/a?x=2&a=1&x=1", "https://example.com/a?a=1&x=2&x=1"},
+		{"https://example.com/a?q=hello+world", "https://example.com/a?q=hello+world"},
+		{"https://example.com/a?q=hello%20world", "https://example.com/a?q=hello+world"},
+		{"https://example.com/a?", "https://example.com/a"},
+		{"https://example.com/a?&&b=1", "https://example.com/a?b=1"},
+		{"https://example.com/a#Section", "https://example.com/a#Section"},
+		{"https://[::1]:443/a", "https://[::1]/a"},
+		{"https://[::1]:8443/a", "https://[::1]:8443/a"},
+		{"MAILTO:someone@example.com", "mailto:someone@example.com"},
+		{"example.com/a", "example.com/a"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
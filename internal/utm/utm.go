@@ -0,0 +1,72 @@
+// Package utm reads and writes the utm_* query parameters on destination
+// URLs.
+package utm
+
+import "net/url"
+
+// Keys are the UTM parameters managed by the link forms, in display order.
+var Keys = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"}
+
+// Apply strips existing UTM params from rawURL then appends non-empty
+// values. Returns rawURL unchanged if it cannot be parsed.
+func Apply(rawURL string, values map[string]string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	for _, k := range Keys {
+		q.Del(k)
+	}
+	for _, k := range Keys {
+		if v := values[k]; v != "" {
+			q.Set(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Extract returns a map of utm_* key → value parsed from rawURL.
+// Missing params are empty strings.
+func Extract(rawURL string) map[string]string {
+	result := make(map[string]string, len(Keys))
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		for _, k := range Keys {
+			result[k] = ""
+		}
+		return result
+	}
+	q := u.Query()
+	for _, k := range Keys {
+		result[k] = q.Get(k)
+	}
+	return result
+}
+
+// Strip returns rawURL with all utm_* query params removed.
+func Strip(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	for _, k := range Keys {
+		q.Del(k)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// WithDefaults returns values with every empty key filled from defaults.
+func WithDefaults(values, defaults map[string]string) map[string]string {
+	out := make(map[string]string, len(Keys))
+	for _, k := range Keys {
+		out[k] = values[k]
+		if out[k] == "" {
+			out[k] = defaults[k]
+		}
+	}
+	return out
+}
@@ -0,0 +1,24 @@
+package utm
+
+import "testing"
+
+func TestApply_ReplacesExisting(t *testing.T) {
+	got := Apply("https://example.com/p?utm_source=old&x=1", map[string]string{"utm_source": "news", "utm_medium": "email"})
+	want := "https://example.com/p?utm_medium=email&utm_source=news&x=1"
+	if got != want {
+		t.Errorf("Apply = %q, want %q", got, want)
+	}
+	if Strip(got) != "https://example.com/p?x=1" {
+		t.Errorf("Strip = %q", Strip(got))
+	}
+	if v := Extract(got); v["utm_source"] != "news" || v["utm_term"] != "" {
+		t.Errorf("Extract = %v", v)
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	got := WithDefaults(map[string]string{"utm_source": "twitter"}, map[string]string{"utm_source": "news", "utm_campaign": "spring"})
+	if got["utm_source"] != "twitter" || got["utm_campaign"] != "spring" || got["utm_medium"] != "" {
+		t.Errorf("WithDefaults = %v", got)
+	}
+}
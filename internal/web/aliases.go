@@ -0,0 +1,104 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+// LinkAliasCreate adds another slug for a link from the edit page. Errors
+// are reported as a flash since the alias form sits below the link form.
+func (h *AdminHandler) LinkAliasCreate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	link := &models.Link{ID: id}
+	if err := models.GetLinkByID(h.db, link); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	editURL := "/admin/links/" + strconv.FormatInt(id, 10) + "/edit"
+
+	r.ParseForm()
+	domain := link.Domain
+	if d := r.FormValue("alias_domain"); d != "" {
+		domain = config.CanonicalDomain(d)
+		if !h.cfg.IsDomainAllowed(domain) {
+			setFlash(w, "error", "Domain is not configured")
+			http.Redirect(w, r, editURL, http.StatusFound)
+			return
+		}
+	}
+	s := h.cfg.NormalizeSlug(domain, r.FormValue("alias_slug"))
+	if s == "" {
+		setFlash(w, "error", "Alias slug is required")
+		http.Redirect(w, r, editURL, http.StatusFound)
+		return
+	}
+	if err := h.cfg.SlugValidator().Validate(s); err != nil {
+		setFlash(w, "error", sentence(err.Error()))
+		http.Redirect(w, r, editURL, http.StatusFound)
+		return
+	}
+
+	slugExists := models.SlugExists
+	if h.cfg.SettingsFor(domain).CaseInsensitive {
+		slugExists = models.SlugExistsFold
+	}
+	exists, err := slugExists(h.db, s, domain)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if exists {
+		setFlash(w, "error", "This slug already exists for this domain")
+		http.Redirect(w, r, editURL, http.StatusFound)
+		return
+	}
+
+	alias := &models.LinkAlias{LinkID: id, Slug: s, Domain: domain}
+	if err := models.CreateLinkAlias(h.db, alias); err != nil {
+		// Another request may have taken the slug since the check above
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			setFlash(w, "error", "This slug already exists for this domain")
+			http.Redirect(w, r, editURL, http.StatusFound)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	setFlash(w, "success", "Alias added")
+	http.Redirect(w, r, editURL, http.StatusFound)
+}
+
+// LinkAliasDelete removes one of a link's aliases.
+func (h *AdminHandler) LinkAliasDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	aliasID, err := strconv.ParseInt(chi.URLParam(r, "aliasID"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid alias id", http.StatusBadRequest)
+		return
+	}
+
+	alias, err := models.DeleteLinkAlias(h.db, id, aliasID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	h.cache.Invalidate(alias.Domain, alias.Slug)
+
+	setFlash(w, "success", "Alias removed")
+	http.Redirect(w, r, "/admin/links/"+strconv.FormatInt(id, 10)+"/edit", http.StatusFound)
+}
@@ -0,0 +1,272 @@
+package web
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/utm"
+)
+
+type CampaignsData struct {
+	PageData
+	Campaigns []models.CampaignSummary
+	Today     time.Time
+	Errors    map[string]string
+	Values    map[string]string
+}
+
+type CampaignData struct {
+	PageData
+	Campaign     models.Campaign
+	Status       string
+	Links        []models.LinkWithClicks
+	Clicks       int
+	TopReferrers []models.ValueCount
+	TopCountries []models.ValueCount
+	TopDevices   []models.ValueCount
+	Errors       map[string]string
+	Values       map[string]string
+}
+
+// campaignFormValues reads the campaign form fields shared by create and edit.
+func campaignFormValues(r *http.Request) map[string]string {
+	r.ParseForm()
+	values := map[string]string{
+		"name":      r.FormValue("name"),
+		"starts_on": r.FormValue("starts_on"),
+		"ends_on":   r.FormValue("ends_on"),
+	}
+	for _, k := range utm.Keys {
+		values[k] = r.FormValue(k)
+	}
+	return values
+}
+
+func campaignValues(c *models.Campaign) map[string]string {
+	values := c.UTM()
+	values["name"] = c.Name
+	values["starts_on"] = c.StartsOn
+	values["ends_on"] = c.EndsOn
+	return values
+}
+
+func applyCampaignValues(c *models.Campaign, values map[string]string) {
+	c.Name = values["name"]
+	c.StartsOn = values["starts_on"]
+	c.EndsOn = values["ends_on"]
+	c.SetUTM(values)
+}
+
+func (h *AdminHandler) CampaignsPage(w http.ResponseWriter, r *http.Request) {
+	h.renderCampaigns(w, r, map[string]string{}, map[string]string{})
+}
+
+func (h *AdminHandler) renderCampaigns(w http.ResponseWriter, r *http.Request, errors, values map[string]string) {
+	campaigns, err := models.ListCampaigns(h.db)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.templates.Render(w, "templates/campaigns.html", CampaignsData{
+		PageData:  h.pageData(w, r),
+		Campaigns: campaigns,
+		Today:     time.Now().UTC(),
+		Errors:    errors,
+		Values:    values,
+	})
+}
+
+func (h *AdminHandler) CampaignCreate(w http.ResponseWriter, r *http.Request) {
+	values := campaignFormValues(r)
+	c := &models.Campaign{}
+	applyCampaignValues(c, values)
+	if err := c.Validate(); err != nil {
+		h.renderCampaigns(w, r, map[string]string{"campaign": sentence(err.Error())}, values)
+		return
+	}
+	if err := models.CreateCampaign(h.db, c); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			h.renderCampaigns(w, r, map[string]string{"campaign": "A campaign with this name already exists"}, values)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	setFlash(w, "success", "Campaign created")
+	http.Redirect(w, r, campaignPath(c.ID), http.StatusFound)
+}
+
+func (h *AdminHandler) CampaignPage(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.campaignFromURL(w, r)
+	if !ok {
+		return
+	}
+	h.renderCampaign(w, r, c, map[string]string{}, campaignValues(c))
+}
+
+func (h *AdminHandler) renderCampaign(w http.ResponseWriter, r *http.Request, c *models.Campaign, errors, values map[string]string) {
+	links, err := models.CampaignLinks(h.db, c)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	for i := range links {
+		links[i].Link.FillShortURL(h.cfg.ShortURLBase)
+	}
+	clicks, _ := models.ClicksForCampaign(h.db, c)
+	scope := models.CampaignClicks(c)
+	topReferrers, _ := models.TopValues(h.db, scope, models.DimensionReferrer, 5)
+	topCountries, _ := models.TopValues(h.db, scope, models.DimensionCountry, 5)
+	topDevices, _ := models.TopValues(h.db, scope, models.DimensionDevice, 5)
+
+	h.templates.Render(w, "templates/campaign.html", CampaignData{
+		PageData:     h.pageData(w, r),
+		Campaign:     *c,
+		Status:       c.Status(time.Now().UTC()),
+		Links:        links,
+		Clicks:       clicks,
+		TopReferrers: topReferrers,
+		TopCountries: topCountries,
+		TopDevices:   topDevices,
+		Errors:       errors,
+		Values:       values,
+	})
+}
+
+func (h *AdminHandler) CampaignUpdate(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.campaignFromURL(w, r)
+	if !ok {
+		return
+	}
+	values := campaignFormValues(r)
+	stored := *c
+	applyCampaignValues(c, values)
+	if err := c.Validate(); err != nil {
+		h.renderCampaign(w, r, &stored, map[string]string{"campaign": sentence(err.Error())}, values)
+		return
+	}
+	if err := models.UpdateCampaign(h.db, c); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			h.renderCampaign(w, r, &stored, map[string]string{"campaign": "A campaign with this name already exists"}, values)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	setFlash(w, "success", "Campaign updated")
+	http.Redirect(w, r, campaignPath(c.ID), http.StatusFound)
+}
+
+func (h *AdminHandler) CampaignDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if err := models.DeleteCampaign(h.db, id); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	setFlash(w, "success", "Campaign deleted")
+	http.Redirect(w, r, "/admin/campaigns", http.StatusFound)
+}
+
+// CampaignAttach adds a link, given by short URL or ID, to the campaign.
+func (h *AdminHandler) CampaignAttach(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.campaignFromURL(w, r)
+	if !ok {
+		return
+	}
+	r.ParseForm()
+	link, err := h.findLink(r.FormValue("link"))
+	if err != nil {
+		setFlash(w, "error", "No link matches "+strconv.Quote(strings.TrimSpace(r.FormValue("link"))))
+		http.Redirect(w, r, campaignPath(c.ID), http.StatusFound)
+		return
+	}
+
+	attached, err := models.AttachLinks(h.db, c, []int64{link.ID})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.cache.InvalidateLinks(h.db, attached)
+
+	setFlash(w, "success", "Link added to campaign")
+	http.Redirect(w, r, campaignPath(c.ID), http.StatusFound)
+}
+
+func (h *AdminHandler) CampaignDetach(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	linkID, err := strconv.ParseInt(chi.URLParam(r, "linkID"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid link id", http.StatusBadRequest)
+		return
+	}
+	if err := models.DetachLink(h.db, id, linkID); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	setFlash(w, "success", "Link removed from campaign")
+	http.Redirect(w, r, campaignPath(id), http.StatusFound)
+}
+
+func (h *AdminHandler) campaignFromURL(w http.ResponseWriter, r *http.Request) (*models.Campaign, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+	c := &models.Campaign{ID: id}
+	if err := models.GetCampaign(h.db, c); err != nil {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return c, true
+}
+
+// findLink resolves a link from an ID or a short URL such as
+// "short.io/launch" or "https://short.io/launch".
+func (h *AdminHandler) findLink(input string) (*models.Link, error) {
+	input = strings.TrimSpace(input)
+	if id, err := strconv.ParseInt(input, 10, 64); err == nil {
+		l := &models.Link{ID: id}
+		if err := models.GetLinkByID(h.db, l); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+
+	input = strings.TrimPrefix(strings.TrimPrefix(input, "https://"), "http://")
+	host, path, ok := strings.Cut(input, "/")
+	if !ok || path == "" {
+		return nil, sql.ErrNoRows
+	}
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	domain := config.CanonicalDomain(host)
+	s := h.cfg.NormalizeSlug(domain, path)
+	if h.cfg.SettingsFor(domain).CaseInsensitive {
+		return models.GetLinkBySlugAndDomainFold(h.db, s, domain)
+	}
+	return models.GetLinkBySlugAndDomain(h.db, s, domain)
+}
+
+func campaignPath(id int64) string {
+	return "/admin/campaigns/" + strconv.FormatInt(id, 10)
+}
@@ -1,87 +1,177 @@
 package web
 
 import (
-	"net"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"sort"
 	"strings"
-	"sync"
 	"time"
-)
-
-type dnsCache struct {
-	results   map[string][]string // domain → resolved IPs
-	checkedAt time.Time
-	mu        sync.RWMutex
-}
 
-func newDNSCache() *dnsCache {
-	return &dnsCache{results: make(map[string][]string)}
-}
-
-func (c *dnsCache) refresh(domains []string) {
-	results := make(map[string][]string, len(domains))
-	for _, d := range domains {
-		ips, err := net.LookupHost(d)
-		if err != nil {
-			results[d] = nil
-		} else {
-			results[d] = ips
-		}
-	}
-	c.mu.Lock()
-	c.results = results
-	c.checkedAt = time.Now()
-	c.mu.Unlock()
-}
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/models"
+)
 
-func (c *dnsCache) get() (map[string][]string, time.Time) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.results, c.checkedAt
+type domainEntry struct {
+	Name         string
+	IPs          string
+	Check        *models.DomainCheck
+	DaysLeft     int
+	ExpiringSoon bool
+	Expired      bool
 }
 
-func (c *dnsCache) isStale() bool {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return len(c.results) == 0 || time.Since(c.checkedAt) > 12*time.Hour
+type aliasEntry struct {
+	Alias  string
+	Target string
 }
 
-type domainEntry struct {
-	Name string
-	IPs  string
+// domainMoveView is the preview shown before links are moved between domains.
+type domainMoveView struct {
+	From  string
+	To    string
+	Plan  *models.DomainMove
+	Error string
 }
 
 type DomainsData struct {
 	PageData
-	Domains   []domainEntry
-	CheckedAt time.Time
+	Domains     []domainEntry
+	Aliases     []aliasEntry
+	CheckedAt   time.Time
+	WarnDays    int
+	LinkDomains []models.DomainLinkCount
+	MoveTargets []string
+	Move        *domainMoveView
 }
 
 func (h *AdminHandler) DomainsPage(w http.ResponseWriter, r *http.Request) {
-	if h.dns.isStale() {
-		h.dns.refresh(h.cfg.Domains)
+	data, err := h.domainsData(w, r)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
 	}
+	h.templates.Render(w, "templates/domains.html", data)
+}
 
-	results, checkedAt := h.dns.get()
+func (h *AdminHandler) domainsData(w http.ResponseWriter, r *http.Request) (DomainsData, error) {
+	checks, err := models.ListDomainChecks(h.db)
+	if err != nil {
+		return DomainsData{}, err
+	}
+	linkDomains, err := models.CountLinksByDomain(h.db)
+	if err != nil {
+		return DomainsData{}, err
+	}
 
+	var checkedAt time.Time
 	entries := make([]domainEntry, 0, len(h.cfg.Domains))
 	for _, d := range h.cfg.Domains {
-		ips := results[d]
-		entries = append(entries, domainEntry{
-			Name: d,
-			IPs:  strings.Join(ips, ", "),
-		})
+		entry := domainEntry{Name: d}
+		if check := checks[d]; check != nil {
+			entry.Check = check
+			entry.IPs = strings.Join(check.IPs, ", ")
+			entry.ExpiringSoon = check.ExpiresWithin(h.cfg.CertWarnWindow)
+			if check.TLSNotAfter != nil {
+				entry.DaysLeft = int(time.Until(*check.TLSNotAfter).Hours() / 24)
+				entry.Expired = time.Now().After(*check.TLSNotAfter)
+			}
+			if check.CheckedAt.After(checkedAt) {
+				checkedAt = check.CheckedAt
+			}
+		}
+		entries = append(entries, entry)
 	}
 
-	h.templates.Render(w, "templates/domains.html", DomainsData{
-		PageData:  h.pageData(w, r),
-		Domains:   entries,
-		CheckedAt: checkedAt,
-	})
+	aliases := make([]aliasEntry, 0, len(h.cfg.DomainAliases))
+	for alias, target := range h.cfg.DomainAliases {
+		aliases = append(aliases, aliasEntry{Alias: alias, Target: target})
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Alias < aliases[j].Alias })
+
+	var targets []string
+	for _, d := range h.cfg.Domains {
+		if !config.IsWildcard(d) {
+			targets = append(targets, d)
+		}
+	}
+
+	return DomainsData{
+		PageData:    h.pageData(w, r),
+		Domains:     entries,
+		Aliases:     aliases,
+		CheckedAt:   checkedAt,
+		WarnDays:    int(h.cfg.CertWarnWindow.Hours() / 24),
+		LinkDomains: linkDomains,
+		MoveTargets: targets,
+	}, nil
 }
 
 func (h *AdminHandler) DomainsRefresh(w http.ResponseWriter, r *http.Request) {
-	h.dns.refresh(h.cfg.Domains)
-	setFlash(w, "success", "DNS records refreshed")
+	if h.domains != nil {
+		h.domains.Refresh()
+	}
+	setFlash(w, "success", "Domain checks refreshed")
+	http.Redirect(w, r, "/admin/domains", http.StatusFound)
+}
+
+// DomainsMove previews or performs a bulk move of every link from one domain
+// to another. The preview lists collisions; the move only runs when there
+// are none.
+func (h *AdminHandler) DomainsMove(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	from := config.CanonicalDomain(r.FormValue("from"))
+	to := config.CanonicalDomain(r.FormValue("to"))
+
+	if from == "" || to == "" || from == to {
+		setFlash(w, "error", "Pick two different domains to move links between")
+		http.Redirect(w, r, "/admin/domains", http.StatusFound)
+		return
+	}
+	if !h.cfg.IsDomainAllowed(to) {
+		setFlash(w, "error", "Domain not allowed: "+to)
+		http.Redirect(w, r, "/admin/domains", http.StatusFound)
+		return
+	}
+
+	plan, err := models.PlanDomainMove(h.db, from, to, h.cfg.SettingsFor(to).CaseInsensitive)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.FormValue("action") != "move" || len(plan.Collisions) > 0 {
+		data, err := h.domainsData(w, r)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		data.Move = &domainMoveView{From: from, To: to, Plan: plan}
+		if r.FormValue("action") == "move" {
+			data.Move.Error = "Nothing was moved. Resolve the collisions below first."
+		}
+		h.templates.Render(w, "templates/domains.html", data)
+		return
+	}
+
+	if err := models.ApplyDomainMove(h.db, plan); err != nil {
+		// Slugs taken on the target since the plan was made collide too
+		if errors.Is(err, models.ErrMoveCollisions) || strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			setFlash(w, "error", "Nothing was moved: links on "+to+" changed since the preview. Preview the move again.")
+		} else {
+			log.Printf("domain move: %v", err)
+			setFlash(w, "error", "Move failed. Nothing was moved.")
+		}
+		http.Redirect(w, r, "/admin/domains", http.StatusFound)
+		return
+	}
+	for _, l := range plan.Links {
+		h.cache.Invalidate(from, l.Slug)
+		h.cache.Invalidate(to, plan.NewSlug(l))
+	}
+	h.cache.InvalidateAliases(plan.Aliases)
+
+	setFlash(w, "success", fmt.Sprintf("Moved %d links from %s to %s", len(plan.Links), from, to))
 	http.Redirect(w, r, "/admin/domains", http.StatusFound)
 }
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/scmmishra/dubly/internal/models"
+)
+
+type DuplicatesData struct {
+	PageData
+	Groups []DuplicateGroupView
+}
+
+// DuplicateGroupView is a group of duplicate links with the one suggested
+// for keeping: the most clicked, or the oldest on a tie.
+type DuplicateGroupView struct {
+	models.DuplicateGroup
+	KeepID int64
+}
+
+// DuplicatesPage lists active links that share a domain and destination.
+func (h *AdminHandler) DuplicatesPage(w http.ResponseWriter, r *http.Request) {
+	groups, err := models.FindDuplicateLinks(h.db)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	views := make([]DuplicateGroupView, len(groups))
+	for i, g := range groups {
+		for j := range g.Links {
+			g.Links[j].FillShortURL(h.cfg.ShortURLBase)
+		}
+		keep := g.Links[0]
+		for _, l := range g.Links[1:] {
+			if l.Clicks > keep.Clicks {
+				keep = l
+			}
+		}
+		views[i] = DuplicateGroupView{DuplicateGroup: g, KeepID: keep.ID}
+	}
+	h.templates.Render(w, "templates/duplicates.html", DuplicatesData{
+		PageData: h.pageData(w, r),
+		Groups:   views,
+	})
+}
+
+// DuplicatesMerge merges a group of duplicates into the link chosen to keep.
+func (h *AdminHandler) DuplicatesMerge(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	keepID, err := strconv.ParseInt(r.FormValue("keep"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var ids []int64
+	for _, v := range r.Form["ids"] {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	merged, err := models.MergeLinks(h.db, keepID, ids)
+	if err != nil {
+		if errors.Is(err, models.ErrNotDuplicate) {
+			setFlash(w, "error", "Those links changed since the report was loaded, so nothing was merged. Check the report again.")
+			http.Redirect(w, r, "/admin/duplicates", http.StatusFound)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// Cached copies of the merged links and their aliases name links that
+	// no longer exist
+	for _, l := range merged {
+		h.cache.Invalidate(l.Domain, l.Slug)
+	}
+	if aliases, err := models.ListLinkAliases(h.db, keepID); err == nil {
+		h.cache.InvalidateAliases(aliases)
+	}
+
+	keep := &models.Link{ID: keepID}
+	if err := models.GetLinkByID(h.db, keep); err == nil && len(merged) > 0 {
+		setFlash(w, "success", fmt.Sprintf("Merged %d links into /%s", len(merged), keep.Slug))
+	}
+	http.Redirect(w, r, "/admin/duplicates", http.StatusFound)
+}
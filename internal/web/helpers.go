@@ -6,6 +6,10 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/tags"
+	"github.com/scmmishra/dubly/internal/utm"
 )
 
 func templateFuncMap() template.FuncMap {
@@ -19,10 +23,14 @@ func templateFuncMap() template.FuncMap {
 		"lower":       strings.ToLower,
 		"title":       titleCase,
 		"countryFlag": countryFlag,
-		"spaceTags":   func(s string) string { return strings.ReplaceAll(s, ",", ", ") },
+		"splitTags":   tags.Parse,
+		"pathEscape":  url.PathEscape,
 		"hostname":    hostname,
+		"displayURL":  displayURL,
+		"displayHost": config.DisplayDomain,
+		"isWildcard":  config.IsWildcard,
 		"hasUTM": func(values map[string]string) bool {
-			for _, k := range []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"} {
+			for _, k := range utm.Keys {
 				if values[k] != "" {
 					return true
 				}
@@ -96,6 +104,14 @@ func titleCase(s string) string {
 	return strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
 }
 
+// sentence capitalizes the first letter of an error message for display.
+func sentence(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
 func seq(start, end int) []int {
 	if start > end {
 		return nil
@@ -107,6 +123,21 @@ func seq(start, end int) []int {
 	return result
 }
 
+// displayURL renders an encoded short URL the way people read it: IDN hosts
+// in Unicode and the path percent-decoded. Links and copy buttons keep the
+// encoded form.
+func displayURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	host := config.DisplayDomain(u.Hostname())
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	return u.Scheme + "://" + host + u.Path
+}
+
 func hostname(rawURL string) string {
 	u, err := url.Parse(rawURL)
 	if err != nil {
@@ -18,10 +18,12 @@ type AnalyticsData struct {
 	ClicksPrevWeek int
 	WeekChange     int  // percentage change, e.g. +25 or -10
 	WeekChangeUp   bool // true if this week >= prev week
-	TopReferrers   []models.ReferrerCount
-	TopCountries   []models.CountryCount
-	TopBrowsers    []models.BrowserCount
-	TopDevices     []models.DeviceCount
+	TopReferrers   []models.ValueCount
+	TopCountries   []models.ValueCount
+	TopBrowsers    []models.ValueCount
+	TopDevices     []models.ValueCount
+	ClicksBySlug   []models.AliasClickCount // own slug first, then aliases
+	Historical     *models.HistoricalClicks // clicks counted before the link was imported
 }
 
 func (h *AdminHandler) LinkAnalytics(w http.ResponseWriter, r *http.Request) {
@@ -36,16 +38,19 @@ func (h *AdminHandler) LinkAnalytics(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	link.FillShortURL()
+	link.FillShortURL(h.cfg.ShortURLBase)
 
 	totalClicks, _ := models.ClickCountForLink(h.db, id)
 	clicksToday, _ := models.ClicksTodayForLink(h.db, id)
 	clicksThisWeek, _ := models.ClicksThisWeekForLink(h.db, id)
 	clicksPrevWeek, _ := models.ClicksPrevWeekForLink(h.db, id)
-	topReferrers, _ := models.TopReferrersForLink(h.db, id, 5)
-	topCountries, _ := models.TopCountriesForLink(h.db, id, 5)
-	topBrowsers, _ := models.TopBrowsersForLink(h.db, id, 5)
-	topDevices, _ := models.TopDevicesForLink(h.db, id, 5)
+	scope := models.LinkClicks(id)
+	topReferrers, _ := models.TopValues(h.db, scope, models.DimensionReferrer, 5)
+	topCountries, _ := models.TopValues(h.db, scope, models.DimensionCountry, 5)
+	topBrowsers, _ := models.TopValues(h.db, scope, models.DimensionBrowser, 5)
+	topDevices, _ := models.TopValues(h.db, scope, models.DimensionDevice, 5)
+	clicksBySlug, _ := models.ClicksByAliasForLink(h.db, link)
+	historical, _ := models.GetHistoricalClicks(h.db, id)
 
 	weekChange := 0
 	weekChangeUp := true
@@ -69,6 +74,8 @@ func (h *AdminHandler) LinkAnalytics(w http.ResponseWriter, r *http.Request) {
 		TopCountries:   topCountries,
 		TopBrowsers:    topBrowsers,
 		TopDevices:     topDevices,
+		ClicksBySlug:   clicksBySlug,
+		Historical:     historical,
 	}
 
 	h.templates.Render(w, "templates/link_analytics.html", data)
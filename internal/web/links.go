@@ -1,155 +1,227 @@
 package web
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/linkquery"
 	"github.com/scmmishra/dubly/internal/models"
 	"github.com/scmmishra/dubly/internal/slug"
+	"github.com/scmmishra/dubly/internal/tags"
+	"github.com/scmmishra/dubly/internal/utm"
 )
 
-var utmKeys = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"}
-
-// buildDestinationWithUTM strips existing UTM params from rawURL then appends
-// any non-empty values from utmValues. Returns rawURL unchanged on parse error.
-func buildDestinationWithUTM(rawURL string, utmValues map[string]string) string {
-	u, err := url.Parse(rawURL)
-	if err != nil {
-		return rawURL
-	}
-	q := u.Query()
-	for _, k := range utmKeys {
-		q.Del(k)
-	}
-	for _, k := range utmKeys {
-		if v := utmValues[k]; v != "" {
-			q.Set(k, v)
-		}
-	}
-	u.RawQuery = q.Encode()
-	return u.String()
-}
-
-// extractUTMValues returns a map of utm_* key → value parsed from rawURL.
-// Missing params are empty strings.
-func extractUTMValues(rawURL string) map[string]string {
-	result := make(map[string]string, len(utmKeys))
-	u, err := url.Parse(rawURL)
-	if err != nil {
-		for _, k := range utmKeys {
-			result[k] = ""
-		}
-		return result
-	}
-	q := u.Query()
-	for _, k := range utmKeys {
-		result[k] = q.Get(k)
-	}
-	return result
-}
-
-// stripUTMParams returns rawURL with all utm_* query params removed.
-func stripUTMParams(rawURL string) string {
-	u, err := url.Parse(rawURL)
-	if err != nil {
-		return rawURL
-	}
-	q := u.Query()
-	for _, k := range utmKeys {
-		q.Del(k)
-	}
-	u.RawQuery = q.Encode()
-	return u.String()
-}
-
 const linksPerPage = 12
 
 type LinksData struct {
 	PageData
 	Links         []models.LinkWithClicks
-	Search        string
-	Page          int
-	TotalPages    int
+	Search        string // query language, see package linkquery
+	SearchError   string
+	Tag           string // exact tag filter, empty for all links
+	Tags          []models.TagCount
+	Domain        string
+	Domains       []models.DomainLinkCount
+	Status        string // "active", "inactive" or empty for all links
+	Sort          string // empty for the default: relevance when searching, else newest
+	Order         string
+	CreatedAfter  string
+	CreatedBefore string
+	ClickedAfter  string
+	ClickedBefore string
+	MoreFilters   bool // a date filter is set
+	Filtered      bool // a filter besides search and tag is set
+	PrevURL       string
+	NextURL       string
 	Total         int
 	TotalLinks    int
 	ClicksToday   int
 	ClicksAllTime int
-	TopReferrers  []models.ReferrerCount
-	TopCountries  []models.CountryCount
-	TopBrowsers   []models.BrowserCount
-	TopDevices    []models.DeviceCount
+	TopReferrers  []models.ValueCount
+	TopCountries  []models.ValueCount
+	TopBrowsers   []models.ValueCount
+	TopDevices    []models.ValueCount
+}
+
+// listURL is the link list with the current search, filters and sort,
+// starting at cursor.
+func (d LinksData) listURL(cursor string) string {
+	v := url.Values{}
+	for key, value := range map[string]string{
+		"search":              d.Search,
+		"tag":                 d.Tag,
+		"domain":              d.Domain,
+		"status":              d.Status,
+		"created_after":       d.CreatedAfter,
+		"created_before":      d.CreatedBefore,
+		"last_clicked_after":  d.ClickedAfter,
+		"last_clicked_before": d.ClickedBefore,
+		"sort":                d.Sort,
+		"order":               d.Order,
+		"cursor":              cursor,
+	} {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	return "/admin?" + v.Encode()
 }
 
 type LinkFormData struct {
 	PageData
 	Link    *models.Link
+	Aliases []models.LinkAlias
+	Presets []models.UTMPreset
 	Domains []string
 	Errors  map[string]string
 	Values  map[string]string
 }
 
-func (h *AdminHandler) LinkList(w http.ResponseWriter, r *http.Request) {
-	search := r.URL.Query().Get("search")
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
+// formDomain resolves the domain picked in a link form. A wildcard entry is
+// combined with the entered subdomain into the concrete host the link is
+// stored under. It returns the domain or a form error message.
+func (h *AdminHandler) formDomain(selected, subdomain string) (string, string) {
+	if config.IsWildcard(selected) {
+		subdomain = strings.ToLower(strings.TrimSpace(subdomain))
+		if subdomain == "" {
+			return "", "Enter a subdomain for " + selected
+		}
+		if !config.IsValidLabel(subdomain) {
+			return "", "Subdomain must be a single label of letters, digits and hyphens"
+		}
+		selected = subdomain + selected[1:]
+	}
+	if !h.cfg.IsDomainAllowed(selected) {
+		return "", "Domain not allowed"
+	}
+	return config.CanonicalDomain(selected), ""
+}
+
+// splitFormDomain is the inverse of formDomain: it maps a stored domain back
+// to the dropdown entry and subdomain that produce it.
+func (h *AdminHandler) splitFormDomain(domain string) (string, string) {
+	pattern, ok := h.cfg.MatchDomain(domain)
+	if !ok || !config.IsWildcard(pattern) {
+		return domain, ""
 	}
+	return pattern, strings.TrimSuffix(domain, config.WildcardSuffix(pattern))
+}
 
-	offset := (page - 1) * linksPerPage
-	links, total, err := models.ListLinks(h.db, linksPerPage, offset, search)
+func (h *AdminHandler) LinkList(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	data := LinksData{
+		Search:        params.Get("search"),
+		Tag:           tags.Normalize(params.Get("tag")),
+		Domain:        params.Get("domain"),
+		Status:        params.Get("status"),
+		CreatedAfter:  params.Get("created_after"),
+		CreatedBefore: params.Get("created_before"),
+		ClickedAfter:  params.Get("last_clicked_after"),
+		ClickedBefore: params.Get("last_clicked_before"),
+	}
+
+	// Unknown sorts and filters fall back to the defaults rather than
+	// failing, since they only come from hand-edited URLs.
+	sort, sortErr := models.ParseLinkSort(params.Get("sort"), params.Get("order"))
+	if sortErr == nil {
+		data.Sort, data.Order = params.Get("sort"), params.Get("order")
+	}
+	q := models.LinkQuery{
+		Filter: models.LinkFilter{Domain: data.Domain},
+		Sort:   sort,
+		Cursor: params.Get("cursor"),
+		Limit:  linksPerPage,
+	}
+	if data.Tag != "" {
+		q.Filter.Tags = []string{data.Tag}
+	}
+	switch data.Status {
+	case "active", "inactive":
+		active := data.Status == "active"
+		q.Filter.Active = &active
+	default:
+		data.Status = ""
+	}
+	bounds := []struct {
+		value *string
+		dst   **time.Time
+		upper bool
+	}{
+		{&data.CreatedAfter, &q.Filter.CreatedAfter, false},
+		{&data.CreatedBefore, &q.Filter.CreatedBefore, true},
+		{&data.ClickedAfter, &q.Filter.LastClickedAfter, false},
+		{&data.ClickedBefore, &q.Filter.LastClickedBefore, true},
+	}
+	for _, b := range bounds {
+		t, err := models.ParseFilterTime(*b.value, b.upper)
+		if err != nil {
+			*b.value = ""
+			continue
+		}
+		*b.dst = t
+	}
+
+	// The search box takes the query language; a query that doesn't
+	// parse lists nothing and says why
+	page := &models.LinkPage{}
+	var err error
+	if searchErr := linkquery.Apply(&q.Filter, data.Search); searchErr != nil {
+		data.SearchError = searchErr.Error()
+	} else {
+		page, err = models.PageLinks(h.db, q)
+		if errors.Is(err, models.ErrInvalidCursor) {
+			q.Cursor = ""
+			page, err = models.PageLinks(h.db, q)
+		}
+	}
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	// Get click counts for all links
-	ids := make([]int64, len(links))
-	for i, l := range links {
-		ids[i] = l.ID
+	data.Links = make([]models.LinkWithClicks, len(page.Links))
+	for i, l := range page.Links {
+		l.FillShortURL(h.cfg.ShortURLBase)
+		data.Links[i] = models.LinkWithClicks{Link: l, ClickCount: l.Clicks}
 	}
-	clickCounts, _ := models.ClickCountsForLinks(h.db, ids)
-
-	linksWithClicks := make([]models.LinkWithClicks, len(links))
-	for i, l := range links {
-		linksWithClicks[i] = models.LinkWithClicks{
-			Link:       l,
-			ClickCount: clickCounts[l.ID],
-		}
+	data.Total = page.Total
+	data.MoreFilters = data.CreatedAfter != "" || data.CreatedBefore != "" || data.ClickedAfter != "" || data.ClickedBefore != ""
+	data.Filtered = data.Domain != "" || data.Status != "" || data.MoreFilters
+	if page.PrevCursor != "" {
+		data.PrevURL = data.listURL(page.PrevCursor)
 	}
-
-	totalPages := (total + linksPerPage - 1) / linksPerPage
-	if totalPages < 1 {
-		totalPages = 1
+	if page.NextCursor != "" {
+		data.NextURL = data.listURL(page.NextCursor)
 	}
 
 	// Fetch dashboard stats
 	totalLinks, _ := models.TotalLinkCount(h.db)
 	clicksToday, _ := models.ClicksToday(h.db)
 	clicksAllTime, _ := models.ClicksAllTime(h.db)
-	topReferrers, _ := models.TopReferrersGlobal(h.db, 5)
-	topCountries, _ := models.TopCountriesGlobal(h.db, 5)
-	topBrowsers, _ := models.TopBrowsersGlobal(h.db, 5)
-	topDevices, _ := models.TopDevicesGlobal(h.db, 5)
-
-	data := LinksData{
-		PageData:      h.pageData(w, r),
-		Links:         linksWithClicks,
-		Search:        search,
-		Page:          page,
-		TotalPages:    totalPages,
-		Total:         total,
-		TotalLinks:    totalLinks,
-		ClicksToday:   clicksToday,
-		ClicksAllTime: clicksAllTime,
-		TopReferrers:  topReferrers,
-		TopCountries:  topCountries,
-		TopBrowsers:   topBrowsers,
-		TopDevices:    topDevices,
-	}
+	var everything models.ClickQuery
+	topReferrers, _ := models.TopValues(h.db, everything, models.DimensionReferrer, 5)
+	topCountries, _ := models.TopValues(h.db, everything, models.DimensionCountry, 5)
+	topBrowsers, _ := models.TopValues(h.db, everything, models.DimensionBrowser, 5)
+	topDevices, _ := models.TopValues(h.db, everything, models.DimensionDevice, 5)
+	data.Tags, _ = models.ListTags(h.db)
+	data.Domains, _ = models.CountLinksByDomain(h.db)
+
+	data.PageData = h.pageData(w, r)
+	data.TotalLinks = totalLinks
+	data.ClicksToday = clicksToday
+	data.ClicksAllTime = clicksAllTime
+	data.TopReferrers = topReferrers
+	data.TopCountries = topCountries
+	data.TopBrowsers = topBrowsers
+	data.TopDevices = topDevices
 
 	// HTMX partial rendering
 	if r.Header.Get("HX-Request") == "true" {
@@ -162,13 +234,22 @@ func (h *AdminHandler) LinkList(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AdminHandler) LinkNewPage(w http.ResponseWriter, r *http.Request) {
-	data := LinkFormData{
+	h.renderLinkNew(w, r, map[string]string{}, map[string]string{"domain": h.cfg.Domains[0]})
+}
+
+func (h *AdminHandler) renderLinkNew(w http.ResponseWriter, r *http.Request, errors, values map[string]string) {
+	presets, err := models.ListUTMPresets(h.db)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.templates.Render(w, "templates/link_new.html", LinkFormData{
 		PageData: h.pageData(w, r),
+		Presets:  presets,
 		Domains:  h.cfg.Domains,
-		Errors:   map[string]string{},
-		Values:   map[string]string{"domain": h.cfg.Domains[0]},
-	}
-	h.templates.Render(w, "templates/link_new.html", data)
+		Errors:   errors,
+		Values:   values,
+	})
 }
 
 func (h *AdminHandler) LinkCreate(w http.ResponseWriter, r *http.Request) {
@@ -177,6 +258,7 @@ func (h *AdminHandler) LinkCreate(w http.ResponseWriter, r *http.Request) {
 	values := map[string]string{
 		"destination":  r.FormValue("destination"),
 		"domain":       r.FormValue("domain"),
+		"subdomain":    r.FormValue("subdomain"),
 		"slug":         r.FormValue("slug"),
 		"title":        r.FormValue("title"),
 		"tags":         r.FormValue("tags"),
@@ -186,6 +268,7 @@ func (h *AdminHandler) LinkCreate(w http.ResponseWriter, r *http.Request) {
 		"utm_campaign": r.FormValue("utm_campaign"),
 		"utm_term":     r.FormValue("utm_term"),
 		"utm_content":  r.FormValue("utm_content"),
+		"utm_preset":   r.FormValue("utm_preset"),
 	}
 
 	errors := map[string]string{}
@@ -194,88 +277,98 @@ func (h *AdminHandler) LinkCreate(w http.ResponseWriter, r *http.Request) {
 		errors["destination"] = "Destination URL is required"
 	}
 
-	domain := strings.ToLower(values["domain"])
-	if !h.cfg.IsDomainAllowed(domain) {
-		errors["domain"] = "Domain not allowed"
+	// A preset fills the UTM fields left blank in the form
+	if values["utm_preset"] != "" {
+		preset, err := models.GetUTMPresetByName(h.db, values["utm_preset"])
+		switch {
+		case err == sql.ErrNoRows:
+			errors["utm_preset"] = "Preset not found"
+		case err != nil:
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		default:
+			for k, v := range utm.WithDefaults(values, preset.UTM()) {
+				values[k] = v
+			}
+		}
+	}
+
+	values["domain"] = strings.ToLower(values["domain"])
+	domain, domainErr := h.formDomain(values["domain"], values["subdomain"])
+	if domainErr != "" {
+		errors["domain"] = domainErr
 	}
-	values["domain"] = domain
 
 	if len(errors) > 0 {
-		data := LinkFormData{
-			PageData: h.pageData(w, r),
-			Domains:  h.cfg.Domains,
-			Errors:   errors,
-			Values:   values,
-		}
-		h.templates.Render(w, "templates/link_new.html", data)
+		h.renderLinkNew(w, r, errors, values)
 		return
 	}
 
+	settings := h.cfg.SettingsFor(domain)
+	slugExists := models.SlugExists
+	if settings.CaseInsensitive {
+		slugExists = models.SlugExistsFold
+	}
+
 	// Auto-generate slug if not provided
-	slugVal := values["slug"]
+	validator := h.cfg.SlugValidator()
+	slugVal := h.cfg.NormalizeSlug(domain, values["slug"])
 	if slugVal == "" {
-		for range 10 {
-			candidate, err := slug.Generate()
-			if err != nil {
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
-			exists, err := models.SlugExists(h.db, candidate, domain)
-			if err != nil {
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
-			if !exists {
-				slugVal = candidate
-				break
-			}
+		gen, err := h.cfg.SlugGenerator(domain)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
 		}
-		if slugVal == "" {
+		slugVal, err = slug.Unique(gen, slug.Input{Title: values["title"]}, validator, func(candidate string) (bool, error) {
+			return slugExists(h.db, candidate, domain)
+		})
+		if err != nil {
 			errors["slug"] = "Failed to generate unique slug"
-			data := LinkFormData{
-				PageData: h.pageData(w, r),
-				Domains:  h.cfg.Domains,
-				Errors:   errors,
-				Values:   values,
-			}
-			h.templates.Render(w, "templates/link_new.html", data)
+		}
+	} else if err := validator.Validate(slugVal); err != nil {
+		errors["slug"] = sentence(err.Error())
+	} else {
+		exists, err := slugExists(h.db, slugVal, domain)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
+		if exists {
+			errors["slug"] = "This slug already exists for this domain"
+		}
+	}
+	if len(errors) > 0 {
+		h.renderLinkNew(w, r, errors, values)
+		return
 	}
 
-	utmValues := map[string]string{
-		"utm_source":   values["utm_source"],
-		"utm_medium":   values["utm_medium"],
-		"utm_campaign": values["utm_campaign"],
-		"utm_term":     values["utm_term"],
-		"utm_content":  values["utm_content"],
+	tags := values["tags"]
+	if tags == "" {
+		tags = settings.DefaultTags
 	}
 
 	link := &models.Link{
 		Slug:        slugVal,
 		Domain:      domain,
-		Destination: buildDestinationWithUTM(values["destination"], utmValues),
+		Destination: values["destination"],
 		Title:       values["title"],
-		Tags:        values["tags"],
+		Tags:        tags,
 		Notes:       values["notes"],
 	}
+	// UTM parameters typed into the destination fill the fields left blank
+	link.SetUTM(utm.WithDefaults(values, utm.Extract(link.Destination)))
 
 	if err := models.CreateLink(h.db, link); err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
 			errors["slug"] = "This slug already exists for this domain"
-			data := LinkFormData{
-				PageData: h.pageData(w, r),
-				Domains:  h.cfg.Domains,
-				Errors:   errors,
-				Values:   values,
-			}
-			h.templates.Render(w, "templates/link_new.html", data)
+			h.renderLinkNew(w, r, errors, values)
 			return
 		}
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
+	link.FillShortURL(h.cfg.ShortURLBase)
 	setFlash(w, "success", "Link created: "+link.ShortURL)
 	http.Redirect(w, r, "/admin", http.StatusFound)
 }
@@ -293,10 +386,12 @@ func (h *AdminHandler) LinkEditPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utmVals := extractUTMValues(link.Destination)
+	utmVals := link.UTM()
+	formDomain, subdomain := h.splitFormDomain(link.Domain)
 	values := map[string]string{
-		"destination":  stripUTMParams(link.Destination),
-		"domain":       link.Domain,
+		"destination":  utm.Strip(link.Destination),
+		"domain":       formDomain,
+		"subdomain":    subdomain,
 		"slug":         link.Slug,
 		"title":        link.Title,
 		"tags":         link.Tags,
@@ -306,11 +401,20 @@ func (h *AdminHandler) LinkEditPage(w http.ResponseWriter, r *http.Request) {
 		"utm_campaign": utmVals["utm_campaign"],
 		"utm_term":     utmVals["utm_term"],
 		"utm_content":  utmVals["utm_content"],
+		"version":      link.Version(),
+	}
+
+	aliases, err := models.ListLinkAliases(h.db, id)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
 	}
+	h.fillShortURLs(link, aliases)
 
 	data := LinkFormData{
 		PageData: h.pageData(w, r),
 		Link:     link,
+		Aliases:  aliases,
 		Domains:  h.cfg.Domains,
 		Errors:   map[string]string{},
 		Values:   values,
@@ -330,12 +434,19 @@ func (h *AdminHandler) LinkUpdate(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
+	aliases, err := models.ListLinkAliases(h.db, id)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.fillShortURLs(existing, aliases)
 
 	r.ParseForm()
 
 	values := map[string]string{
 		"destination":  r.FormValue("destination"),
 		"domain":       r.FormValue("domain"),
+		"subdomain":    r.FormValue("subdomain"),
 		"slug":         r.FormValue("slug"),
 		"title":        r.FormValue("title"),
 		"tags":         r.FormValue("tags"),
@@ -345,6 +456,14 @@ func (h *AdminHandler) LinkUpdate(w http.ResponseWriter, r *http.Request) {
 		"utm_campaign": r.FormValue("utm_campaign"),
 		"utm_term":     r.FormValue("utm_term"),
 		"utm_content":  r.FormValue("utm_content"),
+		"version":      r.FormValue("version"),
+	}
+
+	// Don't quietly overwrite changes saved since the form was opened
+	version := values["version"]
+	if version != "" && version != existing.Version() {
+		h.renderEditConflict(w, r, id, aliases, values)
+		return
 	}
 
 	errors := map[string]string{}
@@ -356,16 +475,21 @@ func (h *AdminHandler) LinkUpdate(w http.ResponseWriter, r *http.Request) {
 		errors["slug"] = "Slug is required"
 	}
 
-	domain := strings.ToLower(values["domain"])
-	if !h.cfg.IsDomainAllowed(domain) {
-		errors["domain"] = "Domain not allowed"
+	values["domain"] = strings.ToLower(values["domain"])
+	domain, domainErr := h.formDomain(values["domain"], values["subdomain"])
+	if domainErr != "" {
+		errors["domain"] = domainErr
+	} else if values["slug"] != "" {
+		if err := h.cfg.SlugValidator().Validate(h.cfg.NormalizeSlug(domain, values["slug"])); err != nil {
+			errors["slug"] = sentence(err.Error())
+		}
 	}
-	values["domain"] = domain
 
 	if len(errors) > 0 {
 		data := LinkFormData{
 			PageData: h.pageData(w, r),
 			Link:     existing,
+			Aliases:  aliases,
 			Domains:  h.cfg.Domains,
 			Errors:   errors,
 			Values:   values,
@@ -377,29 +501,56 @@ func (h *AdminHandler) LinkUpdate(w http.ResponseWriter, r *http.Request) {
 	// Capture old key for cache invalidation
 	oldDomain, oldSlug := existing.Domain, existing.Slug
 
-	utmValues := map[string]string{
-		"utm_source":   values["utm_source"],
-		"utm_medium":   values["utm_medium"],
-		"utm_campaign": values["utm_campaign"],
-		"utm_term":     values["utm_term"],
-		"utm_content":  values["utm_content"],
-	}
-
-	existing.Slug = values["slug"]
+	existing.Slug = h.cfg.NormalizeSlug(domain, values["slug"])
 	existing.Domain = domain
-	existing.Destination = buildDestinationWithUTM(values["destination"], utmValues)
+	existing.Destination = values["destination"]
+	existing.SetUTM(utm.WithDefaults(values, utm.Extract(existing.Destination)))
 	existing.Title = values["title"]
 	existing.Tags = values["tags"]
 	existing.Notes = values["notes"]
 
-	h.cache.Invalidate(oldDomain, oldSlug)
+	if existing.Slug != oldSlug || existing.Domain != oldDomain {
+		slugExists := models.SlugExists
+		if h.cfg.SettingsFor(domain).CaseInsensitive && !strings.EqualFold(existing.Slug, oldSlug) {
+			slugExists = models.SlugExistsFold
+		}
+		exists, err := slugExists(h.db, existing.Slug, existing.Domain)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			errors["slug"] = "This slug already exists for this domain"
+			h.templates.Render(w, "templates/link_edit.html", LinkFormData{
+				PageData: h.pageData(w, r),
+				Link:     existing,
+				Aliases:  aliases,
+				Domains:  h.cfg.Domains,
+				Errors:   errors,
+				Values:   values,
+			})
+			return
+		}
+	}
+
+	h.cache.InvalidateLink(h.db, id, oldDomain, oldSlug)
 
-	if err := models.UpdateLink(h.db, existing); err != nil {
+	if version != "" {
+		err = models.UpdateLinkIfUnchanged(h.db, existing, version)
+	} else {
+		err = models.UpdateLink(h.db, existing)
+	}
+	if err != nil {
+		if err == models.ErrLinkChanged {
+			h.renderEditConflict(w, r, id, aliases, values)
+			return
+		}
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
 			errors["slug"] = "This slug already exists for this domain"
 			data := LinkFormData{
 				PageData: h.pageData(w, r),
 				Link:     existing,
+				Aliases:  aliases,
 				Domains:  h.cfg.Domains,
 				Errors:   errors,
 				Values:   values,
@@ -415,6 +566,37 @@ func (h *AdminHandler) LinkUpdate(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/links/"+strconv.FormatInt(id, 10)+"/edit", http.StatusFound)
 }
 
+// renderEditConflict shows the edit form again, keeping what was typed,
+// when someone else saved the link after the form was opened. The form then
+// carries the latest version, so saving it again overwrites their changes
+// deliberately.
+func (h *AdminHandler) renderEditConflict(w http.ResponseWriter, r *http.Request, id int64, aliases []models.LinkAlias, values map[string]string) {
+	current := &models.Link{ID: id}
+	if err := models.GetLinkByID(h.db, current); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	current.FillShortURL(h.cfg.ShortURLBase)
+	values["version"] = current.Version()
+	h.templates.Render(w, "templates/link_edit.html", LinkFormData{
+		PageData: h.pageData(w, r),
+		Link:     current,
+		Aliases:  aliases,
+		Domains:  h.cfg.Domains,
+		Errors:   map[string]string{"version": "Someone else changed this link after you opened it. Saving again will overwrite their changes."},
+		Values:   values,
+	})
+}
+
+// fillShortURLs sets the short URLs of a link and its aliases for the edit
+// form.
+func (h *AdminHandler) fillShortURLs(link *models.Link, aliases []models.LinkAlias) {
+	link.FillShortURL(h.cfg.ShortURLBase)
+	for i := range aliases {
+		aliases[i].FillShortURL(h.cfg.ShortURLBase)
+	}
+}
+
 func (h *AdminHandler) LinkDelete(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -425,7 +607,7 @@ func (h *AdminHandler) LinkDelete(w http.ResponseWriter, r *http.Request) {
 	// Get link for cache invalidation
 	link := &models.Link{ID: id}
 	if err := models.GetLinkByID(h.db, link); err == nil {
-		h.cache.Invalidate(link.Domain, link.Slug)
+		h.cache.InvalidateLink(h.db, id, link.Domain, link.Slug)
 	}
 
 	if err := models.SoftDeleteLink(h.db, id); err != nil {
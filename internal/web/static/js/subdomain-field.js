@@ -0,0 +1,8 @@
+// Show the subdomain field when a wildcard domain is selected
+(function() {
+    var select = document.getElementById('domain');
+    var field = document.getElementById('subdomain-field');
+    select.addEventListener('change', function() {
+        field.hidden = select.value.indexOf('*.') !== 0;
+    });
+})();
@@ -0,0 +1,53 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/tags"
+)
+
+type TagAnalyticsData struct {
+	PageData
+	Tag          models.TagCount
+	ClicksToday  int
+	TopLinks     []models.LinkWithClicks
+	TopReferrers []models.ValueCount
+	TopCountries []models.ValueCount
+}
+
+// TagAnalytics shows clicks aggregated across every link carrying a tag.
+func (h *AdminHandler) TagAnalytics(w http.ResponseWriter, r *http.Request) {
+	name, err := url.PathUnescape(chi.URLParam(r, "name"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	tag, err := models.GetTag(h.db, tags.Normalize(name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	clicksToday, _ := models.ClicksTodayForTag(h.db, tag.Name)
+	topLinks, _ := models.TopLinksForTag(h.db, tag.Name, 10)
+	for i := range topLinks {
+		topLinks[i].Link.FillShortURL(h.cfg.ShortURLBase)
+	}
+	scope := models.TagClicks(tag.Name)
+	topReferrers, _ := models.TopValues(h.db, scope, models.DimensionReferrer, 5)
+	topCountries, _ := models.TopValues(h.db, scope, models.DimensionCountry, 5)
+
+	data := TagAnalyticsData{
+		PageData:     h.pageData(w, r),
+		Tag:          *tag,
+		ClicksToday:  clicksToday,
+		TopLinks:     topLinks,
+		TopReferrers: topReferrers,
+		TopCountries: topCountries,
+	}
+	h.templates.Render(w, "templates/tag_analytics.html", data)
+}
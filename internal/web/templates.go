@@ -37,7 +37,11 @@ func NewTemplateRegistry() (*TemplateRegistry, error) {
 		"templates/link_new.html",
 		"templates/link_edit.html",
 		"templates/link_analytics.html",
+		"templates/tag_analytics.html",
+		"templates/utm_presets.html",
 		"templates/domains.html",
+		"templates/duplicates.html",
+		"templates/import.html",
 	}
 
 	for _, page := range pages {
@@ -48,6 +52,15 @@ func NewTemplateRegistry() (*TemplateRegistry, error) {
 		tr.cache[page] = t
 	}
 
+	// Campaign pages share the form fields
+	for _, page := range []string{"templates/campaigns.html", "templates/campaign.html"} {
+		t, err := template.Must(layout.Clone()).ParseFS(templateFS, page, "templates/campaign_form.html")
+		if err != nil {
+			return nil, err
+		}
+		tr.cache[page] = t
+	}
+
 	// Links page needs the cards partial too
 	linksT, err := template.Must(layout.Clone()).ParseFS(templateFS, "templates/links.html", "templates/links_cards.html")
 	if err != nil {
@@ -0,0 +1,139 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/transfer"
+)
+
+const maxImportSize = 100 << 20 // 100 MB
+
+type ImportData struct {
+	PageData
+	Summary *transfer.Summary
+	Error   string
+	Values  map[string]string
+	Domains []string
+}
+
+// Export downloads every link, and with include=clicks every click.
+func (h *AdminHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format, err := transfer.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	includeClicks := r.URL.Query().Get("include") == "clicks"
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+format.Filename(time.Now().UTC())+`"`)
+	if err := transfer.Export(h.db, w, format, includeClicks); err != nil {
+		log.Printf("export: %v", err)
+	}
+}
+
+func (h *AdminHandler) ImportPage(w http.ResponseWriter, r *http.Request) {
+	h.templates.Render(w, "templates/import.html", ImportData{
+		PageData: h.pageData(w, r),
+		Values:   map[string]string{"conflict": string(transfer.Skip)},
+		Domains:  importDomains(h.cfg),
+	})
+}
+
+// Import loads an uploaded export, ours or another tool's, reporting what it
+// did or, for dry runs, what it would do.
+func (h *AdminHandler) Import(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file, header, err := r.FormFile("file")
+	data := ImportData{
+		PageData: h.pageData(w, r),
+		Values: map[string]string{
+			"format":            r.FormValue("format"),
+			"conflict":          r.FormValue("conflict"),
+			"domain":            r.FormValue("domain"),
+			"historical_clicks": r.FormValue("historical_clicks"),
+		},
+		Domains: importDomains(h.cfg),
+	}
+	render := func(msg string) {
+		data.Error = msg
+		h.templates.Render(w, "templates/import.html", data)
+	}
+
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			render("File is larger than 100 MB")
+			return
+		}
+		render("Choose a file to import")
+		return
+	}
+	defer file.Close()
+
+	conflict, err := transfer.ParseConflict(data.Values["conflict"])
+	if err != nil {
+		render(sentence(err.Error()))
+		return
+	}
+	formatName := data.Values["format"]
+	if formatName == "" {
+		formatName = formatFromExtension(header.Filename)
+	}
+	format, err := transfer.ParseImportFormat(formatName)
+	if err != nil {
+		render(sentence(err.Error()))
+		return
+	}
+
+	im := &transfer.Importer{DB: h.db, Cfg: h.cfg, Cache: h.cache}
+	data.Summary, err = im.Import(file, transfer.Options{
+		Format:           format,
+		Conflict:         conflict,
+		Domain:           data.Values["domain"],
+		HistoricalClicks: data.Values["historical_clicks"] != "",
+		DryRun:           r.FormValue("dry_run") != "",
+	})
+	if err != nil {
+		var inputErr *transfer.InputError
+		if !errors.As(err, &inputErr) {
+			log.Printf("import: %v", err)
+			render("Import failed")
+			return
+		}
+		render(sentence(err.Error()))
+		return
+	}
+	render("")
+}
+
+// formatFromExtension guesses an upload's format from its name. CSV files
+// are taken to be ours; other tools' CSVs need the format picked.
+func formatFromExtension(filename string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(path.Ext(filename), ".")); ext {
+	case "htm", "html":
+		return string(transfer.Bookmarks)
+	case "sql":
+		return string(transfer.YOURLS)
+	default:
+		return ext
+	}
+}
+
+// importDomains are the domains links can be moved onto; a wildcard names
+// no domain of its own.
+func importDomains(cfg *config.Config) []string {
+	var domains []string
+	for _, d := range cfg.Domains {
+		if !config.IsWildcard(d) {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/utm"
+)
+
+type UTMPresetsData struct {
+	PageData
+	Presets []models.UTMPreset
+	Errors  map[string]string
+	Values  map[string]string
+}
+
+func (h *AdminHandler) UTMPresetsPage(w http.ResponseWriter, r *http.Request) {
+	h.renderUTMPresets(w, r, map[string]string{}, map[string]string{})
+}
+
+func (h *AdminHandler) renderUTMPresets(w http.ResponseWriter, r *http.Request, errors, values map[string]string) {
+	presets, err := models.ListUTMPresets(h.db)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	h.templates.Render(w, "templates/utm_presets.html", UTMPresetsData{
+		PageData: h.pageData(w, r),
+		Presets:  presets,
+		Errors:   errors,
+		Values:   values,
+	})
+}
+
+func (h *AdminHandler) UTMPresetCreate(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	values := map[string]string{"name": r.FormValue("name")}
+	for _, k := range utm.Keys {
+		values[k] = r.FormValue(k)
+	}
+
+	p := &models.UTMPreset{Name: values["name"]}
+	p.SetUTM(values)
+	if err := p.Validate(); err != nil {
+		h.renderUTMPresets(w, r, map[string]string{"preset": sentence(err.Error())}, values)
+		return
+	}
+	if err := models.CreateUTMPreset(h.db, p); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			h.renderUTMPresets(w, r, map[string]string{"preset": "A preset with this name already exists"}, values)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	setFlash(w, "success", "Preset saved")
+	http.Redirect(w, r, "/admin/utm-presets", http.StatusFound)
+}
+
+func (h *AdminHandler) UTMPresetDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if err := models.DeleteUTMPreset(h.db, id); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	setFlash(w, "success", "Preset deleted")
+	http.Redirect(w, r, "/admin/utm-presets", http.StatusFound)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/scmmishra/dubly/internal/cache"
 	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/domaincheck"
 )
 
 type AdminHandler struct {
@@ -18,10 +19,12 @@ type AdminHandler struct {
 	cache     *cache.LinkCache
 	templates *TemplateRegistry
 	appName   string
-	dns       *dnsCache
+	domains   *domaincheck.Checker
 }
 
-func NewAdminHandler(db *sql.DB, cfg *config.Config, linkCache *cache.LinkCache) (*AdminHandler, error) {
+// NewAdminHandler builds the admin UI. domainChecker may be nil, in which
+// case the Domains page only shows previously stored results.
+func NewAdminHandler(db *sql.DB, cfg *config.Config, linkCache *cache.LinkCache, domainChecker *domaincheck.Checker) (*AdminHandler, error) {
 	tmpl, err := NewTemplateRegistry()
 	if err != nil {
 		return nil, err
@@ -33,7 +36,7 @@ func NewAdminHandler(db *sql.DB, cfg *config.Config, linkCache *cache.LinkCache)
 		cache:     linkCache,
 		templates: tmpl,
 		appName:   cfg.AppName,
-		dns:       newDNSCache(),
+		domains:   domainChecker,
 	}, nil
 }
 
@@ -58,10 +61,29 @@ func (h *AdminHandler) RegisterRoutes(r chi.Router) {
 			r.Get("/links/{id}/edit", h.LinkEditPage)
 			r.Post("/links/{id}", h.LinkUpdate)
 			r.Delete("/links/{id}", h.LinkDelete)
+			r.Post("/links/{id}/aliases", h.LinkAliasCreate)
+			r.Post("/links/{id}/aliases/{aliasID}/delete", h.LinkAliasDelete)
 			r.Get("/links/{id}/analytics", h.LinkAnalytics)
 			r.Get("/links/{id}/qr", h.LinkQRCode)
+			r.Get("/tags/{name}", h.TagAnalytics)
+			r.Get("/campaigns", h.CampaignsPage)
+			r.Post("/campaigns", h.CampaignCreate)
+			r.Get("/campaigns/{id}", h.CampaignPage)
+			r.Post("/campaigns/{id}", h.CampaignUpdate)
+			r.Post("/campaigns/{id}/delete", h.CampaignDelete)
+			r.Post("/campaigns/{id}/links", h.CampaignAttach)
+			r.Post("/campaigns/{id}/links/{linkID}/delete", h.CampaignDetach)
+			r.Get("/utm-presets", h.UTMPresetsPage)
+			r.Post("/utm-presets", h.UTMPresetCreate)
+			r.Post("/utm-presets/{id}/delete", h.UTMPresetDelete)
+			r.Get("/export", h.Export)
+			r.Get("/import", h.ImportPage)
+			r.Post("/import", h.Import)
 			r.Get("/domains", h.DomainsPage)
 			r.Post("/domains/refresh", h.DomainsRefresh)
+			r.Post("/domains/move", h.DomainsMove)
+			r.Get("/duplicates", h.DuplicatesPage)
+			r.Post("/duplicates/merge", h.DuplicatesMerge)
 		})
 	})
 }
@@ -1,11 +1,16 @@
 package web_test
 
 import (
+	"bytes"
 	"database/sql"
 	"fmt"
+	"html"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -18,6 +23,7 @@ import (
 	"github.com/scmmishra/dubly/internal/db"
 	"github.com/scmmishra/dubly/internal/geo"
 	"github.com/scmmishra/dubly/internal/models"
+	"github.com/scmmishra/dubly/internal/slug"
 	"github.com/scmmishra/dubly/internal/web"
 )
 
@@ -25,18 +31,21 @@ const testPassword = "test-secret"
 
 func setupRouter(t *testing.T) (*chi.Mux, *sql.DB) {
 	t.Helper()
+	return setupRouterWithConfig(t, &config.Config{
+		Password: testPassword,
+		Domains:  []string{"short.io", "s.co"},
+	})
+}
+
+func setupRouterWithConfig(t *testing.T, cfg *config.Config) (*chi.Mux, *sql.DB) {
+	t.Helper()
 
 	database, err := db.Open(":memory:")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	cfg := &config.Config{
-		Password: testPassword,
-		Domains:  []string{"short.io", "s.co"},
-	}
-
-	linkCache, err := cache.New(100)
+	linkCache, err := cache.New(100, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -44,7 +53,7 @@ func setupRouter(t *testing.T) (*chi.Mux, *sql.DB) {
 	geoReader, _ := geo.Open("")
 	collector := analytics.NewCollector(database, geoReader, 1000, time.Hour)
 
-	adminHandler, err := web.NewAdminHandler(database, cfg, linkCache)
+	adminHandler, err := web.NewAdminHandler(database, cfg, linkCache, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -281,6 +290,103 @@ func TestLinkList_Search(t *testing.T) {
 	}
 }
 
+func TestLinkList_HTMXSearchRanksMatches(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	models.CreateLink(database, &models.Link{Slug: "roadmap", Domain: "short.io", Destination: "https://example.com", Notes: "shared at the launch"})
+	models.CreateLink(database, &models.Link{Slug: "launch", Domain: "short.io", Destination: "https://example.com", Title: "Launch post"})
+	models.CreateLink(database, &models.Link{Slug: "other", Domain: "short.io", Destination: "https://example.com"})
+
+	req := httptest.NewRequest("GET", "/admin?search=launc", nil)
+	req.AddCookie(cookie)
+	req.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	launch, roadmap := strings.Index(body, "short.io/launch"), strings.Index(body, "short.io/roadmap")
+	if launch < 0 || roadmap < 0 || launch > roadmap {
+		t.Error("search should list the title match before the notes match")
+	}
+	if strings.Contains(body, "short.io/other") {
+		t.Error("search should leave out links that don't match")
+	}
+}
+
+func TestLinkList_SearchQueryLanguage(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	models.CreateLink(database, &models.Link{Slug: "tagged", Domain: "short.io", Destination: "https://example.com", Tags: "launch"})
+	models.CreateLink(database, &models.Link{Slug: "other", Domain: "s.co", Destination: "https://example.com", Tags: "launch"})
+	models.CreateLink(database, &models.Link{Slug: "plain", Domain: "short.io", Destination: "https://example.com"})
+
+	body := authGet(r, cookie, "/admin?search="+url.QueryEscape("tag:launch -domain:s.co")).Body.String()
+	if !strings.Contains(body, "short.io/tagged") || strings.Contains(body, "s.co/other") || strings.Contains(body, "short.io/plain") {
+		t.Error("query should keep only launch links off s.co")
+	}
+
+	body = authGet(r, cookie, "/admin?search="+url.QueryEscape("clicks:>lots")).Body.String()
+	if !strings.Contains(body, "Couldn't read that search") || !strings.Contains(body, "isn&#39;t a number of clicks") {
+		t.Error("expected the parse error to be shown")
+	}
+}
+
+func TestLinkList_CursorPagination(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	for i := range 13 {
+		l := &models.Link{Slug: fmt.Sprintf("page%02d", i), Domain: "short.io", Destination: "https://example.com"}
+		if err := models.CreateLink(database, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := authGet(r, cookie, "/admin?sort=title&order=asc").Body.String()
+	if strings.Contains(body, "&larr; Prev") || strings.Contains(body, "short.io/page12") {
+		t.Fatal("first page should start at the top and stop before page12")
+	}
+	next := regexp.MustCompile(`hx-get="(/admin\?[^"]*cursor=[^"]*)"`).FindStringSubmatch(body)
+	if next == nil {
+		t.Fatal("first page should link to the next one")
+	}
+	nextURL := html.UnescapeString(next[1])
+	if !strings.Contains(nextURL, "sort=title") {
+		t.Errorf("next page %q should keep the sort", nextURL)
+	}
+
+	body = authGet(r, cookie, nextURL).Body.String()
+	if !strings.Contains(body, "short.io/page12") || strings.Contains(body, "short.io/page00") {
+		t.Error("second page should hold only page12")
+	}
+	if !strings.Contains(body, "&larr; Prev") {
+		t.Error("second page should link back")
+	}
+}
+
+func TestLinkList_StatusFilter(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	live := &models.Link{Slug: "live", Domain: "short.io", Destination: "https://example.com"}
+	gone := &models.Link{Slug: "gone", Domain: "short.io", Destination: "https://example.com"}
+	models.CreateLink(database, live)
+	models.CreateLink(database, gone)
+	models.SoftDeleteLink(database, gone.ID)
+
+	body := authGet(r, cookie, "/admin?status=inactive").Body.String()
+	if !strings.Contains(body, "short.io/gone") || strings.Contains(body, "short.io/live") {
+		t.Error("inactive filter should show only the deleted link")
+	}
+
+	body = authGet(r, cookie, "/admin?created_before=2000-01-01").Body.String()
+	if !strings.Contains(body, "No links match these filters") {
+		t.Error("expected filtered empty state")
+	}
+}
+
 // === Create Link Tests ===
 
 func TestLinkCreate_NewPage(t *testing.T) {
@@ -323,6 +429,50 @@ func TestLinkCreate_Success(t *testing.T) {
 	}
 }
 
+func TestLinkCreate_WithUTMPreset(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	w := authPost(r, cookie, "/admin/utm-presets", url.Values{
+		"name":       {"Newsletter"},
+		"utm_source": {"newsletter"},
+		"utm_medium": {"email"},
+	})
+	if w.Code != http.StatusFound {
+		t.Fatalf("create preset status = %d", w.Code)
+	}
+	if body := authGet(r, cookie, "/admin/links/new").Body.String(); !strings.Contains(body, `<option value="Newsletter"`) {
+		t.Error("new link page should offer the preset")
+	}
+
+	w = authPost(r, cookie, "/admin/links", url.Values{
+		"destination": {"https://example.com/page"},
+		"domain":      {"short.io"},
+		"slug":        {"preset"},
+		"utm_preset":  {"Newsletter"},
+		"utm_medium":  {"social"},
+	})
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+	link, err := models.GetLinkBySlugAndDomain(database, "preset", "short.io")
+	if err != nil {
+		t.Fatalf("link not created: %v", err)
+	}
+	if link.Destination != "https://example.com/page?utm_medium=social&utm_source=newsletter" {
+		t.Errorf("destination = %q, want preset filling blank fields only", link.Destination)
+	}
+
+	w = authPost(r, cookie, "/admin/links", url.Values{
+		"destination": {"https://example.com/page"},
+		"domain":      {"short.io"},
+		"utm_preset":  {"Missing"},
+	})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Preset not found") {
+		t.Errorf("unknown preset: status = %d", w.Code)
+	}
+}
+
 func TestLinkCreate_AutoSlug(t *testing.T) {
 	r, database := setupRouter(t)
 	cookie := sessionCookie(t, r)
@@ -338,7 +488,7 @@ func TestLinkCreate_AutoSlug(t *testing.T) {
 	}
 
 	// Verify a link was created (with auto-generated slug)
-	links, total, err := models.ListLinks(database, 10, 0, "")
+	links, total, err := models.ListLinks(database, 10, 0, models.LinkFilter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -350,6 +500,134 @@ func TestLinkCreate_AutoSlug(t *testing.T) {
 	}
 }
 
+func TestLinkCreate_DomainSettings(t *testing.T) {
+	r, database := setupRouterWithConfig(t, &config.Config{
+		Password: testPassword,
+		Domains:  []string{"short.io", "go"},
+		DomainSettings: map[string]config.DomainSettings{
+			"go": {CaseInsensitive: true, SlugLength: 3, DefaultTags: "intranet"},
+		},
+	})
+	cookie := sessionCookie(t, r)
+
+	form := url.Values{"destination": {"https://wiki.example.com"}, "domain": {"go"}, "slug": {"Wiki"}}
+	if w := authPost(r, cookie, "/admin/links", form); w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+	link, err := models.GetLinkBySlugAndDomain(database, "wiki", "go")
+	if err != nil {
+		t.Fatalf("slug should be stored lowercased: %v", err)
+	}
+	if link.Tags != "intranet" {
+		t.Errorf("tags = %q, want default %q", link.Tags, "intranet")
+	}
+
+	// Auto-generated slugs use the domain's length
+	form = url.Values{"destination": {"https://example.com"}, "domain": {"go"}}
+	authPost(r, cookie, "/admin/links", form)
+	links, _, _ := models.ListLinks(database, 10, 0, models.LinkFilter{Search: "https://example.com"})
+	if len(links) != 1 || len(links[0].Slug) != 3 {
+		t.Errorf("links = %+v, want one link with a 3-character slug", links)
+	}
+
+	// Differing only by case is a collision
+	form = url.Values{"destination": {"https://other.com"}, "domain": {"go"}, "slug": {"WIKI"}}
+	w := authPost(r, cookie, "/admin/links", form)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "already exists") {
+		t.Errorf("status = %d, want form re-rendered with slug error", w.Code)
+	}
+}
+
+func TestLinkCreate_WildcardSubdomain(t *testing.T) {
+	r, database := setupRouterWithConfig(t, &config.Config{
+		Password: testPassword,
+		Domains:  []string{"short.io", "*.links.example.com"},
+	})
+	cookie := sessionCookie(t, r)
+
+	form := url.Values{"destination": {"https://alice.dev"}, "domain": {"*.links.example.com"}, "subdomain": {"Alice"}, "slug": {"cv"}}
+	if w := authPost(r, cookie, "/admin/links", form); w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+	link, err := models.GetLinkBySlugAndDomain(database, "cv", "alice.links.example.com")
+	if err != nil {
+		t.Fatalf("link should be stored against the concrete host: %v", err)
+	}
+
+	// Edit page maps the host back to the wildcard entry and subdomain
+	w := authGet(r, cookie, fmt.Sprintf("/admin/links/%d/edit", link.ID))
+	body := w.Body.String()
+	if !strings.Contains(body, `value="*.links.example.com" selected`) {
+		t.Error("edit page should preselect the wildcard entry")
+	}
+	if !strings.Contains(body, `value="alice"`) {
+		t.Error("edit page should prefill the subdomain")
+	}
+
+	// A wildcard without a subdomain is rejected
+	form = url.Values{"destination": {"https://bob.dev"}, "domain": {"*.links.example.com"}}
+	w = authPost(r, cookie, "/admin/links", form)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Enter a subdomain") {
+		t.Errorf("status = %d, want form re-rendered with subdomain error", w.Code)
+	}
+}
+
+func TestLinkCreate_ReservedSlug(t *testing.T) {
+	r, _ := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	form := url.Values{"destination": {"https://example.com"}, "domain": {"short.io"}, "slug": {"admin"}}
+	w := authPost(r, cookie, "/admin/links", form)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Slug is reserved") {
+		t.Errorf("status = %d, want form re-rendered with reserved error", w.Code)
+	}
+
+	form.Set("slug", "two words")
+	w = authPost(r, cookie, "/admin/links", form)
+	if !strings.Contains(w.Body.String(), "Slug can&#39;t contain") {
+		t.Error("expected character policy error")
+	}
+}
+
+func TestLinkList_ShowsUnicodeSlugs(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	form := url.Values{"destination": {"https://example.com"}, "domain": {"short.io"}, "slug": {"日本"}}
+	if w := authPost(r, cookie, "/admin/links", form); w.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+	link, err := models.GetLinkBySlugAndDomain(database, "日本", "short.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := authGet(r, cookie, "/admin/").Body.String()
+	if !strings.Contains(body, "short.io/日本") {
+		t.Error("list should show the decoded slug")
+	}
+	if !strings.Contains(body, `data-url="https://short.io/%E6%97%A5%E6%9C%AC"`) {
+		t.Error("copy button should carry the encoded URL")
+	}
+
+	w := authGet(r, cookie, fmt.Sprintf("/admin/links/%d/qr?dl=1", link.ID))
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Disposition"), "%E6%97%A5%E6%9C%AC-qr.png") {
+		t.Errorf("qr: status = %d, disposition = %q", w.Code, w.Header().Get("Content-Disposition"))
+	}
+}
+
+func TestAdminRoutes_AreReservedSlugs(t *testing.T) {
+	r, _ := setupRouter(t)
+	v := slug.NewValidator(0, nil)
+	chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		first := strings.SplitN(strings.TrimPrefix(route, "/"), "/", 2)[0]
+		if first != "" && !v.IsReserved(first) {
+			t.Errorf("route %s %s is not covered by the reserved slug list", method, route)
+		}
+		return nil
+	})
+}
+
 func TestLinkCreate_MissingDestination(t *testing.T) {
 	r, _ := setupRouter(t)
 	cookie := sessionCookie(t, r)
@@ -450,6 +728,116 @@ func TestLinkUpdate_Success(t *testing.T) {
 	}
 }
 
+func TestLinkUpdate_DetectsConcurrentEdit(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	l := &models.Link{Slug: "shared", Domain: "short.io", Destination: "https://v1.com"}
+	models.CreateLink(database, l)
+	path := fmt.Sprintf("/admin/links/%d", l.ID)
+
+	// Both editors open the form, and the first one saves
+	page := authGet(r, cookie, path+"/edit").Body.String()
+	m := regexp.MustCompile(`name="version" value="(\d+)"`).FindStringSubmatch(page)
+	if m == nil {
+		t.Fatal("edit form should carry the link version")
+	}
+	form := url.Values{
+		"destination": {"https://first.com"},
+		"domain":      {"short.io"},
+		"slug":        {"shared"},
+		"version":     {m[1]},
+	}
+	if w := authPost(r, cookie, path, form); w.Code != http.StatusFound {
+		t.Fatalf("first save: status = %d, want %d", w.Code, http.StatusFound)
+	}
+
+	// The second editor's save is held back, keeping what they typed
+	form.Set("destination", "https://second.com")
+	w := authPost(r, cookie, path, form)
+	if w.Code != http.StatusOK {
+		t.Fatalf("stale save: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "Someone else changed this link") {
+		t.Error("stale save should explain the conflict")
+	}
+	if !strings.Contains(body, "https://second.com") {
+		t.Error("stale save should keep the typed destination")
+	}
+	current := &models.Link{ID: l.ID}
+	models.GetLinkByID(database, current)
+	if current.Destination != "https://first.com" {
+		t.Fatalf("destination = %q, want the first save kept", current.Destination)
+	}
+
+	// Saving the re-rendered form overwrites deliberately
+	m = regexp.MustCompile(`name="version" value="(\d+)"`).FindStringSubmatch(body)
+	if m == nil || m[1] != current.Version() {
+		t.Fatalf("re-rendered form version = %v, want %s", m, current.Version())
+	}
+	form.Set("version", m[1])
+	if w := authPost(r, cookie, path, form); w.Code != http.StatusFound {
+		t.Fatalf("second save: status = %d, want %d", w.Code, http.StatusFound)
+	}
+	models.GetLinkByID(database, current)
+	if current.Destination != "https://second.com" {
+		t.Errorf("destination = %q, want https://second.com", current.Destination)
+	}
+}
+
+// === Link Alias Tests ===
+
+func TestLinkAlias_AddAndRemove(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	l := &models.Link{Slug: "main", Domain: "short.io", Destination: "https://example.com"}
+	models.CreateLink(database, l)
+	editPath := fmt.Sprintf("/admin/links/%d/edit", l.ID)
+
+	w := authPost(r, cookie, fmt.Sprintf("/admin/links/%d/aliases", l.ID), url.Values{
+		"alias_domain": {"s.co"},
+		"alias_slug":   {"promo"},
+	})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != editPath {
+		t.Fatalf("add = %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	aliases, _ := models.ListLinkAliases(database, l.ID)
+	if len(aliases) != 1 || aliases[0].Domain != "s.co" || aliases[0].Slug != "promo" {
+		t.Fatalf("aliases = %+v", aliases)
+	}
+	if body := authGet(r, cookie, editPath).Body.String(); !strings.Contains(body, "s.co/promo") {
+		t.Error("edit page should list the alias")
+	}
+
+	w = authPost(r, cookie, fmt.Sprintf("/admin/links/%d/aliases/%d/delete", l.ID, aliases[0].ID), url.Values{})
+	if w.Code != http.StatusFound {
+		t.Fatalf("remove status = %d", w.Code)
+	}
+	if aliases, _ := models.ListLinkAliases(database, l.ID); len(aliases) != 0 {
+		t.Errorf("aliases after remove = %+v", aliases)
+	}
+}
+
+func TestLinkAlias_RejectsTakenSlug(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	l := &models.Link{Slug: "main", Domain: "short.io", Destination: "https://example.com"}
+	models.CreateLink(database, l)
+	models.CreateLink(database, &models.Link{Slug: "taken", Domain: "short.io", Destination: "https://example.com"})
+
+	w := authPost(r, cookie, fmt.Sprintf("/admin/links/%d/aliases", l.ID), url.Values{"alias_slug": {"taken"}})
+	if w.Code != http.StatusFound {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if aliases, _ := models.ListLinkAliases(database, l.ID); len(aliases) != 0 {
+		t.Errorf("aliases = %+v, want none", aliases)
+	}
+}
+
 // === Delete Link Tests ===
 
 func TestLinkDelete_Success(t *testing.T) {
@@ -508,6 +896,268 @@ func TestLinkAnalytics_NotFound(t *testing.T) {
 	}
 }
 
+// === Tag Tests ===
+
+func TestLinkList_TagSidebarAndFilter(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	models.CreateLink(database, &models.Link{Slug: "ai-link", Domain: "short.io", Destination: "https://example.com", Tags: "ai"})
+	models.CreateLink(database, &models.Link{Slug: "mail-link", Domain: "short.io", Destination: "https://example.com", Tags: "mail"})
+
+	body := authGet(r, cookie, "/admin").Body.String()
+	if !strings.Contains(body, `href="/admin?tag=ai"`) || !strings.Contains(body, `href="/admin?tag=mail"`) {
+		t.Error("link list should show a tag sidebar")
+	}
+
+	body = authGet(r, cookie, "/admin?tag=ai").Body.String()
+	if !strings.Contains(body, "short.io/ai-link") {
+		t.Error("tag filter should include links tagged ai")
+	}
+	if strings.Contains(body, "short.io/mail-link") {
+		t.Error("tag filter should exclude links tagged mail")
+	}
+	if !strings.Contains(body, `href="/admin/tags/ai"`) {
+		t.Error("filtered list should link to tag analytics")
+	}
+}
+
+func TestTagAnalytics_Renders(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	l := &models.Link{Slug: "launch", Domain: "short.io", Destination: "https://example.com", Tags: "product launch"}
+	models.CreateLink(database, l)
+	models.BatchInsertClicks(database, []models.Click{{LinkID: l.ID, ClickedAt: time.Now(), Country: "US"}})
+
+	w := authGet(r, cookie, "/admin/tags/product%20launch")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "short.io/launch") || !strings.Contains(body, "Top countries") {
+		t.Error("tag analytics should list the tag's links and breakdowns")
+	}
+
+	if w := authGet(r, cookie, "/admin/tags/unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown tag status = %d, want 404", w.Code)
+	}
+}
+
+// === Campaign Tests ===
+
+func TestCampaignCreate_AndAttachByShortURL(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	l := &models.Link{Slug: "launch", Domain: "short.io", Destination: "https://example.com"}
+	models.CreateLink(database, l)
+
+	w := authPost(r, cookie, "/admin/campaigns", url.Values{
+		"name":       {"Spring launch"},
+		"starts_on":  {"2026-03-01"},
+		"utm_source": {"newsletter"},
+	})
+	if w.Code != http.StatusFound {
+		t.Fatalf("create status = %d", w.Code)
+	}
+	campaigns, _ := models.ListCampaigns(database)
+	if len(campaigns) != 1 || campaigns[0].UTMSource != "newsletter" {
+		t.Fatalf("campaigns = %+v", campaigns)
+	}
+	path := fmt.Sprintf("/admin/campaigns/%d", campaigns[0].ID)
+	if loc := w.Header().Get("Location"); loc != path {
+		t.Errorf("Location = %q, want %q", loc, path)
+	}
+
+	w = authPost(r, cookie, path+"/links", url.Values{"link": {"https://short.io/launch"}})
+	if w.Code != http.StatusFound {
+		t.Fatalf("attach status = %d", w.Code)
+	}
+	got := &models.Link{ID: l.ID}
+	models.GetLinkByID(database, got)
+	if got.Destination != "https://example.com?utm_source=newsletter" {
+		t.Errorf("Destination = %q, want campaign UTM applied", got.Destination)
+	}
+
+	w = authGet(r, cookie, path)
+	if w.Code != http.StatusOK {
+		t.Fatalf("page status = %d", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "Spring launch") || !strings.Contains(body, "short.io/launch") || !strings.Contains(body, "Top referrers") {
+		t.Error("campaign page should show the campaign, its links and breakdowns")
+	}
+}
+
+func TestCampaignCreate_Invalid(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	w := authPost(r, cookie, "/admin/campaigns", url.Values{
+		"name":      {"Backwards"},
+		"starts_on": {"2026-03-31"},
+		"ends_on":   {"2026-03-01"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the form re-rendered", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Ends_on is before starts_on") {
+		t.Error("form should show the validation error")
+	}
+	if campaigns, _ := models.ListCampaigns(database); len(campaigns) != 0 {
+		t.Errorf("campaigns = %+v, want none", campaigns)
+	}
+}
+
+// === Export/Import Tests ===
+
+func authUpload(router *chi.Mux, cookie *http.Cookie, filename, content string, fields map[string]string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	fw, _ := mw.CreateFormFile("file", filename)
+	io.WriteString(fw, content)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/admin/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestExport_Downloads(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+	models.CreateLink(database, &models.Link{Slug: "abc", Domain: "short.io", Destination: "https://example.com"})
+
+	w := authGet(r, cookie, "/admin/export?format=ndjson")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, ".ndjson") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	if !strings.Contains(w.Body.String(), `"slug":"abc"`) {
+		t.Errorf("export = %q", w.Body.String())
+	}
+}
+
+func TestImport_Upload(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+	csv := "type,domain,slug,destination\nlink,short.io,imported,https://example.com/i\n"
+
+	w := authUpload(r, cookie, "links.csv", csv, map[string]string{"conflict": "skip", "dry_run": "1"})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Dry run") {
+		t.Fatalf("dry run: status = %d", w.Code)
+	}
+	if exists, _ := models.SlugExists(database, "imported", "short.io"); exists {
+		t.Fatal("dry run created the link")
+	}
+
+	w = authUpload(r, cookie, "links.csv", csv, map[string]string{"conflict": "skip"})
+	if !strings.Contains(w.Body.String(), "Import complete") {
+		t.Fatalf("import page missing summary: %s", w.Body.String())
+	}
+	if exists, _ := models.SlugExists(database, "imported", "short.io"); !exists {
+		t.Error("link was not imported")
+	}
+}
+
+func TestImport_RejectsUnknownExtension(t *testing.T) {
+	r, _ := setupRouter(t)
+	cookie := sessionCookie(t, r)
+	w := authUpload(r, cookie, "links.xml", "<links/>", nil)
+	if !strings.Contains(w.Body.String(), "Unknown format") {
+		t.Errorf("expected format error, got: %s", w.Body.String())
+	}
+}
+
+// === Duplicates Tests ===
+
+func TestDuplicatesPage(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	for _, l := range []*models.Link{
+		{Slug: "dup-a", Domain: "short.io", Destination: "https://example.com/page?b=2&a=1"},
+		{Slug: "dup-b", Domain: "short.io", Destination: "https://EXAMPLE.com/page?a=1&b=2"},
+		{Slug: "single", Domain: "short.io", Destination: "https://example.com/other"},
+	} {
+		models.CreateLink(database, l)
+	}
+
+	w := authGet(r, cookie, "/admin/duplicates")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "https://example.com/page?a=1&amp;b=2") {
+		t.Error("page should show the shared normalized destination")
+	}
+	if !strings.Contains(body, "/dup-a") || !strings.Contains(body, "/dup-b") {
+		t.Error("page should list both duplicates")
+	}
+	if strings.Contains(body, "/single") {
+		t.Error("page shouldn't list links without duplicates")
+	}
+}
+
+func TestDuplicatesPage_Empty(t *testing.T) {
+	r, _ := setupRouter(t)
+	cookie := sessionCookie(t, r)
+	if body := authGet(r, cookie, "/admin/duplicates").Body.String(); !strings.Contains(body, "No duplicate links.") {
+		t.Error("page should say there are no duplicates")
+	}
+}
+
+func TestDuplicatesMerge(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	keep := &models.Link{Slug: "keep", Domain: "short.io", Destination: "https://example.com"}
+	dup := &models.Link{Slug: "dup", Domain: "short.io", Destination: "https://example.com/"}
+	models.CreateLink(database, keep)
+	models.CreateLink(database, dup)
+	models.BatchInsertClicks(database, []models.Click{{LinkID: dup.ID, ClickedAt: time.Now()}})
+
+	form := url.Values{
+		"keep": {fmt.Sprint(keep.ID)},
+		"ids":  {fmt.Sprint(keep.ID), fmt.Sprint(dup.ID)},
+	}
+	w := authPost(r, cookie, "/admin/duplicates/merge", form)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/admin/duplicates" {
+		t.Fatalf("status = %d, location = %q", w.Code, w.Header().Get("Location"))
+	}
+	models.GetLinkByID(database, keep)
+	if keep.Clicks != 1 {
+		t.Errorf("kept link clicks = %d, want 1", keep.Clicks)
+	}
+	if l, err := models.GetLinkBySlugAndDomain(database, "dup", "short.io"); err != nil || l.ID != keep.ID {
+		t.Errorf("dup slug resolves to %+v, %v, want the kept link", l, err)
+	}
+
+	// Merging again finds the merged link gone and changes nothing
+	w = authPost(r, cookie, "/admin/duplicates/merge", form)
+	if w.Code != http.StatusFound {
+		t.Fatalf("stale merge: status = %d", w.Code)
+	}
+	var flash string
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "dubly_flash" {
+			flash = c.Value
+		}
+	}
+	if flash == "" {
+		t.Error("stale merge should explain why nothing was merged")
+	}
+}
+
 // === Logout Tests ===
 
 func TestLogout(t *testing.T) {
@@ -525,6 +1175,131 @@ func TestLogout(t *testing.T) {
 	}
 }
 
+// === Domains Tests ===
+
+func TestDomainsPage_ShowsStoredChecks(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	expiry := time.Now().Add(60 * 24 * time.Hour)
+	models.UpsertDomainCheck(database, &models.DomainCheck{
+		Domain:      "short.io",
+		IPs:         []string{"203.0.113.7"},
+		TLSIssuer:   "R3 (Let's Encrypt)",
+		TLSSANs:     []string{"short.io"},
+		TLSNotAfter: &expiry,
+		TLSValid:    true,
+		CheckedAt:   time.Now(),
+	})
+	models.UpsertDomainCheck(database, &models.DomainCheck{
+		Domain:    "s.co",
+		IPs:       []string{},
+		TLSSANs:   []string{},
+		TLSError:  "x509: certificate is valid for other.co, not s.co",
+		CheckedAt: time.Now(),
+	})
+
+	w := authGet(r, cookie, "/admin/domains")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"203.0.113.7", "TLS ok", "R3 (Let&#39;s Encrypt)", expiry.Format("2006-01-02"), "TLS error", "not s.co"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("domains page should contain %q", want)
+		}
+	}
+}
+
+func TestDomainsPage_ShowsExpiredCertificate(t *testing.T) {
+	r, database := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	expiry := time.Now().Add(-3 * 24 * time.Hour)
+	models.UpsertDomainCheck(database, &models.DomainCheck{
+		Domain:      "short.io",
+		IPs:         []string{},
+		TLSSANs:     []string{"short.io"},
+		TLSNotAfter: &expiry,
+		TLSError:    "x509: certificate has expired",
+		CheckedAt:   time.Now(),
+	})
+
+	body := authGet(r, cookie, "/admin/domains").Body.String()
+	if !strings.Contains(body, ">Expired<") || strings.Contains(body, "Expires in -") {
+		t.Error("domains page should mark the certificate expired, not expiring in negative days")
+	}
+}
+
+func TestDomainsRefresh_WithoutChecker(t *testing.T) {
+	r, _ := setupRouter(t)
+	cookie := sessionCookie(t, r)
+
+	w := authPost(r, cookie, "/admin/domains/refresh", url.Values{})
+	if w.Code != http.StatusFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+}
+
+func TestDomainsMove_PreviewThenMove(t *testing.T) {
+	r, database := setupRouterWithConfig(t, &config.Config{
+		Password:      testPassword,
+		Domains:       []string{"new.io"},
+		DomainAliases: map[string]string{"old.io": "new.io"},
+	})
+	cookie := sessionCookie(t, r)
+
+	link := &models.Link{Slug: "abc", Domain: "old.io", Destination: "https://example.com"}
+	if err := models.CreateLink(database, link); err != nil {
+		t.Fatal(err)
+	}
+
+	w := authGet(r, cookie, "/admin/domains")
+	body := w.Body.String()
+	if !strings.Contains(body, "old.io (1)") || !strings.Contains(body, "→ new.io") {
+		t.Error("domains page should list link domains and aliases")
+	}
+
+	form := url.Values{"from": {"old.io"}, "to": {"new.io"}, "action": {"preview"}}
+	w = authPost(r, cookie, "/admin/domains/move", form)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Move 1 links") {
+		t.Fatalf("preview: status = %d, want move button", w.Code)
+	}
+
+	form.Set("action", "move")
+	if w = authPost(r, cookie, "/admin/domains/move", form); w.Code != http.StatusFound {
+		t.Fatalf("move: status = %d, want %d", w.Code, http.StatusFound)
+	}
+	if _, err := models.GetLinkBySlugAndDomain(database, "abc", "new.io"); err != nil {
+		t.Errorf("link should be on new.io: %v", err)
+	}
+}
+
+func TestDomainsMove_CollisionsBlockMove(t *testing.T) {
+	r, database := setupRouterWithConfig(t, &config.Config{
+		Password: testPassword,
+		Domains:  []string{"old.io", "new.io"},
+	})
+	cookie := sessionCookie(t, r)
+
+	for _, domain := range []string{"old.io", "new.io"} {
+		l := &models.Link{Slug: "abc", Domain: domain, Destination: "https://example.com"}
+		if err := models.CreateLink(database, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	form := url.Values{"from": {"old.io"}, "to": {"new.io"}, "action": {"move"}}
+	w := authPost(r, cookie, "/admin/domains/move", form)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "Nothing was moved") || !strings.Contains(body, "already taken") {
+		t.Errorf("status = %d, want collision report", w.Code)
+	}
+	if _, err := models.GetLinkBySlugAndDomain(database, "abc", "old.io"); err != nil {
+		t.Errorf("colliding link should stay on old.io: %v", err)
+	}
+}
+
 // === Static Files Tests ===
 
 func TestStaticCSS(t *testing.T) {
@@ -554,3 +1329,14 @@ func TestStaticHTMX(t *testing.T) {
 		t.Error("HTMX JS should be substantial in size")
 	}
 }
+
+func TestStaticSubdomainField(t *testing.T) {
+	r, _ := setupRouter(t)
+	req := httptest.NewRequest("GET", "/admin/static/js/subdomain-field.js", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "subdomain-field") {
+		t.Errorf("status = %d, want the subdomain field script", w.Code)
+	}
+}
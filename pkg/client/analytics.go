@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatCount is one row of a click breakdown.
+type StatCount struct {
+	Value  string `json:"value"`
+	Clicks int    `json:"clicks"`
+}
+
+// SlugStat counts the clicks through one of a link's slugs.
+type SlugStat struct {
+	AliasID int64  `json:"alias_id"` // 0 for the link's own slug
+	Slug    string `json:"slug"`
+	Domain  string `json:"domain"`
+	Clicks  int    `json:"clicks"`
+}
+
+// HistoricalClicks is a click total carried over from another shortener.
+type HistoricalClicks struct {
+	Source     string    `json:"source"`
+	Clicks     int       `json:"clicks"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+// LinkStats are a link's click totals and its top referrers, countries,
+// browsers and devices.
+type LinkStats struct {
+	LinkID         int64       `json:"link_id"`
+	Clicks         int         `json:"clicks"`
+	ClicksToday    int         `json:"clicks_today"`
+	ClicksThisWeek int         `json:"clicks_this_week"` // the last 7 days
+	ClicksPrevWeek int         `json:"clicks_prev_week"` // the 7 days before
+	Referrers      []StatCount `json:"referrers"`
+	Countries      []StatCount `json:"countries"`
+	Browsers       []StatCount `json:"browsers"`
+	Devices        []StatCount `json:"devices"`
+	Slugs          []SlugStat  `json:"slugs"` // own slug first, then aliases
+	// HistoricalClicks is nil unless the link was imported with a total.
+	HistoricalClicks *HistoricalClicks `json:"historical_clicks"`
+}
+
+// LinkStats returns the stats of the link with the given ID. limit caps
+// each breakdown; 0 leaves the server's default of 10.
+func (c *Client) LinkStats(ctx context.Context, linkID int64, limit int) (*LinkStats, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var stats LinkStats
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: linkPath(linkID) + "/stats", query: query}, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// AnalyticsQuery selects and groups clicks for Analytics. Zero values are
+// left out, so the server's defaults apply: the 30 days up to now, in UTC,
+// ungrouped, 100 rows.
+type AnalyticsQuery struct {
+	From, To time.Time
+	Timezone string   // IANA name, e.g. "Europe/Berlin"
+	Interval string   // hour, day, week or month
+	GroupBy  []string // up to 3 of link, tag, domain, country, region, city, referrer, browser, os, device
+	// Filters keeps clicks whose value for each dimension is one of the
+	// listed values, e.g. {"country": {"US", "CA"}, "tag": {"launch"}}.
+	Filters  map[string][]string
+	Campaign int64
+	Limit    int // up to 1000
+}
+
+func (q *AnalyticsQuery) values() url.Values {
+	v := url.Values{}
+	if !q.From.IsZero() {
+		v.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		v.Set("to", q.To.Format(time.RFC3339))
+	}
+	if q.Timezone != "" {
+		v.Set("tz", q.Timezone)
+	}
+	if q.Interval != "" {
+		v.Set("interval", q.Interval)
+	}
+	if len(q.GroupBy) > 0 {
+		v.Set("group_by", strings.Join(q.GroupBy, ","))
+	}
+	for dim, values := range q.Filters {
+		for _, value := range values {
+			v.Add(dim, value)
+		}
+	}
+	if q.Campaign != 0 {
+		v.Set("campaign", strconv.FormatInt(q.Campaign, 10))
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return v
+}
+
+// ClickBucket is the clicks in one interval of a series.
+type ClickBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int       `json:"clicks"`
+}
+
+// AnalyticsRow is the clicks on one combination of grouped values.
+type AnalyticsRow struct {
+	Values map[string]string `json:"values"` // keyed by dimension; links by ID
+	Clicks int               `json:"clicks"`
+	Series []ClickBucket     `json:"series"` // with an interval
+}
+
+// AnalyticsResult is the answer to an AnalyticsQuery, most clicked rows
+// first.
+type AnalyticsResult struct {
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	Timezone string         `json:"timezone"`
+	Interval string         `json:"interval"`
+	GroupBy  []string       `json:"group_by"`
+	Clicks   int            `json:"clicks"` // every click in range matching the filters
+	Rows     []AnalyticsRow `json:"rows"`
+}
+
+// Analytics counts clicks as q asks.
+func (c *Client) Analytics(ctx context.Context, q *AnalyticsQuery) (*AnalyticsResult, error) {
+	if q == nil {
+		q = &AnalyticsQuery{}
+	}
+	var res AnalyticsResult
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: "/analytics", query: q.values()}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,234 @@
+// Package client is a Go client for the Dubly API.
+//
+//	c := client.New("https://dubly.example.com", os.Getenv("DUBLY_API_KEY"))
+//	link, err := c.CreateLink(ctx, &client.LinkInput{
+//		Domain:      "short.io",
+//		Destination: "https://example.com/launch",
+//	})
+//
+// Requests that fail with a 5xx status or a network error are retried with
+// exponential backoff when retrying is safe: every request but a POST,
+// and POST /api/links, which the client sends with an Idempotency-Key.
+// Error responses come back as *APIError, which errors.Is matches against
+// ErrNotFound, ErrConflict and the other sentinels by status code.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 250 * time.Millisecond
+)
+
+// Client calls the API of one Dubly instance. Its fields may be changed
+// before the first request.
+type Client struct {
+	BaseURL string // e.g. https://dubly.example.com, without /api
+	APIKey  string
+	// HTTPClient sends the requests; http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// MaxRetries is how many times a failed request is retried.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubling after each.
+	RetryBackoff time.Duration
+}
+
+// New returns a client for the instance at baseURL that retries failed
+// requests three times.
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		APIKey:       apiKey,
+		MaxRetries:   defaultMaxRetries,
+		RetryBackoff: defaultRetryBackoff,
+	}
+}
+
+// Sentinels for errors.Is against an *APIError.
+var (
+	ErrBadRequest         = errors.New("bad request")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrServer             = errors.New("server error")
+)
+
+// APIError is an error response from the API.
+type APIError struct {
+	StatusCode int
+	Message    string // the response's error field, or its status text
+	// ETag is the link's current version on a 412 from a conditional
+	// update or delete.
+	ETag string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("dubly: %d: %s", e.StatusCode, e.Message)
+}
+
+// Is matches the sentinel for e's status code.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// request is one API call.
+type request struct {
+	method string
+	path   string // under /api
+	query  url.Values
+	header http.Header
+	body   any // encoded as JSON when set
+	// ok lists the statuses whose body decodes into the response value,
+	// besides 2xx ones. Bulk requests report a failed batch with 422.
+	ok []int
+}
+
+// do sends req and decodes the response body into out when out is
+// non-nil. It returns the response so callers can read headers and the
+// status.
+func (c *Client) do(ctx context.Context, req *request, out any) (*http.Response, error) {
+	resp, err := c.roundTrip(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp, decode(resp, req.ok, out)
+}
+
+// roundTrip sends req, retrying as the package documentation describes,
+// and returns the last response with its body unread.
+func (c *Client) roundTrip(ctx context.Context, req *request) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("dubly: encode request: %w", err)
+		}
+	}
+	u := c.BaseURL + "/api" + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	retry := req.method != http.MethodPost || req.header.Get("Idempotency-Key") != ""
+
+	backoff := c.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req, u, body)
+		failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		if !failed || !retry || attempt >= c.MaxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, req *request, u string, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, r)
+	if err != nil {
+		return nil, fmt.Errorf("dubly: %w", err)
+	}
+	for k, v := range req.header {
+		httpReq.Header[k] = v
+	}
+	httpReq.Header.Set("X-API-Key", c.APIKey)
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("dubly: %s %s: %w", req.method, req.path, err)
+	}
+	return resp, nil
+}
+
+// decode reads resp into out, or into an *APIError for error statuses not
+// listed in ok.
+func decode(resp *http.Response, ok []int, out any) error {
+	defer resp.Body.Close()
+
+	success := resp.StatusCode < http.StatusMultipleChoices
+	for _, s := range ok {
+		success = success || resp.StatusCode == s
+	}
+	if !success {
+		apiErr := &APIError{StatusCode: resp.StatusCode, ETag: resp.Header.Get("ETag")}
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e) == nil && e.Error != "" {
+			apiErr.Message = e.Error
+		} else {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("dubly: decode response: %w", err)
+	}
+	return nil
+}
+
+// newIdempotencyKey returns a random key, so that retries of one call are
+// recognised by the server while separate calls never collide.
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// String returns a pointer to s, for the optional fields of LinkUpdate.
+func String(s string) *string {
+	return &s
+}
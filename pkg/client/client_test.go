@@ -0,0 +1,304 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/scmmishra/dubly/internal/cache"
+	"github.com/scmmishra/dubly/internal/config"
+	"github.com/scmmishra/dubly/internal/db"
+	"github.com/scmmishra/dubly/internal/server"
+	"github.com/scmmishra/dubly/pkg/client"
+)
+
+const testKey = "secret"
+
+// newServer serves the API from an empty database.
+func newServer(t *testing.T) *client.Client {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkCache, err := cache.New(100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Password: testKey, Domains: []string{"short.io"}, IdempotencyWindow: time.Hour}
+	r := chi.NewRouter()
+	r.Mount("/api", server.APIRouter(database, cfg, linkCache))
+	srv := httptest.NewServer(r)
+	t.Cleanup(func() {
+		srv.Close()
+		database.Close()
+	})
+
+	c := client.New(srv.URL, testKey)
+	c.RetryBackoff = time.Millisecond
+	return c
+}
+
+func TestLinks_RoundTrip(t *testing.T) {
+	c := newServer(t)
+	ctx := context.Background()
+
+	link, err := c.CreateLink(ctx, &client.LinkInput{
+		Slug: "launch", Domain: "short.io", Destination: "https://example.com/launch",
+		Tags: []string{"b", "a"}, UTMSource: client.String("news"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.ShortURL == "" || link.Destination != "https://example.com/launch?utm_source=news" || link.ETag == "" {
+		t.Errorf("created = %+v", link)
+	}
+	if tags := link.TagList(); len(tags) != 2 || tags[0] != "b" || tags[1] != "a" {
+		t.Errorf("tags = %q", tags)
+	}
+
+	got, err := c.GetLink(ctx, link.ID)
+	if err != nil || got.Slug != "launch" || got.ETag != link.ETag {
+		t.Fatalf("get = %+v, %v", got, err)
+	}
+
+	updated, err := c.UpdateLink(ctx, link.ID, &client.LinkUpdate{Title: client.String("Launch"), IfMatch: got.ETag})
+	if err != nil || updated.Title != "Launch" {
+		t.Fatalf("update = %+v, %v", updated, err)
+	}
+	_, err = c.UpdateLink(ctx, link.ID, &client.LinkUpdate{Title: client.String("Stale"), IfMatch: got.ETag})
+	var apiErr *client.APIError
+	if !errors.Is(err, client.ErrPreconditionFailed) || !errors.As(err, &apiErr) || apiErr.ETag != updated.ETag {
+		t.Errorf("stale update: err = %v, want 412 with the current ETag", err)
+	}
+
+	again, created, err := c.GetOrCreateLink(ctx, &client.LinkInput{Domain: "short.io", Destination: "https://EXAMPLE.com/launch?utm_source=news"})
+	if err != nil || created || again.ID != link.ID {
+		t.Errorf("get or create = %+v, created %v, %v; want the existing link", again, created, err)
+	}
+
+	if err := c.DeleteLink(ctx, link.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.GetLink(ctx, link.ID); err != nil || got.IsActive {
+		t.Errorf("after delete = %+v, %v", got, err)
+	}
+	if _, err := c.GetLink(ctx, 99999); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("unknown link: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestEachLink_FollowsCursors(t *testing.T) {
+	c := newServer(t)
+	ctx := context.Background()
+	for i := range 7 {
+		in := &client.LinkInput{Domain: "short.io", Destination: fmt.Sprintf("https://example.com/%d", i)}
+		if _, err := c.CreateLink(ctx, in); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := map[int64]bool{}
+	err := c.EachLink(ctx, &client.ListOptions{Limit: 3, Sort: "created", Order: "asc"}, func(l *client.Link) error {
+		seen[l.ID] = true
+		return nil
+	})
+	if err != nil || len(seen) != 7 {
+		t.Errorf("saw %d links, err %v; want all 7", len(seen), err)
+	}
+
+	stop := errors.New("stop")
+	if err := c.EachLink(ctx, nil, func(*client.Link) error { return stop }); err != stop {
+		t.Errorf("err = %v, want fn's error", err)
+	}
+}
+
+func TestBulkCreate_ReportsFailedItems(t *testing.T) {
+	c := newServer(t)
+	resp, err := c.BulkCreateLinks(context.Background(), []client.LinkInput{
+		{Domain: "short.io", Destination: "https://example.com/a"},
+		{Domain: "evil.com", Destination: "https://example.com/b"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Failed != 1 || resp.Succeeded != 0 || len(resp.Results) != 2 {
+		t.Errorf("resp = %+v, want the atomic batch rejected", resp)
+	}
+}
+
+func TestLinkStats(t *testing.T) {
+	c := newServer(t)
+	ctx := context.Background()
+	link, err := c.CreateLink(ctx, &client.LinkInput{Slug: "a", Domain: "short.io", Destination: "https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateAlias(ctx, link.ID, "b", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := c.LinkStats(ctx, link.ID, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.LinkID != link.ID || stats.Clicks != 0 || stats.Referrers == nil || stats.HistoricalClicks != nil {
+		t.Errorf("stats = %+v", stats)
+	}
+	if _, err := c.LinkStats(ctx, 99999, 0); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("unknown link: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAnalytics(t *testing.T) {
+	c := newServer(t)
+	ctx := context.Background()
+
+	res, err := c.Analytics(ctx, &client.AnalyticsQuery{
+		Timezone: "UTC",
+		Interval: "day",
+		GroupBy:  []string{"country"},
+		Filters:  map[string][]string{"device": {"mobile"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Timezone != "UTC" || res.Interval != "day" || len(res.GroupBy) != 1 || res.Clicks != 0 || res.Rows == nil {
+		t.Errorf("result = %+v", res)
+	}
+	var apiErr *client.APIError
+	if _, err := c.Analytics(ctx, &client.AnalyticsQuery{GroupBy: []string{"planet"}}); !errors.As(err, &apiErr) || apiErr.StatusCode != 400 {
+		t.Errorf("unknown dimension: err = %v, want a 400", err)
+	}
+}
+
+func TestErrors_Typed(t *testing.T) {
+	c := newServer(t)
+	ctx := context.Background()
+
+	c.APIKey = "wrong"
+	_, err := c.ListLinks(ctx, nil)
+	if !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("wrong key: err = %v, want ErrUnauthorized", err)
+	}
+	c.APIKey = testKey
+
+	in := &client.LinkInput{Slug: "taken", Domain: "short.io", Destination: "https://example.com"}
+	c.CreateLink(ctx, in)
+	_, err = c.CreateLink(ctx, in)
+	var apiErr *client.APIError
+	if !errors.Is(err, client.ErrConflict) || !errors.As(err, &apiErr) || apiErr.Message != "slug already exists for this domain" {
+		t.Errorf("taken slug: err = %v, want ErrConflict with the server's message", err)
+	}
+
+	if check, err := c.CheckSlug(ctx, "short.io", "taken"); err != nil || check.Available || check.Reason != "taken" {
+		t.Errorf("check = %+v, %v", check, err)
+	}
+	if _, err := c.CheckSlug(ctx, "", "x"); !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("check without domain: err = %v, want ErrBadRequest", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	c := newServer(t)
+	ctx := context.Background()
+	if _, err := c.CreateLink(ctx, &client.LinkInput{Slug: "a", Domain: "short.io", Destination: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := c.Export(ctx, "ndjson", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	body, _ := io.ReadAll(rc)
+	if !strings.Contains(string(body), `"slug":"a"`) {
+		t.Errorf("export = %s", body)
+	}
+
+	if _, err := c.Export(ctx, "xml", false); !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("unknown format: err = %v, want ErrBadRequest", err)
+	}
+}
+
+// flaky answers the first failures requests with 503, then 200.
+func flaky(t *testing.T, failures int32) (*client.Client, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"try later"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1,"slug":"a","links":[],"results":[]}`))
+	}))
+	t.Cleanup(srv.Close)
+	c := client.New(srv.URL, testKey)
+	c.RetryBackoff = time.Millisecond
+	return c, &calls
+}
+
+func TestRetries_ServerErrors(t *testing.T) {
+	c, calls := flaky(t, 2)
+	link, err := c.GetLink(context.Background(), 1)
+	if err != nil || link.Slug != "a" || calls.Load() != 3 {
+		t.Errorf("got %+v, %v after %d calls; want success on the third", link, err, calls.Load())
+	}
+
+	c, calls = flaky(t, 10)
+	_, err = c.GetLink(context.Background(), 1)
+	var apiErr *client.APIError
+	if !errors.Is(err, client.ErrServer) || !errors.As(err, &apiErr) || apiErr.Message != "try later" {
+		t.Errorf("err = %v, want the last server error", err)
+	}
+	if calls.Load() != 4 {
+		t.Errorf("calls = %d, want 1 plus 3 retries", calls.Load())
+	}
+}
+
+func TestRetries_OnlyWhenSafe(t *testing.T) {
+	// Creating a link is retried: it carries an Idempotency-Key
+	c, calls := flaky(t, 1)
+	if _, err := c.CreateLink(context.Background(), &client.LinkInput{Domain: "short.io", Destination: "https://example.com"}); err != nil {
+		t.Errorf("create: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("create: calls = %d, want 2", calls.Load())
+	}
+
+	// Other POSTs aren't
+	c, calls = flaky(t, 1)
+	if _, err := c.BulkCreateLinks(context.Background(), nil, true); !errors.Is(err, client.ErrServer) {
+		t.Errorf("bulk create: err = %v, want ErrServer", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("bulk create: calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestRetries_StopWithContext(t *testing.T) {
+	c, _ := flaky(t, 10)
+	c.RetryBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.GetLink(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("retry backoff ignored the context")
+	}
+}
@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Export streams every link, and with includeClicks every click, in format
+// "csv" or "ndjson"; an empty format means csv. The caller must close the
+// returned reader.
+func (c *Client) Export(ctx context.Context, format string, includeClicks bool) (io.ReadCloser, error) {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	if includeClicks {
+		query.Set("include", "clicks")
+	}
+	resp, err := c.roundTrip(ctx, &request{method: http.MethodGet, path: "/export", query: query})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, decode(resp, nil, nil)
+	}
+	return resp.Body, nil
+}
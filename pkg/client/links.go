@@ -0,0 +1,403 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Link is a short link as the API returns it.
+type Link struct {
+	ID          int64  `json:"id"`
+	Slug        string `json:"slug"`
+	Domain      string `json:"domain"`
+	ShortURL    string `json:"short_url"`
+	Destination string `json:"destination"`
+
+	UTMSource   string `json:"utm_source"`
+	UTMMedium   string `json:"utm_medium"`
+	UTMCampaign string `json:"utm_campaign"`
+	UTMTerm     string `json:"utm_term"`
+	UTMContent  string `json:"utm_content"`
+
+	Title         string     `json:"title"`
+	Tags          string     `json:"tags"` // comma-separated
+	Notes         string     `json:"notes"`
+	IsActive      bool       `json:"is_active"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Clicks        int        `json:"clicks"`
+	LastClickedAt *time.Time `json:"last_clicked_at"`
+
+	// ETag is the link's version when it was read, for LinkUpdate.IfMatch.
+	// Links from listings don't carry one.
+	ETag string `json:"-"`
+}
+
+// TagList returns the link's tags.
+func (l *Link) TagList() []string {
+	var tags []string
+	for _, t := range strings.Split(l.Tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// LinkInput creates a link. Slug is generated when empty. A set UTM field
+// wins over the preset named by UTMPreset; an empty one removes the
+// parameter from the destination.
+type LinkInput struct {
+	Slug        string   `json:"slug,omitempty"`
+	Domain      string   `json:"domain"`
+	Destination string   `json:"destination"`
+	Title       string   `json:"title,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+
+	UTMPreset   string  `json:"utm_preset,omitempty"`
+	UTMSource   *string `json:"utm_source,omitempty"`
+	UTMMedium   *string `json:"utm_medium,omitempty"`
+	UTMCampaign *string `json:"utm_campaign,omitempty"`
+	UTMTerm     *string `json:"utm_term,omitempty"`
+	UTMContent  *string `json:"utm_content,omitempty"`
+}
+
+// LinkUpdate changes the fields that are set and leaves the rest alone.
+// Tags replaces every tag; point it at an empty slice to clear them.
+type LinkUpdate struct {
+	Slug        string    `json:"slug,omitempty"`
+	Domain      string    `json:"domain,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	Title       *string   `json:"title,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+	Notes       *string   `json:"notes,omitempty"`
+
+	UTMPreset   string  `json:"utm_preset,omitempty"`
+	UTMSource   *string `json:"utm_source,omitempty"`
+	UTMMedium   *string `json:"utm_medium,omitempty"`
+	UTMCampaign *string `json:"utm_campaign,omitempty"`
+	UTMTerm     *string `json:"utm_term,omitempty"`
+	UTMContent  *string `json:"utm_content,omitempty"`
+
+	// IfMatch, when set, makes the update fail with ErrPreconditionFailed
+	// if the link has changed since the read that returned this ETag.
+	IfMatch string `json:"-"`
+}
+
+// ListOptions filters and pages ListLinks. Zero values are left out.
+type ListOptions struct {
+	Limit  int    // 1 to 100; the server defaults to 25
+	Offset int    // ignored with a cursor
+	Cursor string // NextCursor or PrevCursor of an earlier page
+
+	Search string   // matches slug, destination, title and notes
+	Query  string   // the search language, e.g. "tag:launch clicks:>10"
+	Tags   []string // links with every one of these tags
+	Domain string
+	Status string // active, inactive or all
+	Sort   string // created, updated, title, clicks, last_clicked or relevance
+	Order  string // asc or desc
+
+	CreatedAfter      time.Time
+	CreatedBefore     time.Time
+	LastClickedAfter  time.Time
+	LastClickedBefore time.Time
+}
+
+func (o *ListOptions) values() url.Values {
+	v := url.Values{}
+	if o == nil {
+		return v
+	}
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	if o.Limit > 0 {
+		set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		set("offset", strconv.Itoa(o.Offset))
+	}
+	set("cursor", o.Cursor)
+	set("search", o.Search)
+	set("q", o.Query)
+	for _, t := range o.Tags {
+		v.Add("tag", t)
+	}
+	set("domain", o.Domain)
+	set("status", o.Status)
+	set("sort", o.Sort)
+	set("order", o.Order)
+	for _, b := range []struct {
+		key string
+		t   time.Time
+	}{
+		{"created_after", o.CreatedAfter},
+		{"created_before", o.CreatedBefore},
+		{"last_clicked_after", o.LastClickedAfter},
+		{"last_clicked_before", o.LastClickedBefore},
+	} {
+		if !b.t.IsZero() {
+			set(b.key, b.t.UTC().Format(time.RFC3339))
+		}
+	}
+	return v
+}
+
+// LinkList is one page of ListLinks.
+type LinkList struct {
+	Links      []Link `json:"links"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor"`
+	PrevCursor string `json:"prev_cursor"`
+}
+
+// CreateLink creates a link. Retries are safe: the request carries an
+// Idempotency-Key, so the link is created once.
+func (c *Client) CreateLink(ctx context.Context, in *LinkInput) (*Link, error) {
+	var l Link
+	resp, err := c.do(ctx, &request{
+		method: http.MethodPost,
+		path:   "/links",
+		header: http.Header{"Idempotency-Key": {newIdempotencyKey()}},
+		body:   in,
+	}, &l)
+	if err != nil {
+		return nil, err
+	}
+	l.ETag = resp.Header.Get("ETag")
+	return &l, nil
+}
+
+// GetOrCreateLink returns the active link on in.Domain whose destination
+// matches in.Destination, once in's UTM fields are applied, creating it
+// when there is none. created reports which happened.
+func (c *Client) GetOrCreateLink(ctx context.Context, in *LinkInput) (link *Link, created bool, err error) {
+	var l Link
+	resp, err := c.do(ctx, &request{method: http.MethodPut, path: "/links/by-destination", body: in}, &l)
+	if err != nil {
+		return nil, false, err
+	}
+	l.ETag = resp.Header.Get("ETag")
+	return &l, resp.StatusCode == http.StatusCreated, nil
+}
+
+// GetLink returns the link with the given ID, or ErrNotFound.
+func (c *Client) GetLink(ctx context.Context, id int64) (*Link, error) {
+	var l Link
+	resp, err := c.do(ctx, &request{method: http.MethodGet, path: linkPath(id)}, &l)
+	if err != nil {
+		return nil, err
+	}
+	l.ETag = resp.Header.Get("ETag")
+	return &l, nil
+}
+
+// ListLinks returns one page of links.
+func (c *Client) ListLinks(ctx context.Context, opts *ListOptions) (*LinkList, error) {
+	var list LinkList
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: "/links", query: opts.values()}, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// EachLink calls fn with every link matching opts, following cursors from
+// page to page. It stops at the first error, from the API or from fn.
+// opts.Cursor and opts.Offset are ignored.
+func (c *Client) EachLink(ctx context.Context, opts *ListOptions, fn func(*Link) error) error {
+	page := ListOptions{}
+	if opts != nil {
+		page = *opts
+	}
+	page.Offset, page.Cursor = 0, ""
+	for {
+		list, err := c.ListLinks(ctx, &page)
+		if err != nil {
+			return err
+		}
+		for i := range list.Links {
+			if err := fn(&list.Links[i]); err != nil {
+				return err
+			}
+		}
+		if list.NextCursor == "" || len(list.Links) == 0 {
+			return nil
+		}
+		page.Cursor = list.NextCursor
+	}
+}
+
+// UpdateLink applies upd to the link with the given ID.
+func (c *Client) UpdateLink(ctx context.Context, id int64, upd *LinkUpdate) (*Link, error) {
+	req := &request{method: http.MethodPatch, path: linkPath(id), body: upd}
+	if upd.IfMatch != "" {
+		req.header = http.Header{"If-Match": {upd.IfMatch}}
+	}
+	var l Link
+	resp, err := c.do(ctx, req, &l)
+	if err != nil {
+		return nil, err
+	}
+	l.ETag = resp.Header.Get("ETag")
+	return &l, nil
+}
+
+// DeleteLink deactivates the link with the given ID. Its clicks are kept.
+func (c *Client) DeleteLink(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, &request{method: http.MethodDelete, path: linkPath(id)}, nil)
+	return err
+}
+
+// BulkResult is the outcome of one item of a bulk request.
+type BulkResult struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	Link   *Link  `json:"link"`
+	Error  string `json:"error"`
+}
+
+// BulkResponse reports every item of a bulk request. An atomic batch with
+// a failed item writes nothing.
+type BulkResponse struct {
+	Atomic    bool         `json:"atomic"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
+
+// BulkUpdateItem is one link's change in BulkUpdateLinks.
+type BulkUpdateItem struct {
+	ID int64 `json:"id"`
+	LinkUpdate
+}
+
+// BulkCreateLinks creates up to 500 links. Items that fail are reported in
+// the response, not as an error. Bulk creates aren't retried.
+func (c *Client) BulkCreateLinks(ctx context.Context, links []LinkInput, atomic bool) (*BulkResponse, error) {
+	body := struct {
+		Atomic bool        `json:"atomic"`
+		Links  []LinkInput `json:"links"`
+	}{atomic, links}
+	var resp BulkResponse
+	if _, err := c.do(ctx, &request{method: http.MethodPost, path: "/links/bulk", body: body, ok: []int{http.StatusUnprocessableEntity}}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// BulkUpdateLinks updates up to 500 links; see BulkCreateLinks. IfMatch is
+// not supported on bulk updates.
+func (c *Client) BulkUpdateLinks(ctx context.Context, items []BulkUpdateItem, atomic bool) (*BulkResponse, error) {
+	body := struct {
+		Atomic bool             `json:"atomic"`
+		Links  []BulkUpdateItem `json:"links"`
+	}{atomic, items}
+	var resp BulkResponse
+	if _, err := c.do(ctx, &request{method: http.MethodPatch, path: "/links/bulk", body: body, ok: []int{http.StatusUnprocessableEntity}}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Alias is another slug that redirects to a link.
+type Alias struct {
+	ID        int64     `json:"id"`
+	LinkID    int64     `json:"link_id"`
+	Slug      string    `json:"slug"`
+	Domain    string    `json:"domain"`
+	ShortURL  string    `json:"short_url"`
+	Clicks    int       `json:"clicks"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AliasList is a link's aliases with their click counts.
+type AliasList struct {
+	Aliases []Alias `json:"aliases"`
+	// PrimaryClicks counts clicks through the link's own slug.
+	PrimaryClicks int `json:"primary_clicks"`
+}
+
+// ListAliases returns the aliases of the link with the given ID.
+func (c *Client) ListAliases(ctx context.Context, linkID int64) (*AliasList, error) {
+	var list AliasList
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: linkPath(linkID) + "/aliases"}, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// CreateAlias adds slug as an alias of the link. An empty domain means the
+// link's own.
+func (c *Client) CreateAlias(ctx context.Context, linkID int64, slug, domain string) (*Alias, error) {
+	body := struct {
+		Slug   string `json:"slug"`
+		Domain string `json:"domain,omitempty"`
+	}{slug, domain}
+	var a Alias
+	if _, err := c.do(ctx, &request{method: http.MethodPost, path: linkPath(linkID) + "/aliases", body: body}, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// DeleteAlias removes an alias. Clicks made through it are kept.
+func (c *Client) DeleteAlias(ctx context.Context, linkID, aliasID int64) error {
+	path := fmt.Sprintf("%s/aliases/%d", linkPath(linkID), aliasID)
+	_, err := c.do(ctx, &request{method: http.MethodDelete, path: path}, nil)
+	return err
+}
+
+// SlugCheck says whether a slug can be used on a domain.
+type SlugCheck struct {
+	Domain    string `json:"domain"`
+	Slug      string `json:"slug"` // as the domain's settings normalize it
+	Available bool   `json:"available"`
+	// Reason is "invalid", "reserved" or "taken" for unavailable slugs.
+	Reason      string   `json:"reason"`
+	Error       string   `json:"error"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// CheckSlug reports whether slug is free on domain, with suggestions when
+// it's taken or reserved.
+func (c *Client) CheckSlug(ctx context.Context, domain, slug string) (*SlugCheck, error) {
+	var check SlugCheck
+	query := url.Values{"domain": {domain}, "slug": {slug}}
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: "/slugs/check", query: query}, &check); err != nil {
+		return nil, err
+	}
+	return &check, nil
+}
+
+// Tag is a tag in use, with its link and click counts.
+type Tag struct {
+	Name   string `json:"name"`
+	Links  int    `json:"links"`
+	Clicks int    `json:"clicks"`
+}
+
+// ListTags returns every tag in use.
+func (c *Client) ListTags(ctx context.Context) ([]Tag, error) {
+	var resp struct {
+		Tags []Tag `json:"tags"`
+	}
+	if _, err := c.do(ctx, &request{method: http.MethodGet, path: "/tags"}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tags, nil
+}
+
+func linkPath(id int64) string {
+	return "/links/" + strconv.FormatInt(id, 10)
+}